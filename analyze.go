@@ -0,0 +1,42 @@
+package main
+
+import (
+	"ai-api/analyzers"
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// runAnalyze runs go/analysis analyzers on a checkout and prints the
+// diagnostics as JSON. The server starts it through analyzers.Sandbox so
+// untrusted code is loaded in an isolated process.
+func runAnalyze(args []string) int {
+	flags := flag.NewFlagSet("analyze", flag.ContinueOnError)
+	dir := flags.String("dir", ".", "repository checkout to analyze")
+	names := flags.String("analyzers", analyzers.VetGroup, "comma separated analyzers to run")
+	if err := flags.Parse(args); err != nil {
+		return exitError
+	}
+
+	selected, err := analyzers.Lookup(strings.Split(*names, ","))
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return exitError
+	}
+	diagnostics, err := analyzers.Run(context.Background(), *dir, selected, flags.Args())
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return exitError
+	}
+	if diagnostics == nil {
+		diagnostics = []analyzers.Diagnostic{}
+	}
+	if err := json.NewEncoder(os.Stdout).Encode(diagnostics); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return exitError
+	}
+	return exitOK
+}
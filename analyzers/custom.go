@@ -0,0 +1,83 @@
+package analyzers
+
+import (
+	"go/ast"
+	"go/constant"
+	"go/types"
+	"strings"
+
+	"golang.org/x/tools/go/analysis"
+	"golang.org/x/tools/go/analysis/passes/inspect"
+	"golang.org/x/tools/go/ast/inspector"
+	"golang.org/x/tools/go/types/typeutil"
+)
+
+// CtxFirst reports functions taking a context.Context anywhere but first.
+var CtxFirst = &analysis.Analyzer{
+	Name:     "ctxfirst",
+	Doc:      "check that context.Context is the first parameter of functions that take one",
+	Requires: []*analysis.Analyzer{inspect.Analyzer},
+	Run:      runCtxFirst,
+}
+
+func runCtxFirst(pass *analysis.Pass) (interface{}, error) {
+	inspect := pass.ResultOf[inspect.Analyzer].(*inspector.Inspector)
+	inspect.Preorder([]ast.Node{(*ast.FuncType)(nil)}, func(node ast.Node) {
+		params := node.(*ast.FuncType).Params
+		if params == nil {
+			return
+		}
+		index := 0
+		for _, field := range params.List {
+			if index > 0 && isContext(pass.TypesInfo.TypeOf(field.Type)) {
+				pass.Reportf(field.Pos(), "context.Context should be the first parameter")
+				return
+			}
+			index += max(len(field.Names), 1)
+		}
+	})
+	return nil, nil
+}
+
+// isContext reports whether t is context.Context.
+func isContext(t types.Type) bool {
+	named, ok := t.(*types.Named)
+	if !ok {
+		return false
+	}
+	obj := named.Obj()
+	return obj.Pkg() != nil && obj.Pkg().Path() == "context" && obj.Name() == "Context"
+}
+
+// ErrWrap reports fmt.Errorf calls that format an error without %w, which
+// hides it from errors.Is and errors.As.
+var ErrWrap = &analysis.Analyzer{
+	Name:     "errwrap",
+	Doc:      "check that fmt.Errorf wraps error arguments with %w",
+	Requires: []*analysis.Analyzer{inspect.Analyzer},
+	Run:      runErrWrap,
+}
+
+func runErrWrap(pass *analysis.Pass) (interface{}, error) {
+	errorType := types.Universe.Lookup("error").Type().Underlying().(*types.Interface)
+
+	inspect := pass.ResultOf[inspect.Analyzer].(*inspector.Inspector)
+	inspect.Preorder([]ast.Node{(*ast.CallExpr)(nil)}, func(node ast.Node) {
+		call := node.(*ast.CallExpr)
+		fn, ok := typeutil.Callee(pass.TypesInfo, call).(*types.Func)
+		if !ok || fn.FullName() != "fmt.Errorf" || len(call.Args) < 2 {
+			return
+		}
+		format := pass.TypesInfo.Types[call.Args[0]].Value
+		if format == nil || format.Kind() != constant.String || strings.Contains(constant.StringVal(format), "%w") {
+			return
+		}
+		for _, arg := range call.Args[1:] {
+			if t := pass.TypesInfo.TypeOf(arg); t != nil && types.Implements(t, errorType) {
+				pass.Reportf(arg.Pos(), "error is formatted without %%w; wrap it so callers can use errors.Is and errors.As")
+				return
+			}
+		}
+	})
+	return nil, nil
+}
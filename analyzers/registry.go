@@ -0,0 +1,131 @@
+// File: analyzers/registry.go
+// The go/analysis analyzers the review can run: the passes of go vet, a few
+// optional upstream passes and the reviewer's own checks.
+package analyzers
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"golang.org/x/tools/go/analysis"
+	"golang.org/x/tools/go/analysis/passes/appends"
+	"golang.org/x/tools/go/analysis/passes/assign"
+	"golang.org/x/tools/go/analysis/passes/atomic"
+	"golang.org/x/tools/go/analysis/passes/bools"
+	"golang.org/x/tools/go/analysis/passes/buildtag"
+	"golang.org/x/tools/go/analysis/passes/composite"
+	"golang.org/x/tools/go/analysis/passes/copylock"
+	"golang.org/x/tools/go/analysis/passes/defers"
+	"golang.org/x/tools/go/analysis/passes/directive"
+	"golang.org/x/tools/go/analysis/passes/errorsas"
+	"golang.org/x/tools/go/analysis/passes/httpresponse"
+	"golang.org/x/tools/go/analysis/passes/ifaceassert"
+	"golang.org/x/tools/go/analysis/passes/loopclosure"
+	"golang.org/x/tools/go/analysis/passes/lostcancel"
+	"golang.org/x/tools/go/analysis/passes/nilfunc"
+	"golang.org/x/tools/go/analysis/passes/nilness"
+	"golang.org/x/tools/go/analysis/passes/printf"
+	"golang.org/x/tools/go/analysis/passes/shadow"
+	"golang.org/x/tools/go/analysis/passes/shift"
+	"golang.org/x/tools/go/analysis/passes/sigchanyzer"
+	"golang.org/x/tools/go/analysis/passes/slog"
+	"golang.org/x/tools/go/analysis/passes/stdmethods"
+	"golang.org/x/tools/go/analysis/passes/stringintconv"
+	"golang.org/x/tools/go/analysis/passes/structtag"
+	"golang.org/x/tools/go/analysis/passes/testinggoroutine"
+	"golang.org/x/tools/go/analysis/passes/tests"
+	"golang.org/x/tools/go/analysis/passes/timeformat"
+	"golang.org/x/tools/go/analysis/passes/unmarshal"
+	"golang.org/x/tools/go/analysis/passes/unreachable"
+	"golang.org/x/tools/go/analysis/passes/unsafeptr"
+	"golang.org/x/tools/go/analysis/passes/unusedresult"
+)
+
+// VetGroup is the name selecting every pass of vet.
+const VetGroup = "vet"
+
+// vet holds the passes go vet runs by default, except those that only apply
+// to assembly and cgo.
+var vet = []*analysis.Analyzer{
+	appends.Analyzer,
+	assign.Analyzer,
+	atomic.Analyzer,
+	bools.Analyzer,
+	buildtag.Analyzer,
+	composite.Analyzer,
+	copylock.Analyzer,
+	defers.Analyzer,
+	directive.Analyzer,
+	errorsas.Analyzer,
+	httpresponse.Analyzer,
+	ifaceassert.Analyzer,
+	loopclosure.Analyzer,
+	lostcancel.Analyzer,
+	nilfunc.Analyzer,
+	printf.Analyzer,
+	shift.Analyzer,
+	sigchanyzer.Analyzer,
+	slog.Analyzer,
+	stdmethods.Analyzer,
+	stringintconv.Analyzer,
+	structtag.Analyzer,
+	testinggoroutine.Analyzer,
+	tests.Analyzer,
+	timeformat.Analyzer,
+	unmarshal.Analyzer,
+	unreachable.Analyzer,
+	unsafeptr.Analyzer,
+	unusedresult.Analyzer,
+}
+
+// extra holds analyzers that are not part of vet and must be named.
+var extra = []*analysis.Analyzer{
+	nilness.Analyzer,
+	shadow.Analyzer,
+	CtxFirst,
+	ErrWrap,
+}
+
+// Lookup returns the analyzers for a list of names. "vet" selects every vet
+// pass; other names select a single analyzer.
+func Lookup(names []string) ([]*analysis.Analyzer, error) {
+	byName := map[string]*analysis.Analyzer{}
+	for _, analyzer := range append(append([]*analysis.Analyzer{}, vet...), extra...) {
+		byName[analyzer.Name] = analyzer
+	}
+
+	var selected []*analysis.Analyzer
+	seen := map[*analysis.Analyzer]bool{}
+	add := func(analyzer *analysis.Analyzer) {
+		if !seen[analyzer] {
+			seen[analyzer] = true
+			selected = append(selected, analyzer)
+		}
+	}
+	for _, name := range names {
+		name = strings.TrimSpace(name)
+		switch analyzer, ok := byName[name]; {
+		case name == "":
+		case name == VetGroup:
+			for _, analyzer := range vet {
+				add(analyzer)
+			}
+		case ok:
+			add(analyzer)
+		default:
+			return nil, fmt.Errorf("unknown analyzer %q (available: %s, %s)", name, VetGroup, strings.Join(Names(), ", "))
+		}
+	}
+	return selected, nil
+}
+
+// Names returns the names of all available analyzers, sorted.
+func Names() []string {
+	var names []string
+	for _, analyzer := range append(append([]*analysis.Analyzer{}, vet...), extra...) {
+		names = append(names, analyzer.Name)
+	}
+	sort.Strings(names)
+	return names
+}
@@ -0,0 +1,177 @@
+package analyzers
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"golang.org/x/tools/go/analysis"
+	"golang.org/x/tools/go/analysis/checker"
+	"golang.org/x/tools/go/packages"
+)
+
+// Diagnostic is a problem an analyzer reported in one of the changed files.
+type Diagnostic struct {
+	Analyzer string `json:"analyzer"`
+	// File is relative to the repository root, with forward slashes.
+	File    string `json:"file"`
+	Line    int    `json:"line"`
+	Column  int    `json:"column"`
+	Message string `json:"message"`
+}
+
+// Run loads the packages containing files (paths relative to the repository
+// checked out in dir) and runs the analyzers on them in-process. Only
+// diagnostics in files are returned. Packages are loaded with the go command,
+// which must be installed, in the restricted environment of goEnv: module
+// dependencies must be vendored or already in the module cache.
+//
+// The checkout is untrusted code; servers call Run through a Sandbox.
+func Run(ctx context.Context, dir string, analyzers []*analysis.Analyzer, files []string) ([]Diagnostic, error) {
+	wanted := map[string]bool{}
+	patternsByModule := map[string]map[string]bool{}
+	for _, file := range files {
+		if !strings.HasSuffix(file, ".go") {
+			continue
+		}
+		wanted[file] = true
+		pkgDir := path.Dir(file)
+		module, ok := moduleRoot(dir, pkgDir)
+		if !ok {
+			continue
+		}
+		rel := strings.TrimPrefix(strings.TrimPrefix(pkgDir, module), "/")
+		if patternsByModule[module] == nil {
+			patternsByModule[module] = map[string]bool{}
+		}
+		patternsByModule[module]["./"+rel] = true
+	}
+
+	seen := map[Diagnostic]bool{}
+	var diagnostics []Diagnostic
+	for module, patterns := range patternsByModule {
+		moduleDir := filepath.Join(dir, filepath.FromSlash(module))
+		pkgs, err := packages.Load(&packages.Config{
+			Context: ctx,
+			Mode:    packages.LoadAllSyntax,
+			Dir:     moduleDir,
+			Tests:   true,
+			Env:     goEnv(moduleDir),
+		}, keys(patterns)...)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load packages of %s: %w", moduleName(module), err)
+		}
+		graph, err := checker.Analyze(analyzers, pkgs, nil)
+		if err != nil {
+			return nil, fmt.Errorf("failed to analyze %s: %w", moduleName(module), err)
+		}
+
+		for _, action := range graph.Roots {
+			if action.Err != nil {
+				// typically a package that does not type-check; stdout is
+				// the JSON output of the analyze command, so say it on stderr
+				fmt.Fprintf(os.Stderr, "analyzer %s failed on %s: %v\n", action.Analyzer.Name, action.Package.PkgPath, action.Err)
+				continue
+			}
+			for _, d := range action.Diagnostics {
+				position := action.Package.Fset.Position(d.Pos)
+				file, err := filepath.Rel(dir, position.Filename)
+				if err != nil || !wanted[filepath.ToSlash(file)] {
+					continue
+				}
+				diagnostic := Diagnostic{
+					Analyzer: action.Analyzer.Name,
+					File:     filepath.ToSlash(file),
+					Line:     position.Line,
+					Column:   position.Column,
+					Message:  d.Message,
+				}
+				// test variants of a package report the same problems twice
+				if !seen[diagnostic] {
+					seen[diagnostic] = true
+					diagnostics = append(diagnostics, diagnostic)
+				}
+			}
+		}
+	}
+
+	sort.Slice(diagnostics, func(i, j int) bool {
+		a, b := diagnostics[i], diagnostics[j]
+		if a.File != b.File {
+			return a.File < b.File
+		}
+		if a.Line != b.Line {
+			return a.Line < b.Line
+		}
+		return a.Column < b.Column
+	})
+	return diagnostics, nil
+}
+
+// goEnvKeys are the variables passed on to the go command: those locating
+// the toolchain and its caches. Credentials in the environment are not.
+var goEnvKeys = []string{"PATH", "HOME", "TMPDIR", "GOROOT", "GOPATH", "GOCACHE", "GOMODCACHE"}
+
+// goEnv is the environment of the go command loading the module in
+// moduleDir. The module's dependencies come from its vendor directory or
+// the module cache and are never downloaded, the checkout cannot select a
+// toolchain, workspace or go flags, and cgo, which runs the C toolchain on
+// the checkout's files, is off.
+func goEnv(moduleDir string) []string {
+	var env []string
+	for _, key := range goEnvKeys {
+		if value, ok := os.LookupEnv(key); ok {
+			env = append(env, key+"="+value)
+		}
+	}
+	goFlags := "-mod=readonly"
+	if _, err := os.Stat(filepath.Join(moduleDir, "vendor", "modules.txt")); err == nil {
+		goFlags = "-mod=vendor"
+	}
+	return append(env,
+		"GOFLAGS="+goFlags,
+		"GOPROXY=off",
+		"GOSUMDB=off",
+		"GOENV=off",
+		"GOWORK=off",
+		"GOTOOLCHAIN=local",
+		"CGO_ENABLED=0",
+	)
+}
+
+// moduleRoot returns the directory of the go.mod governing pkgDir, both
+// relative to the repository root ("" being the root itself).
+func moduleRoot(dir, pkgDir string) (string, bool) {
+	for current := pkgDir; ; current = path.Dir(current) {
+		if current == "." {
+			current = ""
+		}
+		if _, err := os.Stat(filepath.Join(dir, filepath.FromSlash(current), "go.mod")); err == nil {
+			return current, true
+		}
+		if current == "" {
+			return "", false
+		}
+	}
+}
+
+// moduleName describes a module directory in messages.
+func moduleName(module string) string {
+	if module == "" {
+		return "the root module"
+	}
+	return "module " + module
+}
+
+func keys(set map[string]bool) []string {
+	list := make([]string, 0, len(set))
+	for key := range set {
+		list = append(list, key)
+	}
+	sort.Strings(list)
+	return list
+}
@@ -0,0 +1,201 @@
+package analyzers
+
+import (
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"golang.org/x/tools/go/analysis"
+	"golang.org/x/tools/go/analysis/passes/printf"
+)
+
+func writeFiles(t *testing.T, dir string, files map[string]string) {
+	t.Helper()
+	for name, content := range files {
+		path := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestGoEnv(t *testing.T) {
+	t.Setenv("AI_CHECKER_GITHUB_TOKEN", "ghp_secret")
+	t.Setenv("GOFLAGS", "-mod=mod")
+	t.Setenv("GOPROXY", "https://proxy.golang.org")
+
+	tests := []struct {
+		name   string
+		files  map[string]string
+		wantGo string
+	}{
+		{"module cache", map[string]string{"go.mod": "module example.com/m\n"}, "GOFLAGS=-mod=readonly"},
+		{"vendored", map[string]string{"go.mod": "module example.com/m\n", "vendor/modules.txt": ""}, "GOFLAGS=-mod=vendor"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			writeFiles(t, dir, tt.files)
+			env := goEnv(dir)
+			joined := strings.Join(env, "\n")
+			if strings.Contains(joined, "ghp_secret") {
+				t.Errorf("goEnv() passes credentials on: %v", env)
+			}
+			for _, want := range []string{tt.wantGo, "GOPROXY=off", "CGO_ENABLED=0", "GOWORK=off", "GOTOOLCHAIN=local", "GOENV=off"} {
+				if !containsString(env, want) {
+					t.Errorf("goEnv() lacks %s: %v", want, env)
+				}
+			}
+			for _, unwanted := range []string{"GOFLAGS=-mod=mod", "GOPROXY=https://proxy.golang.org"} {
+				if containsString(env, unwanted) {
+					t.Errorf("goEnv() keeps %s", unwanted)
+				}
+			}
+		})
+	}
+}
+
+func containsString(list []string, value string) bool {
+	for _, item := range list {
+		if item == value {
+			return true
+		}
+	}
+	return false
+}
+
+func TestRun(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{
+		"go.mod": "module example.com/m\n\ngo 1.21\n",
+		"cmd/main.go": `package main
+
+import "fmt"
+
+func main() {
+	fmt.Printf("%d\n", "not a number")
+}
+`,
+		"lib/lib.go": `package lib
+
+import "fmt"
+
+func Unchanged() string { return fmt.Sprintf("%s", 1) }
+`,
+	})
+
+	diagnostics, err := Run(context.Background(), dir, []*analysis.Analyzer{printf.Analyzer}, []string{"cmd/main.go", "README.md"})
+	if err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+	if len(diagnostics) != 1 {
+		t.Fatalf("Run() = %+v, want one diagnostic in cmd/main.go", diagnostics)
+	}
+	got := diagnostics[0]
+	if got.Analyzer != "printf" || got.File != "cmd/main.go" || got.Line != 6 {
+		t.Errorf("diagnostic = %+v, want printf at cmd/main.go:6", got)
+	}
+}
+
+// TestRunBrokenPackage runs the analyzers on a module with a package that
+// does not compile: the other package is still analyzed and nothing is
+// written to stdout, which carries the JSON output of the analyze command.
+func TestRunBrokenPackage(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{
+		"go.mod": "module example.com/m\n\ngo 1.21\n",
+		"cmd/main.go": `package main
+
+import "fmt"
+
+func main() {
+	fmt.Printf("%d\n", "not a number")
+}
+`,
+		"broken/broken.go": `package broken
+
+func Broken() int { return "not an int" }
+`,
+	})
+
+	stdout := os.Stdout
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	os.Stdout = w
+	diagnostics, err := Run(context.Background(), dir, []*analysis.Analyzer{printf.Analyzer}, []string{"cmd/main.go", "broken/broken.go"})
+	os.Stdout = stdout
+	w.Close()
+	written, readErr := io.ReadAll(r)
+	if readErr != nil {
+		t.Fatal(readErr)
+	}
+
+	if err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+	if len(diagnostics) != 1 || diagnostics[0].File != "cmd/main.go" {
+		t.Errorf("Run() = %+v, want one diagnostic in cmd/main.go", diagnostics)
+	}
+	if len(written) > 0 {
+		t.Errorf("Run() wrote %q to stdout", written)
+	}
+}
+
+func TestSandboxRun(t *testing.T) {
+	t.Setenv("AI_CHECKER_LLM_API_KEY", "sk-secret")
+	// the fake analyze command fails when the credential leaks, and echoes
+	// its arguments as the message of its one diagnostic
+	script := `[ -z "$AI_CHECKER_LLM_API_KEY" ] || { echo leaked >&2; exit 1; }
+printf '[{"analyzer":"printf","file":"a.go","line":3,"column":2,"message":"%s"}]' "$*"`
+
+	tests := []struct {
+		name    string
+		sandbox Sandbox
+		want    []Diagnostic
+		wantErr string
+	}{
+		{
+			name:    "isolated command",
+			sandbox: Sandbox{Command: []string{"sh", "-c", script, "analyze"}, Isolate: []string{"env"}},
+			want:    []Diagnostic{{Analyzer: "printf", File: "a.go", Line: 3, Column: 2, Message: "-dir DIR -analyzers printf,ctxfirst -- a.go b.go"}},
+		},
+		{
+			name:    "noise on stderr",
+			sandbox: Sandbox{Command: []string{"sh", "-c", "echo 'analyzer printf failed on example.com/m/broken' >&2; echo '[]'"}},
+			want:    []Diagnostic{},
+		},
+		{name: "failing command", sandbox: Sandbox{Command: []string{"sh", "-c", "echo broken >&2; exit 2"}}, wantErr: "broken"},
+		{name: "invalid output", sandbox: Sandbox{Command: []string{"sh", "-c", "echo not json"}}, wantErr: "failed to parse analysis output"},
+		{name: "no command", sandbox: Sandbox{}, wantErr: "no analysis command"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			got, err := tt.sandbox.Run(context.Background(), dir, []*analysis.Analyzer{printf.Analyzer, CtxFirst}, []string{"a.go", "b.go"})
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("Run() error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Run() error = %v", err)
+			}
+			for i := range got {
+				got[i].Message = strings.ReplaceAll(got[i].Message, dir, "DIR")
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Run() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
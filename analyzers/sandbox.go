@@ -0,0 +1,67 @@
+package analyzers
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+
+	"golang.org/x/tools/go/analysis"
+)
+
+// DefaultIsolation runs a command in new user and network namespaces, so
+// it has no network access. It needs util-linux and unprivileged user
+// namespaces.
+var DefaultIsolation = []string{"unshare", "--net", "--map-root-user"}
+
+// Sandbox runs the analysis of an untrusted checkout in a child process:
+// Command prefixed with Isolate, in an environment holding none of the
+// parent's credentials. Command must behave like "ai-api analyze": take
+// -dir, -analyzers and the files as arguments and print the diagnostics
+// as JSON.
+type Sandbox struct {
+	Command []string
+	// Isolate is the command the analysis runs under, e.g.
+	// DefaultIsolation or a bwrap invocation. Empty runs Command directly,
+	// for processes already isolated from the network.
+	Isolate []string
+}
+
+// Run runs the analyzers on the packages of files in the checkout in dir,
+// like the in-process Run.
+func (s Sandbox) Run(ctx context.Context, dir string, analyzers []*analysis.Analyzer, files []string) ([]Diagnostic, error) {
+	if len(s.Command) == 0 {
+		return nil, fmt.Errorf("no analysis command configured")
+	}
+	names := make([]string, len(analyzers))
+	for i, analyzer := range analyzers {
+		names[i] = analyzer.Name
+	}
+	args := append(append([]string{}, s.Isolate...), s.Command...)
+	args = append(args, "-dir", dir, "-analyzers", strings.Join(names, ","), "--")
+	args = append(args, files...)
+
+	cmd := exec.CommandContext(ctx, args[0], args[1:]...)
+	cmd.Dir = dir
+	for _, key := range goEnvKeys {
+		if value, ok := os.LookupEnv(key); ok {
+			cmd.Env = append(cmd.Env, key+"="+value)
+		}
+	}
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout, cmd.Stderr = &stdout, &stderr
+	if err := cmd.Run(); err != nil {
+		return nil, fmt.Errorf("analysis process failed: %w: %s", err, strings.TrimSpace(stderr.String()))
+	}
+	if message := strings.TrimSpace(stderr.String()); message != "" {
+		fmt.Printf("analysis process: %s\n", message)
+	}
+	var diagnostics []Diagnostic
+	if err := json.Unmarshal(stdout.Bytes(), &diagnostics); err != nil {
+		return nil, fmt.Errorf("failed to parse analysis output: %w", err)
+	}
+	return diagnostics, nil
+}
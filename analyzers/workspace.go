@@ -0,0 +1,80 @@
+package analyzers
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// MaxArchiveBytes bounds the total size of the files extracted from a
+// repository archive.
+const MaxArchiveBytes = 512 << 20
+
+// Extract unpacks a gzipped tarball of a repository, as served by the GitHub
+// tarball API, into dir. The archive's single top-level directory
+// ("owner-repo-sha/") is stripped. Only regular files and directories are
+// extracted; entries escaping dir are rejected.
+func Extract(r io.Reader, dir string) error {
+	gz, err := gzip.NewReader(r)
+	if err != nil {
+		return fmt.Errorf("failed to read archive: %w", err)
+	}
+	defer gz.Close()
+
+	var total int64
+	archive := tar.NewReader(gz)
+	for {
+		header, err := archive.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("failed to read archive: %w", err)
+		}
+
+		_, name, _ := strings.Cut(path.Clean(header.Name), "/")
+		if name == "" || name == "." {
+			continue
+		}
+		if path.IsAbs(name) || name == ".." || strings.HasPrefix(name, "../") {
+			return fmt.Errorf("archive entry %q escapes the checkout", header.Name)
+		}
+		target := filepath.Join(dir, filepath.FromSlash(name))
+
+		switch header.Typeflag {
+		case tar.TypeDir:
+			if err := os.MkdirAll(target, 0o755); err != nil {
+				return fmt.Errorf("failed to extract archive: %w", err)
+			}
+		case tar.TypeReg:
+			total += header.Size
+			if total > MaxArchiveBytes {
+				return fmt.Errorf("archive is larger than %d bytes", MaxArchiveBytes)
+			}
+			if err := extractFile(archive, target, header.Size); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// extractFile writes the current archive entry to target.
+func extractFile(r io.Reader, target string, size int64) error {
+	if err := os.MkdirAll(filepath.Dir(target), 0o755); err != nil {
+		return fmt.Errorf("failed to extract archive: %w", err)
+	}
+	f, err := os.OpenFile(target, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0o644)
+	if err != nil {
+		return fmt.Errorf("failed to extract archive: %w", err)
+	}
+	if _, err := io.CopyN(f, r, size); err != nil {
+		f.Close()
+		return fmt.Errorf("failed to extract archive: %w", err)
+	}
+	return f.Close()
+}
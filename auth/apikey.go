@@ -0,0 +1,127 @@
+// File: auth/apikey.go
+// Static API keys for service-to-service calls. Keys are stored as
+// SHA-256 hashes together with an audit-friendly ID and a list of scopes
+// limiting which owners/repos the key can trigger reviews for.
+package auth
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path"
+	"strings"
+)
+
+// hashPrefix marks the hashing scheme used for stored keys.
+const hashPrefix = "sha256:"
+
+// AdminScope grants access to routes that are not about one repository,
+// such as reports aggregated over every repository. Repository patterns,
+// even "*/*", never grant it.
+const AdminScope = "admin"
+
+// APIKey is a single stored API key. The raw key is never kept in memory
+// after hashing, only its hash.
+type APIKey struct {
+	ID     string   `json:"id"`
+	Hash   string   `json:"hash"`
+	Scopes []string `json:"scopes"`
+}
+
+// KeyStore holds the set of API keys accepted by the service.
+type KeyStore struct {
+	keys []APIKey
+}
+
+// NewKeyStore creates a KeyStore from already-hashed keys.
+func NewKeyStore(keys []APIKey) (*KeyStore, error) {
+	seen := map[string]bool{}
+	for _, key := range keys {
+		if key.ID == "" {
+			return nil, fmt.Errorf("api key is missing an id")
+		}
+		if seen[key.ID] {
+			return nil, fmt.Errorf("duplicate api key id %q", key.ID)
+		}
+		seen[key.ID] = true
+		if !strings.HasPrefix(key.Hash, hashPrefix) {
+			return nil, fmt.Errorf("api key %q: hash must start with %q", key.ID, hashPrefix)
+		}
+		if len(key.Scopes) == 0 {
+			return nil, fmt.Errorf("api key %q has no scopes", key.ID)
+		}
+	}
+	return &KeyStore{keys: keys}, nil
+}
+
+// LoadKeyStore reads a JSON array of APIKey entries from filePath.
+func LoadKeyStore(filePath string) (*KeyStore, error) {
+	data, err := os.ReadFile(filePath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read api keys file: %w", err)
+	}
+	var keys []APIKey
+	if err := json.Unmarshal(data, &keys); err != nil {
+		return nil, fmt.Errorf("failed to parse api keys file: %w", err)
+	}
+	return NewKeyStore(keys)
+}
+
+// Authenticate returns the key matching rawKey. Every stored hash is compared
+// in constant time so the lookup does not leak which keys exist.
+func (s *KeyStore) Authenticate(rawKey string) (*APIKey, bool) {
+	if rawKey == "" {
+		return nil, false
+	}
+	hashed := []byte(HashKey(rawKey))
+
+	var found *APIKey
+	for i := range s.keys {
+		if subtle.ConstantTimeCompare(hashed, []byte(s.keys[i].Hash)) == 1 {
+			found = &s.keys[i]
+		}
+	}
+	return found, found != nil
+}
+
+// Allows reports whether the key may trigger reviews for owner/repo.
+// Scopes are "owner/repo" patterns using path.Match syntax, e.g. "acme/*" or "*/*".
+func (k *APIKey) Allows(owner, repo string) bool {
+	target := owner + "/" + repo
+	for _, scope := range k.Scopes {
+		if ok, err := path.Match(scope, target); err == nil && ok {
+			return true
+		}
+	}
+	return false
+}
+
+// IsAdmin reports whether the key has the AdminScope.
+func (k *APIKey) IsAdmin() bool {
+	for _, scope := range k.Scopes {
+		if scope == AdminScope {
+			return true
+		}
+	}
+	return false
+}
+
+// HashKey returns the stored representation of a raw API key.
+func HashKey(rawKey string) string {
+	sum := sha256.Sum256([]byte(rawKey))
+	return hashPrefix + hex.EncodeToString(sum[:])
+}
+
+// GenerateKey returns a new random raw key prefixed with its ID so it can be
+// recognised in secret scanners. Only HashKey(raw) should be stored.
+func GenerateKey(id string) (string, error) {
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return "", fmt.Errorf("failed to generate api key: %w", err)
+	}
+	return "prc_" + id + "_" + hex.EncodeToString(buf), nil
+}
@@ -0,0 +1,122 @@
+package auth
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestNewKeyStoreValidation(t *testing.T) {
+	valid := APIKey{ID: "ci", Hash: HashKey("secret"), Scopes: []string{"acme/*"}}
+	tests := []struct {
+		name    string
+		keys    []APIKey
+		wantErr string
+	}{
+		{"valid", []APIKey{valid}, ""},
+		{"missing id", []APIKey{{Hash: valid.Hash, Scopes: valid.Scopes}}, "missing an id"},
+		{"duplicate id", []APIKey{valid, valid}, "duplicate api key id"},
+		{"unhashed", []APIKey{{ID: "ci", Hash: "secret", Scopes: valid.Scopes}}, "hash must start with"},
+		{"no scopes", []APIKey{{ID: "ci", Hash: valid.Hash}}, "has no scopes"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := NewKeyStore(tt.keys)
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Fatalf("NewKeyStore() error = %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Fatalf("NewKeyStore() error = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestAuthenticate(t *testing.T) {
+	store, err := NewKeyStore([]APIKey{
+		{ID: "ci", Hash: HashKey("ci-secret"), Scopes: []string{"acme/*"}},
+		{ID: "ops", Hash: HashKey("ops-secret"), Scopes: []string{"admin"}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		raw    string
+		wantID string
+	}{
+		{"ci-secret", "ci"},
+		{"ops-secret", "ops"},
+		{"wrong", ""},
+		{"", ""},
+	}
+	for _, tt := range tests {
+		key, ok := store.Authenticate(tt.raw)
+		if tt.wantID == "" {
+			if ok {
+				t.Errorf("Authenticate(%q) = %s, want no key", tt.raw, key.ID)
+			}
+			continue
+		}
+		if !ok || key.ID != tt.wantID {
+			t.Errorf("Authenticate(%q) = %v, %v, want %s", tt.raw, key, ok, tt.wantID)
+		}
+	}
+}
+
+func TestAllows(t *testing.T) {
+	tests := []struct {
+		scopes      []string
+		owner, repo string
+		want        bool
+	}{
+		{[]string{"acme/*"}, "acme", "api", true},
+		{[]string{"acme/*"}, "other", "api", false},
+		{[]string{"acme/api"}, "acme", "web", false},
+		{[]string{"acme/api", "acme/web"}, "acme", "web", true},
+		{[]string{"*/*"}, "anyone", "anything", true},
+		// GitLab subgroups have a slash in the owner
+		{[]string{"*/*"}, "group/sub", "project", false},
+		{[]string{"group/sub/*"}, "group/sub", "project", true},
+		{[]string{"admin"}, "acme", "api", false},
+	}
+	for _, tt := range tests {
+		key := &APIKey{Scopes: tt.scopes}
+		if got := key.Allows(tt.owner, tt.repo); got != tt.want {
+			t.Errorf("Allows(%s/%s) with %v = %v, want %v", tt.owner, tt.repo, tt.scopes, got, tt.want)
+		}
+	}
+}
+
+func TestIsAdmin(t *testing.T) {
+	tests := []struct {
+		scopes []string
+		want   bool
+	}{
+		{[]string{"admin"}, true},
+		{[]string{"acme/*", "admin"}, true},
+		{[]string{"*/*"}, false},
+		{[]string{"*"}, false},
+	}
+	for _, tt := range tests {
+		key := &APIKey{Scopes: tt.scopes}
+		if got := key.IsAdmin(); got != tt.want {
+			t.Errorf("IsAdmin() with %v = %v, want %v", tt.scopes, got, tt.want)
+		}
+	}
+}
+
+func TestGenerateKey(t *testing.T) {
+	raw, err := GenerateKey("ci")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(raw, "prc_ci_") {
+		t.Errorf("GenerateKey() = %q, want prefix prc_ci_", raw)
+	}
+	store, _ := NewKeyStore([]APIKey{{ID: "ci", Hash: HashKey(raw), Scopes: []string{"*/*"}}})
+	if _, ok := store.Authenticate(raw); !ok {
+		t.Error("generated key does not authenticate against its hash")
+	}
+}
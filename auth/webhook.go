@@ -0,0 +1,31 @@
+// File: auth/webhook.go
+// Verification of webhook deliveries signed with a shared secret, as GitHub
+// does in the X-Hub-Signature-256 header.
+package auth
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"strings"
+)
+
+// signaturePrefix precedes the hex HMAC in X-Hub-Signature-256.
+const signaturePrefix = "sha256="
+
+// SignPayload returns the X-Hub-Signature-256 value of payload for secret.
+func SignPayload(secret string, payload []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(payload)
+	return signaturePrefix + hex.EncodeToString(mac.Sum(nil))
+}
+
+// VerifySignature reports whether signature, an X-Hub-Signature-256 header
+// value, is the HMAC-SHA256 of payload under secret. An empty secret never
+// verifies.
+func VerifySignature(secret string, payload []byte, signature string) bool {
+	if secret == "" || !strings.HasPrefix(signature, signaturePrefix) {
+		return false
+	}
+	return hmac.Equal([]byte(SignPayload(secret, payload)), []byte(signature))
+}
@@ -0,0 +1,28 @@
+package auth
+
+import "testing"
+
+func TestVerifySignature(t *testing.T) {
+	payload := []byte(`{"action":"created"}`)
+	valid := SignPayload("secret", payload)
+	tests := []struct {
+		name, secret, signature string
+		payload                 []byte
+		want                    bool
+	}{
+		{"valid", "secret", valid, payload, true},
+		{"wrong secret", "other", valid, payload, false},
+		{"tampered payload", "secret", valid, []byte(`{"action":"deleted"}`), false},
+		{"missing", "secret", "", payload, false},
+		{"no prefix", "secret", valid[len("sha256="):], payload, false},
+		{"sha1", "secret", "sha1=" + valid[len("sha256="):], payload, false},
+		{"empty secret", "", SignPayload("", payload), payload, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := VerifySignature(tt.secret, tt.payload, tt.signature); got != tt.want {
+				t.Errorf("VerifySignature() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,54 @@
+package authz
+
+import (
+	clients "ai-api/clients"
+	"ai-api/config"
+	"context"
+	"fmt"
+	"time"
+)
+
+// Authorization modes of config.AuthzMode.
+const (
+	ModeLocal   = "local"
+	ModeOpenFGA = "openfga"
+)
+
+// Checker answers whether user has relation on object. Contextual tuples
+// hold for the check only, e.g. the owner org of a repo.
+type Checker interface {
+	Check(ctx context.Context, user, relation, object string, contextual ...Tuple) (bool, error)
+}
+
+// FromConfig creates the Checker of cfg.AuthzMode: the in-memory evaluator
+// of AuthzTuplesFile, the OpenFGA server at OpenFGAURL, or nil when no
+// mode is set and API keys are not authorized beyond their scopes. A mode
+// set with AuthDisabled is an error, as nothing would be checked without
+// API keys.
+func FromConfig(cfg config.Config) (Checker, error) {
+	if cfg.AuthzMode != "" && cfg.AuthDisabled {
+		return nil, fmt.Errorf("authz mode %q needs API keys and cannot be used with auth_disabled", cfg.AuthzMode)
+	}
+	switch cfg.AuthzMode {
+	case "":
+		return nil, nil
+	case ModeLocal:
+		memory, err := LoadMemory(cfg.AuthzTuplesFile)
+		if err != nil {
+			return nil, err
+		}
+		return memory, nil
+	case ModeOpenFGA:
+		httpClient, err := clients.NewHTTPClient(10*time.Second, cfg.CABundle)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create http client: %w", err)
+		}
+		openFGA, err := NewOpenFGA(httpClient, cfg.OpenFGAURL, cfg.OpenFGAStoreID, cfg.OpenFGAModelID, cfg.OpenFGAToken)
+		if err != nil {
+			return nil, err
+		}
+		return openFGA, nil
+	default:
+		return nil, fmt.Errorf("invalid authz mode %q, want %s or %s", cfg.AuthzMode, ModeLocal, ModeOpenFGA)
+	}
+}
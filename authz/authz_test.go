@@ -0,0 +1,34 @@
+package authz
+
+import (
+	"ai-api/config"
+	"strings"
+	"testing"
+)
+
+func TestFromConfig(t *testing.T) {
+	tests := []struct {
+		name    string
+		cfg     config.Config
+		wantErr string
+	}{
+		{name: "off", cfg: config.Config{}},
+		{name: "off without auth", cfg: config.Config{AuthDisabled: true}},
+		{name: "with auth disabled", cfg: config.Config{AuthzMode: ModeLocal, AuthDisabled: true}, wantErr: "cannot be used with auth_disabled"},
+		{name: "invalid mode", cfg: config.Config{AuthzMode: "ldap"}, wantErr: `invalid authz mode "ldap"`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			checker, err := FromConfig(tt.cfg)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("FromConfig() error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil || checker != nil {
+				t.Errorf("FromConfig() = %v, %v, want no checker", checker, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,127 @@
+package authz
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestMemoryCheck(t *testing.T) {
+	tuples := []Tuple{
+		{User: "user:ci", Relation: "reviewer", Object: "org:acme"},
+		{User: "team:platform#member", Relation: "maintainer", Object: "repo:acme/api"},
+		{User: "team:sre#member", Relation: "member", Object: "team:platform"},
+		{User: "user:ann", Relation: "member", Object: "team:sre"},
+		{User: "user:bob", Relation: "member", Object: "team:platform"},
+		{User: "user:*", Relation: "viewer", Object: "tenant:_instance"},
+		{User: "user:*", Relation: "viewer", Object: "repo:acme/docs"},
+		// teams nested in each other
+		{User: "team:a#member", Relation: "member", Object: "team:b"},
+		{User: "team:b#member", Relation: "member", Object: "team:a"},
+		{User: "team:a#member", Relation: "reviewer", Object: "repo:acme/loop"},
+	}
+	repo := func(owner, name string) (string, []Tuple) {
+		object, ownerTuple := Repo(owner, name)
+		return object, []Tuple{ownerTuple}
+	}
+	tests := []struct {
+		name     string
+		user     string
+		relation string
+		object   string
+		owner    string
+		want     bool
+	}{
+		{name: "org role on a repo", user: "user:ci", relation: CanTriggerReview, object: "api", owner: "acme", want: true},
+		{name: "org role on another org's repo", user: "user:ci", relation: CanTriggerReview, object: "api", owner: "globex"},
+		{name: "org role below the permission", user: "user:ci", relation: CanConfigure, object: "api", owner: "acme"},
+		{name: "org role on a GitLab subgroup repo", user: "user:ci", relation: CanTriggerReview, object: "api", owner: "Acme/Platform", want: true},
+		{name: "org role without the owner tuple", user: "user:ci", relation: CanTriggerReview, object: "repo:acme/api"},
+		{name: "team member", user: "user:bob", relation: CanConfigure, object: "api", owner: "acme", want: true},
+		{name: "member of a nested team", user: "user:ann", relation: CanConfigure, object: "api", owner: "acme", want: true},
+		{name: "team member on another repo", user: "user:bob", relation: CanTriggerReview, object: "web", owner: "acme"},
+		{name: "every user on a tenant", user: "user:anyone", relation: CanViewHistory, object: Tenant(""), want: true},
+		{name: "every user on a public repo", user: "user:anyone", relation: CanViewHistory, object: "docs", owner: "acme", want: true},
+		{name: "every user is not every team", user: "team:platform", relation: CanViewHistory, object: "tenant:_instance"},
+		{name: "every user on another tenant", user: "user:anyone", relation: CanViewHistory, object: Tenant("acme")},
+		{name: "cycle of teams", user: "user:ann", relation: CanTriggerReview, object: "loop", owner: "acme"},
+	}
+	m, err := NewMemory(DefaultModel, tuples)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			object, contextual := tt.object, []Tuple(nil)
+			if tt.owner != "" {
+				object, contextual = repo(tt.owner, tt.object)
+			}
+			got, err := m.Check(context.Background(), tt.user, tt.relation, object, contextual...)
+			if err != nil {
+				t.Fatalf("Check() error = %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("Check(%s, %s, %s) = %v, want %v", tt.user, tt.relation, object, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMemoryCheckDepth(t *testing.T) {
+	tests := []struct {
+		teams   int
+		want    bool
+		wantErr bool
+	}{
+		{teams: 10, want: true},
+		{teams: maxDepth, want: true},
+		{teams: maxDepth + 5, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(fmt.Sprint(tt.teams), func(t *testing.T) {
+			// user:ann is a member of team:t0, whose members are members of
+			// team:t1, and so on
+			tuples := []Tuple{{User: "user:ann", Relation: "member", Object: "team:t0"}}
+			for i := 1; i < tt.teams; i++ {
+				tuples = append(tuples, Tuple{User: fmt.Sprintf("team:t%d#member", i-1), Relation: "member", Object: fmt.Sprintf("team:t%d", i)})
+			}
+			m, err := NewMemory(DefaultModel, tuples)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, err := m.Check(context.Background(), "user:ann", "member", fmt.Sprintf("team:t%d", tt.teams-1))
+			if tt.wantErr {
+				if err == nil || !strings.Contains(err.Error(), "resolution too deep") {
+					t.Fatalf("Check() = %v, %v, want a depth error", got, err)
+				}
+				return
+			}
+			if err != nil || got != tt.want {
+				t.Errorf("Check() = %v, %v, want %v", got, err, tt.want)
+			}
+		})
+	}
+}
+
+func TestNewMemoryInvalidTuples(t *testing.T) {
+	tests := []struct {
+		tuple   Tuple
+		wantErr string
+	}{
+		{Tuple{User: "user:ci", Relation: "reviewer", Object: "acme"}, "not of the form type:id"},
+		{Tuple{User: "user:ci", Relation: "reviewer", Object: "project:acme"}, `unknown object type "project"`},
+		{Tuple{User: "user:ci", Relation: "admin", Object: "org:acme"}, "type org has no relation admin"},
+		{Tuple{User: "user:ci", Relation: CanConfigure, Object: "repo:acme/api"}, "cannot be assigned"},
+		{Tuple{User: "group:sre", Relation: "reviewer", Object: "org:acme"}, `unknown user type "group"`},
+		{Tuple{User: "user:*", Relation: "maintainer", Object: "org:acme"}, "cannot be granted to every user"},
+		{Tuple{User: "user:ci", Relation: "reviewer", Object: "org:Acme"}, "org:Acme must be lowercase"},
+		{Tuple{User: "org:Acme", Relation: "owner", Object: "repo:acme/api"}, "org:Acme must be lowercase"},
+	}
+	for _, tt := range tests {
+		_, err := NewMemory(DefaultModel, []Tuple{tt.tuple})
+		if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+			t.Errorf("NewMemory(%+v) error = %v, want %q", tt.tuple, err, tt.wantErr)
+		}
+	}
+}
@@ -0,0 +1,152 @@
+// File: authz/model.go
+// The relationship model deciding who may use the API: users and teams
+// are granted roles on orgs and repos, and the permissions checked by the
+// server (can_trigger_review, can_configure, can_view_history) follow from
+// those roles. model.fga holds the same model in the OpenFGA DSL, to write
+// to an OpenFGA store.
+package authz
+
+import (
+	_ "embed"
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+// Permissions checked by the server.
+const (
+	CanTriggerReview = "can_trigger_review"
+	CanConfigure     = "can_configure"
+	CanViewHistory   = "can_view_history"
+)
+
+// InstanceTenant is the tenant object of the instance itself, which no
+// tenant can be named since tenant names start with a letter or digit.
+const InstanceTenant = "_instance"
+
+// ModelDSL is the authorization model in the OpenFGA DSL.
+//
+//go:embed model.fga
+var ModelDSL string
+
+// Tuple is a relationship: User has Relation on Object. Users are objects
+// ("user:ci", "org:acme"), usersets ("team:platform#member") or every
+// user ("user:*").
+type Tuple struct {
+	User     string `yaml:"user" json:"user"`
+	Relation string `yaml:"relation" json:"relation"`
+	Object   string `yaml:"object" json:"object"`
+}
+
+// Relation defines who has a relation on an object: users it is assigned
+// to directly, users having one of the Implied relations on the same
+// object, and users having an Inherited relation on a related object.
+// Public relations can be assigned to every user ("user:*").
+type Relation struct {
+	Direct    bool
+	Public    bool
+	Implied   []string
+	Inherited []Inherit
+}
+
+// Inherit grants Relation on the objects an object is related to by
+// Tupleset, e.g. the maintainers of a repo's owner org.
+type Inherit struct {
+	Tupleset string
+	Relation string
+}
+
+// Model maps object types to their relations.
+type Model map[string]map[string]Relation
+
+// DefaultModel is ModelDSL for the in-memory evaluator.
+var DefaultModel = Model{
+	"user": {},
+	"team": {
+		"member": {Direct: true},
+	},
+	"org": {
+		"maintainer": {Direct: true},
+		"reviewer":   {Direct: true, Implied: []string{"maintainer"}},
+		"viewer":     {Direct: true, Public: true, Implied: []string{"reviewer"}},
+	},
+	"repo": {
+		"owner":              {Direct: true},
+		"maintainer":         {Direct: true, Inherited: []Inherit{{"owner", "maintainer"}}},
+		"reviewer":           {Direct: true, Implied: []string{"maintainer"}, Inherited: []Inherit{{"owner", "reviewer"}}},
+		"viewer":             {Direct: true, Public: true, Implied: []string{"reviewer"}, Inherited: []Inherit{{"owner", "viewer"}}},
+		"can_configure":      {Implied: []string{"maintainer"}},
+		"can_trigger_review": {Implied: []string{"reviewer"}},
+		"can_view_history":   {Implied: []string{"viewer"}},
+	},
+	"tenant": {
+		"viewer":           {Direct: true, Public: true},
+		"can_view_history": {Implied: []string{"viewer"}},
+	},
+}
+
+// User returns the user object of an API key.
+func User(keyID string) string {
+	return "user:" + keyID
+}
+
+// Repo returns the repo object of owner/repo and the contextual tuple
+// relating it to its owner org, so org roles apply to every repo without
+// storing a tuple per repo. Owners are normalized like tenant owners:
+// lowercase, URL-decoded, and a GitLab group path ("acme/platform") is owned
+// by its top group.
+func Repo(owner, repo string) (object string, ownerTuple Tuple) {
+	if unescaped, err := url.PathUnescape(owner); err == nil {
+		owner = unescaped
+	}
+	owner = strings.ToLower(owner)
+	object = "repo:" + owner + "/" + strings.ToLower(repo)
+	org, _, _ := strings.Cut(owner, "/")
+	return object, Tuple{User: "org:" + org, Relation: "owner", Object: object}
+}
+
+// Tenant returns the tenant object of the named tenant, the instance's
+// when name is empty.
+func Tenant(name string) string {
+	if name == "" {
+		name = InstanceTenant
+	}
+	return "tenant:" + name
+}
+
+// validate reports tuples on unknown types or relations, or assigning a
+// relation that cannot be assigned directly.
+func (m Model) validate(t Tuple) error {
+	objectType, _, ok := strings.Cut(t.Object, ":")
+	if !ok {
+		return fmt.Errorf("object %q is not of the form type:id", t.Object)
+	}
+	relations, ok := m[objectType]
+	if !ok {
+		return fmt.Errorf("unknown object type %q", objectType)
+	}
+	relation, ok := relations[t.Relation]
+	if !ok {
+		return fmt.Errorf("type %s has no relation %s", objectType, t.Relation)
+	}
+	if !relation.Direct {
+		return fmt.Errorf("relation %s of %s cannot be assigned, it follows from other relations", t.Relation, objectType)
+	}
+	userType, _, ok := strings.Cut(t.User, ":")
+	if !ok {
+		return fmt.Errorf("user %q is not of the form type:id", t.User)
+	}
+	if _, ok := m[userType]; !ok {
+		return fmt.Errorf("unknown user type %q", userType)
+	}
+	if strings.HasSuffix(t.User, ":*") && !relation.Public {
+		return fmt.Errorf("relation %s of %s cannot be granted to every user", t.Relation, objectType)
+	}
+	// checks name orgs and repos in lowercase, see Repo
+	for _, id := range []string{t.Object, t.User} {
+		if (strings.HasPrefix(id, "org:") || strings.HasPrefix(id, "repo:")) && id != strings.ToLower(id) {
+			return fmt.Errorf("%s must be lowercase, orgs and repos are checked in lowercase", id)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,23 @@
+package authz
+
+import "testing"
+
+func TestRepo(t *testing.T) {
+	tests := []struct {
+		owner, repo string
+		wantObject  string
+		wantOrg     string
+	}{
+		{owner: "acme", repo: "api", wantObject: "repo:acme/api", wantOrg: "org:acme"},
+		{owner: "Acme", repo: "API", wantObject: "repo:acme/api", wantOrg: "org:acme"},
+		{owner: "acme/platform", repo: "api", wantObject: "repo:acme/platform/api", wantOrg: "org:acme"},
+		{owner: "Acme%2FPlatform", repo: "api", wantObject: "repo:acme/platform/api", wantOrg: "org:acme"},
+	}
+	for _, tt := range tests {
+		object, ownerTuple := Repo(tt.owner, tt.repo)
+		want := Tuple{User: tt.wantOrg, Relation: "owner", Object: tt.wantObject}
+		if object != tt.wantObject || ownerTuple != want {
+			t.Errorf("Repo(%q, %q) = %q, %+v, want %q, %+v", tt.owner, tt.repo, object, ownerTuple, tt.wantObject, want)
+		}
+	}
+}
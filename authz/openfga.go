@@ -0,0 +1,100 @@
+package authz
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// OpenFGA checks relationships with the Check API of an OpenFGA (or
+// compatible) server, whose store holds ModelDSL and the tuples.
+type OpenFGA struct {
+	httpClient *http.Client
+	baseURL    string
+	storeID    string
+	// modelID pins the authorization model; the store's latest when empty.
+	modelID string
+	token   string
+}
+
+// NewOpenFGA creates a client of the store storeID on the server at baseURL,
+// authenticating with token when set.
+func NewOpenFGA(httpClient *http.Client, baseURL, storeID, modelID, token string) (*OpenFGA, error) {
+	if baseURL == "" || storeID == "" {
+		return nil, fmt.Errorf("openfga api url and store id are required")
+	}
+	return &OpenFGA{
+		httpClient: httpClient,
+		baseURL:    strings.TrimSuffix(baseURL, "/"),
+		storeID:    storeID,
+		modelID:    modelID,
+		token:      token,
+	}, nil
+}
+
+type tupleKeys struct {
+	TupleKeys []Tuple `json:"tuple_keys"`
+}
+
+type checkRequest struct {
+	TupleKey             Tuple      `json:"tuple_key"`
+	ContextualTuples     *tupleKeys `json:"contextual_tuples,omitempty"`
+	AuthorizationModelID string     `json:"authorization_model_id,omitempty"`
+}
+
+// Check reports whether user has relation on object, given the store's
+// tuples and the contextual ones.
+func (c *OpenFGA) Check(ctx context.Context, user, relation, object string, contextual ...Tuple) (bool, error) {
+	body := checkRequest{
+		TupleKey:             Tuple{User: user, Relation: relation, Object: object},
+		AuthorizationModelID: c.modelID,
+	}
+	if len(contextual) > 0 {
+		body.ContextualTuples = &tupleKeys{TupleKeys: contextual}
+	}
+	payload, err := json.Marshal(body)
+	if err != nil {
+		return false, fmt.Errorf("failed to encode check request: %w", err)
+	}
+	endpoint := c.baseURL + "/stores/" + url.PathEscape(c.storeID) + "/check"
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, bytes.NewReader(payload))
+	if err != nil {
+		return false, fmt.Errorf("failed to create check request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if c.token != "" {
+		req.Header.Set("Authorization", "Bearer "+c.token)
+	}
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return false, fmt.Errorf("failed to check %s on %s: %w", relation, object, err)
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return false, fmt.Errorf("failed to read check response: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		var apiErr struct {
+			Code    string `json:"code"`
+			Message string `json:"message"`
+		}
+		if json.Unmarshal(data, &apiErr) == nil && apiErr.Message != "" {
+			return false, fmt.Errorf("failed to check %s on %s: %s (%s, status %d)", relation, object, apiErr.Message, apiErr.Code, resp.StatusCode)
+		}
+		return false, fmt.Errorf("failed to check %s on %s: status %d", relation, object, resp.StatusCode)
+	}
+	var result struct {
+		Allowed bool `json:"allowed"`
+	}
+	if err := json.Unmarshal(data, &result); err != nil {
+		return false, fmt.Errorf("failed to parse check response: %w", err)
+	}
+	return result.Allowed, nil
+}
@@ -0,0 +1,367 @@
+package clients
+
+import (
+	"ai-api/diff"
+	"ai-api/models"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// BitbucketClient implements SCMProvider for Bitbucket Server (REST 1.0)
+// and Bitbucket Cloud (API 2.0). Cloud is used when the base URL points at
+// api.bitbucket.org. For Server, OwnerID is the project key and RepoID the
+// repository slug; for Cloud they are the workspace and repository slug.
+type BitbucketClient struct {
+	HttpClient *http.Client
+	APIKey     string
+	BaseURL    string
+
+	identity *botIdentity
+}
+
+// bitbucketCloudBaseURL is the API root of Bitbucket Cloud.
+const bitbucketCloudBaseURL = "https://api.bitbucket.org/2.0"
+
+func NewBitbucketClient(httpClient *http.Client, apiKey, baseUrl string) *BitbucketClient {
+	if baseUrl == "" {
+		baseUrl = bitbucketCloudBaseURL
+	}
+	return &BitbucketClient{
+		HttpClient: httpClient,
+		APIKey:     apiKey,
+		BaseURL:    strings.TrimSuffix(baseUrl, "/"),
+		identity:   &botIdentity{},
+	}
+}
+
+// bitbucketServerComment is the request body of a Bitbucket Server PR comment.
+type bitbucketServerComment struct {
+	Text   string                 `json:"text"`
+	Anchor *bitbucketServerAnchor `json:"anchor,omitempty"`
+}
+
+// bitbucketServerAnchor pins a comment to a line of the "to" side of the diff.
+type bitbucketServerAnchor struct {
+	Path     string `json:"path"`
+	Line     int    `json:"line"`
+	LineType string `json:"lineType"` // ADDED, REMOVED or CONTEXT
+	FileType string `json:"fileType"` // FROM or TO
+	DiffType string `json:"diffType"`
+}
+
+// bitbucketCloudComment is the request body of a Bitbucket Cloud PR comment.
+type bitbucketCloudComment struct {
+	Content struct {
+		Raw string `json:"raw"`
+	} `json:"content"`
+	Inline *bitbucketCloudInline `json:"inline,omitempty"`
+}
+
+type bitbucketCloudInline struct {
+	Path string `json:"path"`
+	To   int    `json:"to"`
+}
+
+func (b *BitbucketClient) isCloud() bool {
+	return strings.Contains(b.BaseURL, "api.bitbucket.org")
+}
+
+// pullRequestPath returns the API path of a pull request for the flavor in use.
+func (b *BitbucketClient) pullRequestPath(owner, repo, id string) string {
+	if b.isCloud() {
+		return fmt.Sprintf("/repositories/%s/%s/pullrequests/%s", owner, repo, id)
+	}
+	return fmt.Sprintf("/rest/api/1.0/projects/%s/repos/%s/pull-requests/%s", owner, repo, id)
+}
+
+// FetchChanges downloads the raw diff of the pull request and splits it into
+// files with diff.Parse. The head commit is read from the pull request itself.
+func (b *BitbucketClient) FetchChanges(ctx context.Context, req models.PullRequestRequest) (*models.ChangeFiles, error) {
+	prPath := b.pullRequestPath(req.OwnerID, req.RepoID, req.ID)
+
+	diffPath := prPath + ".diff"
+	if b.isCloud() {
+		diffPath = prPath + "/diff"
+	}
+	rawDiff, err := b.get(ctx, diffPath, "text/plain")
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch PR diff from Bitbucket: %w", err)
+	}
+	changeFiles, err := diff.Parse(bytes.NewReader(rawDiff))
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse Bitbucket diff: %w", err)
+	}
+
+	prBody, err := b.get(ctx, prPath, "application/json")
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch PR from Bitbucket: %w", err)
+	}
+	var pr struct {
+		FromRef struct {
+			LatestCommit string `json:"latestCommit"`
+		} `json:"fromRef"`
+		Source struct {
+			Commit struct {
+				Hash string `json:"hash"`
+			} `json:"commit"`
+		} `json:"source"`
+	}
+	if err := json.Unmarshal(prBody, &pr); err != nil {
+		return nil, fmt.Errorf("failed to decode Bitbucket PR: %w", err)
+	}
+	changeFiles.HeadSHA = pr.FromRef.LatestCommit
+	if b.isCloud() {
+		changeFiles.HeadSHA = pr.Source.Commit.Hash
+	}
+	return changeFiles, nil
+}
+
+// PostInlineComment posts a comment anchored to the file and line of params.
+func (b *BitbucketClient) PostInlineComment(ctx context.Context, params models.GeneratePRCommentParams) (string, error) {
+	path := b.pullRequestPath(params.RepoOwner, params.RepoName, params.PRNumber) + "/comments"
+
+	var body interface{}
+	if b.isCloud() {
+		comment := bitbucketCloudComment{Inline: &bitbucketCloudInline{Path: params.FileName, To: params.Line}}
+		comment.Content.Raw = params.ProseBody()
+		body = comment
+	} else {
+		lineType := "ADDED"
+		if params.LineType == diff.LineContext {
+			lineType = "CONTEXT"
+		}
+		body = bitbucketServerComment{
+			Text: params.ProseBody(),
+			Anchor: &bitbucketServerAnchor{
+				Path:     params.FileName,
+				Line:     params.Line,
+				LineType: lineType,
+				FileType: "TO",
+				DiffType: "EFFECTIVE",
+			},
+		}
+	}
+
+	var created struct {
+		ID int64 `json:"id"`
+	}
+	if err := b.post(ctx, path, body, &created); err != nil {
+		return "", fmt.Errorf("error posting PR comment: %w", err)
+	}
+	return strconv.FormatInt(created.ID, 10), nil
+}
+
+// PostSummary posts a comment on the pull request without an anchor.
+func (b *BitbucketClient) PostSummary(ctx context.Context, req models.PullRequestRequest, text string) error {
+	path := b.pullRequestPath(req.OwnerID, req.RepoID, req.ID) + "/comments"
+
+	var body interface{} = bitbucketServerComment{Text: text}
+	if b.isCloud() {
+		comment := bitbucketCloudComment{}
+		comment.Content.Raw = text
+		body = comment
+	}
+	if err := b.post(ctx, path, body, nil); err != nil {
+		return fmt.Errorf("error posting PR summary: %w", err)
+	}
+	return nil
+}
+
+// UpsertSummary edits the token user's pull request comment holding marker
+// or posts a new one. The first 100 comments (Cloud) or activities (Server)
+// are searched.
+func (b *BitbucketClient) UpsertSummary(ctx context.Context, req models.PullRequestRequest, marker, text string) error {
+	prPath := b.pullRequestPath(req.OwnerID, req.RepoID, req.ID)
+	bot, err := b.authenticatedUser(ctx)
+	if err != nil {
+		return err
+	}
+
+	if b.isCloud() {
+		raw, err := b.get(ctx, prPath+"/comments?pagelen=100", "application/json")
+		if err != nil {
+			return fmt.Errorf("error listing PR comments: %w", err)
+		}
+		var page struct {
+			Values []struct {
+				ID      int64 `json:"id"`
+				Content struct {
+					Raw string `json:"raw"`
+				} `json:"content"`
+				User struct {
+					UUID string `json:"uuid"`
+				} `json:"user"`
+			} `json:"values"`
+		}
+		if err := json.Unmarshal(raw, &page); err != nil {
+			return fmt.Errorf("failed to decode Bitbucket comments: %w", err)
+		}
+		for _, comment := range page.Values {
+			if comment.User.UUID == bot && strings.Contains(comment.Content.Raw, marker) {
+				edit := bitbucketCloudComment{}
+				edit.Content.Raw = text
+				if err := b.send(ctx, "PUT", fmt.Sprintf("%s/comments/%d", prPath, comment.ID), edit, nil); err != nil {
+					return fmt.Errorf("error editing PR comment: %w", err)
+				}
+				return nil
+			}
+		}
+		return b.PostSummary(ctx, req, text)
+	}
+
+	raw, err := b.get(ctx, prPath+"/activities?limit=100", "application/json")
+	if err != nil {
+		return fmt.Errorf("error listing PR activities: %w", err)
+	}
+	var page struct {
+		Values []struct {
+			Action  string `json:"action"`
+			Comment struct {
+				ID      int64  `json:"id"`
+				Version int    `json:"version"`
+				Text    string `json:"text"`
+				Author  struct {
+					Name string `json:"name"`
+				} `json:"author"`
+			} `json:"comment"`
+		} `json:"values"`
+	}
+	if err := json.Unmarshal(raw, &page); err != nil {
+		return fmt.Errorf("failed to decode Bitbucket activities: %w", err)
+	}
+	for _, activity := range page.Values {
+		if activity.Action == "COMMENTED" && strings.EqualFold(activity.Comment.Author.Name, bot) && strings.Contains(activity.Comment.Text, marker) {
+			// Bitbucket Server rejects edits that do not name the current version
+			edit := map[string]interface{}{"text": text, "version": activity.Comment.Version}
+			if err := b.send(ctx, "PUT", fmt.Sprintf("%s/comments/%d", prPath, activity.Comment.ID), edit, nil); err != nil {
+				return fmt.Errorf("error editing PR comment: %w", err)
+			}
+			return nil
+		}
+	}
+	return b.PostSummary(ctx, req, text)
+}
+
+// authenticatedUser identifies the token's user: its UUID on Cloud, its
+// user name on Server, which only reports it through the whoami servlet.
+func (b *BitbucketClient) authenticatedUser(ctx context.Context) (string, error) {
+	return b.identity.get(func() (string, error) {
+		if !b.isCloud() {
+			raw, err := b.get(ctx, "/plugins/servlet/applinks/whoami", "text/plain")
+			if err != nil {
+				return "", fmt.Errorf("failed to look up the token's user: %w", err)
+			}
+			name := strings.TrimSpace(string(raw))
+			if name == "" {
+				return "", fmt.Errorf("failed to look up the token's user: anonymous access")
+			}
+			return name, nil
+		}
+		raw, err := b.get(ctx, "/user", "application/json")
+		if err != nil {
+			return "", fmt.Errorf("failed to look up the token's user: %w", err)
+		}
+		var user struct {
+			UUID string `json:"uuid"`
+		}
+		if err := json.Unmarshal(raw, &user); err != nil {
+			return "", fmt.Errorf("failed to decode the token's user: %w", err)
+		}
+		if user.UUID == "" {
+			return "", fmt.Errorf("the token's user has no uuid")
+		}
+		return user.UUID, nil
+	})
+}
+
+// SetStatus reports a build status on the commit.
+func (b *BitbucketClient) SetStatus(ctx context.Context, req models.PullRequestRequest, sha string, status models.CommitStatus) error {
+	state := "FAILED"
+	switch status.State {
+	case models.StatusPending:
+		state = "INPROGRESS"
+	case models.StatusSuccess:
+		state = "SUCCESSFUL"
+	}
+
+	path := fmt.Sprintf("/rest/build-status/1.0/commits/%s", sha)
+	if b.isCloud() {
+		path = fmt.Sprintf("/repositories/%s/%s/commit/%s/statuses/build", req.OwnerID, req.RepoID, sha)
+	}
+	body := map[string]string{
+		"state":       state,
+		"key":         status.Context,
+		"name":        status.Context,
+		"description": status.Description,
+		"url":         b.BaseURL + b.pullRequestPath(req.OwnerID, req.RepoID, req.ID),
+	}
+	if err := b.post(ctx, path, body, nil); err != nil {
+		return fmt.Errorf("error setting build status: %w", err)
+	}
+	return nil
+}
+
+func (b *BitbucketClient) get(ctx context.Context, path, accept string) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", b.BaseURL+path, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create HTTP request: %w", err)
+	}
+	req.Header.Set("Authorization", "Bearer "+b.APIKey)
+	req.Header.Set("Accept", accept)
+
+	resp, err := b.HttpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("Bitbucket request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("received non-OK response from Bitbucket: %s", resp.Status)
+	}
+	return io.ReadAll(resp.Body)
+}
+
+// post sends body as JSON and accepts any 2xx response: Bitbucket Server
+// answers 201 for comments and 204 for build statuses. The response is
+// decoded into out when it is not nil.
+func (b *BitbucketClient) post(ctx context.Context, path string, body, out interface{}) error {
+	return b.send(ctx, "POST", path, body, out)
+}
+
+// send is post for any method.
+func (b *BitbucketClient) send(ctx context.Context, method, path string, body, out interface{}) error {
+	jsonData, err := json.Marshal(body)
+	if err != nil {
+		return fmt.Errorf("failed to marshal request body: %w", err)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, b.BaseURL+path, bytes.NewBuffer(jsonData))
+	if err != nil {
+		return fmt.Errorf("failed to create HTTP request: %w", err)
+	}
+	req.Header.Set("Authorization", "Bearer "+b.APIKey)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := b.HttpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("Bitbucket request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("received unexpected response from Bitbucket: %s", resp.Status)
+	}
+	if out == nil {
+		return nil
+	}
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return fmt.Errorf("failed to decode Bitbucket response: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,234 @@
+package clients
+
+import (
+	"ai-api/diff"
+	"ai-api/models"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// bitbucketServer serves recorded Bitbucket responses from
+// testdata/bitbucket by "METHOD path" and records the bodies posted.
+type bitbucketServer struct {
+	t      *testing.T
+	routes map[string]string // "METHOD path" -> testdata file or literal JSON
+	posted map[string][]map[string]interface{}
+}
+
+func (s *bitbucketServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	key := r.Method + " " + r.URL.Path
+	if r.Method != http.MethodGet {
+		var body map[string]interface{}
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			s.t.Errorf("%s: body is not JSON: %v", key, err)
+		}
+		s.posted[key] = append(s.posted[key], body)
+	}
+	if r.Header.Get("Authorization") != "Bearer bb-token" {
+		s.t.Errorf("%s: Authorization = %q", key, r.Header.Get("Authorization"))
+	}
+	response, ok := s.routes[key]
+	if !ok {
+		s.t.Errorf("unexpected request %s", key)
+		http.NotFound(w, r)
+		return
+	}
+	if filepath.Ext(response) == ".json" || filepath.Ext(response) == ".diff" {
+		data, err := os.ReadFile(filepath.Join("testdata", "bitbucket", response))
+		if err != nil {
+			s.t.Fatal(err)
+		}
+		response = string(data)
+	}
+	if r.Method == http.MethodPost {
+		w.WriteHeader(http.StatusCreated)
+	}
+	io.WriteString(w, response)
+}
+
+// hostTransport sends every request to target, whatever its host, so the
+// client under test keeps the Bitbucket Cloud base URL.
+type hostTransport struct{ target *url.URL }
+
+func (h hostTransport) RoundTrip(r *http.Request) (*http.Response, error) {
+	r = r.Clone(r.Context())
+	r.URL.Scheme, r.URL.Host = h.target.Scheme, h.target.Host
+	return http.DefaultTransport.RoundTrip(r)
+}
+
+// newBitbucketTestClient starts a server with routes and returns a Cloud
+// client (base URL api.bitbucket.org) or a Server one pointing at it.
+func newBitbucketTestClient(t *testing.T, cloud bool, routes map[string]string) (*BitbucketClient, *bitbucketServer) {
+	t.Helper()
+	handler := &bitbucketServer{t: t, routes: routes, posted: map[string][]map[string]interface{}{}}
+	srv := httptest.NewServer(handler)
+	t.Cleanup(srv.Close)
+	if !cloud {
+		return NewBitbucketClient(srv.Client(), "bb-token", srv.URL+"/"), handler
+	}
+	target, _ := url.Parse(srv.URL)
+	return NewBitbucketClient(&http.Client{Transport: hostTransport{target}}, "bb-token", ""), handler
+}
+
+func TestBitbucketFetchChanges(t *testing.T) {
+	tests := []struct {
+		name      string
+		cloud     bool
+		routes    map[string]string
+		owner     string
+		wantHead  string
+		wantFiles map[string]string // filename -> status
+	}{
+		{
+			name:  "server",
+			owner: "ACME",
+			routes: map[string]string{
+				"GET /rest/api/1.0/projects/ACME/repos/api/pull-requests/5.diff": "server-pull-request.diff",
+				"GET /rest/api/1.0/projects/ACME/repos/api/pull-requests/5":      "server-pull-request.json",
+			},
+			wantHead: "9f8e7d6c5b4a39281706f5e4d3c2b1a098765432",
+			wantFiles: map[string]string{
+				"store/cache.go":   "modified",
+				"store/legacy.go":  "removed",
+				"store/metrics.go": "added",
+			},
+		},
+		{
+			name:  "cloud",
+			cloud: true,
+			owner: "acme",
+			routes: map[string]string{
+				"GET /2.0/repositories/acme/api/pullrequests/5/diff": "cloud-pull-request.diff",
+				"GET /2.0/repositories/acme/api/pullrequests/5":      "cloud-pull-request.json",
+			},
+			wantHead: "9f8e7d6c5b4a",
+			wantFiles: map[string]string{
+				"store/cache.go":    "modified",
+				"store/new_name.go": "renamed",
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client, _ := newBitbucketTestClient(t, tt.cloud, tt.routes)
+			changes, err := client.FetchChanges(context.Background(), models.PullRequestRequest{OwnerID: tt.owner, RepoID: "api", ID: "5"})
+			if err != nil {
+				t.Fatalf("FetchChanges() error = %v", err)
+			}
+			if changes.HeadSHA != tt.wantHead {
+				t.Errorf("HeadSHA = %q, want %q", changes.HeadSHA, tt.wantHead)
+			}
+			if len(changes.Files) != len(tt.wantFiles) {
+				t.Fatalf("got %d files, want %d", len(changes.Files), len(tt.wantFiles))
+			}
+			for _, file := range changes.Files {
+				if status, ok := tt.wantFiles[file.Filename]; !ok || status != file.Status {
+					t.Errorf("file %q status %q, want %q", file.Filename, file.Status, status)
+				}
+			}
+			cache := changes.Files[0]
+			if cache.Additions != 3 || cache.Deletions != 0 {
+				t.Errorf("cache.go +%d -%d, want +3 -0", cache.Additions, cache.Deletions)
+			}
+			// the mutex field is the 6th line of the new file
+			if pos := diff.PositionForLine(cache.Patch, 6); diff.LineForPosition(cache.Patch, pos) != 6 {
+				t.Errorf("line 6 of cache.go is not anchored in %q", cache.Patch)
+			}
+		})
+	}
+}
+
+func TestBitbucketPostInlineComment(t *testing.T) {
+	params := models.GeneratePRCommentParams{
+		RepoOwner: "acme", RepoName: "api", PRNumber: "5",
+		FileName: "store/cache.go", Line: 6, LineType: diff.LineAdded,
+		CommentBody: "Document what mu guards.",
+	}
+
+	t.Run("cloud", func(t *testing.T) {
+		path := "/2.0/repositories/acme/api/pullrequests/5/comments"
+		client, srv := newBitbucketTestClient(t, true, map[string]string{"POST " + path: `{"id": 301}`})
+		id, err := client.PostInlineComment(context.Background(), params)
+		if err != nil || id != "301" {
+			t.Fatalf("PostInlineComment() = %q, %v", id, err)
+		}
+		body := srv.posted["POST "+path][0]
+		inline, _ := body["inline"].(map[string]interface{})
+		if inline["path"] != "store/cache.go" || inline["to"] != float64(6) {
+			t.Errorf("inline = %v, want path store/cache.go and to 6", inline)
+		}
+		if _, ok := body["anchor"]; ok {
+			t.Error("cloud comment carries a Server anchor")
+		}
+		content, _ := body["content"].(map[string]interface{})
+		if content["raw"] != params.CommentBody {
+			t.Errorf("content.raw = %v", content["raw"])
+		}
+	})
+
+	tests := []struct {
+		lineType, want string
+	}{
+		{diff.LineAdded, "ADDED"},
+		{diff.LineContext, "CONTEXT"},
+	}
+	for _, tt := range tests {
+		t.Run("server "+tt.lineType, func(t *testing.T) {
+			path := "/rest/api/1.0/projects/acme/repos/api/pull-requests/5/comments"
+			client, srv := newBitbucketTestClient(t, false, map[string]string{"POST " + path: `{"id": 42, "version": 0}`})
+			p := params
+			p.LineType = tt.lineType
+			id, err := client.PostInlineComment(context.Background(), p)
+			if err != nil || id != "42" {
+				t.Fatalf("PostInlineComment() = %q, %v", id, err)
+			}
+			body := srv.posted["POST "+path][0]
+			anchor, _ := body["anchor"].(map[string]interface{})
+			want := map[string]interface{}{
+				"path":     "store/cache.go",
+				"line":     float64(6),
+				"lineType": tt.want,
+				"fileType": "TO",
+				"diffType": "EFFECTIVE",
+			}
+			for key, value := range want {
+				if anchor[key] != value {
+					t.Errorf("anchor.%s = %v, want %v", key, anchor[key], value)
+				}
+			}
+			if body["text"] != params.CommentBody {
+				t.Errorf("text = %v", body["text"])
+			}
+		})
+	}
+}
+
+func TestBitbucketSetStatus(t *testing.T) {
+	tests := []struct {
+		cloud       bool
+		path, state string
+		status      string
+	}{
+		{false, "/rest/build-status/1.0/commits/abc", models.StatusPending, "INPROGRESS"},
+		{false, "/rest/build-status/1.0/commits/abc", models.StatusSuccess, "SUCCESSFUL"},
+		{true, "/2.0/repositories/acme/api/commit/abc/statuses/build", models.StatusFailure, "FAILED"},
+	}
+	for _, tt := range tests {
+		client, srv := newBitbucketTestClient(t, tt.cloud, map[string]string{"POST " + tt.path: `{}`})
+		err := client.SetStatus(context.Background(), models.PullRequestRequest{OwnerID: "acme", RepoID: "api", ID: "5"}, "abc",
+			models.CommitStatus{State: tt.state, Context: "pr-checker", Description: "review"})
+		if err != nil {
+			t.Fatalf("SetStatus() error = %v", err)
+		}
+		if got := srv.posted["POST "+tt.path][0]["state"]; got != tt.status {
+			t.Errorf("SetStatus(%s) state = %v, want %s", tt.state, got, tt.status)
+		}
+	}
+}
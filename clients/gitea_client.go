@@ -0,0 +1,142 @@
+package clients
+
+import (
+	"ai-api/diff"
+	"ai-api/models"
+	"bytes"
+	"context"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// GiteaClient implements SCMProvider for Gitea and Forgejo. Their API is
+// close enough to GitHub's that requests are built and sent by an embedded
+// GithubClient; only the endpoints that differ (diffs and review
+// submission) are handled here.
+type GiteaClient struct {
+	api *GithubClient
+}
+
+// NewGiteaClient creates a client of the Gitea API at baseUrl, the API
+// root, e.g. https://gitea.internal/api/v1. Gitea has no public instance to
+// default to, and the embedded GithubClient would send apiKey to
+// api.github.com, so baseUrl is required.
+func NewGiteaClient(httpClient *http.Client, apiKey, baseUrl string) (*GiteaClient, error) {
+	if baseUrl == "" {
+		return nil, fmt.Errorf("gitea base url is required")
+	}
+	return &GiteaClient{
+		api: NewGithubClient(httpClient, apiKey, strings.TrimSuffix(baseUrl, "/")),
+	}, nil
+}
+
+// giteaReview is the request body of POST /repos/{owner}/{repo}/pulls/{index}/reviews
+type giteaReview struct {
+	CommitID string               `json:"commit_id"`
+	Event    string               `json:"event"`
+	Body     string               `json:"body"`
+	Comments []giteaReviewComment `json:"comments"`
+}
+
+// giteaReviewComment is an inline comment of a review. Gitea positions are
+// line numbers in the new file rather than GitHub diff positions.
+type giteaReviewComment struct {
+	Path        string `json:"path"`
+	Body        string `json:"body"`
+	NewPosition int    `json:"new_position"`
+}
+
+func (g *GiteaClient) repoURL(owner, repo string) string {
+	return fmt.Sprintf("%s/repos/%s/%s", g.api.BaseURL, owner, repo)
+}
+
+// FetchChanges downloads the pull request's unified diff, which Gitea serves
+// instead of per-file patches, and reads the head commit from the pull request.
+func (g *GiteaClient) FetchChanges(ctx context.Context, req models.PullRequestRequest) (*models.ChangeFiles, error) {
+	prURL := fmt.Sprintf("%s/pulls/%s", g.repoURL(req.OwnerID, req.RepoID), req.ID)
+
+	diffReq, err := g.api.newRequest(ctx, "GET", prURL+".diff", nil)
+	if err != nil {
+		return nil, err
+	}
+	diffReq.Header.Set("Accept", "text/plain")
+	rawDiff, err := g.api.doRaw(diffReq, http.StatusOK)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch PR diff from Gitea: %w", err)
+	}
+	changeFiles, err := diff.Parse(bytes.NewReader(rawDiff))
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse Gitea diff: %w", err)
+	}
+
+	prReq, err := g.api.newRequest(ctx, "GET", prURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	var pr struct {
+		Head models.Branch `json:"head"`
+	}
+	if err := g.api.do(prReq, http.StatusOK, &pr); err != nil {
+		return nil, fmt.Errorf("failed to fetch PR from Gitea: %w", err)
+	}
+	changeFiles.HeadSHA = pr.Head.SHA
+	return changeFiles, nil
+}
+
+// PostInlineComment submits a COMMENT review holding a single inline comment.
+func (g *GiteaClient) PostInlineComment(ctx context.Context, params models.GeneratePRCommentParams) (string, error) {
+	review := giteaReview{
+		CommitID: params.CommitSha,
+		Event:    "COMMENT",
+		Comments: []giteaReviewComment{{
+			Path:        params.FileName,
+			Body:        params.ProseBody(),
+			NewPosition: params.Line,
+		}},
+	}
+	url := fmt.Sprintf("%s/pulls/%s/reviews", g.repoURL(params.RepoOwner, params.RepoName), params.PRNumber)
+	req, err := g.api.newRequest(ctx, "POST", url, review)
+	if err != nil {
+		return "", err
+	}
+	var created struct {
+		ID int64 `json:"id"`
+	}
+	if err := g.api.do(req, http.StatusOK, &created); err != nil {
+		return "", fmt.Errorf("error posting PR review: %w", err)
+	}
+	return strconv.FormatInt(created.ID, 10), nil
+}
+
+// PostSummary posts an issue comment on the pull request.
+func (g *GiteaClient) PostSummary(ctx context.Context, req models.PullRequestRequest, body string) error {
+	url := fmt.Sprintf("%s/issues/%s/comments", g.repoURL(req.OwnerID, req.RepoID), req.ID)
+	if err := g.api.postJSON(ctx, url, map[string]string{"body": body}); err != nil {
+		return fmt.Errorf("error posting PR summary: %w", err)
+	}
+	return nil
+}
+
+// UpsertSummary edits the token user's issue comment holding marker or posts
+// a new one. Gitea returns every comment of an issue at once.
+func (g *GiteaClient) UpsertSummary(ctx context.Context, req models.PullRequestRequest, marker, body string) error {
+	bot, err := g.api.authenticatedLogin(ctx)
+	if err != nil {
+		return fmt.Errorf("error posting PR summary: %w", err)
+	}
+	if err := g.api.upsertIssueComment(ctx, req, bot, marker, body, 1); err != nil {
+		return fmt.Errorf("error posting PR summary: %w", err)
+	}
+	return nil
+}
+
+// SetStatus sets a commit status; Gitea accepts GitHub's states.
+func (g *GiteaClient) SetStatus(ctx context.Context, req models.PullRequestRequest, sha string, status models.CommitStatus) error {
+	url := fmt.Sprintf("%s/statuses/%s", g.repoURL(req.OwnerID, req.RepoID), sha)
+	if err := g.api.postJSON(ctx, url, status); err != nil {
+		return fmt.Errorf("error setting commit status: %w", err)
+	}
+	return nil
+}
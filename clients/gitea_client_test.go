@@ -0,0 +1,84 @@
+package clients
+
+import (
+	"ai-api/models"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestNewGiteaClient(t *testing.T) {
+	tests := []struct {
+		name, token, baseURL string
+		wantErr              bool
+		wantBase             string
+	}{
+		{"token without base url", "gitea-token", "", true, ""},
+		{"no configuration", "", "", true, ""},
+		{"base url", "gitea-token", "https://gitea.internal/api/v1/", false, "https://gitea.internal/api/v1"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client, err := NewGiteaClient(http.DefaultClient, tt.token, tt.baseURL)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("NewGiteaClient() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err == nil && client.api.BaseURL != tt.wantBase {
+				t.Errorf("BaseURL = %q, want %q", client.api.BaseURL, tt.wantBase)
+			}
+		})
+	}
+}
+
+func TestGiteaFetchChangesAndComment(t *testing.T) {
+	var review giteaReview
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if got := r.Header.Get("Authorization"); got != "token gitea-token" {
+			t.Errorf("%s %s: Authorization = %q", r.Method, r.URL.Path, got)
+		}
+		switch r.Method + " " + r.URL.Path {
+		case "GET /api/v1/repos/platform/api/pulls/3.diff":
+			w.Write([]byte("diff --git a/cache.go b/cache.go\n--- a/cache.go\n+++ b/cache.go\n@@ -1 +1,2 @@\n package store\n+var hits int\n"))
+		case "GET /api/v1/repos/platform/api/pulls/3":
+			respondJSON(http.StatusOK, `{"head": {"sha": "abc123"}}`)(w, r)
+		case "POST /api/v1/repos/platform/api/pulls/3/reviews":
+			if err := json.NewDecoder(r.Body).Decode(&review); err != nil {
+				t.Errorf("review body: %v", err)
+			}
+			respondJSON(http.StatusOK, `{"id": 7}`)(w, r)
+		default:
+			t.Errorf("unexpected request %s %s", r.Method, r.URL.Path)
+			http.NotFound(w, r)
+		}
+	}))
+	defer srv.Close()
+
+	client, err := NewGiteaClient(srv.Client(), "gitea-token", srv.URL+"/api/v1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	pr := models.PullRequestRequest{OwnerID: "platform", RepoID: "api", ID: "3", Provider: models.ProviderGitea}
+	changes, err := client.FetchChanges(context.Background(), pr)
+	if err != nil {
+		t.Fatalf("FetchChanges() error = %v", err)
+	}
+	if changes.HeadSHA != "abc123" || len(changes.Files) != 1 || changes.Files[0].Filename != "cache.go" {
+		t.Errorf("FetchChanges() = head %q, files %+v", changes.HeadSHA, changes.Files)
+	}
+
+	id, err := client.PostInlineComment(context.Background(), models.GeneratePRCommentParams{
+		RepoOwner: "platform", RepoName: "api", PRNumber: "3", CommitSha: "abc123",
+		FileName: "cache.go", Line: 2, CommentBody: "Name the counter for what it counts.",
+	})
+	if err != nil || id != "7" {
+		t.Fatalf("PostInlineComment() = %q, %v", id, err)
+	}
+	if review.Event != "COMMENT" || review.CommitID != "abc123" || len(review.Comments) != 1 {
+		t.Fatalf("review = %+v", review)
+	}
+	if c := review.Comments[0]; c.Path != "cache.go" || c.NewPosition != 2 {
+		t.Errorf("review comment = %+v, want cache.go at new_position 2", c)
+	}
+}
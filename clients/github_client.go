@@ -3,9 +3,18 @@ package clients
 import (
 	"ai-api/models"
 	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/base64"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
 	"net/http"
+	neturl "net/url"
+	"strconv"
+	"strings"
+	"time"
 )
 
 // Concrete implementation
@@ -13,30 +22,117 @@ type GithubClient struct {
 	HttpClient *http.Client
 	APIKey     string
 	BaseURL    string
+	// GraphQLURL is derived from BaseURL, see NewGithubClient.
+	GraphQLURL string
+	// BotLogin is the login the client posts as, required for GitHub App
+	// tokens; when empty it is the token's user, looked up once.
+	BotLogin string
+
+	identity *botIdentity
 }
 
 type GithubClientInterface interface {
 	FetchPullRequestChanges(prRequestBody models.PullRequestRequest) (*models.ChangeFiles, error)
-	PostPullRequestCommentOnLine(params models.GeneratePRCommentParams) (results []models.CommentBody, err error)
 }
 
+// NewGithubClient creates a client for github.com or, when baseUrl is set to
+// something like https://ghe.internal/api/v3, for GitHub Enterprise Server.
+// The GraphQL endpoint follows the layout of the host.
 func NewGithubClient(httpClient *http.Client, apiKey, baseUrl string) *GithubClient {
+	baseUrl = strings.TrimSuffix(baseUrl, "/")
+	if baseUrl == "" {
+		baseUrl = defaultGithubBaseURL
+	}
+
+	graphQLURL := defaultGithubBaseURL + "/graphql"
+	if root, ok := strings.CutSuffix(baseUrl, "/api/v3"); ok {
+		// GitHub Enterprise Server
+		graphQLURL = root + "/api/graphql"
+	} else if baseUrl != defaultGithubBaseURL {
+		graphQLURL = baseUrl + "/graphql"
+	}
+
 	return &GithubClient{
 		HttpClient: httpClient,
 		APIKey:     apiKey,
 		BaseURL:    baseUrl,
+		GraphQLURL: graphQLURL,
+		identity:   &botIdentity{},
 	}
 }
 
+const defaultGithubBaseURL = "https://api.github.com"
+
+// API paths, relative to BaseURL
 const (
-	githubFetchPRChangesURL = "https://api.github.com/repos/%s/%s/pulls/%s/files"
-	githubPostPRCommentURL  = "https://api.github.com/repos/%s/%s/pulls/%s/comments" // github treats prs as issues for comments!
+	githubFetchPRChangesURL   = "/repos/%s/%s/pulls/%s/files"
+	githubPostPRCommentURL    = "/repos/%s/%s/pulls/%s/comments" // github treats prs as issues for comments!
+	githubIssueCommentURL     = "/repos/%s/%s/issues/%s/comments"
+	githubEditCommentURL      = "/repos/%s/%s/issues/comments/%s"
+	githubCommitStatusURL     = "/repos/%s/%s/statuses/%s"
+	githubPullRequestURL      = "/repos/%s/%s/pulls/%s"
+	githubContentsURL         = "/repos/%s/%s/contents/%s?ref=%s"
+	githubCommentReactionsURL = "/repos/%s/%s/pulls/comments/%s/reactions?per_page=100"
+	githubTarballURL          = "/repos/%s/%s/tarball/%s"
+	githubCodeScanningURL     = "/repos/%s/%s/code-scanning/sarifs"
+	githubCommentReplyURL     = "/repos/%s/%s/pulls/%s/comments/%s/replies"
+	githubPermissionURL       = "/repos/%s/%s/collaborators/%s/permission"
+	githubIssueReactionURL    = "/repos/%s/%s/issues/comments/%s/reactions"
+	githubReviewReactionURL   = "/repos/%s/%s/pulls/comments/%s/reactions"
+	githubUserURL             = "/user"
 )
 
+// ErrNotFound is returned when the requested GitHub resource does not exist.
+var ErrNotFound = errors.New("not found")
+
+// RateLimitError is returned when GitHub refuses a request because the
+// primary or a secondary rate limit was hit.
+type RateLimitError struct {
+	Host   string
+	Status string
+	// RetryAfter is how long GitHub asks to wait, 0 when it does not say.
+	RetryAfter time.Duration
+}
+
+func (e *RateLimitError) Error() string {
+	return fmt.Sprintf("rate limited by %s: %s, retry after %s", e.Host, e.Status, e.RetryAfter)
+}
+
+// rateLimitError returns the RateLimitError of resp, or nil when resp is
+// not a rate limit answer: a 429, or a 403 with the primary limit used up
+// or a Retry-After header (secondary limits).
+func rateLimitError(resp *http.Response, now time.Time) *RateLimitError {
+	if resp.StatusCode != http.StatusTooManyRequests && resp.StatusCode != http.StatusForbidden {
+		return nil
+	}
+	retryAfter := resp.Header.Get("Retry-After")
+	exhausted := resp.Header.Get("X-RateLimit-Remaining") == "0"
+	if resp.StatusCode == http.StatusForbidden && retryAfter == "" && !exhausted {
+		return nil
+	}
+	err := &RateLimitError{Host: resp.Request.URL.Host, Status: resp.Status}
+	if seconds, convErr := strconv.Atoi(retryAfter); convErr == nil {
+		err.RetryAfter = time.Duration(seconds) * time.Second
+	} else if reset, convErr := strconv.ParseInt(resp.Header.Get("X-RateLimit-Reset"), 10, 64); convErr == nil && exhausted {
+		err.RetryAfter = max(time.Unix(reset, 0).Sub(now), 0)
+	}
+	return err
+}
+
+// apiURL builds a URL on the configured API host from one of the paths above.
+func (g *GithubClient) apiURL(path string, args ...interface{}) string {
+	return g.BaseURL + fmt.Sprintf(path, args...)
+}
+
 func (g *GithubClient) FetchPullRequestChanges(prRequestBody models.PullRequestRequest) (*models.ChangeFiles, error) {
+	return g.FetchChanges(context.Background(), prRequestBody)
+}
+
+// FetchChanges implements SCMProvider.
+func (g *GithubClient) FetchChanges(ctx context.Context, prRequestBody models.PullRequestRequest) (*models.ChangeFiles, error) {
 	// Create a new HTTP request
-	url := fmt.Sprintf(githubFetchPRChangesURL, prRequestBody.OwnerID, prRequestBody.RepoID, prRequestBody.ID)
-	req, err := http.NewRequest("GET", url, nil)
+	url := g.apiURL(githubFetchPRChangesURL, prRequestBody.OwnerID, prRequestBody.RepoID, prRequestBody.ID)
+	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create HTTP request: %w", err)
 	}
@@ -65,39 +161,521 @@ func (g *GithubClient) FetchPullRequestChanges(prRequestBody models.PullRequestR
 	return &prResponse, nil
 }
 
-func (g *GithubClient) PostPullRequestCommentOnLine(params models.GeneratePRCommentParams) (results []models.CommentBody, err error) {
-
-	url := fmt.Sprintf(githubPostPRCommentURL, params.RepoOwner, params.RepoName, params.PRNumber)
-	prReviewCommentRequestBody := models.CommentBody{
+// PostInlineComment implements SCMProvider.
+func (g *GithubClient) PostInlineComment(ctx context.Context, params models.GeneratePRCommentParams) (string, error) {
+	url := g.apiURL(githubPostPRCommentURL, params.RepoOwner, params.RepoName, params.PRNumber)
+	var body interface{} = models.CommentBody{
 		Body:     params.CommentBody,
 		CommitID: params.CommitSha,
 		Path:     params.FileName,
 		Position: params.Position,
 	}
-	jsonData, err := json.Marshal(prReviewCommentRequestBody)
+	if params.Suggestion != "" {
+		body = suggestionComment(params)
+	}
+	req, err := g.newRequest(ctx, "POST", url, body)
+	if err != nil {
+		return "", err
+	}
+	var comment struct {
+		ID int64 `json:"id"`
+	}
+	if err := g.do(req, http.StatusCreated, &comment); err != nil {
+		return "", fmt.Errorf("error posting PR comment: %w", err)
+	}
+	return strconv.FormatInt(comment.ID, 10), nil
+}
+
+// githubLineComment is a review comment anchored by line rather than diff
+// position, which multi-line comments require.
+type githubLineComment struct {
+	Body      string `json:"body"`
+	CommitID  string `json:"commit_id"`
+	Path      string `json:"path"`
+	Line      int    `json:"line"`
+	Side      string `json:"side"`
+	StartLine int    `json:"start_line,omitempty"`
+	StartSide string `json:"start_side,omitempty"`
+}
+
+// suggestionComment anchors a comment holding a suggestion block to the
+// lines it replaces on the RIGHT (new) side of the diff.
+func suggestionComment(params models.GeneratePRCommentParams) githubLineComment {
+	comment := githubLineComment{
+		Body:     params.SuggestionBody(),
+		CommitID: params.CommitSha,
+		Path:     params.FileName,
+		Line:     params.Line,
+		Side:     "RIGHT",
+	}
+	if params.StartLine > 0 && params.StartLine < params.Line {
+		comment.StartLine = params.StartLine
+		comment.StartSide = "RIGHT"
+	}
+	return comment
+}
+
+// PostSummary implements SCMProvider by posting an issue comment on the PR.
+func (g *GithubClient) PostSummary(ctx context.Context, req models.PullRequestRequest, body string) error {
+	url := g.apiURL(githubIssueCommentURL, req.OwnerID, req.RepoID, req.ID)
+	return g.postJSON(ctx, url, map[string]string{"body": body})
+}
+
+// UpsertSummary implements SCMProvider by editing the issue comment holding
+// marker or posting a new one.
+func (g *GithubClient) UpsertSummary(ctx context.Context, req models.PullRequestRequest, marker, body string) error {
+	bot := g.BotLogin
+	if bot == "" {
+		var err error
+		if bot, err = g.authenticatedLogin(ctx); err != nil {
+			return err
+		}
+	}
+	return g.upsertIssueComment(ctx, req, bot, marker, body, githubMaxCommentPages)
+}
+
+// authenticatedLogin returns the login of the token's user. GitHub App
+// installation tokens have no user; their clients need BotLogin.
+func (g *GithubClient) authenticatedLogin(ctx context.Context) (string, error) {
+	return g.identity.get(func() (string, error) {
+		req, err := g.newRequest(ctx, "GET", g.apiURL(githubUserURL), nil)
+		if err != nil {
+			return "", err
+		}
+		var user models.User
+		if err := g.do(req, http.StatusOK, &user); err != nil {
+			return "", fmt.Errorf("failed to look up the token's user (GitHub App tokens need a bot login): %w", err)
+		}
+		if user.Login == "" {
+			return "", fmt.Errorf("the authenticated user has no login")
+		}
+		return user.Login, nil
+	})
+}
+
+// githubMaxCommentPages bounds the pages of issue comments searched for a marker.
+const githubMaxCommentPages = 30
+
+// upsertIssueComment searches up to pages pages of 100 issue comments for
+// one by bot holding marker and edits it, or posts a new one. Comments by
+// anyone else are never edited, even when they quote the marker. Gitea
+// shares the endpoints but does not paginate them, so it passes a single
+// page.
+func (g *GithubClient) upsertIssueComment(ctx context.Context, req models.PullRequestRequest, bot, marker, body string, pages int) error {
+	listURL := g.apiURL(githubIssueCommentURL, req.OwnerID, req.RepoID, req.ID)
+	for page := 1; page <= pages; page++ {
+		httpReq, err := g.newRequest(ctx, "GET", fmt.Sprintf("%s?per_page=100&page=%d", listURL, page), nil)
+		if err != nil {
+			return err
+		}
+		var comments []models.IssueComment
+		if err := g.do(httpReq, http.StatusOK, &comments); err != nil {
+			return fmt.Errorf("failed to list PR comments: %w", err)
+		}
+		for _, comment := range comments {
+			if !strings.EqualFold(comment.User.Login, bot) || !strings.Contains(comment.Body, marker) {
+				continue
+			}
+			editURL := g.apiURL(githubEditCommentURL, req.OwnerID, req.RepoID, strconv.FormatInt(comment.ID, 10))
+			editReq, err := g.newRequest(ctx, "PATCH", editURL, map[string]string{"body": body})
+			if err != nil {
+				return err
+			}
+			if err := g.do(editReq, http.StatusOK, nil); err != nil {
+				return fmt.Errorf("failed to edit PR comment: %w", err)
+			}
+			return nil
+		}
+		if len(comments) < 100 {
+			break
+		}
+	}
+	return g.postJSON(ctx, listURL, map[string]string{"body": body})
+}
+
+// SetStatus implements SCMProvider using the commit statuses API.
+func (g *GithubClient) SetStatus(ctx context.Context, req models.PullRequestRequest, sha string, status models.CommitStatus) error {
+	url := g.apiURL(githubCommitStatusURL, req.OwnerID, req.RepoID, sha)
+	return g.postJSON(ctx, url, status)
+}
+
+// FetchCommentReactions counts the reactions on a pull request review
+// comment by content ("+1", "-1", "laugh", "confused", "heart", "hooray",
+// "rocket", "eyes").
+func (g *GithubClient) FetchCommentReactions(ctx context.Context, owner, repo, commentID string) (map[string]int, error) {
+	req, err := g.newRequest(ctx, "GET", g.apiURL(githubCommentReactionsURL, owner, repo, commentID), nil)
+	if err != nil {
+		return nil, err
+	}
+	var reactions []struct {
+		Content string `json:"content"`
+	}
+	if err := g.do(req, http.StatusOK, &reactions); err != nil {
+		return nil, fmt.Errorf("failed to fetch comment reactions from GitHub: %w", err)
+	}
+	counts := map[string]int{}
+	for _, reaction := range reactions {
+		counts[reaction.Content]++
+	}
+	return counts, nil
+}
+
+// FetchReviewComments lists the review comments of a pull request, oldest
+// first, reading at most githubMaxCommentPages pages.
+func (g *GithubClient) FetchReviewComments(ctx context.Context, owner, repo, prNumber string) ([]models.ReviewComment, error) {
+	var comments []models.ReviewComment
+	listURL := g.apiURL(githubPostPRCommentURL, owner, repo, prNumber)
+	for page := 1; page <= githubMaxCommentPages; page++ {
+		req, err := g.newRequest(ctx, "GET", fmt.Sprintf("%s?per_page=100&page=%d", listURL, page), nil)
+		if err != nil {
+			return nil, err
+		}
+		var batch []models.ReviewComment
+		if err := g.do(req, http.StatusOK, &batch); err != nil {
+			return nil, fmt.Errorf("failed to fetch review comments from GitHub: %w", err)
+		}
+		comments = append(comments, batch...)
+		if len(batch) < 100 {
+			break
+		}
+	}
+	return comments, nil
+}
+
+// ReplyToReviewComment posts a reply in the thread of a review comment and
+// returns the id of the reply.
+func (g *GithubClient) ReplyToReviewComment(ctx context.Context, owner, repo, prNumber string, commentID int64, body string) (string, error) {
+	url := g.apiURL(githubCommentReplyURL, owner, repo, prNumber, strconv.FormatInt(commentID, 10))
+	req, err := g.newRequest(ctx, "POST", url, map[string]string{"body": body})
+	if err != nil {
+		return "", err
+	}
+	var reply struct {
+		ID int64 `json:"id"`
+	}
+	if err := g.do(req, http.StatusCreated, &reply); err != nil {
+		return "", fmt.Errorf("failed to reply to review comment: %w", err)
+	}
+	return strconv.FormatInt(reply.ID, 10), nil
+}
+
+// reviewThreadsQuery lists the review threads of a pull request with the id
+// of their first comment.
+const reviewThreadsQuery = `query($owner: String!, $repo: String!, $number: Int!, $cursor: String) {
+  repository(owner: $owner, name: $repo) {
+    pullRequest(number: $number) {
+      reviewThreads(first: 100, after: $cursor) {
+        pageInfo { hasNextPage endCursor }
+        nodes {
+          isResolved
+          comments(first: 1) { nodes { databaseId } }
+        }
+      }
+    }
+  }
+}`
+
+// FetchThreadResolutions reports which review threads of a pull request are
+// resolved, keyed by the id of the thread's first comment. Resolution state
+// is only available through the GraphQL API.
+func (g *GithubClient) FetchThreadResolutions(ctx context.Context, owner, repo string, prNumber int) (map[string]bool, error) {
+	resolved := map[string]bool{}
+	var cursor *string
+	for page := 0; page < githubMaxCommentPages; page++ {
+		req, err := g.newRequest(ctx, "POST", g.GraphQLURL, map[string]interface{}{
+			"query":     reviewThreadsQuery,
+			"variables": map[string]interface{}{"owner": owner, "repo": repo, "number": prNumber, "cursor": cursor},
+		})
+		if err != nil {
+			return nil, err
+		}
+		var response struct {
+			Data struct {
+				Repository struct {
+					PullRequest struct {
+						ReviewThreads struct {
+							PageInfo struct {
+								HasNextPage bool   `json:"hasNextPage"`
+								EndCursor   string `json:"endCursor"`
+							} `json:"pageInfo"`
+							Nodes []struct {
+								IsResolved bool `json:"isResolved"`
+								Comments   struct {
+									Nodes []struct {
+										DatabaseID int64 `json:"databaseId"`
+									} `json:"nodes"`
+								} `json:"comments"`
+							} `json:"nodes"`
+						} `json:"reviewThreads"`
+					} `json:"pullRequest"`
+				} `json:"repository"`
+			} `json:"data"`
+			Errors []struct {
+				Message string `json:"message"`
+			} `json:"errors"`
+		}
+		if err := g.do(req, http.StatusOK, &response); err != nil {
+			return nil, fmt.Errorf("failed to fetch review threads from GitHub: %w", err)
+		}
+		if len(response.Errors) > 0 {
+			return nil, fmt.Errorf("failed to fetch review threads from GitHub: %s", response.Errors[0].Message)
+		}
+		threads := response.Data.Repository.PullRequest.ReviewThreads
+		for _, thread := range threads.Nodes {
+			if len(thread.Comments.Nodes) > 0 {
+				resolved[strconv.FormatInt(thread.Comments.Nodes[0].DatabaseID, 10)] = thread.IsResolved
+			}
+		}
+		if !threads.PageInfo.HasNextPage {
+			break
+		}
+		cursor = &threads.PageInfo.EndCursor
+	}
+	return resolved, nil
+}
+
+// FetchPermission returns a user's permission on a repository: "admin",
+// "write", "read" or "none". Maintainers are reported as "write".
+func (g *GithubClient) FetchPermission(ctx context.Context, owner, repo, user string) (string, error) {
+	req, err := g.newRequest(ctx, "GET", g.apiURL(githubPermissionURL, owner, repo, neturl.PathEscape(user)), nil)
+	if err != nil {
+		return "", err
+	}
+	var permission struct {
+		Permission string `json:"permission"`
+	}
+	if err := g.do(req, http.StatusOK, &permission); err != nil {
+		return "", fmt.Errorf("failed to fetch permission of %s: %w", user, err)
+	}
+	return permission.Permission, nil
+}
+
+// AddReaction reacts to a comment with content ("+1", "-1", "eyes",
+// "confused", ...). reviewComment selects a review comment rather than a
+// comment on the pull request's conversation.
+func (g *GithubClient) AddReaction(ctx context.Context, owner, repo string, commentID int64, reviewComment bool, content string) error {
+	path := githubIssueReactionURL
+	if reviewComment {
+		path = githubReviewReactionURL
+	}
+	req, err := g.newRequest(ctx, "POST", g.apiURL(path, owner, repo, strconv.FormatInt(commentID, 10)), map[string]string{"content": content})
+	if err != nil {
+		return err
+	}
+	resp, err := g.HttpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to add reaction: %w", err)
+	}
+	defer resp.Body.Close()
+	// 200 when the same reaction was already there
+	if resp.StatusCode != http.StatusCreated && resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("failed to add reaction: %s", resp.Status)
+	}
+	return nil
+}
+
+// FetchPullRequest returns the pull request's metadata (title, author,
+// labels, head and base branches).
+func (g *GithubClient) FetchPullRequest(ctx context.Context, req models.PullRequestRequest) (*models.PullRequest, error) {
+	httpReq, err := g.newRequest(ctx, "GET", g.apiURL(githubPullRequestURL, req.OwnerID, req.RepoID, req.ID), nil)
+	if err != nil {
+		return nil, err
+	}
+	var pr models.PullRequest
+	if err := g.do(httpReq, http.StatusOK, &pr); err != nil {
+		return nil, fmt.Errorf("failed to fetch PR from GitHub: %w", err)
+	}
+	return &pr, nil
+}
+
+// FetchFileContents returns the raw contents of a file at ref using the
+// contents API. It returns ErrNotFound when the file does not exist.
+func (g *GithubClient) FetchFileContents(ctx context.Context, owner, repo, filePath, ref string) ([]byte, error) {
+	url := g.apiURL(githubContentsURL, owner, repo, escapePath(filePath), neturl.QueryEscape(ref))
+	req, err := g.newRequest(ctx, "GET", url, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", "application/vnd.github.raw+json")
+
+	resp, err := g.HttpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch %s from GitHub: %w", filePath, err)
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+		return io.ReadAll(resp.Body)
+	case http.StatusNotFound:
+		return nil, fmt.Errorf("%s@%s: %w", filePath, ref, ErrNotFound)
+	}
+	return nil, fmt.Errorf("received non-OK response fetching %s from GitHub: %s", filePath, resp.Status)
+}
+
+// ListDirectory returns the paths of the files directly under dir at ref,
+// leaving out subdirectories. It returns ErrNotFound when dir does not exist.
+func (g *GithubClient) ListDirectory(ctx context.Context, owner, repo, dir, ref string) ([]string, error) {
+	url := g.apiURL(githubContentsURL, owner, repo, escapePath(dir), neturl.QueryEscape(ref))
+	req, err := g.newRequest(ctx, "GET", url, nil)
 	if err != nil {
-		return nil, fmt.Errorf("failed to marshal PR Comment body: %w", err)
+		return nil, err
 	}
 
-	req, err := http.NewRequest("POST", url, bytes.NewBuffer(jsonData))
+	resp, err := g.HttpClient.Do(req)
 	if err != nil {
-		return nil, fmt.Errorf("error making PR Comment request: %w", err)
+		return nil, fmt.Errorf("failed to list %s on GitHub: %w", dir, err)
 	}
+	defer resp.Body.Close()
 
+	switch resp.StatusCode {
+	case http.StatusOK:
+	case http.StatusNotFound:
+		return nil, fmt.Errorf("%s@%s: %w", dir, ref, ErrNotFound)
+	default:
+		return nil, fmt.Errorf("received non-OK response listing %s on GitHub: %s", dir, resp.Status)
+	}
+	var entries []struct {
+		Type string `json:"type"`
+		Path string `json:"path"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&entries); err != nil {
+		return nil, fmt.Errorf("failed to decode the listing of %s: %w", dir, err)
+	}
+	var paths []string
+	for _, entry := range entries {
+		if entry.Type == "file" {
+			paths = append(paths, entry.Path)
+		}
+	}
+	return paths, nil
+}
+
+// DownloadTarball returns a gzipped tarball of the repository at ref. The
+// caller must close it.
+func (g *GithubClient) DownloadTarball(ctx context.Context, owner, repo, ref string) (io.ReadCloser, error) {
+	req, err := g.newRequest(ctx, "GET", g.apiURL(githubTarballURL, owner, repo, neturl.PathEscape(ref)), nil)
+	if err != nil {
+		return nil, err
+	}
+	// GitHub redirects to a short-lived, pre-authorized download URL
+	resp, err := g.HttpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to download tarball from GitHub: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		resp.Body.Close()
+		return nil, fmt.Errorf("received non-OK response downloading tarball from GitHub: %s", resp.Status)
+	}
+	return resp.Body, nil
+}
+
+// UploadSARIF uploads a SARIF log to code scanning for a commit and ref
+// (e.g. "refs/pull/12/head") and returns the id of the upload. GitHub
+// expects the log gzipped and base64 encoded.
+func (g *GithubClient) UploadSARIF(ctx context.Context, owner, repo, commitSHA, ref string, log []byte) (string, error) {
+	var compressed bytes.Buffer
+	gz := gzip.NewWriter(&compressed)
+	if _, err := gz.Write(log); err != nil {
+		return "", fmt.Errorf("failed to compress SARIF: %w", err)
+	}
+	if err := gz.Close(); err != nil {
+		return "", fmt.Errorf("failed to compress SARIF: %w", err)
+	}
+
+	req, err := g.newRequest(ctx, "POST", g.apiURL(githubCodeScanningURL, owner, repo), map[string]string{
+		"commit_sha": commitSHA,
+		"ref":        ref,
+		"sarif":      base64.StdEncoding.EncodeToString(compressed.Bytes()),
+	})
+	if err != nil {
+		return "", err
+	}
+	var upload struct {
+		ID string `json:"id"`
+	}
+	if err := g.do(req, http.StatusAccepted, &upload); err != nil {
+		return "", fmt.Errorf("failed to upload SARIF to GitHub: %w", err)
+	}
+	return upload.ID, nil
+}
+
+// escapePath escapes each segment of a repository path.
+func escapePath(filePath string) string {
+	segments := strings.Split(filePath, "/")
+	for i, segment := range segments {
+		segments[i] = neturl.PathEscape(segment)
+	}
+	return strings.Join(segments, "/")
+}
+
+// postJSON posts body as JSON to url and expects 201 Created.
+func (g *GithubClient) postJSON(ctx context.Context, url string, body interface{}) error {
+	req, err := g.newRequest(ctx, "POST", url, body)
+	if err != nil {
+		return err
+	}
+	return g.do(req, http.StatusCreated, nil)
+}
+
+// newRequest builds an API request with the GitHub auth and version headers.
+// body is encoded as JSON when it is not nil.
+func (g *GithubClient) newRequest(ctx context.Context, method, url string, body interface{}) (*http.Request, error) {
+	var reqBody io.Reader
+	if body != nil {
+		jsonData, err := json.Marshal(body)
+		if err != nil {
+			return nil, fmt.Errorf("failed to marshal request body: %w", err)
+		}
+		reqBody = bytes.NewBuffer(jsonData)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, url, reqBody)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create HTTP request: %w", err)
+	}
 	req.Header.Set("Authorization", "token "+g.APIKey)
 	req.Header.Set("Accept", "application/vnd.github+json")
 	req.Header.Set("X-GitHub-Api-Version", "2022-11-28")
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	return req, nil
+}
 
+// do sends req, checks the response status and decodes the JSON response
+// into out when it is not nil.
+func (g *GithubClient) do(req *http.Request, wantStatus int, out interface{}) error {
+	body, err := g.doRaw(req, wantStatus)
+	if err != nil {
+		return err
+	}
+	if out == nil {
+		return nil
+	}
+	if err := json.Unmarshal(body, out); err != nil {
+		return fmt.Errorf("failed to decode response body: %w", err)
+	}
+	return nil
+}
+
+// doRaw sends req, checks the response status and returns the raw body.
+func (g *GithubClient) doRaw(req *http.Request, wantStatus int) ([]byte, error) {
 	resp, err := g.HttpClient.Do(req)
 	if err != nil {
-		return nil, fmt.Errorf("error making PR Comment request: %w", err)
+		return nil, fmt.Errorf("error making %s request: %w", req.URL.Host, err)
 	}
 	defer resp.Body.Close()
 
-	if resp.StatusCode != http.StatusCreated {
-		return nil, fmt.Errorf("error posting PR comment: %s", resp.Status)
+	if resp.StatusCode != wantStatus {
+		if rateLimited := rateLimitError(resp, time.Now()); rateLimited != nil {
+			return nil, rateLimited
+		}
+		return nil, fmt.Errorf("received unexpected response from %s: %s", req.URL.Host, resp.Status)
 	}
-
-	results = append(results, prReviewCommentRequestBody)
-	return results, nil
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read response body: %w", err)
+	}
+	return body, nil
 }
@@ -0,0 +1,43 @@
+package clients
+
+import (
+	"net/http"
+	"net/url"
+	"testing"
+	"time"
+)
+
+func TestRateLimitError(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	tests := []struct {
+		name           string
+		status         int
+		header         http.Header
+		wantLimited    bool
+		wantRetryAfter time.Duration
+	}{
+		{"secondary", http.StatusForbidden, http.Header{"Retry-After": {"60"}, "X-Ratelimit-Remaining": {"4999"}}, true, time.Minute},
+		{"primary", http.StatusForbidden, http.Header{"X-Ratelimit-Remaining": {"0"}, "X-Ratelimit-Reset": {"1700000300"}}, true, 5 * time.Minute},
+		{"primary already reset", http.StatusForbidden, http.Header{"X-Ratelimit-Remaining": {"0"}, "X-Ratelimit-Reset": {"1690000000"}}, true, 0},
+		{"too many requests", http.StatusTooManyRequests, nil, true, 0},
+		{"forbidden", http.StatusForbidden, http.Header{"X-Ratelimit-Remaining": {"4999"}}, false, 0},
+		{"not found", http.StatusNotFound, nil, false, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp := &http.Response{
+				StatusCode: tt.status,
+				Status:     http.StatusText(tt.status),
+				Header:     tt.header,
+				Request:    &http.Request{URL: &url.URL{Host: "api.github.com"}},
+			}
+			got := rateLimitError(resp, now)
+			if (got != nil) != tt.wantLimited {
+				t.Fatalf("rateLimitError() = %v, want limited %v", got, tt.wantLimited)
+			}
+			if got != nil && got.RetryAfter != tt.wantRetryAfter {
+				t.Errorf("RetryAfter = %s, want %s", got.RetryAfter, tt.wantRetryAfter)
+			}
+		})
+	}
+}
@@ -0,0 +1,275 @@
+package clients
+
+import (
+	"ai-api/models"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// GitlabClient talks to the GitLab REST API (gitlab.com or self-hosted) and
+// implements SCMProvider for merge requests.
+type GitlabClient struct {
+	HttpClient *http.Client
+	APIKey     string
+	BaseURL    string
+
+	identity *botIdentity
+}
+
+// defaultGitlabBaseURL is used when no base URL is configured.
+const defaultGitlabBaseURL = "https://gitlab.com/api/v4"
+
+func NewGitlabClient(httpClient *http.Client, apiKey, baseUrl string) *GitlabClient {
+	if baseUrl == "" {
+		baseUrl = defaultGitlabBaseURL
+	}
+	return &GitlabClient{
+		HttpClient: httpClient,
+		APIKey:     apiKey,
+		BaseURL:    strings.TrimSuffix(baseUrl, "/"),
+		identity:   &botIdentity{},
+	}
+}
+
+// gitlabMRChanges is the response of GET /projects/:id/merge_requests/:iid/changes
+type gitlabMRChanges struct {
+	SHA      string `json:"sha"`
+	DiffRefs struct {
+		BaseSHA  string `json:"base_sha"`
+		HeadSHA  string `json:"head_sha"`
+		StartSHA string `json:"start_sha"`
+	} `json:"diff_refs"`
+	Changes []struct {
+		OldPath     string `json:"old_path"`
+		NewPath     string `json:"new_path"`
+		NewFile     bool   `json:"new_file"`
+		RenamedFile bool   `json:"renamed_file"`
+		DeletedFile bool   `json:"deleted_file"`
+		Diff        string `json:"diff"`
+	} `json:"changes"`
+}
+
+// gitlabPosition anchors a discussion to a line of the merge request diff.
+type gitlabPosition struct {
+	PositionType string `json:"position_type"`
+	BaseSHA      string `json:"base_sha"`
+	StartSHA     string `json:"start_sha"`
+	HeadSHA      string `json:"head_sha"`
+	OldPath      string `json:"old_path"`
+	NewPath      string `json:"new_path"`
+	NewLine      int    `json:"new_line"`
+}
+
+// FetchChanges returns the changed files of a merge request. The project is
+// taken from req.OwnerID (numeric ID or full path, or the group when RepoID
+// holds the project name) and the iid from req.ID.
+func (g *GitlabClient) FetchChanges(ctx context.Context, req models.PullRequestRequest) (*models.ChangeFiles, error) {
+	var mr gitlabMRChanges
+	if err := g.do(ctx, "GET", g.mergeRequestPath(req, "/changes"), nil, http.StatusOK, &mr); err != nil {
+		return nil, fmt.Errorf("failed to fetch MR changes from GitLab: %w", err)
+	}
+
+	changeFiles := &models.ChangeFiles{
+		Files:    []models.ChangeFile{},
+		BaseSHA:  mr.DiffRefs.BaseSHA,
+		StartSHA: mr.DiffRefs.StartSHA,
+		HeadSHA:  mr.DiffRefs.HeadSHA,
+	}
+	for _, change := range mr.Changes {
+		file := models.ChangeFile{
+			Filename:         change.NewPath,
+			PreviousFilename: change.OldPath,
+			Patch:            change.Diff,
+			Sha:              mr.DiffRefs.HeadSHA,
+			Status:           "modified",
+		}
+		switch {
+		case change.NewFile:
+			file.Status = "added"
+		case change.DeletedFile:
+			file.Status = "removed"
+		case change.RenamedFile:
+			file.Status = "renamed"
+		}
+		for _, line := range strings.Split(change.Diff, "\n") {
+			switch {
+			case strings.HasPrefix(line, "+"):
+				file.Additions++
+			case strings.HasPrefix(line, "-"):
+				file.Deletions++
+			}
+		}
+		file.Changes = file.Additions + file.Deletions
+		changeFiles.Files = append(changeFiles.Files, file)
+	}
+	return changeFiles, nil
+}
+
+// PostInlineComment starts a discussion on the new side of the diff.
+func (g *GitlabClient) PostInlineComment(ctx context.Context, params models.GeneratePRCommentParams) (string, error) {
+	oldPath := params.OldFileName
+	if oldPath == "" {
+		oldPath = params.FileName
+	}
+	body := map[string]interface{}{
+		"body": params.ProseBody(),
+		"position": gitlabPosition{
+			PositionType: "text",
+			BaseSHA:      params.BaseSha,
+			StartSHA:     params.StartSha,
+			HeadSHA:      params.CommitSha,
+			OldPath:      oldPath,
+			NewPath:      params.FileName,
+			NewLine:      params.Line,
+		},
+	}
+	req := models.PullRequestRequest{OwnerID: params.RepoOwner, RepoID: params.RepoName, ID: params.PRNumber}
+	var discussion struct {
+		Notes []struct {
+			ID int64 `json:"id"`
+		} `json:"notes"`
+	}
+	if err := g.do(ctx, "POST", g.mergeRequestPath(req, "/discussions"), body, http.StatusCreated, &discussion); err != nil {
+		return "", fmt.Errorf("error posting MR discussion: %w", err)
+	}
+	if len(discussion.Notes) == 0 {
+		return "", nil
+	}
+	return strconv.FormatInt(discussion.Notes[0].ID, 10), nil
+}
+
+// PostSummary posts a note on the merge request.
+func (g *GitlabClient) PostSummary(ctx context.Context, req models.PullRequestRequest, body string) error {
+	if err := g.do(ctx, "POST", g.mergeRequestPath(req, "/notes"), map[string]string{"body": body}, http.StatusCreated, nil); err != nil {
+		return fmt.Errorf("error posting MR note: %w", err)
+	}
+	return nil
+}
+
+// UpsertSummary edits the token user's merge request note holding marker or
+// posts a new one. The 100 most recent notes are searched.
+func (g *GitlabClient) UpsertSummary(ctx context.Context, req models.PullRequestRequest, marker, body string) error {
+	bot, err := g.authenticatedUser(ctx)
+	if err != nil {
+		return err
+	}
+	var notes []struct {
+		ID     int64  `json:"id"`
+		Body   string `json:"body"`
+		System bool   `json:"system"`
+		Author struct {
+			Username string `json:"username"`
+		} `json:"author"`
+	}
+	if err := g.do(ctx, "GET", g.mergeRequestPath(req, "/notes?per_page=100&sort=desc"), nil, http.StatusOK, &notes); err != nil {
+		return fmt.Errorf("error listing MR notes: %w", err)
+	}
+	for _, note := range notes {
+		// only the reviewer's own notes are edited
+		if note.System || !strings.EqualFold(note.Author.Username, bot) || !strings.Contains(note.Body, marker) {
+			continue
+		}
+		path := g.mergeRequestPath(req, fmt.Sprintf("/notes/%d", note.ID))
+		if err := g.do(ctx, "PUT", path, map[string]string{"body": body}, http.StatusOK, nil); err != nil {
+			return fmt.Errorf("error editing MR note: %w", err)
+		}
+		return nil
+	}
+	return g.PostSummary(ctx, req, body)
+}
+
+// authenticatedUser returns the username of the token's user.
+func (g *GitlabClient) authenticatedUser(ctx context.Context) (string, error) {
+	return g.identity.get(func() (string, error) {
+		var user struct {
+			Username string `json:"username"`
+		}
+		if err := g.do(ctx, "GET", "/user", nil, http.StatusOK, &user); err != nil {
+			return "", fmt.Errorf("failed to look up the token's user: %w", err)
+		}
+		if user.Username == "" {
+			return "", fmt.Errorf("the token's user has no username")
+		}
+		return user.Username, nil
+	})
+}
+
+// SetStatus sets a commit status. GitLab has no "failure"/"error" states so
+// both are reported as "failed".
+func (g *GitlabClient) SetStatus(ctx context.Context, req models.PullRequestRequest, sha string, status models.CommitStatus) error {
+	state := status.State
+	if state == models.StatusFailure || state == models.StatusError {
+		state = "failed"
+	}
+	body := map[string]string{
+		"state":       state,
+		"description": status.Description,
+		"name":        status.Context,
+	}
+	path := fmt.Sprintf("/projects/%s/statuses/%s", gitlabProject(req), sha)
+	if err := g.do(ctx, "POST", path, body, http.StatusCreated, nil); err != nil {
+		return fmt.Errorf("error setting commit status: %w", err)
+	}
+	return nil
+}
+
+func (g *GitlabClient) mergeRequestPath(req models.PullRequestRequest, suffix string) string {
+	return fmt.Sprintf("/projects/%s/merge_requests/%s%s", gitlabProject(req), req.ID, suffix)
+}
+
+// gitlabProject returns the escaped project of a request. Requests coming
+// from the owner/repo routes carry the path split in two.
+func gitlabProject(req models.PullRequestRequest) string {
+	project := req.OwnerID
+	if req.RepoID != "" {
+		project += "/" + req.RepoID
+	}
+	return url.PathEscape(project)
+}
+
+// do sends a request to the GitLab API, checks for wantStatus and decodes the
+// response into out when it is not nil.
+func (g *GitlabClient) do(ctx context.Context, method, path string, body interface{}, wantStatus int, out interface{}) error {
+	var reqBody io.Reader
+	if body != nil {
+		jsonData, err := json.Marshal(body)
+		if err != nil {
+			return fmt.Errorf("failed to marshal request body: %w", err)
+		}
+		reqBody = bytes.NewBuffer(jsonData)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, g.BaseURL+path, reqBody)
+	if err != nil {
+		return fmt.Errorf("failed to create HTTP request: %w", err)
+	}
+	req.Header.Set("PRIVATE-TOKEN", g.APIKey)
+	req.Header.Set("Accept", "application/json")
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	resp, err := g.HttpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("GitLab request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != wantStatus {
+		return fmt.Errorf("received unexpected response from GitLab: %s", resp.Status)
+	}
+	if out == nil {
+		return nil
+	}
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return fmt.Errorf("failed to decode GitLab response body: %w", err)
+	}
+	return nil
+}
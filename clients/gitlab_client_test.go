@@ -0,0 +1,225 @@
+package clients
+
+import (
+	"ai-api/models"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// gitlabRequest is a request received by the test server.
+type gitlabRequest struct {
+	Method, Path, Token string
+	Body                map[string]interface{}
+}
+
+// newGitlabTestServer serves handlers by "METHOD escaped-path" and records
+// every request.
+func newGitlabTestServer(t *testing.T, handlers map[string]http.HandlerFunc) (*GitlabClient, *[]gitlabRequest) {
+	t.Helper()
+	var requests []gitlabRequest
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		recorded := gitlabRequest{Method: r.Method, Path: r.URL.EscapedPath(), Token: r.Header.Get("PRIVATE-TOKEN")}
+		if data, _ := io.ReadAll(r.Body); len(data) > 0 {
+			if err := json.Unmarshal(data, &recorded.Body); err != nil {
+				t.Errorf("request body is not JSON: %s", data)
+			}
+		}
+		requests = append(requests, recorded)
+		handler, ok := handlers[r.Method+" "+r.URL.EscapedPath()]
+		if !ok {
+			t.Errorf("unexpected request %s %s", r.Method, r.URL.EscapedPath())
+			http.NotFound(w, r)
+			return
+		}
+		handler(w, r)
+	}))
+	t.Cleanup(srv.Close)
+	return NewGitlabClient(srv.Client(), "glpat-test", srv.URL+"/api/v4/"), &requests
+}
+
+func respondJSON(status int, body string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(status)
+		io.WriteString(w, body)
+	}
+}
+
+const gitlabChangesResponse = `{
+  "sha": "head123",
+  "diff_refs": {"base_sha": "base123", "head_sha": "head123", "start_sha": "start123"},
+  "changes": [
+    {"old_path": "cache.go", "new_path": "cache.go", "diff": "@@ -1,2 +1,3 @@\n package store\n+import \"sync\"\n-var x = 1\n+var x = 2\n"},
+    {"old_path": "new.go", "new_path": "new.go", "new_file": true, "diff": "@@ -0,0 +1 @@\n+package store\n"},
+    {"old_path": "gone.go", "new_path": "gone.go", "deleted_file": true, "diff": "@@ -1 +0,0 @@\n-package store\n"},
+    {"old_path": "old.go", "new_path": "renamed.go", "renamed_file": true, "diff": ""}
+  ]
+}`
+
+func TestGitlabFetchChanges(t *testing.T) {
+	client, requests := newGitlabTestServer(t, map[string]http.HandlerFunc{
+		"GET /api/v4/projects/group%2Fsub%2Fapi/merge_requests/7/changes": respondJSON(http.StatusOK, gitlabChangesResponse),
+	})
+
+	changes, err := client.FetchChanges(context.Background(), models.PullRequestRequest{OwnerID: "group/sub", RepoID: "api", ID: "7"})
+	if err != nil {
+		t.Fatalf("FetchChanges() error = %v", err)
+	}
+	if (*requests)[0].Token != "glpat-test" {
+		t.Errorf("PRIVATE-TOKEN = %q, want the api key", (*requests)[0].Token)
+	}
+	if changes.BaseSHA != "base123" || changes.StartSHA != "start123" || changes.HeadSHA != "head123" {
+		t.Errorf("diff refs = %s %s %s", changes.BaseSHA, changes.StartSHA, changes.HeadSHA)
+	}
+	want := []struct {
+		name, previous, status string
+		additions, deletions   int
+	}{
+		{"cache.go", "cache.go", "modified", 2, 1},
+		{"new.go", "new.go", "added", 1, 0},
+		{"gone.go", "gone.go", "removed", 0, 1},
+		{"renamed.go", "old.go", "renamed", 0, 0},
+	}
+	if len(changes.Files) != len(want) {
+		t.Fatalf("got %d files, want %d", len(changes.Files), len(want))
+	}
+	for i, w := range want {
+		got := changes.Files[i]
+		if got.Filename != w.name || got.PreviousFilename != w.previous || got.Status != w.status ||
+			got.Additions != w.additions || got.Deletions != w.deletions || got.Changes != w.additions+w.deletions {
+			t.Errorf("file %d = %+v, want %+v", i, got, w)
+		}
+		if got.Sha != "head123" {
+			t.Errorf("file %d sha = %q, want the head sha", i, got.Sha)
+		}
+	}
+}
+
+func TestGitlabFetchChangesErrors(t *testing.T) {
+	client, _ := newGitlabTestServer(t, map[string]http.HandlerFunc{
+		"GET /api/v4/projects/42/merge_requests/1/changes": respondJSON(http.StatusNotFound, `{"message":"404 Not found"}`),
+		"GET /api/v4/projects/42/merge_requests/2/changes": func(w http.ResponseWriter, r *http.Request) {
+			select {
+			case <-r.Context().Done():
+			case <-time.After(5 * time.Second):
+			}
+		},
+	})
+
+	if _, err := client.FetchChanges(context.Background(), models.PullRequestRequest{OwnerID: "42", ID: "1"}); err == nil || !strings.Contains(err.Error(), "404") {
+		t.Errorf("FetchChanges() on a missing MR error = %v, want a 404", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	if _, err := client.FetchChanges(ctx, models.PullRequestRequest{OwnerID: "42", ID: "2"}); err == nil {
+		t.Error("FetchChanges() with an expired context succeeded")
+	}
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Errorf("FetchChanges() ignored the context deadline, took %s", elapsed)
+	}
+}
+
+func TestGitlabPostInlineComment(t *testing.T) {
+	tests := []struct {
+		name        string
+		params      models.GeneratePRCommentParams
+		wantOldPath string
+		wantBody    string
+	}{
+		{
+			name: "modified file",
+			params: models.GeneratePRCommentParams{
+				RepoOwner: "group", RepoName: "api", PRNumber: "7", FileName: "cache.go", Line: 12,
+				CommentBody: "Put writes the map without holding mu.",
+				CommitSha:   "head123", BaseSha: "base123", StartSha: "start123",
+			},
+			wantOldPath: "cache.go",
+			wantBody:    "Put writes the map without holding mu.",
+		},
+		{
+			name: "renamed file with a suggestion",
+			params: models.GeneratePRCommentParams{
+				RepoOwner: "group", RepoName: "api", PRNumber: "7", FileName: "renamed.go", OldFileName: "old.go", Line: 3,
+				CommentBody: "Wrap the error.", Suggestion: "return fmt.Errorf(\"load: %w\", err)",
+				CommitSha: "head123", BaseSha: "base123", StartSha: "start123",
+			},
+			wantOldPath: "old.go",
+			wantBody:    "Wrap the error.\n\nSuggested replacement for line 3:\n```\nreturn fmt.Errorf(\"load: %w\", err)\n```",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client, requests := newGitlabTestServer(t, map[string]http.HandlerFunc{
+				"POST /api/v4/projects/group%2Fapi/merge_requests/7/discussions": respondJSON(http.StatusCreated, `{"id":"d1","notes":[{"id":9001}]}`),
+			})
+			id, err := client.PostInlineComment(context.Background(), tt.params)
+			if err != nil {
+				t.Fatalf("PostInlineComment() error = %v", err)
+			}
+			if id != "9001" {
+				t.Errorf("PostInlineComment() id = %q, want the first note's id", id)
+			}
+			body := (*requests)[0].Body
+			if body["body"] != tt.wantBody {
+				t.Errorf("body = %q, want %q", body["body"], tt.wantBody)
+			}
+			position, _ := body["position"].(map[string]interface{})
+			want := map[string]interface{}{
+				"position_type": "text",
+				"base_sha":      "base123",
+				"start_sha":     "start123",
+				"head_sha":      "head123",
+				"old_path":      tt.wantOldPath,
+				"new_path":      tt.params.FileName,
+				"new_line":      float64(tt.params.Line),
+			}
+			for key, value := range want {
+				if position[key] != value {
+					t.Errorf("position.%s = %v, want %v", key, position[key], value)
+				}
+			}
+		})
+	}
+}
+
+func TestGitlabPostInlineCommentRejected(t *testing.T) {
+	client, _ := newGitlabTestServer(t, map[string]http.HandlerFunc{
+		"POST /api/v4/projects/group%2Fapi/merge_requests/7/discussions": respondJSON(http.StatusBadRequest, `{"message":"400 Bad request - Note {:line_code=>[\"can't be blank\"]}"}`),
+	})
+	_, err := client.PostInlineComment(context.Background(), models.GeneratePRCommentParams{RepoOwner: "group", RepoName: "api", PRNumber: "7", FileName: "cache.go", Line: 99})
+	if err == nil || !strings.Contains(err.Error(), "400") {
+		t.Errorf("PostInlineComment() outside the diff error = %v, want a 400", err)
+	}
+}
+
+func TestGitlabSetStatus(t *testing.T) {
+	tests := []struct {
+		state, want string
+	}{
+		{models.StatusSuccess, "success"},
+		{models.StatusPending, "pending"},
+		{models.StatusFailure, "failed"},
+		{models.StatusError, "failed"},
+	}
+	for _, tt := range tests {
+		client, requests := newGitlabTestServer(t, map[string]http.HandlerFunc{
+			"POST /api/v4/projects/42/statuses/head123": respondJSON(http.StatusCreated, `{}`),
+		})
+		err := client.SetStatus(context.Background(), models.PullRequestRequest{OwnerID: "42", ID: "7"}, "head123",
+			models.CommitStatus{State: tt.state, Description: "review done", Context: "pr-checker"})
+		if err != nil {
+			t.Fatalf("SetStatus(%s) error = %v", tt.state, err)
+		}
+		if got := (*requests)[0].Body["state"]; got != tt.want {
+			t.Errorf("SetStatus(%s) state = %v, want %s", tt.state, got, tt.want)
+		}
+	}
+}
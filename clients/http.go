@@ -0,0 +1,39 @@
+package clients
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"net/http"
+	"os"
+	"time"
+)
+
+// NewHTTPClient returns the http.Client shared by all API clients. When
+// caBundlePath is set, the PEM certificates in it are trusted in addition to
+// the system roots, e.g. for a GitHub Enterprise Server behind an internal CA.
+func NewHTTPClient(timeout time.Duration, caBundlePath string) (*http.Client, error) {
+	client := &http.Client{
+		Timeout: timeout,
+	}
+	if caBundlePath == "" {
+		return client, nil
+	}
+
+	pem, err := os.ReadFile(caBundlePath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read CA bundle: %w", err)
+	}
+	pool, err := x509.SystemCertPool()
+	if err != nil {
+		pool = x509.NewCertPool()
+	}
+	if !pool.AppendCertsFromPEM(pem) {
+		return nil, fmt.Errorf("no certificates found in CA bundle %s", caBundlePath)
+	}
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.TLSClientConfig = &tls.Config{RootCAs: pool, MinVersion: tls.VersionTLS12}
+	client.Transport = transport
+	return client, nil
+}
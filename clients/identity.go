@@ -0,0 +1,28 @@
+package clients
+
+import "sync"
+
+// botIdentity caches the user a client's token authenticates as, so
+// comments the reviewer posted itself can be told from everyone else's.
+type botIdentity struct {
+	mu   sync.Mutex
+	user string
+}
+
+// get returns the cached user, calling fetch on first use. A failed fetch
+// is retried on the next call.
+func (id *botIdentity) get(fetch func() (string, error)) (string, error) {
+	if id == nil {
+		return fetch()
+	}
+	id.mu.Lock()
+	defer id.mu.Unlock()
+	if id.user == "" {
+		user, err := fetch()
+		if err != nil {
+			return "", err
+		}
+		id.user = user
+	}
+	return id.user, nil
+}
@@ -9,6 +9,7 @@ import (
 	"os"
 	"sort"
 	"strings"
+	"sync"
 
 	"github.com/PuerkitoBio/goquery"
 	openai "github.com/openai/openai-go"
@@ -22,8 +23,26 @@ import (
 // based on code diffs and style guides.
 type OpenFGAClient struct {
 	Client               *openai.Client
+	Model                string
 	styleGuideEmbeddings [][]float64
 	styleGuideChunks     []string
+
+	// extraEmbeddings caches embeddings of repository style guide chunks
+	extraMu         sync.Mutex
+	extraEmbeddings map[string][]float64
+}
+
+// ReviewRequest holds the inputs of a single file review.
+type ReviewRequest struct {
+	// CodeDiff is the patch to review.
+	CodeDiff string
+	// Prompt is the base prompt the style guide and code are appended to.
+	Prompt string
+	// Model overrides the client's default model when set.
+	Model string
+	// ExtraStyleChunks are additional style guide chunks, e.g. from a
+	// repository's own style guide, considered together with the built-in ones.
+	ExtraStyleChunks []string
 }
 
 // ScoredChunk represents a chunk of text with its associated score.
@@ -39,20 +58,24 @@ type ScoredChunk struct {
 
 // OpenFGAClientInterface defines the methods for interacting with the OpenAI API
 type OpenFGAClientInterface interface {
-	GenerateReviewComment(ctx context.Context, codeDiff, promptTemplate string) (string, error)
+	GenerateReviewComment(ctx context.Context, req ReviewRequest) (string, error)
+	RelevantStyleChunks(ctx context.Context, code string, extra []string) ([]string, error)
+	Complete(ctx context.Context, prompt, model string) (string, error)
 }
 
 // NewOpenFGAClient creates a new instance of OpenFGAClient with the provided HTTP client, API key, and base URL.
 // It initializes the OpenAI client, parses style guide chunks from an HTML file, and fetches embeddings for the
-// style guide chunks. If any error occurs during the initialization process, it logs the error and returns nil.
+// style guide chunks. If any step of the initialization fails, it returns the error.
 //
 // Parameters:
 //   - httpClient: The HTTP client to be used for making requests.
 //   - key: The API key for authenticating with the OpenAI service.
 //   - url: The base URL for the OpenAI service.
+//   - model: The chat model used for reviews, gpt-4o when empty.
 //
 // Returns:
-//   - A pointer to an OpenFGAClient instance if successful, or nil if an error occurs.
+//   - A pointer to an OpenFGAClient instance if successful.
+//   - An error if the style guide cannot be parsed or embedded.
 //
 // Parameters:
 //   - ctx: The context for the API request, which can be used to control timeouts or cancellations.
@@ -65,7 +88,7 @@ type OpenFGAClientInterface interface {
 // Errors:
 //   - Returns an error if the OpenAI API call fails.
 //   - Returns an error if the API response does not contain any embeddings.
-func NewOpenFGAClient(httpClient *http.Client, key, url string) *OpenFGAClient {
+func NewOpenFGAClient(httpClient *http.Client, key, url, model string) (*OpenFGAClient, error) {
 
 	client := openai.NewClient(
 		option.WithAPIKey(key),
@@ -76,23 +99,27 @@ func NewOpenFGAClient(httpClient *http.Client, key, url string) *OpenFGAClient {
 	log.Println("loading style guide embeddings")
 	chunks, err := parseStyleGuideChunks("./clients/style_guides/go_style_guide.html")
 	if err != nil {
-		log.Println("error parsing style guide chunks:", err)
-		return nil
+		return nil, fmt.Errorf("failed to parse style guide chunks: %w", err)
 	}
 
 	// Fetch embeddings for the style guide chunks
 	embeddings, err := fetchStyleGuideEmbeddings(chunks, &client)
 	if err != nil {
-		log.Println("error fetching style guide embeddings:", err)
-		return nil
+		return nil, fmt.Errorf("failed to fetch style guide embeddings: %w", err)
+	}
+
+	if model == "" {
+		model = openai.ChatModelGPT4o
 	}
 
 	log.Println("creating client")
 	return &OpenFGAClient{
 		Client:               &client,
+		Model:                model,
 		styleGuideChunks:     chunks,
 		styleGuideEmbeddings: embeddings,
-	}
+		extraEmbeddings:      map[string][]float64{},
+	}, nil
 }
 
 // GenerateReviewComment generates a review comment based on the provided diff string
@@ -101,31 +128,105 @@ func NewOpenFGAClient(httpClient *http.Client, key, url string) *OpenFGAClient {
 //
 // Parameters:
 //   - ctx: The context for managing request deadlines and cancellations.
-//   - diff: A string representing the diff for which the review comment is to be generated.
+//   - req: The diff to review, the base prompt, and optionally a model override
+//     and extra style guide chunks.
 //
 // Returns:
 //   - A string containing the generated review comment.
 //   - An error if the API call fails or any other issue occurs.
-func (o *OpenFGAClient) GenerateReviewComment(ctx context.Context, codeDiff, promptTemplate string) (string, error) {
+func (o *OpenFGAClient) GenerateReviewComment(ctx context.Context, req ReviewRequest) (string, error) {
+
+	topChunks, err := o.RelevantStyleChunks(ctx, req.CodeDiff, req.ExtraStyleChunks)
+	if err != nil {
+		return "", err
+	}
+	prompt := buildReviewPrompt(topChunks, req.Prompt, req.CodeDiff)
 
-	topChunks, err := FindRelevantChunks(ctx, o.Client, codeDiff, o.styleGuideChunks, o.styleGuideEmbeddings)
+	return o.Complete(ctx, prompt, req.Model)
+}
+
+// RelevantStyleChunks returns the style guide chunks most relevant to code,
+// searching the built-in guide and any extra (repository) chunks.
+func (o *OpenFGAClient) RelevantStyleChunks(ctx context.Context, code string, extra []string) ([]string, error) {
+	chunks, embeddings, err := o.styleGuide(ctx, extra)
 	if err != nil {
-		return "", fmt.Errorf("error finding relevant chunks: %w", err)
+		return nil, fmt.Errorf("error embedding repository style guide: %w", err)
 	}
-	prompt := buildReviewPrompt(topChunks, promptTemplate, codeDiff)
+	topChunks, err := FindRelevantChunks(ctx, o.Client, code, chunks, embeddings)
+	if err != nil {
+		return nil, fmt.Errorf("error finding relevant chunks: %w", err)
+	}
+	return topChunks, nil
+}
 
+// Complete sends a fully rendered prompt to the chat completions API and
+// returns the answer. An empty model uses the client's default model.
+func (o *OpenFGAClient) Complete(ctx context.Context, prompt, model string) (string, error) {
+	if model == "" {
+		model = o.Model
+	}
 	chatCompletion, err := o.Client.Chat.Completions.New(ctx, openai.ChatCompletionNewParams{
 		Messages: []openai.ChatCompletionMessageParamUnion{
 			openai.UserMessage(prompt),
 		},
-		Model: openai.ChatModelGPT4o,
+		Model: model,
 	})
 	if err != nil {
 		return "", fmt.Errorf("error generating review comment: %w", err)
 	}
+	if len(chatCompletion.Choices) == 0 {
+		return "", fmt.Errorf("error generating review comment: no choices returned")
+	}
 	return chatCompletion.Choices[0].Message.Content, nil
 }
 
+// styleGuide returns the built-in style guide chunks and embeddings followed
+// by the extra chunks, embedding extra chunks the first time they are seen.
+func (o *OpenFGAClient) styleGuide(ctx context.Context, extra []string) ([]string, [][]float64, error) {
+	if len(extra) == 0 {
+		return o.styleGuideChunks, o.styleGuideEmbeddings, nil
+	}
+
+	chunks := append(append([]string{}, o.styleGuideChunks...), extra...)
+	embeddings := append([][]float64{}, o.styleGuideEmbeddings...)
+	for _, chunk := range extra {
+		o.extraMu.Lock()
+		embed, ok := o.extraEmbeddings[chunk]
+		o.extraMu.Unlock()
+		if !ok {
+			var err error
+			embed, err = EmbedText(ctx, o.Client, chunk)
+			if err != nil {
+				return nil, nil, err
+			}
+			o.extraMu.Lock()
+			o.extraEmbeddings[chunk] = embed
+			o.extraMu.Unlock()
+		}
+		embeddings = append(embeddings, embed)
+	}
+	return chunks, embeddings, nil
+}
+
+// ParseStyleGuideFile splits a style guide file into chunks. HTML files are
+// parsed like the built-in guide; other files (Markdown, plain text) are
+// split into paragraphs.
+func ParseStyleGuideFile(name string, data []byte) ([]string, error) {
+	lower := strings.ToLower(name)
+	if strings.HasSuffix(lower, ".html") || strings.HasSuffix(lower, ".htm") {
+		return parseStyleGuide(string(data))
+	}
+
+	var chunks []string
+	for _, paragraph := range strings.Split(strings.ReplaceAll(string(data), "\r\n", "\n"), "\n\n") {
+		text := strings.TrimSpace(paragraph)
+		if len(text) > 30 {
+			chunks = append(chunks, text)
+		}
+	}
+	return chunks, nil
+}
+
 // parseStyleGuide extracts and returns textual content from the provided HTML string.
 // It parses the HTML using goquery and selects text from <p>, <li>, <h2>, and <h3> elements.
 // Only text content with a length greater than 30 characters is included in the result.
@@ -262,7 +363,6 @@ func FindRelevantChunks(ctx context.Context, client *openai.Client, userCode str
 //
 // Parameters:
 //   - styleChunks: A slice of strings representing chunks of the style guide.
-//     It is expected to have at least three elements.
 //   - basePrompt: A string containing the base prompt or introductory text.
 //   - code: A string containing the code that needs to be reviewed.
 //
@@ -271,7 +371,7 @@ func FindRelevantChunks(ctx context.Context, client *openai.Client, userCode str
 //	A formatted string that includes the base prompt, the style guide,
 //	and the code to be reviewed.
 func buildReviewPrompt(styleChunks []string, basePrompt, code string) string {
-	return fmt.Sprintf("%s. Here is the style guide: %s\n\n Here is the code to review: \n\n%s", basePrompt, styleChunks[0]+"\n\n"+styleChunks[1]+"\n\n"+styleChunks[2], code)
+	return fmt.Sprintf("%s. Here is the style guide: %s\n\n Here is the code to review: \n\n%s", basePrompt, strings.Join(styleChunks, "\n\n"), code)
 }
 
 // parseStyleGuideChunks reads an HTML file from the specified file path,
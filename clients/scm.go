@@ -0,0 +1,24 @@
+package clients
+
+import (
+	"ai-api/models"
+	"context"
+)
+
+// SCMProvider is the common interface of the source code hosts the reviewer
+// can work with. Each implementation translates it to the host's own pull
+// (or merge) request API.
+type SCMProvider interface {
+	// FetchChanges returns the changed files of a pull request with their patches.
+	FetchChanges(ctx context.Context, req models.PullRequestRequest) (*models.ChangeFiles, error)
+	// PostInlineComment posts a review comment anchored to a line of the diff
+	// and returns the host's id of the new comment.
+	PostInlineComment(ctx context.Context, params models.GeneratePRCommentParams) (commentID string, err error)
+	// PostSummary posts a comment on the pull request as a whole.
+	PostSummary(ctx context.Context, req models.PullRequestRequest, body string) error
+	// UpsertSummary edits the pull request comment containing marker, or
+	// posts body as a new comment when there is none.
+	UpsertSummary(ctx context.Context, req models.PullRequestRequest, marker, body string) error
+	// SetStatus reports the review status on a commit.
+	SetStatus(ctx context.Context, req models.PullRequestRequest, sha string, status models.CommitStatus) error
+}
@@ -0,0 +1,175 @@
+package clients
+
+import (
+	"ai-api/models"
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sort"
+	"strings"
+	"testing"
+)
+
+// upsertMarker is the marker searched by the tests below. Comments 1 (by
+// another user quoting the marker) and 2 (by the bot) hold it.
+const upsertMarker = "<!-- pr-checker:summary -->"
+
+// upsertServer answers GET requests from responses by path and records
+// every other request as "METHOD path".
+func upsertServer(t *testing.T, responses map[string]string) (*httptest.Server, *[]string) {
+	t.Helper()
+	var writes []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			writes = append(writes, r.Method+" "+r.URL.EscapedPath())
+			if r.Method == http.MethodPost {
+				w.WriteHeader(http.StatusCreated)
+			}
+			io.WriteString(w, `{"id": 3}`)
+			return
+		}
+		body, ok := responses[r.URL.EscapedPath()]
+		if !ok {
+			t.Errorf("unexpected request GET %s", r.URL.EscapedPath())
+			http.NotFound(w, r)
+			return
+		}
+		io.WriteString(w, body)
+	}))
+	t.Cleanup(srv.Close)
+	return srv, &writes
+}
+
+func TestUpsertSummaryEditsOnlyOwnComments(t *testing.T) {
+	githubComments := `[
+		{"id": 1, "body": "quoting ` + upsertMarker + `", "user": {"login": "mallory"}},
+		{"id": 2, "body": "` + upsertMarker + ` old summary", "user": {"login": "%s"}}
+	]`
+	tests := []struct {
+		name      string
+		responses map[string]string
+		client    func(baseURL string, httpClient *http.Client) SCMProvider
+		req       models.PullRequestRequest
+		want      []string
+	}{
+		{
+			name:      "github with a bot login",
+			responses: map[string]string{"/repos/acme/api/issues/5/comments": strings.Replace(githubComments, "%s", "PR-Checker[bot]", 1)},
+			client: func(baseURL string, httpClient *http.Client) SCMProvider {
+				client := NewGithubClient(httpClient, "token", baseURL)
+				client.BotLogin = "pr-checker[bot]"
+				return client
+			},
+			want: []string{"PATCH /repos/acme/api/issues/comments/2"},
+		},
+		{
+			name: "github with the token's user",
+			responses: map[string]string{
+				"/user":                             `{"login": "review-bot"}`,
+				"/repos/acme/api/issues/5/comments": strings.Replace(githubComments, "%s", "review-bot", 1),
+			},
+			client: func(baseURL string, httpClient *http.Client) SCMProvider {
+				return NewGithubClient(httpClient, "token", baseURL)
+			},
+			want: []string{"PATCH /repos/acme/api/issues/comments/2"},
+		},
+		{
+			name: "github without an own comment",
+			responses: map[string]string{
+				"/user":                             `{"login": "review-bot"}`,
+				"/repos/acme/api/issues/5/comments": strings.Replace(githubComments, "%s", "someone-else", 1),
+			},
+			client: func(baseURL string, httpClient *http.Client) SCMProvider {
+				return NewGithubClient(httpClient, "token", baseURL)
+			},
+			want: []string{"POST /repos/acme/api/issues/5/comments"},
+		},
+		{
+			name: "gitea",
+			responses: map[string]string{
+				"/api/v1/user": `{"login": "review-bot"}`,
+				"/api/v1/repos/acme/api/issues/5/comments": strings.Replace(githubComments, "%s", "review-bot", 1),
+			},
+			client: func(baseURL string, httpClient *http.Client) SCMProvider {
+				client, _ := NewGiteaClient(httpClient, "token", baseURL+"/api/v1")
+				return client
+			},
+			want: []string{"PATCH /api/v1/repos/acme/api/issues/comments/2"},
+		},
+		{
+			name: "gitlab",
+			responses: map[string]string{
+				"/user": `{"username": "review-bot"}`,
+				"/projects/acme%2Fapi/merge_requests/5/notes": `[
+					{"id": 1, "body": "quoting ` + upsertMarker + `", "author": {"username": "mallory"}},
+					{"id": 9, "body": "` + upsertMarker + `", "system": true, "author": {"username": "review-bot"}},
+					{"id": 2, "body": "` + upsertMarker + ` old summary", "author": {"username": "review-bot"}}
+				]`,
+			},
+			client: func(baseURL string, httpClient *http.Client) SCMProvider {
+				return NewGitlabClient(httpClient, "token", baseURL)
+			},
+			want: []string{"PUT /projects/acme%2Fapi/merge_requests/5/notes/2"},
+		},
+		{
+			name: "bitbucket server",
+			responses: map[string]string{
+				"/plugins/servlet/applinks/whoami": "review-bot\n",
+				"/rest/api/1.0/projects/acme/repos/api/pull-requests/5/activities": `{"values": [
+					{"action": "COMMENTED", "comment": {"id": 1, "version": 0, "text": "quoting ` + upsertMarker + `", "author": {"name": "mallory"}}},
+					{"action": "COMMENTED", "comment": {"id": 2, "version": 4, "text": "` + upsertMarker + ` old", "author": {"name": "review-bot"}}}
+				]}`,
+			},
+			client: func(baseURL string, httpClient *http.Client) SCMProvider {
+				return NewBitbucketClient(httpClient, "token", baseURL)
+			},
+			want: []string{"PUT /rest/api/1.0/projects/acme/repos/api/pull-requests/5/comments/2"},
+		},
+		{
+			name: "bitbucket cloud",
+			responses: map[string]string{
+				"/2.0/user": `{"uuid": "{bot-uuid}"}`,
+				"/2.0/repositories/acme/api/pullrequests/5/comments": `{"values": [
+					{"id": 1, "content": {"raw": "quoting ` + upsertMarker + `"}, "user": {"uuid": "{mallory-uuid}"}},
+					{"id": 2, "content": {"raw": "` + upsertMarker + ` old"}, "user": {"uuid": "{bot-uuid}"}}
+				]}`,
+			},
+			client: func(baseURL string, httpClient *http.Client) SCMProvider {
+				target, _ := url.Parse(baseURL)
+				return NewBitbucketClient(&http.Client{Transport: hostTransport{target}}, "token", "")
+			},
+			want: []string{"PUT /2.0/repositories/acme/api/pullrequests/5/comments/2"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv, writes := upsertServer(t, tt.responses)
+			req := models.PullRequestRequest{OwnerID: "acme", RepoID: "api", ID: "5"}
+			if err := tt.client(srv.URL, srv.Client()).UpsertSummary(context.Background(), req, upsertMarker, upsertMarker+" new summary"); err != nil {
+				t.Fatalf("UpsertSummary() error = %v", err)
+			}
+			sort.Strings(*writes)
+			if strings.Join(*writes, "\n") != strings.Join(tt.want, "\n") {
+				t.Errorf("requests = %q, want %q", *writes, tt.want)
+			}
+		})
+	}
+}
+
+func TestUpsertSummaryUnknownUser(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/user" {
+			t.Errorf("unexpected request %s %s", r.Method, r.URL.Path)
+		}
+		respondJSON(http.StatusForbidden, `{"message": "Resource not accessible by integration"}`)(w, r)
+	}))
+	defer srv.Close()
+
+	client := NewGithubClient(srv.Client(), "installation-token", srv.URL)
+	err := client.UpsertSummary(context.Background(), models.PullRequestRequest{OwnerID: "acme", RepoID: "api", ID: "5"}, upsertMarker, "summary")
+	if err == nil || !strings.Contains(err.Error(), "bot login") {
+		t.Errorf("UpsertSummary() error = %v, want a hint to set the bot login", err)
+	}
+}
@@ -0,0 +1,234 @@
+// File: codecontext/golang.go
+// Builds the surrounding code shown to the model next to a patch: for Go
+// files the import block, the declarations enclosing each changed hunk and
+// the type declarations those refer to, cut to fit a token budget.
+package codecontext
+
+import (
+	"ai-api/diff"
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"path"
+	"sort"
+	"strings"
+)
+
+// charsPerToken is the rough number of characters of Go source per model
+// token used to estimate the size of the context.
+const charsPerToken = 4
+
+// EstimateTokens estimates the number of model tokens in text.
+func EstimateTokens(text string) int {
+	return (len(text) + charsPerToken - 1) / charsPerToken
+}
+
+// section is one piece of context.
+type section struct {
+	title string
+	text  string
+}
+
+// Go returns the context of the hunks of a Go file, given the file's source
+// at the head commit. Sections are added in order of importance — imports,
+// the declarations enclosing the changes, then the types they refer to — and
+// a section that does not fit in what is left of budget tokens is left out.
+// Types are looked up in the file, then in pkgFiles, the sources of the
+// other files of its package by name; files of another package (such as
+// external tests) or that do not parse are skipped.
+func Go(filename string, src []byte, hunks []diff.Hunk, budget int, pkgFiles map[string][]byte) (string, error) {
+	fset := token.NewFileSet()
+	file, err := parser.ParseFile(fset, filename, src, parser.SkipObjectResolution)
+	if err != nil {
+		return "", fmt.Errorf("failed to parse %s: %w", filename, err)
+	}
+	text := func(node ast.Node) string {
+		return string(src[fset.Position(node.Pos()).Offset:fset.Position(node.End()).Offset])
+	}
+	lines := func(node ast.Node) (int, int) {
+		return fset.Position(node.Pos()).Line, fset.Position(node.End()).Line
+	}
+
+	var sections []section
+	for _, decl := range file.Decls {
+		if gen, ok := decl.(*ast.GenDecl); ok && gen.Tok == token.IMPORT {
+			sections = append(sections, section{title: "imports", text: text(gen)})
+		}
+	}
+
+	// declarations overlapping a changed line range
+	seen := map[ast.Decl]bool{}
+	var enclosing []ast.Decl
+	for _, hunk := range hunks {
+		first, last := changedLines(hunk)
+		if first == 0 {
+			continue
+		}
+		for _, decl := range file.Decls {
+			start, end := lines(decl)
+			if seen[decl] || end < first || start > last {
+				continue
+			}
+			if gen, ok := decl.(*ast.GenDecl); ok && gen.Tok == token.IMPORT {
+				continue
+			}
+			seen[decl] = true
+			enclosing = append(enclosing, decl)
+			sections = append(sections, section{
+				title: fmt.Sprintf("%s (lines %d-%d)", describe(decl), start, end),
+				text:  text(decl),
+			})
+		}
+	}
+
+	// type declarations of the package referred to by the enclosing
+	// declarations or by the types already added, this file's first
+	types := typeDecls(file, "", text)
+	for name, decl := range packageTypes(fset, file.Name.Name, filename, pkgFiles) {
+		if _, ok := types[name]; !ok {
+			types[name] = decl
+		}
+	}
+	referring := enclosing
+	for i := 0; i < len(referring); i++ {
+		ast.Inspect(referring[i], func(node ast.Node) bool {
+			ident, ok := node.(*ast.Ident)
+			if !ok {
+				return true
+			}
+			typeDecl, ok := types[ident.Name]
+			if !ok || seen[typeDecl.decl] {
+				return true
+			}
+			seen[typeDecl.decl] = true
+			referring = append(referring, typeDecl.decl)
+			title := "type " + ident.Name
+			if typeDecl.file != "" {
+				title += " (" + path.Base(typeDecl.file) + ")"
+			}
+			sections = append(sections, section{title: title, text: typeDecl.text})
+			return true
+		})
+	}
+
+	var b strings.Builder
+	left := budget
+	for _, s := range sections {
+		entry := fmt.Sprintf("// %s\n%s\n\n", s.title, s.text)
+		cost := EstimateTokens(entry)
+		if cost > left {
+			continue
+		}
+		left -= cost
+		b.WriteString(entry)
+	}
+	return strings.TrimRight(b.String(), "\n"), nil
+}
+
+// changedLines returns the range of new-file lines a hunk adds, or for a
+// hunk that only removes lines, the line the removal happened at.
+func changedLines(hunk diff.Hunk) (first, last int) {
+	line := hunk.NewStart
+	for _, l := range hunk.Lines {
+		switch {
+		case strings.HasPrefix(l, "+"):
+			if first == 0 {
+				first = line
+			}
+			last = line
+			line++
+		case strings.HasPrefix(l, "-"):
+			if first == 0 {
+				first, last = line, line
+			}
+		case strings.HasPrefix(l, "\\"):
+		default:
+			line++
+		}
+	}
+	return first, last
+}
+
+// typeDecl is the declaration of a type and its source.
+type typeDecl struct {
+	decl ast.Decl
+	// file declaring the type, "" for the file the context is built for
+	file string
+	text string
+}
+
+// typeDecls maps the names of the types declared in file, named filename,
+// to their declaration; text returns the source of a node.
+func typeDecls(file *ast.File, filename string, text func(ast.Node) string) map[string]typeDecl {
+	types := map[string]typeDecl{}
+	for _, decl := range file.Decls {
+		gen, ok := decl.(*ast.GenDecl)
+		if !ok || gen.Tok != token.TYPE {
+			continue
+		}
+		for _, spec := range gen.Specs {
+			types[spec.(*ast.TypeSpec).Name.Name] = typeDecl{decl: gen, file: filename, text: text(gen)}
+		}
+	}
+	return types
+}
+
+// packageTypes maps the names of the types declared in the files of package
+// pkg to their declaration, leaving out filename. Files are read in name
+// order, the first declaration of a name winning.
+func packageTypes(fset *token.FileSet, pkg, filename string, files map[string][]byte) map[string]typeDecl {
+	names := make([]string, 0, len(files))
+	for name := range files {
+		if name != filename {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	types := map[string]typeDecl{}
+	for _, name := range names {
+		src := files[name]
+		file, err := parser.ParseFile(fset, name, src, parser.SkipObjectResolution)
+		if err != nil || file.Name.Name != pkg {
+			continue
+		}
+		text := func(node ast.Node) string {
+			return string(src[fset.Position(node.Pos()).Offset:fset.Position(node.End()).Offset])
+		}
+		for typeName, decl := range typeDecls(file, name, text) {
+			if _, ok := types[typeName]; !ok {
+				types[typeName] = decl
+			}
+		}
+	}
+	return types
+}
+
+// describe names a declaration for a section title.
+func describe(decl ast.Decl) string {
+	switch decl := decl.(type) {
+	case *ast.FuncDecl:
+		if decl.Recv != nil && len(decl.Recv.List) > 0 {
+			return fmt.Sprintf("method %s.%s", receiverType(decl.Recv.List[0].Type), decl.Name.Name)
+		}
+		return "func " + decl.Name.Name
+	case *ast.GenDecl:
+		return decl.Tok.String() + " declaration"
+	}
+	return "declaration"
+}
+
+// receiverType returns the name of a method's receiver type.
+func receiverType(expr ast.Expr) string {
+	switch expr := expr.(type) {
+	case *ast.StarExpr:
+		return receiverType(expr.X)
+	case *ast.IndexExpr:
+		return receiverType(expr.X)
+	case *ast.IndexListExpr:
+		return receiverType(expr.X)
+	case *ast.Ident:
+		return expr.Name
+	}
+	return "?"
+}
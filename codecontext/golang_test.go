@@ -0,0 +1,151 @@
+package codecontext
+
+import (
+	"ai-api/diff"
+	"strings"
+	"testing"
+)
+
+const cacheSrc = `package store
+
+import (
+	"os"
+	"sync"
+)
+
+// Cache keeps entries in memory.
+type Cache struct {
+	mu      sync.Mutex
+	entries map[string]Entry
+}
+
+// Save writes the cache to f.
+func (c *Cache) Save(f *os.File) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for key, entry := range c.entries {
+		f.WriteString(key + entry.Value)
+	}
+	return nil
+}
+
+// Size returns the number of entries.
+func Size(c *Cache) int {
+	return len(c.entries)
+}
+`
+
+// entrySrc is another file of package store.
+const entrySrc = `package store
+
+// Entry is a cached value.
+type Entry struct {
+	Value string
+}
+
+// Unused is not referred to by the cache.
+type Unused int
+`
+
+func TestGo(t *testing.T) {
+	// line 19 is inside Cache.Save, line 26 inside Size
+	saveHunk := diff.Hunk{NewStart: 18, Lines: []string{" \tfor key, entry := range c.entries {", "-\t\tf.WriteString(key)", "+\t\tf.WriteString(key + entry.Value)", " \t}"}}
+	sizeHunk := diff.Hunk{NewStart: 26, Lines: []string{"+\treturn len(c.entries)"}}
+	tests := []struct {
+		name     string
+		hunks    []diff.Hunk
+		budget   int
+		pkgFiles map[string][]byte
+		want     []string
+		notWant  []string
+	}{
+		{
+			name:    "enclosing method and same-file types",
+			hunks:   []diff.Hunk{saveHunk},
+			budget:  1000,
+			want:    []string{"// imports\nimport (", "// method Cache.Save (lines 15-22)\nfunc (c *Cache) Save", "// type Cache\ntype Cache struct"},
+			notWant: []string{"func Size", "type Entry"},
+		},
+		{
+			name:     "enclosing func",
+			hunks:    []diff.Hunk{sizeHunk},
+			budget:   1000,
+			want:     []string{"// func Size (lines 25-27)\nfunc Size(c *Cache) int {", "// type Cache\n", "// type Entry (entry.go)\n"},
+			notWant:  []string{"func (c *Cache) Save"},
+			pkgFiles: map[string][]byte{"store/entry.go": []byte(entrySrc)},
+		},
+		{
+			// Entry is used by the fields of Cache
+			name:     "types of other package files",
+			hunks:    []diff.Hunk{saveHunk},
+			budget:   1000,
+			pkgFiles: map[string][]byte{"store/entry.go": []byte(entrySrc), "store/cache.go": []byte("package store\n\ntype Entry bool\n")},
+			want:     []string{"// type Entry (entry.go)\ntype Entry struct {\n\tValue string\n}"},
+			notWant:  []string{"Unused", "type Entry bool"},
+		},
+		{
+			name:     "other packages and broken files skipped",
+			hunks:    []diff.Hunk{saveHunk},
+			budget:   1000,
+			pkgFiles: map[string][]byte{"store/entry_test.go": []byte(strings.Replace(entrySrc, "package store", "package store_test", 1)), "store/broken.go": []byte("package store\n\ntype Entry struct {")},
+			want:     []string{"// type Cache\n"},
+			notWant:  []string{"type Entry"},
+		},
+		{
+			// the imports and the method fit, the type after them does not
+			name:    "budget cut-off",
+			hunks:   []diff.Hunk{saveHunk},
+			budget:  75,
+			want:    []string{"// imports\n", "// method Cache.Save"},
+			notWant: []string{"// type Cache"},
+		},
+		{
+			// the method is larger than the budget, the type after it fits
+			name:    "section larger than the budget skipped",
+			hunks:   []diff.Hunk{saveHunk},
+			budget:  40,
+			want:    []string{"// imports\n", "// type Cache\n"},
+			notWant: []string{"func (c *Cache) Save"},
+		},
+		{
+			name:    "no budget",
+			hunks:   []diff.Hunk{saveHunk},
+			budget:  0,
+			notWant: []string{"//"},
+		},
+		{
+			name:    "no changed lines",
+			hunks:   []diff.Hunk{{NewStart: 18, Lines: []string{" \tfor key, entry := range c.entries {"}}},
+			budget:  1000,
+			want:    []string{"// imports\n"},
+			notWant: []string{"func", "type"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Go("store/cache.go", []byte(cacheSrc), tt.hunks, tt.budget, tt.pkgFiles)
+			if err != nil {
+				t.Fatalf("Go: %v", err)
+			}
+			if EstimateTokens(got) > tt.budget {
+				t.Errorf("context of %d tokens exceeds the budget of %d:\n%s", EstimateTokens(got), tt.budget, got)
+			}
+			for _, want := range tt.want {
+				if !strings.Contains(got, want) {
+					t.Errorf("context lacks %q:\n%s", want, got)
+				}
+			}
+			for _, notWant := range tt.notWant {
+				if strings.Contains(got, notWant) {
+					t.Errorf("context has %q:\n%s", notWant, got)
+				}
+			}
+		})
+	}
+}
+
+func TestGoInvalidSource(t *testing.T) {
+	if _, err := Go("store/cache.go", []byte("package store\n\nfunc {"), nil, 1000, nil); err == nil {
+		t.Fatal("Go of invalid source returned no error")
+	}
+}
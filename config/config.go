@@ -7,6 +7,7 @@ package config
 import (
 	"fmt"
 	"strings"
+	"time"
 
 	"github.com/joho/godotenv"
 	"github.com/knadh/koanf/providers/env"
@@ -17,10 +18,54 @@ import (
 type Config struct {
 	GithubToken      string `koanf:"github_token"`
 	GithubBaseURL    string `koanf:"github_base_url"`
+	GithubHosts      string `koanf:"github_hosts"`       // per-owner GitHub hosts, e.g. "acme=https://ghe.internal/api/v3"
+	GithubHostTokens string `koanf:"github_host_tokens"` // per-owner tokens for GithubHosts, e.g. "acme=ghp_..."
+	CABundle         string `koanf:"ca_bundle"`          // extra PEM CA certificates trusted for outbound TLS
+	HTTPReplayMode   string `koanf:"http_replay_mode"`   // "record" outbound traffic to HTTPFixtureDir or "replay" it from there; live when empty
+	HTTPFixtureDir   string `koanf:"http_fixture_dir"`   // directory of recorded HTTP exchanges
+	GitlabToken      string `koanf:"gitlab_token"`
+	GitlabBaseURL    string `koanf:"gitlab_base_url"`
+	BitbucketToken   string `koanf:"bitbucket_token"`
+	BitbucketBaseURL string `koanf:"bitbucket_base_url"`
+	GiteaToken       string `koanf:"gitea_token"`
+	GiteaBaseURL     string `koanf:"gitea_base_url"`
+	SCMProvider      string `koanf:"scm_provider"`    // provider serving /v1/api/pr routes: github, gitlab, bitbucket or gitea
+	OwnerProviders   string `koanf:"owner_providers"` // per-owner overrides, e.g. "platform=gitea,data=bitbucket"
 	LLMServiceURL    string `koanf:"llm_base_url"`
 	LLMServiceAPIKey string `koanf:"llm_api_key"`
 	LLMModel         string `koanf:"llm_model"`
 	LLMAnalyzePrompt string `koanf:"llm_analyze_pr_prompt"`
+	PromptDir        string `koanf:"prompt_dir"`           // directory of *.tmpl prompt templates
+	DefaultPrompt    string `koanf:"default_prompt"`       // template used when nothing else is selected
+	PromptByLanguage string `koanf:"prompt_by_language"`   // per-language templates, e.g. "go=go-review,python=py-review"
+	PromptVersions   string `koanf:"prompt_versions"`      // stable template versions, e.g. "default=v2"; the latest otherwise
+	PromptExperiment string `koanf:"prompt_experiments"`   // candidate versions and their share of reviews, e.g. "default=v3:20"
+	StoreFile        string `koanf:"store_file"`           // JSON file recording reviews for prompt statistics; in memory when empty
+	ContextTokens    int    `koanf:"context_token_budget"` // tokens of surrounding Go code added to prompts; 0 uses the default, negative disables
+	Analyzers        string `koanf:"analyzers"`            // go/analysis analyzers run on Go changes, e.g. "vet,nilness,ctxfirst"; none when empty
+	AnalyzerRepos    string `koanf:"analyzer_repos"`       // trusted repos the analyzers run on, e.g. "acme/*,tools/cli"; none when empty
+	AnalyzerSandbox  string `koanf:"analyzer_sandbox"`     // command isolating the analysis process; "unshare --net --map-root-user" when empty
+	APIKeysFile      string `koanf:"api_keys_file"`
+	WebhookSecret    string `koanf:"webhook_secret"`         // secret GitHub signs webhook deliveries with; webhooks are rejected when empty
+	BotLogin         string `koanf:"bot_login"`              // GitHub login the reviewer posts as, e.g. "pr-checker[bot]"
+	MaxThreadReplies int    `koanf:"max_thread_replies"`     // replies the bot posts per comment thread; 0 uses the default
+	MaxReviewsPerDay int    `koanf:"max_reviews_per_day"`    // pull request reviews run per UTC day; unlimited when 0
+	FeedbackInterval string `koanf:"feedback_poll_interval"` // how often reactions and thread resolution are fetched, e.g. "1h"; never when empty
+	FeedbackMaxAge   string `koanf:"feedback_max_age"`       // how long after its last review a closed pull request's feedback is fetched; "336h" when empty
+	AuthDisabled     bool   `koanf:"auth_disabled"`
+	DryRun           bool   `koanf:"dry_run"`
+	SummaryDisabled  bool   `koanf:"summary_disabled"` // skip the pr-level summary comment
+	IncludePaths     string `koanf:"include_paths"`    // comma separated path globs reviewed by default
+	ExcludePaths     string `koanf:"exclude_paths"`    // comma separated path globs never reviewed, e.g. "vendor/,**/*.pb.go"
+	TenantsFile      string `koanf:"tenants_file"`     // YAML file of tenants with their own settings, see Tenant
+
+	// authorization of /v1/api requests beyond api key scopes, see package authz
+	AuthzMode       string `koanf:"authz_mode"`        // "local" evaluates AuthzTuplesFile in memory, "openfga" checks with OpenFGAURL; off when empty
+	AuthzTuplesFile string `koanf:"authz_tuples_file"` // YAML relationship tuples for the local evaluator
+	OpenFGAURL      string `koanf:"openfga_api_url"`
+	OpenFGAStoreID  string `koanf:"openfga_store_id"`
+	OpenFGAModelID  string `koanf:"openfga_model_id"` // authorization model checked against; the store's latest when empty
+	OpenFGAToken    string `koanf:"openfga_api_token"`
 }
 
 // LoadConfig reads configuration from a .env file and environment variables.
@@ -30,11 +75,16 @@ func LoadConfig(envFile string) (*Config, error) {
 	if err != nil {
 		return nil, fmt.Errorf("failed to load env file: %w", err)
 	}
+	return LoadConfigFromEnv()
+}
 
+// LoadConfigFromEnv reads configuration from environment variables only,
+// for commands that work without an env file.
+func LoadConfigFromEnv() (*Config, error) {
 	var k = koanf.New(".")
 
 	// Load environment variables with the prefix "AICHECKER_".
-	err = k.Load(env.Provider("AI_CHECKER_", ".", func(s string) string {
+	err := k.Load(env.Provider("AI_CHECKER_", ".", func(s string) string {
 		// Transform environment variable names to match struct field names
 		return strings.ToLower(strings.TrimPrefix(s, "AI_CHECKER_"))
 	}), nil)
@@ -48,3 +98,91 @@ func LoadConfig(envFile string) (*Config, error) {
 	}
 	return &cfg, nil
 }
+
+// GithubHost is the GitHub (or GitHub Enterprise Server) instance an owner lives on.
+type GithubHost struct {
+	BaseURL string
+	Token   string
+}
+
+// GithubHostsByOwner returns the owners configured in GithubHosts with their
+// host and token. Owners without a host-specific token use GithubToken.
+func (c Config) GithubHostsByOwner() map[string]GithubHost {
+	tokens := parseKeyValueList(c.GithubHostTokens)
+	hosts := map[string]GithubHost{}
+	for owner, baseURL := range parseKeyValueList(c.GithubHosts) {
+		token, ok := tokens[owner]
+		if !ok {
+			token = c.GithubToken
+		}
+		hosts[owner] = GithubHost{BaseURL: baseURL, Token: token}
+	}
+	return hosts
+}
+
+// PromptsByLanguage returns the per-language prompt templates of PromptByLanguage.
+func (c Config) PromptsByLanguage() map[string]string {
+	return parseKeyValueList(c.PromptByLanguage)
+}
+
+// StablePromptVersions returns the stable template versions of PromptVersions.
+func (c Config) StablePromptVersions() map[string]string {
+	return parseKeyValueList(c.PromptVersions)
+}
+
+// PromptExperiments returns the "<version>:<percent>" experiment of each
+// template in PromptExperiment.
+func (c Config) PromptExperiments() map[string]string {
+	return parseKeyValueList(c.PromptExperiment)
+}
+
+// FeedbackPollInterval parses FeedbackInterval. It returns 0 when feedback
+// is not polled.
+func (c Config) FeedbackPollInterval() (time.Duration, error) {
+	if c.FeedbackInterval == "" {
+		return 0, nil
+	}
+	interval, err := time.ParseDuration(c.FeedbackInterval)
+	if err != nil || interval <= 0 {
+		return 0, fmt.Errorf("invalid feedback_poll_interval %q", c.FeedbackInterval)
+	}
+	return interval, nil
+}
+
+// defaultFeedbackMaxAge is FeedbackMaxAge when not set.
+const defaultFeedbackMaxAge = 14 * 24 * time.Hour
+
+// FeedbackRefreshAge parses FeedbackMaxAge.
+func (c Config) FeedbackRefreshAge() (time.Duration, error) {
+	if c.FeedbackMaxAge == "" {
+		return defaultFeedbackMaxAge, nil
+	}
+	age, err := time.ParseDuration(c.FeedbackMaxAge)
+	if err != nil || age <= 0 {
+		return 0, fmt.Errorf("invalid feedback_max_age %q", c.FeedbackMaxAge)
+	}
+	return age, nil
+}
+
+// ProviderForOwner returns the SCM provider configured for owner in
+// OwnerProviders, falling back to SCMProvider.
+func (c Config) ProviderForOwner(owner string) string {
+	if provider, ok := parseKeyValueList(c.OwnerProviders)[owner]; ok {
+		return provider
+	}
+	return c.SCMProvider
+}
+
+// parseKeyValueList parses "key=value,key2=value2" into a map. Entries
+// without "=" are ignored.
+func parseKeyValueList(list string) map[string]string {
+	values := map[string]string{}
+	for _, entry := range strings.Split(list, ",") {
+		key, value, ok := strings.Cut(entry, "=")
+		if !ok {
+			continue
+		}
+		values[strings.TrimSpace(key)] = strings.TrimSpace(value)
+	}
+	return values
+}
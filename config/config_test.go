@@ -0,0 +1,28 @@
+package config
+
+import (
+	"errors"
+	"io/fs"
+	"path/filepath"
+	"testing"
+)
+
+func TestLoadConfigMissingFile(t *testing.T) {
+	_, err := LoadConfig(filepath.Join(t.TempDir(), ".env"))
+	if !errors.Is(err, fs.ErrNotExist) {
+		t.Fatalf("LoadConfig() error = %v, want fs.ErrNotExist", err)
+	}
+}
+
+func TestLoadConfigFromEnv(t *testing.T) {
+	t.Setenv("AI_CHECKER_LLM_MODEL", "gpt-4o-mini")
+	t.Setenv("AI_CHECKER_MAX_THREAD_REPLIES", "5")
+	t.Setenv("AI_CHECKER_DRY_RUN", "true")
+	cfg, err := LoadConfigFromEnv()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.LLMModel != "gpt-4o-mini" || cfg.MaxThreadReplies != 5 || !cfg.DryRun {
+		t.Errorf("LoadConfigFromEnv() = model %q, replies %d, dry run %v", cfg.LLMModel, cfg.MaxThreadReplies, cfg.DryRun)
+	}
+}
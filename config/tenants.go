@@ -0,0 +1,260 @@
+package config
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/knadh/koanf/v2"
+	"gopkg.in/yaml.v3"
+)
+
+// Tenant is an organization served by a shared instance with its own
+// credentials, providers, prompts, models and limits. Requests for one of
+// its Owners, or webhooks from one of its GitHub App Installations, are
+// handled with the instance's config overridden by Settings.
+type Tenant struct {
+	Name          string   `yaml:"name"`
+	Owners        []string `yaml:"owners"`
+	Installations []int64  `yaml:"installations"`
+	// InheritCredentials lets the tenant use the instance's credentials,
+	// see credentialKeys, for those it does not set; without it the tenant
+	// must set its own.
+	InheritCredentials bool `yaml:"inherit_credentials"`
+	// Settings are config keys, named like the env variables without the
+	// AI_CHECKER_ prefix (e.g. github_token, llm_model, prompt_dir).
+	Settings map[string]interface{} `yaml:"settings"`
+}
+
+// instanceKeys are settings of the whole instance that tenants cannot override.
+var instanceKeys = map[string]bool{
+	"tenants_file":           true,
+	"api_keys_file":          true,
+	"auth_disabled":          true,
+	"webhook_secret":         true,
+	"ca_bundle":              true,
+	"http_replay_mode":       true,
+	"http_fixture_dir":       true,
+	"feedback_poll_interval": true,
+	"feedback_max_age":       true,
+	"analyzer_repos":         true,
+	"analyzer_sandbox":       true,
+	"authz_mode":             true,
+	"authz_tuples_file":      true,
+	"openfga_api_url":        true,
+	"openfga_store_id":       true,
+	"openfga_model_id":       true,
+	"openfga_api_token":      true,
+}
+
+// credentialKeys are the settings holding credentials, or saying where
+// they are sent, which tenants only take from the instance when they
+// inherit its credentials.
+var credentialKeys = map[string]bool{
+	"github_token":       true,
+	"github_hosts":       true,
+	"github_host_tokens": true,
+	"gitlab_token":       true,
+	"bitbucket_token":    true,
+	"gitea_token":        true,
+	"llm_api_key":        true,
+}
+
+// endpointKeys are the settings saying where the credentials are sent. A
+// tenant inheriting the instance's credentials cannot set them, or it could
+// send them to a server of its own.
+var endpointKeys = map[string]bool{
+	"github_base_url":    true,
+	"github_hosts":       true,
+	"gitlab_base_url":    true,
+	"bitbucket_base_url": true,
+	"gitea_base_url":     true,
+	"llm_base_url":       true,
+}
+
+// scmTokenKeys are the credentialKeys of SCM providers, one of which a
+// tenant with its own credentials must set.
+var scmTokenKeys = []string{"github_token", "gitlab_token", "bitbucket_token", "gitea_token"}
+
+var tenantNamePattern = regexp.MustCompile(`^[a-z0-9][a-z0-9_-]*$`)
+
+// LoadTenants reads the tenants of a YAML file holding a "tenants" list and
+// checks that names are unique and no owner or installation belongs to two
+// tenants.
+func LoadTenants(path string) ([]Tenant, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read tenants file: %w", err)
+	}
+	var file struct {
+		Tenants []Tenant `yaml:"tenants"`
+	}
+	dec := yaml.NewDecoder(bytes.NewReader(data))
+	dec.KnownFields(true)
+	if err := dec.Decode(&file); err != nil {
+		return nil, fmt.Errorf("failed to parse tenants file: %w", err)
+	}
+
+	names := map[string]bool{}
+	owners := map[string]string{}
+	installations := map[int64]string{}
+	for _, tenant := range file.Tenants {
+		if !tenantNamePattern.MatchString(tenant.Name) {
+			return nil, fmt.Errorf("invalid tenant name %q: use lowercase letters, digits, - and _", tenant.Name)
+		}
+		if names[tenant.Name] {
+			return nil, fmt.Errorf("tenant %s is defined twice", tenant.Name)
+		}
+		names[tenant.Name] = true
+		if len(tenant.Owners) == 0 && len(tenant.Installations) == 0 {
+			return nil, fmt.Errorf("tenant %s has no owners or installations", tenant.Name)
+		}
+		for _, owner := range tenant.Owners {
+			key := strings.ToLower(owner)
+			if other, ok := owners[key]; ok {
+				return nil, fmt.Errorf("owner %s belongs to tenants %s and %s", owner, other, tenant.Name)
+			}
+			owners[key] = tenant.Name
+		}
+		for _, id := range tenant.Installations {
+			if other, ok := installations[id]; ok {
+				return nil, fmt.Errorf("installation %d belongs to tenants %s and %s", id, other, tenant.Name)
+			}
+			installations[id] = tenant.Name
+		}
+		for key := range tenant.Settings {
+			if err := checkTenantKey(key); err != nil {
+				return nil, fmt.Errorf("tenant %s: %w", tenant.Name, err)
+			}
+		}
+	}
+	return file.Tenants, nil
+}
+
+// checkTenantKey reports settings that are unknown or instance-wide.
+func checkTenantKey(key string) error {
+	if instanceKeys[key] {
+		return fmt.Errorf("%s is set for the whole instance and cannot be overridden", key)
+	}
+	if !configKeys()[key] {
+		known := make([]string, 0, len(configKeys()))
+		for k := range configKeys() {
+			if !instanceKeys[k] {
+				known = append(known, k)
+			}
+		}
+		sort.Strings(known)
+		return fmt.Errorf("unknown setting %s, want one of %s", key, strings.Join(known, ", "))
+	}
+	return nil
+}
+
+// configKeys returns the koanf keys of Config.
+func configKeys() map[string]bool {
+	keys := map[string]bool{}
+	t := reflect.TypeOf(Config{})
+	for i := 0; i < t.NumField(); i++ {
+		if key := t.Field(i).Tag.Get("koanf"); key != "" {
+			keys[key] = true
+		}
+	}
+	return keys
+}
+
+// Apply returns base with the tenant's settings applied. Unless the tenant
+// inherits credentials, the instance's credentials are left out and the
+// tenant must set an LLM key and a token for at least one SCM provider; a
+// tenant inheriting them cannot change the endpoints of endpointKeys. A
+// tenant without its own store_file records its reviews next to the
+// instance's store file (reviews.json becomes reviews.<tenant>.json).
+func (t Tenant) Apply(base Config) (Config, error) {
+	cfg := base
+	if !t.InheritCredentials {
+		if err := t.checkCredentials(); err != nil {
+			return cfg, err
+		}
+		cfg = withoutCredentials(cfg)
+	}
+	k := koanf.New(".")
+	for key, value := range t.Settings {
+		if err := checkTenantKey(key); err != nil {
+			return cfg, fmt.Errorf("tenant %s: %w", t.Name, err)
+		}
+		if t.InheritCredentials && endpointKeys[key] {
+			return cfg, fmt.Errorf("tenant %s inherits the instance's credentials and cannot set %s: set its own credentials instead", t.Name, key)
+		}
+		if err := k.Set(key, value); err != nil {
+			return cfg, fmt.Errorf("tenant %s: %w", t.Name, err)
+		}
+	}
+	if err := k.Unmarshal("", &cfg); err != nil {
+		return cfg, fmt.Errorf("tenant %s: error unmarshaling settings: %v", t.Name, err)
+	}
+	if _, ok := t.Settings["store_file"]; !ok && base.StoreFile != "" {
+		ext := filepath.Ext(base.StoreFile)
+		cfg.StoreFile = strings.TrimSuffix(base.StoreFile, ext) + "." + t.Name + ext
+	}
+	return cfg, nil
+}
+
+// withoutCredentials returns cfg with the settings of credentialKeys unset.
+func withoutCredentials(cfg Config) Config {
+	v := reflect.ValueOf(&cfg).Elem()
+	for i := 0; i < v.NumField(); i++ {
+		if credentialKeys[v.Type().Field(i).Tag.Get("koanf")] {
+			v.Field(i).SetZero()
+		}
+	}
+	return cfg
+}
+
+// checkCredentials reports a tenant without inherited credentials that does
+// not set its own.
+func (t Tenant) checkCredentials() error {
+	if !t.sets("llm_api_key") {
+		return fmt.Errorf("tenant %s sets no llm_api_key: set one, or inherit_credentials: true to use the instance's", t.Name)
+	}
+	for _, key := range scmTokenKeys {
+		if t.sets(key) {
+			return nil
+		}
+	}
+	return fmt.Errorf("tenant %s sets none of %s: set one, or inherit_credentials: true to use the instance's", t.Name, strings.Join(scmTokenKeys, ", "))
+}
+
+// sets reports whether the tenant sets key to a non-empty value.
+func (t Tenant) sets(key string) bool {
+	value, ok := t.Settings[key]
+	return ok && value != nil && fmt.Sprint(value) != ""
+}
+
+// ApplyTenants applies each tenant to base, see Tenant.Apply, and checks
+// that no two of the instance and its tenants share a store file, so
+// tenants never share stored data.
+func ApplyTenants(base Config, tenants []Tenant) ([]Config, error) {
+	storeFiles := map[string]string{}
+	if base.StoreFile != "" {
+		storeFiles[filepath.Clean(base.StoreFile)] = "the instance"
+	}
+	cfgs := make([]Config, len(tenants))
+	for i, tenant := range tenants {
+		cfg, err := tenant.Apply(base)
+		if err != nil {
+			return nil, err
+		}
+		if cfg.StoreFile != "" {
+			path := filepath.Clean(cfg.StoreFile)
+			if other, ok := storeFiles[path]; ok {
+				return nil, fmt.Errorf("tenant %s: store_file %s is used by %s", tenant.Name, cfg.StoreFile, other)
+			}
+			storeFiles[path] = "tenant " + tenant.Name
+		}
+		cfgs[i] = cfg
+	}
+	return cfgs, nil
+}
@@ -0,0 +1,270 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestLoadTenants(t *testing.T) {
+	tests := []struct {
+		name    string
+		file    string
+		want    []Tenant
+		wantErr string
+	}{
+		{
+			name: "valid",
+			file: `
+tenants:
+  - name: acme
+    owners: [acme, acme-labs]
+    installations: [4242]
+    settings:
+      github_token: ghp_acme
+      llm_api_key: sk-acme
+  - name: globex
+    owners: [globex]
+    inherit_credentials: true
+`,
+			want: []Tenant{
+				{Name: "acme", Owners: []string{"acme", "acme-labs"}, Installations: []int64{4242}, Settings: map[string]interface{}{"github_token": "ghp_acme", "llm_api_key": "sk-acme"}},
+				{Name: "globex", Owners: []string{"globex"}, InheritCredentials: true},
+			},
+		},
+		{
+			name:    "unknown field",
+			file:    "tenants:\n  - name: acme\n    owner: [acme]\n",
+			wantErr: "failed to parse tenants file",
+		},
+		{
+			name:    "invalid name",
+			file:    "tenants:\n  - name: Acme\n    owners: [acme]\n",
+			wantErr: `invalid tenant name "Acme"`,
+		},
+		{
+			name:    "duplicate name",
+			file:    "tenants:\n  - name: acme\n    owners: [acme]\n  - name: acme\n    owners: [globex]\n",
+			wantErr: "tenant acme is defined twice",
+		},
+		{
+			name:    "no owners or installations",
+			file:    "tenants:\n  - name: acme\n",
+			wantErr: "tenant acme has no owners or installations",
+		},
+		{
+			name:    "shared owner in another case",
+			file:    "tenants:\n  - name: acme\n    owners: [acme]\n  - name: globex\n    owners: [ACME]\n",
+			wantErr: "owner ACME belongs to tenants acme and globex",
+		},
+		{
+			name:    "shared installation",
+			file:    "tenants:\n  - name: acme\n    installations: [1]\n  - name: globex\n    installations: [1]\n",
+			wantErr: "installation 1 belongs to tenants acme and globex",
+		},
+		{
+			name:    "instance setting",
+			file:    "tenants:\n  - name: acme\n    owners: [acme]\n    settings:\n      webhook_secret: s\n",
+			wantErr: "tenant acme: webhook_secret is set for the whole instance",
+		},
+		{
+			name:    "unknown setting",
+			file:    "tenants:\n  - name: acme\n    owners: [acme]\n    settings:\n      llm_modle: gpt-4o\n",
+			wantErr: "tenant acme: unknown setting llm_modle",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "tenants.yaml")
+			if err := os.WriteFile(path, []byte(tt.file), 0o600); err != nil {
+				t.Fatal(err)
+			}
+			got, err := LoadTenants(path)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("LoadTenants() error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("LoadTenants() error = %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("LoadTenants() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestTenantApply(t *testing.T) {
+	base := Config{
+		GithubToken:      "ghp_instance",
+		GithubHosts:      "acme=https://ghe.internal/api/v3",
+		LLMServiceAPIKey: "sk-instance",
+		LLMModel:         "gpt-4o",
+		StoreFile:        "/var/lib/pr-checker/reviews.json",
+		MaxReviewsPerDay: 1000,
+	}
+	tests := []struct {
+		name    string
+		tenant  Tenant
+		want    func(*Config)
+		wantErr string
+	}{
+		{
+			name: "own credentials",
+			tenant: Tenant{Name: "acme", Settings: map[string]interface{}{
+				"gitlab_token":        "glpat-acme",
+				"llm_api_key":         "sk-acme",
+				"max_reviews_per_day": 200,
+			}},
+			want: func(c *Config) {
+				c.GithubToken, c.GithubHosts = "", ""
+				c.GitlabToken, c.LLMServiceAPIKey = "glpat-acme", "sk-acme"
+				c.MaxReviewsPerDay = 200
+				c.StoreFile = "/var/lib/pr-checker/reviews.acme.json"
+			},
+		},
+		{
+			name:   "inherited credentials",
+			tenant: Tenant{Name: "acme", InheritCredentials: true, Settings: map[string]interface{}{"llm_model": "gpt-4o-mini"}},
+			want: func(c *Config) {
+				c.LLMModel = "gpt-4o-mini"
+				c.StoreFile = "/var/lib/pr-checker/reviews.acme.json"
+			},
+		},
+		{
+			name: "own store file",
+			tenant: Tenant{Name: "acme", InheritCredentials: true, Settings: map[string]interface{}{
+				"store_file": "/srv/acme/reviews.json",
+			}},
+			want: func(c *Config) { c.StoreFile = "/srv/acme/reviews.json" },
+		},
+		{
+			name:    "no llm key",
+			tenant:  Tenant{Name: "acme", Settings: map[string]interface{}{"github_token": "ghp_acme"}},
+			wantErr: "tenant acme sets no llm_api_key",
+		},
+		{
+			name:    "no scm token",
+			tenant:  Tenant{Name: "acme", Settings: map[string]interface{}{"llm_api_key": "sk-acme", "github_token": ""}},
+			wantErr: "tenant acme sets none of github_token, gitlab_token, bitbucket_token, gitea_token",
+		},
+		{
+			name: "own endpoints",
+			tenant: Tenant{Name: "acme", Settings: map[string]interface{}{
+				"github_token":    "ghp_acme",
+				"github_base_url": "https://github.acme.internal/api/v3",
+				"llm_api_key":     "sk-acme",
+				"llm_base_url":    "https://llm.acme.internal/v1",
+			}},
+			want: func(c *Config) {
+				c.GithubToken, c.GithubHosts = "ghp_acme", ""
+				c.GithubBaseURL = "https://github.acme.internal/api/v3"
+				c.LLMServiceAPIKey, c.LLMServiceURL = "sk-acme", "https://llm.acme.internal/v1"
+				c.StoreFile = "/var/lib/pr-checker/reviews.acme.json"
+			},
+		},
+		{
+			name:    "inherited credentials sent to a github base url",
+			tenant:  Tenant{Name: "acme", InheritCredentials: true, Settings: map[string]interface{}{"github_base_url": "https://evil.example/api/v3"}},
+			wantErr: "tenant acme inherits the instance's credentials and cannot set github_base_url",
+		},
+		{
+			name:    "inherited credentials sent to a github host",
+			tenant:  Tenant{Name: "acme", InheritCredentials: true, Settings: map[string]interface{}{"github_hosts": "acme=https://evil.example/api/v3"}},
+			wantErr: "cannot set github_hosts",
+		},
+		{
+			name:    "inherited credentials sent to an llm base url",
+			tenant:  Tenant{Name: "acme", InheritCredentials: true, Settings: map[string]interface{}{"llm_base_url": "https://evil.example/v1"}},
+			wantErr: "cannot set llm_base_url",
+		},
+		{
+			name:    "inherited credentials sent to a gitea base url",
+			tenant:  Tenant{Name: "acme", InheritCredentials: true, Settings: map[string]interface{}{"gitea_base_url": "https://evil.example"}},
+			wantErr: "cannot set gitea_base_url",
+		},
+		{
+			name:    "instance setting",
+			tenant:  Tenant{Name: "acme", InheritCredentials: true, Settings: map[string]interface{}{"auth_disabled": true}},
+			wantErr: "auth_disabled is set for the whole instance",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.tenant.Apply(base)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("Apply() error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Apply() error = %v", err)
+			}
+			want := base
+			tt.want(&want)
+			if got != want {
+				t.Errorf("Apply() = %+v, want %+v", got, want)
+			}
+		})
+	}
+}
+
+func TestApplyTenantsStoreFiles(t *testing.T) {
+	tenant := func(name, storeFile string) Tenant {
+		settings := map[string]interface{}{}
+		if storeFile != "" {
+			settings["store_file"] = storeFile
+		}
+		return Tenant{Name: name, InheritCredentials: true, Settings: settings}
+	}
+	tests := []struct {
+		name      string
+		storeFile string
+		tenants   []Tenant
+		wantErr   string
+	}{
+		{
+			name:      "derived store files",
+			storeFile: "data/reviews.json",
+			tenants:   []Tenant{tenant("acme", ""), tenant("globex", "")},
+		},
+		{
+			name:    "in memory",
+			tenants: []Tenant{tenant("acme", ""), tenant("globex", "")},
+		},
+		{
+			name:      "instance store file",
+			storeFile: "data/reviews.json",
+			tenants:   []Tenant{tenant("acme", "./data/reviews.json")},
+			wantErr:   "tenant acme: store_file ./data/reviews.json is used by the instance",
+		},
+		{
+			name:      "another tenant's store file",
+			storeFile: "data/reviews.json",
+			tenants:   []Tenant{tenant("acme", ""), tenant("globex", "data/reviews.acme.json")},
+			wantErr:   "tenant globex: store_file data/reviews.acme.json is used by tenant acme",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfgs, err := ApplyTenants(Config{StoreFile: tt.storeFile}, tt.tenants)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("ApplyTenants() error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ApplyTenants() error = %v", err)
+			}
+			if len(cfgs) != len(tt.tenants) {
+				t.Errorf("ApplyTenants() returned %d configs, want %d", len(cfgs), len(tt.tenants))
+			}
+		})
+	}
+}
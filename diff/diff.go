@@ -0,0 +1,246 @@
+// File: diff/diff.go
+// Helpers for working with the unified diff patches GitHub returns for each
+// changed file of a pull request.
+package diff
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// LineForPosition returns the line number in the new version of the file
+// for a GitHub diff position. Position 1 is the line just below the first
+// "@@" hunk header and counting continues through later hunk headers.
+// It returns 0 if the position does not exist or points at a removed line
+// or a "\ No newline" marker.
+func LineForPosition(patch string, position int) int {
+	if position < 1 {
+		return 0
+	}
+	newLine := 0
+	for i, line := range strings.Split(patch, "\n") {
+		if strings.HasPrefix(line, "@@") {
+			newLine = hunkNewStart(line)
+			if i == position {
+				return 0
+			}
+			continue
+		}
+		// the "" after a patch's final newline and the markers are not lines
+		notLine := line == "" || strings.HasPrefix(line, "-") || strings.HasPrefix(line, "\\")
+		if i == position {
+			if notLine {
+				return 0
+			}
+			return newLine
+		}
+		if !notLine {
+			newLine++
+		}
+	}
+	return 0
+}
+
+// hunkNewStart parses the starting line of the new file from a hunk header
+// such as "@@ -10,7 +12,8 @@ func main() {".
+func hunkNewStart(header string) int {
+	fields := strings.Fields(header)
+	for _, field := range fields {
+		if !strings.HasPrefix(field, "+") {
+			continue
+		}
+		start, _, _ := strings.Cut(strings.TrimPrefix(field, "+"), ",")
+		n, err := strconv.Atoi(start)
+		if err != nil {
+			return 0
+		}
+		return n
+	}
+	return 0
+}
+
+// PositionForLine is the inverse of LineForPosition: it returns the GitHub
+// diff position of a line in the new version of the file, or 0 if that line
+// is not part of the patch.
+func PositionForLine(patch string, line int) int {
+	if line < 1 {
+		return 0
+	}
+	newLine := 0
+	for i, text := range strings.Split(patch, "\n") {
+		if strings.HasPrefix(text, "@@") {
+			newLine = hunkNewStart(text)
+			continue
+		}
+		if text == "" || strings.HasPrefix(text, "-") || strings.HasPrefix(text, "\\") {
+			continue
+		}
+		if newLine == line && i > 0 {
+			return i
+		}
+		newLine++
+	}
+	return 0
+}
+
+// FirstChangedLine returns the new-file line number of the first added line
+// in patch, or the first line of the first hunk if nothing was added.
+func FirstChangedLine(patch string) int {
+	newLine, first := 0, 0
+	for _, text := range strings.Split(patch, "\n") {
+		switch {
+		case strings.HasPrefix(text, "@@"):
+			newLine = hunkNewStart(text)
+			if first == 0 {
+				first = newLine
+			}
+		case strings.HasPrefix(text, "+"):
+			return newLine
+		case text == "", strings.HasPrefix(text, "-"), strings.HasPrefix(text, "\\"):
+		default:
+			newLine++
+		}
+	}
+	return first
+}
+
+// Kinds of diff lines returned by LineKindForPosition.
+const (
+	LineAdded   = "added"
+	LineRemoved = "removed"
+	LineContext = "context"
+)
+
+// LineKindForPosition reports whether the line at a GitHub diff position was
+// added, removed or is unchanged context. It returns "" for hunk headers,
+// "\ No newline" markers and positions outside the patch.
+func LineKindForPosition(patch string, position int) string {
+	lines := strings.Split(patch, "\n")
+	if position < 1 || position >= len(lines) {
+		return ""
+	}
+	switch line := lines[position]; {
+	case line == "", strings.HasPrefix(line, "@@"), strings.HasPrefix(line, "\\"):
+		return ""
+	case strings.HasPrefix(line, "+"):
+		return LineAdded
+	case strings.HasPrefix(line, "-"):
+		return LineRemoved
+	default:
+		return LineContext
+	}
+}
+
+// CodeAtPosition returns the code of the line at a GitHub diff position,
+// without its "+", "-" or " " prefix, or "" for hunk headers and positions
+// outside the patch.
+func CodeAtPosition(patch string, position int) string {
+	if LineKindForPosition(patch, position) == "" {
+		return ""
+	}
+	line := strings.Split(patch, "\n")[position]
+	if line == "" {
+		return ""
+	}
+	return line[1:]
+}
+
+// LastHunkLine returns the code of the last line of a diff hunk, the line a
+// GitHub review comment's diff_hunk ends at.
+func LastHunkLine(hunk string) string {
+	lines := strings.Split(strings.TrimRight(hunk, "\n"), "\n")
+	last := lines[len(lines)-1]
+	if last == "" || strings.HasPrefix(last, "@@") {
+		return ""
+	}
+	return last[1:]
+}
+
+// generatedHeader is the standard marker of generated Go files, see
+// https://go.dev/s/generatedcode
+var generatedHeader = regexp.MustCompile(`^// Code generated .* DO NOT EDIT\.$`)
+
+// IsGenerated reports whether the patch shows the file carries the standard
+// "// Code generated ... DO NOT EDIT." header, on an added or unchanged line.
+func IsGenerated(patch string) bool {
+	for _, line := range strings.Split(patch, "\n") {
+		if line == "" || line[0] == '-' || line[0] == '@' || line[0] == '\\' {
+			continue
+		}
+		if generatedHeader.MatchString(strings.TrimRight(line[1:], "\r")) {
+			return true
+		}
+	}
+	return false
+}
+
+// Hunk is one "@@" section of a patch.
+type Hunk struct {
+	Header   string
+	OldStart int
+	OldLines int
+	NewStart int
+	NewLines int
+	// Lines are the hunk's lines with their " ", "+" or "-" prefix.
+	Lines []string
+}
+
+// Hunks splits a patch into its hunks.
+func Hunks(patch string) []Hunk {
+	var hunks []Hunk
+	for _, line := range strings.Split(patch, "\n") {
+		if strings.HasPrefix(line, "@@") {
+			oldLines, newLines := hunkLengths(line)
+			hunks = append(hunks, Hunk{
+				Header:   line,
+				OldStart: hunkOldStart(line),
+				OldLines: oldLines,
+				NewStart: hunkNewStart(line),
+				NewLines: newLines,
+			})
+			continue
+		}
+		if len(hunks) > 0 {
+			hunks[len(hunks)-1].Lines = append(hunks[len(hunks)-1].Lines, line)
+		}
+	}
+	return hunks
+}
+
+// InSingleHunk reports whether the new-file lines first through last all
+// appear on the right side of the same hunk, as added or unchanged lines.
+// GitHub only accepts multi-line comments, and so suggestions, on such a
+// range.
+func InSingleHunk(patch string, first, last int) bool {
+	if first < 1 || last < first {
+		return false
+	}
+	for _, hunk := range Hunks(patch) {
+		line, end := hunk.NewStart, 0
+		for _, text := range hunk.Lines {
+			// the "" after a patch's final newline is not a line
+			if text == "" || strings.HasPrefix(text, "-") || strings.HasPrefix(text, "\\") {
+				continue
+			}
+			end = line
+			line++
+		}
+		end = min(end, hunk.NewStart+hunk.NewLines-1)
+		if end > 0 && first >= hunk.NewStart && last <= end {
+			return true
+		}
+	}
+	return false
+}
+
+// hunkOldStart parses the starting line of the old file from a hunk header.
+func hunkOldStart(header string) int {
+	fields := strings.Fields(header)
+	if len(fields) < 2 || !strings.HasPrefix(fields[1], "-") {
+		return 0
+	}
+	start, _, _ := strings.Cut(strings.TrimPrefix(fields[1], "-"), ",")
+	n, _ := strconv.Atoi(start)
+	return n
+}
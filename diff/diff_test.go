@@ -0,0 +1,198 @@
+package diff
+
+import "testing"
+
+// testPatch has two hunks and, like patches read from a file, ends in a
+// newline. Its positions are:
+//
+//	0 @@ -1,4 +1,5 @@
+//	1  package main    new line 1
+//	2 +import "os"     new line 2
+//	3  func main() {   new line 3
+//	4 -	println()
+//	5 +	os.Exit(1)      new line 4
+//	6  }               new line 5
+//	7 @@ -20,2 +21,3 @@
+//	8  func b() {      new line 21
+//	9 +	return         new line 22
+//	10 }               new line 23
+//	11 \ No newline at end of file
+const testPatch = "@@ -1,4 +1,5 @@\n package main\n+import \"os\"\n func main() {\n-\tprintln()\n+\tos.Exit(1)\n }\n" +
+	"@@ -20,2 +21,3 @@\n func b() {\n+\treturn\n }\n\\ No newline at end of file\n"
+
+// markerPatch replaces a last line that had no newline, so a "\ No newline"
+// marker sits between its lines. Its positions are:
+//
+//	0 @@ -1,2 +1,3 @@
+//	1  a               new line 1
+//	2 -b
+//	3 \ No newline at end of file
+//	4 +b               new line 2
+//	5 +c               new line 3
+//	6 \ No newline at end of file
+const markerPatch = "@@ -1,2 +1,3 @@\n a\n-b\n\\ No newline at end of file\n+b\n+c\n\\ No newline at end of file"
+
+func TestMarkerPatchRoundTrip(t *testing.T) {
+	positions := map[int]int{1: 1, 2: 4, 3: 5}
+	for line, position := range positions {
+		if got := PositionForLine(markerPatch, line); got != position {
+			t.Errorf("PositionForLine(%d) = %d, want %d", line, got, position)
+		}
+		if got := LineForPosition(markerPatch, position); got != line {
+			t.Errorf("LineForPosition(%d) = %d, want %d", position, got, line)
+		}
+	}
+	for _, position := range []int{2, 3, 6} {
+		if got := LineForPosition(markerPatch, position); got != 0 {
+			t.Errorf("LineForPosition(%d) = %d, want 0", position, got)
+		}
+	}
+}
+
+func TestLineForPosition(t *testing.T) {
+	tests := []struct {
+		position, want int
+	}{
+		{0, 0},
+		{1, 1},
+		{2, 2},
+		{4, 0}, // removed
+		{5, 4},
+		{6, 5},
+		{7, 0}, // hunk header
+		{8, 21},
+		{10, 23},
+		{11, 0}, // no newline marker
+		{12, 0}, // the "" after the final newline
+		{13, 0}, // past the patch
+	}
+	for _, tt := range tests {
+		if got := LineForPosition(testPatch, tt.position); got != tt.want {
+			t.Errorf("LineForPosition(%d) = %d, want %d", tt.position, got, tt.want)
+		}
+	}
+}
+
+func TestPositionForLine(t *testing.T) {
+	tests := []struct {
+		line, want int
+	}{
+		{0, 0},
+		{1, 1},
+		{4, 5},
+		{5, 6},
+		{6, 0}, // between the hunks
+		{21, 8},
+		{23, 10},
+		{24, 0}, // the "" after the final newline is not a line
+	}
+	for _, tt := range tests {
+		if got := PositionForLine(testPatch, tt.line); got != tt.want {
+			t.Errorf("PositionForLine(%d) = %d, want %d", tt.line, got, tt.want)
+		}
+		if tt.want > 0 {
+			if back := LineForPosition(testPatch, tt.want); back != tt.line {
+				t.Errorf("LineForPosition(PositionForLine(%d)) = %d", tt.line, back)
+			}
+		}
+	}
+}
+
+func TestLineKindForPosition(t *testing.T) {
+	tests := []struct {
+		position int
+		want     string
+	}{
+		{0, ""},
+		{1, LineContext},
+		{2, LineAdded},
+		{4, LineRemoved},
+		{7, ""},
+		{11, ""}, // no newline marker
+		{12, ""}, // trailing ""
+		{13, ""},
+	}
+	for _, tt := range tests {
+		if got := LineKindForPosition(testPatch, tt.position); got != tt.want {
+			t.Errorf("LineKindForPosition(%d) = %q, want %q", tt.position, got, tt.want)
+		}
+	}
+	for position, want := range []string{"", LineContext, LineRemoved, "", LineAdded, LineAdded, ""} {
+		if got := LineKindForPosition(markerPatch, position); got != want {
+			t.Errorf("LineKindForPosition(markerPatch, %d) = %q, want %q", position, got, want)
+		}
+	}
+}
+
+func TestFirstChangedLine(t *testing.T) {
+	tests := []struct {
+		name, patch string
+		want        int
+	}{
+		{"added line", testPatch, 2},
+		{"only removals", "@@ -3,2 +3,1 @@\n keep\n-drop\n", 3},
+		{"after a no newline marker", markerPatch, 2},
+		{"only removals after a marker", "@@ -3,2 +3,1 @@\n-drop\n\\ No newline at end of file\n", 3},
+		{"empty", "", 0},
+	}
+	for _, tt := range tests {
+		if got := FirstChangedLine(tt.patch); got != tt.want {
+			t.Errorf("%s: FirstChangedLine() = %d, want %d", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestInSingleHunk(t *testing.T) {
+	tests := []struct {
+		name        string
+		patch       string
+		first, last int
+		want        bool
+	}{
+		{"whole first hunk", testPatch, 1, 5, true},
+		{"single line", testPatch, 4, 4, true},
+		{"second hunk", testPatch, 21, 23, true},
+		{"across hunks", testPatch, 4, 21, false},
+		{"between hunks", testPatch, 6, 7, false},
+		{"past the final newline", testPatch, 22, 24, false},
+		{"past the end without newline", "@@ -1,1 +1,2 @@\n a\n+b", 1, 3, false},
+		{"bounded by the header", "@@ -1,1 +1,1 @@\n a\n garbage", 1, 2, false},
+		{"across a no newline marker", markerPatch, 1, 3, true},
+		{"past a no newline marker", markerPatch, 2, 4, false},
+		{"reversed", testPatch, 3, 2, false},
+		{"zero", testPatch, 0, 1, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := InSingleHunk(tt.patch, tt.first, tt.last); got != tt.want {
+				t.Errorf("InSingleHunk(%d, %d) = %v, want %v", tt.first, tt.last, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestHunks(t *testing.T) {
+	hunks := Hunks(testPatch)
+	if len(hunks) != 2 {
+		t.Fatalf("Hunks() = %d hunks, want 2", len(hunks))
+	}
+	second := hunks[1]
+	if second.OldStart != 20 || second.OldLines != 2 || second.NewStart != 21 || second.NewLines != 3 {
+		t.Errorf("second hunk = %+v", second)
+	}
+}
+
+func TestLastHunkLine(t *testing.T) {
+	tests := []struct {
+		hunk, want string
+	}{
+		{"@@ -1,2 +1,2 @@\n a\n+b\n", "b"},
+		{"@@ -1,2 +1,2 @@\n a\n-b", "b"},
+		{"@@ -1,2 +1,2 @@", ""},
+	}
+	for _, tt := range tests {
+		if got := LastHunkLine(tt.hunk); got != tt.want {
+			t.Errorf("LastHunkLine(%q) = %q, want %q", tt.hunk, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,157 @@
+package diff
+
+import (
+	"ai-api/models"
+	"bufio"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// Parse reads a unified diff (as produced by `git diff` or `diff -u`) and
+// returns one ChangeFile per file. Each Patch starts at the first "@@" hunk
+// header so it has the same shape as the patches returned by GitHub.
+func Parse(r io.Reader) (*models.ChangeFiles, error) {
+	result := &models.ChangeFiles{Files: []models.ChangeFile{}}
+
+	var (
+		current *models.ChangeFile
+		patch   []string
+		inHunk  bool
+
+		// lines still expected in the current hunk, taken from its header
+		oldLeft, newLeft int
+	)
+	flush := func() {
+		if current == nil {
+			return
+		}
+		current.Patch = strings.Join(patch, "\n")
+		current.Changes = current.Additions + current.Deletions
+		if current.Status == "" {
+			current.Status = "modified"
+		}
+		result.Files = append(result.Files, *current)
+		current, patch, inHunk = nil, nil, false
+	}
+
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), 10*1024*1024)
+	for scanner.Scan() {
+		line := scanner.Text()
+		switch {
+		case strings.HasPrefix(line, "diff --git "):
+			flush()
+			current = &models.ChangeFile{Filename: gitHeaderFilename(line)}
+		case !inHunk && strings.HasPrefix(line, "--- "):
+			// plain `diff -u` output has no "diff --git" line between files
+			if current != nil && len(patch) > 0 {
+				flush()
+			}
+			if current == nil {
+				current = &models.ChangeFile{}
+			}
+			if strings.TrimSpace(strings.TrimPrefix(line, "--- ")) == "/dev/null" {
+				current.Status = "added"
+			}
+		case !inHunk && strings.HasPrefix(line, "+++ "):
+			if current == nil {
+				return nil, fmt.Errorf("malformed diff: %q without preceding file header", line)
+			}
+			name := strings.TrimSpace(strings.TrimPrefix(line, "+++ "))
+			if name == "/dev/null" {
+				current.Status = "removed"
+				continue
+			}
+			current.Filename = stripPathPrefix(name)
+		case current != nil && !inHunk && strings.HasPrefix(line, "new file mode"):
+			current.Status = "added"
+		case current != nil && !inHunk && strings.HasPrefix(line, "deleted file mode"):
+			current.Status = "removed"
+		case current != nil && !inHunk && strings.HasPrefix(line, "rename from "):
+			current.PreviousFilename = strings.TrimPrefix(line, "rename from ")
+		case current != nil && !inHunk && strings.HasPrefix(line, "rename to "):
+			current.Status = "renamed"
+			current.Filename = strings.TrimPrefix(line, "rename to ")
+		case !inHunk && strings.HasPrefix(line, "@@"):
+			if current == nil {
+				return nil, fmt.Errorf("malformed diff: hunk without file header")
+			}
+			oldLeft, newLeft = hunkLengths(line)
+			inHunk = oldLeft > 0 || newLeft > 0
+			patch = append(patch, line)
+		case current != nil && !inHunk && strings.HasPrefix(line, `\`) && len(patch) > 0:
+			patch = append(patch, line)
+		case inHunk:
+			patch = append(patch, line)
+			switch {
+			case strings.HasPrefix(line, "+"):
+				current.Additions++
+				newLeft--
+			case strings.HasPrefix(line, "-"):
+				current.Deletions++
+				oldLeft--
+			case strings.HasPrefix(line, `\`):
+				// "\ No newline at end of file" does not count towards the hunk
+			default:
+				oldLeft--
+				newLeft--
+			}
+			inHunk = oldLeft > 0 || newLeft > 0
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read diff: %w", err)
+	}
+	flush()
+
+	return result, nil
+}
+
+// gitHeaderFilename extracts the new file name from "diff --git a/x b/x".
+func gitHeaderFilename(header string) string {
+	fields := strings.Fields(strings.TrimPrefix(header, "diff --git "))
+	if len(fields) == 0 {
+		return ""
+	}
+	return stripPathPrefix(fields[len(fields)-1])
+}
+
+// hunkLengths returns the old and new line counts from a hunk header such as
+// "@@ -10,7 +12,8 @@". A missing count means one line.
+func hunkLengths(header string) (oldCount, newCount int) {
+	fields := strings.Fields(header)
+	if len(fields) > 3 {
+		fields = fields[:3]
+	}
+	for _, field := range fields {
+		if len(field) < 2 || (field[0] != '-' && field[0] != '+') {
+			continue
+		}
+		count := 1
+		if _, n, ok := strings.Cut(field[1:], ","); ok {
+			count, _ = strconv.Atoi(n)
+		}
+		if field[0] == '-' {
+			oldCount = count
+		} else {
+			newCount = count
+		}
+	}
+	return oldCount, newCount
+}
+
+func stripPathPrefix(name string) string {
+	// drop a trailing timestamp from `diff -u` output
+	if i := strings.IndexByte(name, '\t'); i >= 0 {
+		name = name[:i]
+	}
+	// git uses a/ and b/, Bitbucket Server uses src:// and dst://
+	for _, prefix := range []string{"a/", "b/", "src://", "dst://"} {
+		if strings.HasPrefix(name, prefix) {
+			return strings.TrimPrefix(name, prefix)
+		}
+	}
+	return name
+}
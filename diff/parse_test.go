@@ -0,0 +1,152 @@
+package diff
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	type file struct {
+		name, previous, status string
+		additions, deletions   int
+		patchLines             int
+	}
+	tests := []struct {
+		name    string
+		diff    string
+		want    []file
+		wantErr bool
+	}{
+		{
+			name: "git prefixes",
+			diff: `diff --git a/main.go b/main.go
+index 1111111..2222222 100644
+--- a/main.go
++++ b/main.go
+@@ -1,3 +1,4 @@
+ package main
++import "fmt"
+ func main() {
+-}
++	fmt.Println()
+`,
+			want: []file{{name: "main.go", status: "modified", additions: 2, deletions: 1, patchLines: 6}},
+		},
+		{
+			name: "bitbucket server prefixes",
+			diff: `diff --git src://store/cache.go dst://store/cache.go
+--- src://store/cache.go
++++ dst://store/cache.go
+@@ -1,2 +1,3 @@
+ package store
++
+ type Cache struct{}
+diff --git src://store/metrics.go dst://store/metrics.go
+new file mode 100644
+--- /dev/null
++++ dst://store/metrics.go
+@@ -0,0 +1 @@
++package store
+`,
+			want: []file{
+				{name: "store/cache.go", status: "modified", additions: 1, patchLines: 4},
+				{name: "store/metrics.go", status: "added", additions: 1, patchLines: 2},
+			},
+		},
+		{
+			name: "diff -u with timestamps",
+			diff: "--- old/config.yaml\t2024-01-01 10:00:00\n+++ new/config.yaml\t2024-01-02 10:00:00\n@@ -1 +1 @@\n-a: 1\n+a: 2\n" +
+				"--- old/other.yaml\t2024-01-01 10:00:00\n+++ new/other.yaml\t2024-01-02 10:00:00\n@@ -1 +1,2 @@\n b: 1\n+c: 2\n",
+			want: []file{
+				{name: "new/config.yaml", status: "modified", additions: 1, deletions: 1, patchLines: 3},
+				{name: "new/other.yaml", status: "modified", additions: 1, patchLines: 3},
+			},
+		},
+		{
+			name: "deleted file",
+			diff: `diff --git a/old.go b/old.go
+deleted file mode 100644
+--- a/old.go
++++ /dev/null
+@@ -1,2 +0,0 @@
+-package old
+-var x = 1
+`,
+			want: []file{{name: "old.go", status: "removed", deletions: 2, patchLines: 3}},
+		},
+		{
+			name: "renamed file",
+			diff: `diff --git a/pkg/old_name.go b/pkg/new_name.go
+similarity index 90%
+rename from pkg/old_name.go
+rename to pkg/new_name.go
+--- a/pkg/old_name.go
++++ b/pkg/new_name.go
+@@ -1 +1 @@
+-package old
++package pkg
+`,
+			want: []file{{name: "pkg/new_name.go", previous: "pkg/old_name.go", status: "renamed", additions: 1, deletions: 1, patchLines: 3}},
+		},
+		{
+			name: "no newline at end of file",
+			diff: `diff --git a/a.txt b/a.txt
+--- a/a.txt
++++ b/a.txt
+@@ -1 +1 @@
+-one
+\ No newline at end of file
++two
+\ No newline at end of file
+`,
+			want: []file{{name: "a.txt", status: "modified", additions: 1, deletions: 1, patchLines: 5}},
+		},
+		{
+			name: "deleted line starting with dashes",
+			diff: `diff --git a/notes.md b/notes.md
+--- a/notes.md
++++ b/notes.md
+@@ -1,2 +1 @@
+ # Notes
+--- draft
+`,
+			want: []file{{name: "notes.md", status: "modified", deletions: 1, patchLines: 3}},
+		},
+		{
+			name:    "hunk without file header",
+			diff:    "@@ -1 +1 @@\n-a\n+b\n",
+			wantErr: true,
+		},
+		{
+			name:    "new file name without old",
+			diff:    "+++ b/x.go\n@@ -0,0 +1 @@\n+x\n",
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Parse(strings.NewReader(tt.diff))
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Parse() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if len(got.Files) != len(tt.want) {
+				t.Fatalf("Parse() returned %d files, want %d", len(got.Files), len(tt.want))
+			}
+			for i, w := range tt.want {
+				f := got.Files[i]
+				if f.Filename != w.name || f.PreviousFilename != w.previous || f.Status != w.status {
+					t.Errorf("file %d = %q (from %q) %s, want %q (from %q) %s", i, f.Filename, f.PreviousFilename, f.Status, w.name, w.previous, w.status)
+				}
+				if f.Additions != w.additions || f.Deletions != w.deletions || f.Changes != w.additions+w.deletions {
+					t.Errorf("file %q +%d -%d (%d), want +%d -%d", f.Filename, f.Additions, f.Deletions, f.Changes, w.additions, w.deletions)
+				}
+				if lines := len(strings.Split(f.Patch, "\n")); lines != w.patchLines || !strings.HasPrefix(f.Patch, "@@") {
+					t.Errorf("file %q patch has %d lines, want %d starting with @@:\n%s", f.Filename, lines, w.patchLines, f.Patch)
+				}
+			}
+		})
+	}
+}
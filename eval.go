@@ -0,0 +1,137 @@
+package main
+
+import (
+	"ai-api/clients"
+	"ai-api/config"
+	"ai-api/eval"
+	"ai-api/services"
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io/fs"
+	"os"
+	"time"
+)
+
+// runEval reviews every case of a golden dataset, with the configured LLM or
+// with the responses recorded for each case, and scores the findings against
+// the expected ones. It returns a nonzero exit code when F1 is below -min-f1.
+func runEval(args []string) int {
+	flags := flag.NewFlagSet("eval", flag.ContinueOnError)
+	envFile := flags.String("env", ".env", "path to the env file")
+	casesDir := flags.String("cases", "testdata/eval", "directory of cases, one subdirectory each")
+	stub := flags.Bool("stub", false, "replay the responses recorded for each case instead of calling the LLM")
+	record := flags.Bool("record", false, "call the LLM and save its responses to each case for -stub")
+	tolerance := flags.Int("tolerance", eval.DefaultTolerance, "lines a finding may be off from the expected line")
+	format := flags.String("format", "text", "output format: text or json")
+	minF1 := flags.Float64("min-f1", 0, "exit nonzero when F1 is below this score")
+	if err := flags.Parse(args); err != nil {
+		return exitError
+	}
+	if *stub && *record {
+		fmt.Fprintln(os.Stderr, "use either -stub or -record, not both")
+		return exitError
+	}
+	if *format != "text" && *format != "json" {
+		fmt.Fprintf(os.Stderr, "unknown output format %q\n", *format)
+		return exitError
+	}
+
+	cases, err := eval.LoadCases(*casesDir)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return exitError
+	}
+
+	// replaying needs no credentials, so CI may run without an env file
+	cfg, err := config.LoadConfig(*envFile)
+	if err != nil && *stub && errors.Is(err, fs.ErrNotExist) {
+		cfg, err = &config.Config{}, nil
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return exitError
+	}
+
+	var (
+		replay   *eval.Recorded
+		recorder *eval.Recorder
+		llm      clients.OpenFGAClientInterface
+	)
+	switch {
+	case *stub:
+		replay = eval.NewRecorded()
+		llm = replay
+	case *record:
+		httpClient, err := clients.NewHTTPClient(60*time.Second, cfg.CABundle)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return exitError
+		}
+		live, err := clients.NewOpenFGAClient(httpClient, cfg.LLMServiceAPIKey, cfg.LLMServiceURL, cfg.LLMModel)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "failed to create the LLM client:", err)
+			return exitError
+		}
+		recorder = &eval.Recorder{LLM: live}
+		llm = recorder
+	}
+
+	var svc *services.Services
+	if llm != nil {
+		svc, err = services.NewServicesWithLLM(*cfg, llm)
+	} else {
+		svc, err = services.NewServices(*cfg)
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return exitError
+	}
+
+	ctx := context.Background()
+	results := make([]eval.Result, 0, len(cases))
+	for _, c := range cases {
+		if replay != nil {
+			replay.Use(c)
+		}
+		if recorder != nil {
+			recorder.Use(c)
+		}
+		findings, err := svc.PRService.ReviewDiff(ctx, c.Changes, nil, c.Contents())
+		result := eval.Score(c, findings, *tolerance)
+		if err != nil {
+			result.Error = err.Error()
+		} else if recorder != nil {
+			if err := c.SaveResponses(recorder.Responses()); err != nil {
+				result.Error = err.Error()
+			}
+		}
+		results = append(results, result)
+	}
+
+	totals := eval.Total(results)
+	if *format == "json" {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(struct {
+			Totals  eval.Totals   `json:"totals"`
+			Results []eval.Result `json:"results"`
+		}{totals, results}); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return exitError
+		}
+	} else {
+		eval.WriteReport(os.Stdout, results)
+	}
+
+	if totals.Failed > 0 {
+		return exitError
+	}
+	if totals.F1 < *minF1 {
+		fmt.Fprintf(os.Stderr, "F1 %.3f is below %.3f\n", totals.F1, *minF1)
+		return exitFindings
+	}
+	return exitOK
+}
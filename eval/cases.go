@@ -0,0 +1,208 @@
+// File: eval/cases.go
+// Offline evaluation of the review pipeline against a golden dataset: each
+// case is a diff with the findings a good review should report. Cases are
+// reviewed with the live LLM or with responses recorded from it, and the
+// findings are scored against the expected ones.
+package eval
+
+import (
+	"ai-api/clients"
+	"ai-api/diff"
+	"ai-api/models"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Files of a case directory.
+const (
+	// PatchFile is the unified diff to review.
+	PatchFile = "patch.diff"
+	// ExpectedFile lists the expected findings.
+	ExpectedFile = "expected.yaml"
+	// ContextDir holds the changed files at the head of the diff, used as
+	// surrounding code like the repository contents of a pull request.
+	ContextDir = "context"
+	// ResponsesFile holds the recorded model responses, see Recorded.
+	ResponsesFile = "responses.yaml"
+)
+
+// headRef is the ref the context files are served at.
+const headRef = "eval"
+
+// Expected is a finding a review of the case should report.
+type Expected struct {
+	File string `yaml:"file" json:"file"`
+	Line int    `yaml:"line" json:"line"`
+	// Category must match the finding's category (case-insensitively);
+	// any category matches when empty.
+	Category string `yaml:"category,omitempty" json:"category,omitempty"`
+	// Note says what the finding is about, for the report.
+	Note string `yaml:"note,omitempty" json:"note,omitempty"`
+}
+
+// Case is one diff of the dataset.
+type Case struct {
+	Name     string
+	Dir      string
+	Changes  *models.ChangeFiles
+	Expected []Expected
+	// Responses are the recorded model responses by file, nil when none
+	// were recorded.
+	Responses map[string]string
+}
+
+// LoadCases reads every case of a dataset directory: each subdirectory
+// holding a patch.diff, in name order.
+func LoadCases(dir string) ([]Case, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read cases: %w", err)
+	}
+	var cases []Case
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			continue
+		}
+		caseDir := filepath.Join(dir, entry.Name())
+		if _, err := os.Stat(filepath.Join(caseDir, PatchFile)); errors.Is(err, fs.ErrNotExist) {
+			continue
+		}
+		c, err := loadCase(entry.Name(), caseDir)
+		if err != nil {
+			return nil, err
+		}
+		cases = append(cases, c)
+	}
+	if len(cases) == 0 {
+		return nil, fmt.Errorf("no cases found in %s", dir)
+	}
+	sort.Slice(cases, func(i, j int) bool { return cases[i].Name < cases[j].Name })
+	return cases, nil
+}
+
+func loadCase(name, dir string) (Case, error) {
+	c := Case{Name: name, Dir: dir}
+	patch, err := os.ReadFile(filepath.Join(dir, PatchFile))
+	if err != nil {
+		return c, fmt.Errorf("case %s: %w", name, err)
+	}
+	c.Changes, err = diff.Parse(bytes.NewReader(patch))
+	if err != nil {
+		return c, fmt.Errorf("case %s: %w", name, err)
+	}
+	for _, file := range c.Changes.Files {
+		if err := checkHunks(file.Patch); err != nil {
+			return c, fmt.Errorf("case %s: %s: %w", name, file.Filename, err)
+		}
+	}
+	c.Changes.HeadSHA = headRef
+
+	var expected struct {
+		Findings []Expected `yaml:"findings"`
+	}
+	if err := readYAML(filepath.Join(dir, ExpectedFile), &expected); err != nil {
+		return c, fmt.Errorf("case %s: %w", name, err)
+	}
+	c.Expected = expected.Findings
+
+	if err := readYAML(filepath.Join(dir, ResponsesFile), &c.Responses); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return c, fmt.Errorf("case %s: %w", name, err)
+	}
+	return c, nil
+}
+
+// checkHunks reports hunks whose header miscounts their lines; the
+// expected lines of a case would not point where the header says.
+func checkHunks(patch string) error {
+	for _, hunk := range diff.Hunks(strings.TrimSuffix(patch, "\n")) {
+		oldLines, newLines := 0, 0
+		for _, line := range hunk.Lines {
+			switch {
+			case strings.HasPrefix(line, "+"):
+				newLines++
+			case strings.HasPrefix(line, "-"):
+				oldLines++
+			case strings.HasPrefix(line, "\\"):
+			default:
+				oldLines++
+				newLines++
+			}
+		}
+		if oldLines != hunk.OldLines || newLines != hunk.NewLines {
+			return fmt.Errorf("hunk %q has %d old and %d new lines", hunk.Header, oldLines, newLines)
+		}
+	}
+	return nil
+}
+
+// readYAML decodes a YAML file, rejecting unknown keys.
+func readYAML(path string, out interface{}) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	dec := yaml.NewDecoder(bytes.NewReader(data))
+	dec.KnownFields(true)
+	if err := dec.Decode(out); err != nil {
+		return fmt.Errorf("failed to parse %s: %w", filepath.Base(path), err)
+	}
+	return nil
+}
+
+// SaveResponses writes the recorded responses of a case.
+func (c Case) SaveResponses(responses map[string]string) error {
+	data, err := yaml.Marshal(responses)
+	if err != nil {
+		return fmt.Errorf("failed to encode responses: %w", err)
+	}
+	return os.WriteFile(filepath.Join(c.Dir, ResponsesFile), data, 0o644)
+}
+
+// Contents serves the files of the case's context directory, the way a
+// provider serves repository files, for the surrounding code of Go files.
+func (c Case) Contents() ContextFiles {
+	return ContextFiles{Dir: filepath.Join(c.Dir, ContextDir)}
+}
+
+// ContextFiles serves files of a directory as the repository at the head of
+// a case.
+type ContextFiles struct {
+	Dir string
+}
+
+// FetchFileContents implements services.ContentsFetcher.
+func (f ContextFiles) FetchFileContents(ctx context.Context, owner, repo, path, ref string) ([]byte, error) {
+	data, err := os.ReadFile(filepath.Join(f.Dir, filepath.FromSlash(path)))
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, fmt.Errorf("%s: %w", path, clients.ErrNotFound)
+	}
+	return data, err
+}
+
+// ListDirectory implements services.DirectoryLister.
+func (f ContextFiles) ListDirectory(ctx context.Context, owner, repo, dir, ref string) ([]string, error) {
+	entries, err := os.ReadDir(filepath.Join(f.Dir, filepath.FromSlash(dir)))
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, fmt.Errorf("%s: %w", dir, clients.ErrNotFound)
+	}
+	if err != nil {
+		return nil, err
+	}
+	var paths []string
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			paths = append(paths, path.Join(dir, entry.Name()))
+		}
+	}
+	return paths, nil
+}
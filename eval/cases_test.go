@@ -0,0 +1,43 @@
+package eval
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestLoadCases(t *testing.T) {
+	cases, err := LoadCases("../testdata/eval")
+	if err != nil {
+		t.Fatalf("LoadCases() error = %v", err)
+	}
+	for _, c := range cases {
+		if len(c.Changes.Files) == 0 || len(c.Expected) == 0 {
+			t.Errorf("case %s has %d files and %d expected findings", c.Name, len(c.Changes.Files), len(c.Expected))
+		}
+	}
+}
+
+func TestCheckHunks(t *testing.T) {
+	tests := []struct {
+		name, patch, wantErr string
+	}{
+		{"counts match", "@@ -1,2 +1,3 @@\n a\n-b\n+c\n+d\n", ""},
+		{"no newline marker", "@@ -1 +1 @@\n-a\n\\ No newline at end of file\n+b\n", ""},
+		{"new side overcounted", "@@ -1,2 +1,4 @@\n a\n-b\n+c\n+d\n", "2 old and 3 new"},
+		{"old side undercounted", "@@ -1,1 +1,3 @@\n a\n-b\n+c\n+d\n", "2 old and 3 new"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := checkHunks(tt.patch)
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Errorf("checkHunks() error = %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("checkHunks() error = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
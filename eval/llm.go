@@ -0,0 +1,120 @@
+package eval
+
+import (
+	"ai-api/clients"
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+)
+
+// fileForPrompt returns the file of files whose name appears in a review
+// prompt, preferring the longest name so "a/b.go" wins over "b.go".
+func fileForPrompt(prompt string, files []string) (string, bool) {
+	best := ""
+	for _, file := range files {
+		if strings.Contains(prompt, file) && len(file) > len(best) {
+			best = file
+		}
+	}
+	return best, best != ""
+}
+
+// Recorded is an LLM client replaying the responses recorded for a case, so
+// evaluations run without network access. The response for a prompt is the
+// one recorded for the file the prompt reviews. No style guide chunks are
+// retrieved.
+type Recorded struct {
+	mu        sync.Mutex
+	responses map[string]string
+}
+
+// NewRecorded returns a client with no responses; see Use.
+func NewRecorded() *Recorded {
+	return &Recorded{}
+}
+
+// Use replays the responses of c from now on.
+func (r *Recorded) Use(c Case) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.responses = c.Responses
+}
+
+// GenerateReviewComment implements clients.OpenFGAClientInterface.
+func (r *Recorded) GenerateReviewComment(ctx context.Context, req clients.ReviewRequest) (string, error) {
+	return r.Complete(ctx, req.CodeDiff, req.Model)
+}
+
+// RelevantStyleChunks implements clients.OpenFGAClientInterface.
+func (r *Recorded) RelevantStyleChunks(ctx context.Context, code string, extra []string) ([]string, error) {
+	return nil, nil
+}
+
+// Complete implements clients.OpenFGAClientInterface.
+func (r *Recorded) Complete(ctx context.Context, prompt, model string) (string, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	files := make([]string, 0, len(r.responses))
+	for file := range r.responses {
+		files = append(files, file)
+	}
+	file, ok := fileForPrompt(prompt, files)
+	if !ok {
+		return "", fmt.Errorf("no recorded response for prompt, record the case again")
+	}
+	return r.responses[file], nil
+}
+
+// Recorder passes prompts to a live LLM client and keeps its responses by
+// the file they review, to be saved with Case.SaveResponses. Style guide
+// retrieval is skipped so recorded and replayed prompts are the same.
+type Recorder struct {
+	LLM clients.OpenFGAClientInterface
+
+	mu        sync.Mutex
+	files     []string
+	responses map[string]string
+}
+
+// Use records the responses for the files of c from now on.
+func (r *Recorder) Use(c Case) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.files = r.files[:0]
+	for _, file := range c.Changes.Files {
+		r.files = append(r.files, file.Filename)
+	}
+	r.responses = map[string]string{}
+}
+
+// Responses returns the responses recorded since Use.
+func (r *Recorder) Responses() map[string]string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.responses
+}
+
+// GenerateReviewComment implements clients.OpenFGAClientInterface.
+func (r *Recorder) GenerateReviewComment(ctx context.Context, req clients.ReviewRequest) (string, error) {
+	return r.Complete(ctx, req.CodeDiff, req.Model)
+}
+
+// RelevantStyleChunks implements clients.OpenFGAClientInterface.
+func (r *Recorder) RelevantStyleChunks(ctx context.Context, code string, extra []string) ([]string, error) {
+	return nil, nil
+}
+
+// Complete implements clients.OpenFGAClientInterface.
+func (r *Recorder) Complete(ctx context.Context, prompt, model string) (string, error) {
+	response, err := r.LLM.Complete(ctx, prompt, model)
+	if err != nil {
+		return "", err
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if file, ok := fileForPrompt(prompt, r.files); ok {
+		r.responses[file] = response
+	}
+	return response, nil
+}
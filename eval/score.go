@@ -0,0 +1,156 @@
+package eval
+
+import (
+	"ai-api/models"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// DefaultTolerance is how many lines a finding may be off from the expected
+// line and still match.
+const DefaultTolerance = 3
+
+// Match pairs an expected finding with the finding that reported it.
+type Match struct {
+	Expected Expected                       `json:"expected"`
+	Found    models.GeneratePRCommentParams `json:"found"`
+}
+
+// Result is the score of one case. Near are findings on an expected line
+// whose category differs; they count as unexpected and the expected finding
+// as missed.
+type Result struct {
+	Case       string                           `json:"case"`
+	Matched    []Match                          `json:"matched,omitempty"`
+	Missed     []Expected                       `json:"missed,omitempty"`
+	Unexpected []models.GeneratePRCommentParams `json:"unexpected,omitempty"`
+	Near       []Match                          `json:"near,omitempty"`
+	Error      string                           `json:"error,omitempty"`
+}
+
+// Score matches the findings of a case against its expected findings. Each
+// finding matches at most one expected finding in the same file, within
+// tolerance lines and of the same category, closest line first.
+func Score(c Case, findings []models.GeneratePRCommentParams, tolerance int) Result {
+	result := Result{Case: c.Name}
+	used := make([]bool, len(findings))
+	for _, expected := range c.Expected {
+		best := -1
+		for i, found := range findings {
+			if used[i] || !nearLine(expected, found, tolerance) || !sameCategory(expected, found) {
+				continue
+			}
+			if best < 0 || distance(expected, found) < distance(expected, findings[best]) {
+				best = i
+			}
+		}
+		if best < 0 {
+			result.Missed = append(result.Missed, expected)
+			continue
+		}
+		used[best] = true
+		result.Matched = append(result.Matched, Match{Expected: expected, Found: findings[best]})
+	}
+	for i, found := range findings {
+		if used[i] {
+			continue
+		}
+		result.Unexpected = append(result.Unexpected, found)
+		for _, missed := range result.Missed {
+			if nearLine(missed, found, tolerance) {
+				result.Near = append(result.Near, Match{Expected: missed, Found: found})
+				break
+			}
+		}
+	}
+	return result
+}
+
+func nearLine(expected Expected, found models.GeneratePRCommentParams, tolerance int) bool {
+	return expected.File == found.FileName && distance(expected, found) <= tolerance
+}
+
+func sameCategory(expected Expected, found models.GeneratePRCommentParams) bool {
+	return expected.Category == "" || strings.EqualFold(expected.Category, found.Category)
+}
+
+func distance(expected Expected, found models.GeneratePRCommentParams) int {
+	if d := expected.Line - found.Line; d > 0 {
+		return d
+	}
+	return found.Line - expected.Line
+}
+
+// Totals are the precision, recall and F1 of a set of results.
+type Totals struct {
+	Cases      int     `json:"cases"`
+	Failed     int     `json:"failed"`
+	Matched    int     `json:"matched"`
+	Missed     int     `json:"missed"`
+	Unexpected int     `json:"unexpected"`
+	Precision  float64 `json:"precision"`
+	Recall     float64 `json:"recall"`
+	F1         float64 `json:"f1"`
+}
+
+// Total sums the results. Failed cases count their expected findings as
+// missed.
+func Total(results []Result) Totals {
+	var t Totals
+	for _, r := range results {
+		t.Cases++
+		if r.Error != "" {
+			t.Failed++
+		}
+		t.Matched += len(r.Matched)
+		t.Missed += len(r.Missed)
+		t.Unexpected += len(r.Unexpected)
+	}
+	if found := t.Matched + t.Unexpected; found > 0 {
+		t.Precision = float64(t.Matched) / float64(found)
+	}
+	if expected := t.Matched + t.Missed; expected > 0 {
+		t.Recall = float64(t.Matched) / float64(expected)
+	}
+	if t.Precision+t.Recall > 0 {
+		t.F1 = 2 * t.Precision * t.Recall / (t.Precision + t.Recall)
+	}
+	return t
+}
+
+// WriteReport writes the per-case diff of expected and found findings,
+// followed by the totals: "+" lines were matched, "-" lines missed, "?"
+// lines unexpected and "~" lines on an expected line with another category.
+func WriteReport(w io.Writer, results []Result) {
+	for _, r := range results {
+		t := Total([]Result{r})
+		fmt.Fprintf(w, "== %s  precision %.2f  recall %.2f\n", r.Case, t.Precision, t.Recall)
+		if r.Error != "" {
+			fmt.Fprintf(w, "  ! %s\n", r.Error)
+		}
+		for _, m := range r.Matched {
+			fmt.Fprintf(w, "  + %s  found %d [%s]\n", expectedLine(m.Expected), m.Found.Line, m.Found.Category)
+		}
+		for _, e := range r.Missed {
+			fmt.Fprintf(w, "  - %s\n", expectedLine(e))
+		}
+		for _, m := range r.Near {
+			fmt.Fprintf(w, "  ~ %s  found %d [%s]\n", expectedLine(m.Expected), m.Found.Line, m.Found.Category)
+		}
+		for _, f := range r.Unexpected {
+			fmt.Fprintf(w, "  ? %s:%d [%s] %s\n", f.FileName, f.Line, f.Category, f.Headline())
+		}
+	}
+	t := Total(results)
+	fmt.Fprintf(w, "\n%d cases (%d failed): %d matched, %d missed, %d unexpected\n", t.Cases, t.Failed, t.Matched, t.Missed, t.Unexpected)
+	fmt.Fprintf(w, "precision %.3f  recall %.3f  F1 %.3f\n", t.Precision, t.Recall, t.F1)
+}
+
+func expectedLine(e Expected) string {
+	s := fmt.Sprintf("%s:%d [%s]", e.File, e.Line, e.Category)
+	if e.Note != "" {
+		s += " " + e.Note
+	}
+	return s
+}
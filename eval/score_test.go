@@ -0,0 +1,115 @@
+package eval
+
+import (
+	"ai-api/models"
+	"bytes"
+	"math"
+	"strings"
+	"testing"
+)
+
+func finding(file string, line int, category string) models.GeneratePRCommentParams {
+	return models.GeneratePRCommentParams{FileName: file, Line: line, Category: category, CommentBody: category + " finding\nmore detail"}
+}
+
+func TestScore(t *testing.T) {
+	c := Case{Name: "case", Expected: []Expected{
+		{File: "a.go", Line: 10, Category: "bug"},
+		{File: "a.go", Line: 20, Category: "concurrency"},
+		{File: "b.go", Line: 5},
+	}}
+	tests := []struct {
+		name      string
+		findings  []models.GeneratePRCommentParams
+		tolerance int
+		matched   int
+		missed    int
+		unexpect  int
+		near      int
+	}{
+		{
+			name:     "all found",
+			findings: []models.GeneratePRCommentParams{finding("a.go", 10, "bug"), finding("a.go", 20, "Concurrency"), finding("b.go", 5, "style")},
+			matched:  3,
+		},
+		{
+			name:      "within tolerance",
+			findings:  []models.GeneratePRCommentParams{finding("a.go", 13, "bug")},
+			tolerance: 3,
+			matched:   1, missed: 2,
+		},
+		{
+			name:     "beyond tolerance",
+			findings: []models.GeneratePRCommentParams{finding("a.go", 14, "bug")},
+			missed:   3, unexpect: 1,
+		},
+		{
+			name:     "other file",
+			findings: []models.GeneratePRCommentParams{finding("b.go", 10, "bug")},
+			missed:   3, unexpect: 1,
+		},
+		{
+			name:     "other category on the line",
+			findings: []models.GeneratePRCommentParams{finding("a.go", 10, "style")},
+			missed:   3, unexpect: 1, near: 1,
+		},
+		{
+			name:     "one finding matches once",
+			findings: []models.GeneratePRCommentParams{finding("a.go", 10, "bug"), finding("a.go", 11, "bug")},
+			matched:  1, missed: 2, unexpect: 1,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tolerance := tt.tolerance
+			if tolerance == 0 {
+				tolerance = DefaultTolerance
+			}
+			r := Score(c, tt.findings, tolerance)
+			if len(r.Matched) != tt.matched || len(r.Missed) != tt.missed || len(r.Unexpected) != tt.unexpect || len(r.Near) != tt.near {
+				t.Errorf("Score() = %d matched, %d missed, %d unexpected, %d near; want %d, %d, %d, %d",
+					len(r.Matched), len(r.Missed), len(r.Unexpected), len(r.Near), tt.matched, tt.missed, tt.unexpect, tt.near)
+			}
+		})
+	}
+}
+
+func TestScoreClosestLineFirst(t *testing.T) {
+	c := Case{Expected: []Expected{{File: "a.go", Line: 10}}}
+	r := Score(c, []models.GeneratePRCommentParams{finding("a.go", 12, "bug"), finding("a.go", 9, "bug")}, DefaultTolerance)
+	if len(r.Matched) != 1 || r.Matched[0].Found.Line != 9 {
+		t.Errorf("Score() matched %+v, want the finding on line 9", r.Matched)
+	}
+}
+
+func TestTotal(t *testing.T) {
+	results := []Result{
+		{Matched: make([]Match, 3), Unexpected: make([]models.GeneratePRCommentParams, 1)},
+		{Missed: make([]Expected, 1), Error: "model failed"},
+	}
+	got := Total(results)
+	want := Totals{Cases: 2, Failed: 1, Matched: 3, Missed: 1, Unexpected: 1, Precision: 0.75, Recall: 0.75, F1: 0.75}
+	if got.Cases != want.Cases || got.Failed != want.Failed || got.Matched != want.Matched || got.Missed != want.Missed || got.Unexpected != want.Unexpected {
+		t.Errorf("Total() = %+v, want %+v", got, want)
+	}
+	for _, pair := range [][2]float64{{got.Precision, want.Precision}, {got.Recall, want.Recall}, {got.F1, want.F1}} {
+		if math.Abs(pair[0]-pair[1]) > 1e-9 {
+			t.Errorf("Total() = %+v, want %+v", got, want)
+		}
+	}
+	if empty := Total(nil); empty.Precision != 0 || empty.Recall != 0 || empty.F1 != 0 {
+		t.Errorf("Total(nil) = %+v, want zero rates", empty)
+	}
+}
+
+func TestWriteReport(t *testing.T) {
+	c := Case{Name: "case", Expected: []Expected{{File: "a.go", Line: 10, Category: "bug", Note: "nil map"}}}
+	r := Score(c, []models.GeneratePRCommentParams{finding("a.go", 10, "style")}, DefaultTolerance)
+	var b bytes.Buffer
+	WriteReport(&b, []Result{r})
+	for _, want := range []string{"== case", "- a.go:10 [bug] nil map", "~ a.go:10", "? a.go:10 [style] style finding\n"} {
+		if !strings.Contains(b.String(), want) {
+			t.Errorf("report missing %q:\n%s", want, b.String())
+		}
+	}
+}
@@ -0,0 +1,104 @@
+package fakegithub
+
+import (
+	"net/http"
+	"path"
+	"strings"
+)
+
+// Response is a canned response injected with Fail.
+type Response struct {
+	Status int
+	Header http.Header
+	Body   string
+}
+
+// failure answers matching requests with resp instead of serving them.
+type failure struct {
+	method  string
+	pattern string
+	left    int // requests still to fail; forever when negative
+	resp    Response
+}
+
+// Fail answers the next times requests with method (any when empty) whose
+// path matches pattern with resp, or every one when times is 0. Patterns
+// use path.Match syntax against the request path, e.g.
+// "/repos/*/*/pulls/*/comments"; "*" matches every path.
+func (s *Server) Fail(method, pattern string, times int, resp Response) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	left := times
+	if times <= 0 {
+		left = -1
+	}
+	s.failures = append(s.failures, &failure{method: method, pattern: pattern, left: left, resp: resp})
+}
+
+// ClearFailures removes every injected failure.
+func (s *Server) ClearFailures() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.failures = nil
+}
+
+// injectedFailure returns the response of the first failure matching req
+// and counts it down. s.mu must be held.
+func (s *Server) injectedFailure(method, requestPath string) (Response, bool) {
+	for i, f := range s.failures {
+		if (f.method != "" && f.method != method) || !matchPath(f.pattern, requestPath) {
+			continue
+		}
+		if f.left > 0 {
+			f.left--
+			if f.left == 0 {
+				s.failures = append(s.failures[:i], s.failures[i+1:]...)
+			}
+		}
+		return f.resp, true
+	}
+	return Response{}, false
+}
+
+func matchPath(pattern, requestPath string) bool {
+	if pattern == "*" {
+		return true
+	}
+	ok, _ := path.Match(pattern, requestPath)
+	return ok
+}
+
+// SecondaryRateLimit is GitHub's answer when a client posts too much too
+// fast.
+func SecondaryRateLimit() Response {
+	return Response{
+		Status: http.StatusForbidden,
+		Header: http.Header{"Retry-After": {"60"}, "X-Ratelimit-Remaining": {"4999"}},
+		Body:   `{"message":"You have exceeded a secondary rate limit. Please wait a few minutes before you try again.","documentation_url":"https://docs.github.com/rest/overview/rate-limits-for-the-rest-api#about-secondary-rate-limits"}`,
+	}
+}
+
+// RateLimitExceeded is GitHub's answer when the primary rate limit is used up.
+func RateLimitExceeded() Response {
+	return Response{
+		Status: http.StatusForbidden,
+		Header: http.Header{"X-Ratelimit-Remaining": {"0"}, "X-Ratelimit-Reset": {"1893456000"}},
+		Body:   `{"message":"API rate limit exceeded.","documentation_url":"https://docs.github.com/rest/overview/rate-limits-for-the-rest-api"}`,
+	}
+}
+
+// InvalidPosition is GitHub's answer to a review comment on a line outside
+// the diff.
+func InvalidPosition() Response {
+	return Response{Status: http.StatusUnprocessableEntity, Body: invalidPositionBody}
+}
+
+// ServerError is a 5xx answer, e.g. 502 Bad Gateway.
+func ServerError(status int) Response {
+	return Response{
+		Status: status,
+		Body:   `{"message":"` + strings.ToLower(http.StatusText(status)) + `"}`,
+	}
+}
+
+const invalidPositionBody = `{"message":"Validation Failed","errors":[{"resource":"PullRequestReviewComment","code":"custom","field":"pull_request_review_thread.line","message":"pull_request_review_thread.line must be part of the diff"}],"documentation_url":"https://docs.github.com/rest/pulls/comments#create-a-review-comment-for-a-pull-request"}`
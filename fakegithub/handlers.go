@@ -0,0 +1,667 @@
+package fakegithub
+
+import (
+	"ai-api/diff"
+	"ai-api/models"
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// serveHTTP records the request, checks its token, answers it with an
+// injected failure if one matches and routes it otherwise.
+func (s *Server) serveHTTP(w http.ResponseWriter, r *http.Request) {
+	body, _ := io.ReadAll(r.Body)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.requests = append(s.requests, Request{Method: r.Method, Path: r.URL.Path, Query: r.URL.RawQuery, Body: body})
+
+	if s.Token != "" {
+		header := r.Header.Get("Authorization")
+		if header != "token "+s.Token && header != "Bearer "+s.Token {
+			writeJSON(w, http.StatusUnauthorized, message("Bad credentials"))
+			return
+		}
+	}
+	if resp, ok := s.injectedFailure(r.Method, r.URL.Path); ok {
+		for name, values := range resp.Header {
+			w.Header()[name] = values
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(resp.Status)
+		io.WriteString(w, resp.Body)
+		return
+	}
+	if r.URL.Path == "/graphql" && r.Method == http.MethodPost {
+		s.graphql(w, body)
+		return
+	}
+
+	if r.URL.Path == "/user" && r.Method == http.MethodGet {
+		// like GitHub, app installation tokens have no user
+		if user := s.user(); user.Type == "Bot" {
+			writeJSON(w, http.StatusForbidden, message("Resource not accessible by integration"))
+		} else {
+			writeJSON(w, http.StatusOK, user)
+		}
+		return
+	}
+	segments := strings.Split(strings.Trim(r.URL.Path, "/"), "/")
+	if len(segments) < 4 || segments[0] != "repos" {
+		writeJSON(w, http.StatusNotFound, message("Not Found"))
+		return
+	}
+	repo := s.repo(segments[1], segments[2])
+	route := segments[3:]
+	get, post := r.Method == http.MethodGet, r.Method == http.MethodPost
+
+	switch {
+	case get && match(route, "pulls", "*"):
+		s.withPull(w, repo, route[1], func(pr *pullRequest) { writeJSON(w, http.StatusOK, pr.pr) })
+	case get && match(route, "pulls", "*", "files"):
+		s.withPull(w, repo, route[1], func(pr *pullRequest) {
+			lo, hi := paginate(w, r, len(pr.files))
+			writeJSON(w, http.StatusOK, append([]models.ChangeFile{}, pr.files[lo:hi]...))
+		})
+	case get && match(route, "pulls", "*", "comments"):
+		s.withPull(w, repo, route[1], func(pr *pullRequest) {
+			lo, hi := paginate(w, r, len(pr.reviewComments))
+			writeJSON(w, http.StatusOK, append([]ReviewComment{}, pr.reviewComments[lo:hi]...))
+		})
+	case post && match(route, "pulls", "*", "comments"):
+		s.withPull(w, repo, route[1], func(pr *pullRequest) { s.createReviewComment(w, pr, body) })
+	case post && match(route, "pulls", "*", "comments", "*", "replies"):
+		s.withPull(w, repo, route[1], func(pr *pullRequest) { s.replyToReviewComment(w, pr, route[3], body) })
+	case get && match(route, "pulls", "*", "reviews"):
+		s.withPull(w, repo, route[1], func(pr *pullRequest) {
+			lo, hi := paginate(w, r, len(pr.reviews))
+			writeJSON(w, http.StatusOK, append([]Review{}, pr.reviews[lo:hi]...))
+		})
+	case post && match(route, "pulls", "*", "reviews"):
+		s.withPull(w, repo, route[1], func(pr *pullRequest) { s.submitReview(w, pr, body) })
+	case get && match(route, "pulls", "comments", "*", "reactions"):
+		s.listReactions(w, r, repo, route[2])
+	case post && match(route, "pulls", "comments", "*", "reactions"), post && match(route, "issues", "comments", "*", "reactions"):
+		s.addReaction(w, repo, route[2], body)
+	case get && match(route, "issues", "*", "comments"):
+		s.withPull(w, repo, route[1], func(pr *pullRequest) {
+			lo, hi := paginate(w, r, len(pr.issueComments))
+			writeJSON(w, http.StatusOK, append([]models.IssueComment{}, pr.issueComments[lo:hi]...))
+		})
+	case post && match(route, "issues", "*", "comments"):
+		s.withPull(w, repo, route[1], func(pr *pullRequest) { s.createIssueComment(w, pr, body) })
+	case r.Method == http.MethodPatch && match(route, "issues", "comments", "*"):
+		s.editIssueComment(w, repo, route[2], body)
+	case post && match(route, "statuses", "*"):
+		s.setStatus(w, repo, route[1], body)
+	case post && match(route, "check-runs"):
+		s.createCheckRun(w, repo, body)
+	case r.Method == http.MethodPatch && match(route, "check-runs", "*"):
+		s.updateCheckRun(w, repo, route[1], body)
+	case get && route[0] == "contents":
+		s.fileContents(w, r, repo, strings.Join(route[1:], "/"))
+	case get && match(route, "collaborators", "*", "permission"):
+		permission := repo.permissions[route[1]]
+		if permission == "" {
+			permission = "read"
+		}
+		writeJSON(w, http.StatusOK, map[string]interface{}{"permission": permission, "user": models.User{Login: route[1]}})
+	case get && match(route, "tarball", "*"):
+		s.tarball(w, repo, route[1])
+	case post && match(route, "code-scanning", "sarifs"):
+		s.uploadSARIF(w, repo, body)
+	default:
+		writeJSON(w, http.StatusNotFound, message("Not Found"))
+	}
+}
+
+// match reports whether route has the segments of pattern, "*" matching
+// any one segment.
+func match(route []string, pattern ...string) bool {
+	if len(route) != len(pattern) {
+		return false
+	}
+	for i, segment := range pattern {
+		if segment != "*" && segment != route[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// withPull calls fn with the pull request numbered number, or answers 404.
+func (s *Server) withPull(w http.ResponseWriter, repo *repository, number string, fn func(*pullRequest)) {
+	n, err := strconv.Atoi(number)
+	pr := repo.pulls[n]
+	if err != nil || pr == nil {
+		writeJSON(w, http.StatusNotFound, message("Not Found"))
+		return
+	}
+	fn(pr)
+}
+
+// paginate returns the bounds of the requested page of n items, 30 per page
+// unless per_page (at most 100) says otherwise, and links the next and last
+// pages like GitHub does.
+func paginate(w http.ResponseWriter, r *http.Request, n int) (lo, hi int) {
+	query := r.URL.Query()
+	perPage, err := strconv.Atoi(query.Get("per_page"))
+	if err != nil || perPage <= 0 {
+		perPage = 30
+	}
+	if perPage > 100 {
+		perPage = 100
+	}
+	page, err := strconv.Atoi(query.Get("page"))
+	if err != nil || page < 1 {
+		page = 1
+	}
+	lo = (page - 1) * perPage
+	if lo > n {
+		lo = n
+	}
+	hi = lo + perPage
+	if hi > n {
+		hi = n
+	}
+	if hi < n {
+		last := (n + perPage - 1) / perPage
+		link := func(page int, rel string) string {
+			query.Set("page", strconv.Itoa(page))
+			query.Set("per_page", strconv.Itoa(perPage))
+			return fmt.Sprintf("<http://%s%s?%s>; rel=%q", r.Host, r.URL.Path, query.Encode(), rel)
+		}
+		w.Header().Set("Link", link(page+1, "next")+", "+link(last, "last"))
+	}
+	return lo, hi
+}
+
+// commentRequest is the body of a review comment, on its own or in a review.
+type commentRequest struct {
+	Body      string `json:"body"`
+	CommitID  string `json:"commit_id"`
+	Path      string `json:"path"`
+	Position  int    `json:"position"`
+	Line      int    `json:"line"`
+	Side      string `json:"side"`
+	StartLine int    `json:"start_line"`
+	StartSide string `json:"start_side"`
+}
+
+// anchor validates where a comment goes like GitHub does: the file must be
+// part of the pull request and the position or lines part of its diff.
+func (s *Server) anchor(pr *pullRequest, in commentRequest) (ReviewComment, bool) {
+	var patch string
+	found := false
+	for _, file := range pr.files {
+		if file.Filename == in.Path {
+			patch, found = file.Patch, true
+		}
+	}
+	if !found || in.Body == "" {
+		return ReviewComment{}, false
+	}
+	position := in.Position
+	switch {
+	case position > 0:
+		lines := strings.Split(patch, "\n")
+		if position >= len(lines) || strings.HasPrefix(lines[position], "@@") {
+			return ReviewComment{}, false
+		}
+	case in.Line > 0:
+		position = diff.PositionForLine(patch, in.Line)
+		if position == 0 {
+			return ReviewComment{}, false
+		}
+		if in.StartLine > 0 && (in.StartLine > in.Line || !diff.InSingleHunk(patch, in.StartLine, in.Line)) {
+			return ReviewComment{}, false
+		}
+	default:
+		return ReviewComment{}, false
+	}
+	commitID := in.CommitID
+	if commitID == "" {
+		commitID = pr.pr.Head.SHA
+	}
+	return ReviewComment{
+		ReviewComment: models.ReviewComment{
+			ID:        s.id(),
+			Body:      in.Body,
+			User:      s.user(),
+			Path:      in.Path,
+			DiffHunk:  diffHunk(patch, position),
+			Line:      diff.LineForPosition(patch, position),
+			CommitID:  commitID,
+			CreatedAt: time.Now().UTC(),
+		},
+		Position:  position,
+		StartLine: in.StartLine,
+		Side:      in.Side,
+	}, true
+}
+
+// diffHunk returns the lines of patch from the hunk header above position
+// through position, as GitHub shows with a comment.
+func diffHunk(patch string, position int) string {
+	lines := strings.Split(patch, "\n")
+	start := 0
+	for i := position; i >= 0; i-- {
+		if strings.HasPrefix(lines[i], "@@") {
+			start = i
+			break
+		}
+	}
+	return strings.Join(lines[start:position+1], "\n")
+}
+
+func (s *Server) createReviewComment(w http.ResponseWriter, pr *pullRequest, body []byte) {
+	var in commentRequest
+	if err := json.Unmarshal(body, &in); err != nil {
+		writeJSON(w, http.StatusBadRequest, message("Problems parsing JSON"))
+		return
+	}
+	comment, ok := s.anchor(pr, in)
+	if !ok {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusUnprocessableEntity)
+		io.WriteString(w, invalidPositionBody)
+		return
+	}
+	pr.reviewComments = append(pr.reviewComments, comment)
+	writeJSON(w, http.StatusCreated, comment)
+}
+
+func (s *Server) replyToReviewComment(w http.ResponseWriter, pr *pullRequest, commentID string, body []byte) {
+	id, _ := strconv.ParseInt(commentID, 10, 64)
+	root := findReviewComment(pr, id)
+	var in struct {
+		Body string `json:"body"`
+	}
+	if root == nil {
+		writeJSON(w, http.StatusNotFound, message("Not Found"))
+		return
+	}
+	if err := json.Unmarshal(body, &in); err != nil || in.Body == "" {
+		writeJSON(w, http.StatusUnprocessableEntity, message("Validation Failed"))
+		return
+	}
+	// a reply to a reply joins the thread of the first comment
+	threadID := root.ID
+	if root.InReplyToID != 0 {
+		threadID = root.InReplyToID
+	}
+	reply := *root
+	reply.ID = s.id()
+	reply.InReplyToID = threadID
+	reply.Body = in.Body
+	reply.User = s.user()
+	reply.CreatedAt = time.Now().UTC()
+	reply.PullRequestReviewID = 0
+	pr.reviewComments = append(pr.reviewComments, reply)
+	writeJSON(w, http.StatusCreated, reply)
+}
+
+// reviewStates maps the event of a submitted review to its state.
+var reviewStates = map[string]string{
+	"":                "PENDING",
+	"COMMENT":         "COMMENTED",
+	"APPROVE":         "APPROVED",
+	"REQUEST_CHANGES": "CHANGES_REQUESTED",
+}
+
+func (s *Server) submitReview(w http.ResponseWriter, pr *pullRequest, body []byte) {
+	var in struct {
+		CommitID string           `json:"commit_id"`
+		Body     string           `json:"body"`
+		Event    string           `json:"event"`
+		Comments []commentRequest `json:"comments"`
+	}
+	if err := json.Unmarshal(body, &in); err != nil {
+		writeJSON(w, http.StatusBadRequest, message("Problems parsing JSON"))
+		return
+	}
+	state, ok := reviewStates[in.Event]
+	if !ok {
+		writeJSON(w, http.StatusUnprocessableEntity, message("Validation Failed"))
+		return
+	}
+	review := Review{ID: s.id(), User: s.user(), Body: in.Body, State: state, CommitID: in.CommitID}
+	if review.CommitID == "" {
+		review.CommitID = pr.pr.Head.SHA
+	}
+	var comments []ReviewComment
+	for _, c := range in.Comments {
+		if c.CommitID == "" {
+			c.CommitID = review.CommitID
+		}
+		comment, ok := s.anchor(pr, c)
+		if !ok {
+			writeJSON(w, http.StatusUnprocessableEntity, map[string]interface{}{
+				"message": "Unprocessable Entity",
+				"errors":  []string{"Line could not be resolved"},
+			})
+			return
+		}
+		comment.PullRequestReviewID = review.ID
+		comments = append(comments, comment)
+		review.Comments = append(review.Comments, comment.ID)
+	}
+	pr.reviewComments = append(pr.reviewComments, comments...)
+	pr.reviews = append(pr.reviews, review)
+	writeJSON(w, http.StatusOK, review)
+}
+
+func (s *Server) listReactions(w http.ResponseWriter, r *http.Request, repo *repository, commentID string) {
+	id, _ := strconv.ParseInt(commentID, 10, 64)
+	type listed struct {
+		ID      int64       `json:"id"`
+		User    models.User `json:"user"`
+		Content string      `json:"content"`
+	}
+	reactions := repo.reactions[id]
+	lo, hi := paginate(w, r, len(reactions))
+	out := []listed{}
+	for i, reaction := range reactions[lo:hi] {
+		out = append(out, listed{ID: int64(lo + i + 1), User: models.User{Login: reaction.user}, Content: reaction.content})
+	}
+	writeJSON(w, http.StatusOK, out)
+}
+
+// validReactions are the contents GitHub accepts.
+var validReactions = map[string]bool{
+	"+1": true, "-1": true, "laugh": true, "confused": true,
+	"heart": true, "hooray": true, "rocket": true, "eyes": true,
+}
+
+func (s *Server) addReaction(w http.ResponseWriter, repo *repository, commentID string, body []byte) {
+	id, _ := strconv.ParseInt(commentID, 10, 64)
+	var in struct {
+		Content string `json:"content"`
+	}
+	if err := json.Unmarshal(body, &in); err != nil || !validReactions[in.Content] {
+		writeJSON(w, http.StatusUnprocessableEntity, message("Validation Failed"))
+		return
+	}
+	out := map[string]interface{}{"content": in.Content, "user": s.user()}
+	for _, existing := range repo.reactions[id] {
+		if existing.user == s.Login && existing.content == in.Content {
+			writeJSON(w, http.StatusOK, out)
+			return
+		}
+	}
+	repo.reactions[id] = append(repo.reactions[id], reaction{user: s.Login, content: in.Content})
+	writeJSON(w, http.StatusCreated, out)
+}
+
+func (s *Server) createIssueComment(w http.ResponseWriter, pr *pullRequest, body []byte) {
+	var in struct {
+		Body string `json:"body"`
+	}
+	if err := json.Unmarshal(body, &in); err != nil || in.Body == "" {
+		writeJSON(w, http.StatusUnprocessableEntity, message("Validation Failed"))
+		return
+	}
+	comment := models.IssueComment{ID: s.id(), Body: in.Body, User: s.user()}
+	pr.issueComments = append(pr.issueComments, comment)
+	writeJSON(w, http.StatusCreated, comment)
+}
+
+func (s *Server) editIssueComment(w http.ResponseWriter, repo *repository, commentID string, body []byte) {
+	id, _ := strconv.ParseInt(commentID, 10, 64)
+	var in struct {
+		Body string `json:"body"`
+	}
+	if err := json.Unmarshal(body, &in); err != nil || in.Body == "" {
+		writeJSON(w, http.StatusUnprocessableEntity, message("Validation Failed"))
+		return
+	}
+	for _, pr := range repo.pulls {
+		for i := range pr.issueComments {
+			if pr.issueComments[i].ID == id {
+				pr.issueComments[i].Body = in.Body
+				writeJSON(w, http.StatusOK, pr.issueComments[i])
+				return
+			}
+		}
+	}
+	writeJSON(w, http.StatusNotFound, message("Not Found"))
+}
+
+func (s *Server) setStatus(w http.ResponseWriter, repo *repository, sha string, body []byte) {
+	var status models.CommitStatus
+	if err := json.Unmarshal(body, &status); err != nil {
+		writeJSON(w, http.StatusBadRequest, message("Problems parsing JSON"))
+		return
+	}
+	switch status.State {
+	case models.StatusPending, models.StatusSuccess, models.StatusFailure, models.StatusError:
+	default:
+		writeJSON(w, http.StatusUnprocessableEntity, message("Validation Failed"))
+		return
+	}
+	repo.statuses[sha] = append(repo.statuses[sha], status)
+	writeJSON(w, http.StatusCreated, status)
+}
+
+func (s *Server) createCheckRun(w http.ResponseWriter, repo *repository, body []byte) {
+	run := &CheckRun{}
+	if err := json.Unmarshal(body, run); err != nil || run.Name == "" || run.HeadSHA == "" {
+		writeJSON(w, http.StatusUnprocessableEntity, message("Validation Failed"))
+		return
+	}
+	run.ID = s.id()
+	if run.Status == "" {
+		run.Status = "queued"
+	}
+	repo.checkRuns = append(repo.checkRuns, run)
+	writeJSON(w, http.StatusCreated, run)
+}
+
+func (s *Server) updateCheckRun(w http.ResponseWriter, repo *repository, runID string, body []byte) {
+	id, _ := strconv.ParseInt(runID, 10, 64)
+	for _, run := range repo.checkRuns {
+		if run.ID != id {
+			continue
+		}
+		if err := json.Unmarshal(body, run); err != nil {
+			writeJSON(w, http.StatusBadRequest, message("Problems parsing JSON"))
+			return
+		}
+		run.ID = id
+		writeJSON(w, http.StatusOK, run)
+		return
+	}
+	writeJSON(w, http.StatusNotFound, message("Not Found"))
+}
+
+// fileContents serves a file raw, as GithubClient asks for, or as the
+// base64 encoded JSON object GitHub returns by default. A directory is
+// listed as an array of its entries.
+func (s *Server) fileContents(w http.ResponseWriter, r *http.Request, repo *repository, path string) {
+	files := repo.contents[r.URL.Query().Get("ref")]
+	contents, ok := files[path]
+	if !ok {
+		if entries := directoryEntries(files, path); len(entries) > 0 {
+			writeJSON(w, http.StatusOK, entries)
+			return
+		}
+		writeJSON(w, http.StatusNotFound, message("Not Found"))
+		return
+	}
+	if strings.Contains(r.Header.Get("Accept"), "raw") {
+		w.Header().Set("Content-Type", "application/vnd.github.raw")
+		w.Write(contents)
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"type":     "file",
+		"path":     path,
+		"size":     len(contents),
+		"encoding": "base64",
+		"content":  base64.StdEncoding.EncodeToString(contents),
+	})
+}
+
+// directoryEntries lists the files and subdirectories directly under dir,
+// sorted by path.
+func directoryEntries(files map[string][]byte, dir string) []map[string]interface{} {
+	prefix := ""
+	if dir != "" {
+		prefix = strings.TrimSuffix(dir, "/") + "/"
+	}
+	kinds := map[string]string{}
+	for path := range files {
+		if !strings.HasPrefix(path, prefix) {
+			continue
+		}
+		name, rest, nested := strings.Cut(strings.TrimPrefix(path, prefix), "/")
+		if nested && rest != "" {
+			kinds[prefix+name] = "dir"
+		} else {
+			kinds[prefix+name] = "file"
+		}
+	}
+	paths := make([]string, 0, len(kinds))
+	for path := range kinds {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+	entries := make([]map[string]interface{}, 0, len(paths))
+	for _, path := range paths {
+		entries = append(entries, map[string]interface{}{"type": kinds[path], "path": path, "name": path[len(prefix):]})
+	}
+	return entries
+}
+
+// tarball serves the files at ref as a gzipped tarball under a single top
+// directory, like GitHub's archive downloads.
+func (s *Server) tarball(w http.ResponseWriter, repo *repository, ref string) {
+	files, ok := repo.contents[ref]
+	if !ok {
+		writeJSON(w, http.StatusNotFound, message("Not Found"))
+		return
+	}
+	paths := make([]string, 0, len(files))
+	for path := range files {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	tw := tar.NewWriter(gz)
+	root := fmt.Sprintf("%s-%s-%s/", repo.owner, repo.name, ref)
+	for _, path := range paths {
+		contents := files[path]
+		tw.WriteHeader(&tar.Header{Name: root + path, Mode: 0o644, Size: int64(len(contents)), Typeflag: tar.TypeReg})
+		tw.Write(contents)
+	}
+	tw.Close()
+	gz.Close()
+	w.Header().Set("Content-Type", "application/x-gzip")
+	w.Write(buf.Bytes())
+}
+
+func (s *Server) uploadSARIF(w http.ResponseWriter, repo *repository, body []byte) {
+	var in struct {
+		CommitSHA string `json:"commit_sha"`
+		Ref       string `json:"ref"`
+		SARIF     string `json:"sarif"`
+	}
+	if err := json.Unmarshal(body, &in); err != nil || in.CommitSHA == "" || in.Ref == "" || in.SARIF == "" {
+		writeJSON(w, http.StatusUnprocessableEntity, message("Validation Failed"))
+		return
+	}
+	upload := SARIFUpload{ID: fmt.Sprintf("sarif-%d", s.id()), CommitSHA: in.CommitSHA, Ref: in.Ref, SARIF: in.SARIF}
+	repo.sarifUploads = append(repo.sarifUploads, upload)
+	writeJSON(w, http.StatusAccepted, map[string]string{"id": upload.ID, "url": "https://example.invalid/sarifs/" + upload.ID})
+}
+
+// graphql answers the review threads query of FetchThreadResolutions.
+// Threads are the review comments that are not replies, 100 per page.
+func (s *Server) graphql(w http.ResponseWriter, body []byte) {
+	var in struct {
+		Query     string `json:"query"`
+		Variables struct {
+			Owner  string  `json:"owner"`
+			Repo   string  `json:"repo"`
+			Number int     `json:"number"`
+			Cursor *string `json:"cursor"`
+		} `json:"variables"`
+	}
+	if err := json.Unmarshal(body, &in); err != nil {
+		writeJSON(w, http.StatusBadRequest, message("Problems parsing JSON"))
+		return
+	}
+	if !strings.Contains(in.Query, "reviewThreads") {
+		writeJSON(w, http.StatusOK, map[string]interface{}{"errors": []interface{}{message("query not supported by the fake")}})
+		return
+	}
+	pr := s.repo(in.Variables.Owner, in.Variables.Repo).pulls[in.Variables.Number]
+	if pr == nil {
+		writeJSON(w, http.StatusOK, map[string]interface{}{
+			"data":   map[string]interface{}{"repository": map[string]interface{}{"pullRequest": nil}},
+			"errors": []interface{}{message(fmt.Sprintf("Could not resolve to a PullRequest with the number of %d.", in.Variables.Number))},
+		})
+		return
+	}
+
+	type node struct {
+		IsResolved bool `json:"isResolved"`
+		Comments   struct {
+			Nodes []map[string]int64 `json:"nodes"`
+		} `json:"comments"`
+	}
+	var threads []node
+	for _, c := range pr.reviewComments {
+		if c.InReplyToID != 0 {
+			continue
+		}
+		n := node{IsResolved: pr.resolved[c.ID]}
+		n.Comments.Nodes = []map[string]int64{{"databaseId": c.ID}}
+		threads = append(threads, n)
+	}
+	start := 0
+	if in.Variables.Cursor != nil {
+		start, _ = strconv.Atoi(*in.Variables.Cursor)
+	}
+	if start > len(threads) {
+		start = len(threads)
+	}
+	end := start + 100
+	if end > len(threads) {
+		end = len(threads)
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"data": map[string]interface{}{
+			"repository": map[string]interface{}{
+				"pullRequest": map[string]interface{}{
+					"reviewThreads": map[string]interface{}{
+						"pageInfo": map[string]interface{}{"hasNextPage": end < len(threads), "endCursor": strconv.Itoa(end)},
+						"nodes":    append([]node{}, threads[start:end]...),
+					},
+				},
+			},
+		},
+	})
+}
+
+func message(text string) map[string]string {
+	return map[string]string{"message": text}
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
@@ -0,0 +1,422 @@
+// File: fakegithub/server.go
+// An in-process fake of the GitHub REST and GraphQL endpoints GithubClient
+// uses, for integration tests of the reviewer without GitHub. Pull
+// requests, files, comments, reviews, statuses and check runs are kept in
+// memory, failures can be injected per endpoint, and everything posted can
+// be inspected afterwards.
+package fakegithub
+
+import (
+	"ai-api/auth"
+	"ai-api/clients"
+	"ai-api/models"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefaultToken is the token the fake accepts unless Token is changed.
+const DefaultToken = "fake-github-token"
+
+// DefaultLogin is the account requests are made as.
+const DefaultLogin = "pr-checker[bot]"
+
+// Server is a fake GitHub API. Create it with New and Close it when done.
+type Server struct {
+	*httptest.Server
+
+	// Token is the token requests must carry; any token is accepted when
+	// empty.
+	Token string
+	// Login is the user comments, reviews and reactions are posted as.
+	Login string
+
+	mu       sync.Mutex
+	repos    map[string]*repository
+	nextID   int64
+	failures []*failure
+	requests []Request
+}
+
+// repository is the state of one owner/repo.
+type repository struct {
+	owner, name  string
+	pulls        map[int]*pullRequest
+	contents     map[string]map[string][]byte // ref -> path -> contents
+	permissions  map[string]string
+	statuses     map[string][]models.CommitStatus
+	checkRuns    []*CheckRun
+	sarifUploads []SARIFUpload
+	reactions    map[int64][]reaction
+}
+
+type pullRequest struct {
+	pr             models.PullRequest
+	files          []models.ChangeFile
+	reviewComments []ReviewComment
+	issueComments  []models.IssueComment
+	reviews        []Review
+	resolved       map[int64]bool
+}
+
+type reaction struct {
+	user, content string
+}
+
+// ReviewComment is a review comment as stored and listed by the fake.
+type ReviewComment struct {
+	models.ReviewComment
+	Position            int    `json:"position,omitempty"`
+	StartLine           int    `json:"start_line,omitempty"`
+	Side                string `json:"side,omitempty"`
+	PullRequestReviewID int64  `json:"pull_request_review_id,omitempty"`
+}
+
+// Review is a submitted pull request review.
+type Review struct {
+	ID       int64       `json:"id"`
+	User     models.User `json:"user"`
+	Body     string      `json:"body"`
+	State    string      `json:"state"`
+	CommitID string      `json:"commit_id"`
+	Comments []int64     `json:"-"`
+}
+
+// CheckRun is a check run created or updated through the checks API.
+type CheckRun struct {
+	ID         int64  `json:"id"`
+	Name       string `json:"name"`
+	HeadSHA    string `json:"head_sha"`
+	Status     string `json:"status,omitempty"`
+	Conclusion string `json:"conclusion,omitempty"`
+	Output     struct {
+		Title   string `json:"title,omitempty"`
+		Summary string `json:"summary,omitempty"`
+		Text    string `json:"text,omitempty"`
+	} `json:"output"`
+}
+
+// SARIFUpload is a code scanning upload.
+type SARIFUpload struct {
+	ID        string
+	CommitSHA string
+	Ref       string
+	// SARIF is the log as sent: gzipped and base64 encoded.
+	SARIF string
+}
+
+// Request is a request the fake received.
+type Request struct {
+	Method string
+	Path   string
+	Query  string
+	Body   []byte
+}
+
+// New starts a fake GitHub server.
+func New() *Server {
+	s := &Server{
+		Token:  DefaultToken,
+		Login:  DefaultLogin,
+		repos:  map[string]*repository{},
+		nextID: 1000,
+	}
+	s.Server = httptest.NewServer(http.HandlerFunc(s.serveHTTP))
+	return s
+}
+
+// Client returns a GithubClient talking to the fake with its token, posting
+// as Login.
+func (s *Server) Client() *clients.GithubClient {
+	client := clients.NewGithubClient(s.Server.Client(), s.Token, s.URL)
+	client.BotLogin = s.Login
+	return client
+}
+
+// AddPullRequest adds a pull request with its changed files. The number,
+// head SHA, state and file contents URLs are filled in when missing.
+func (s *Server) AddPullRequest(owner, repo string, pr models.PullRequest, files ...models.ChangeFile) models.PullRequest {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	r := s.repo(owner, repo)
+	if pr.Number == 0 {
+		pr.Number = len(r.pulls) + 1
+	}
+	if pr.Head.SHA == "" {
+		pr.Head.SHA = fmt.Sprintf("%040d", pr.Number)
+	}
+	if pr.User.Login == "" {
+		pr.User = models.User{Login: "octocat", Type: "User"}
+	}
+	if pr.State == "" {
+		pr.State = "open"
+	}
+	pr.HTMLURL = fmt.Sprintf("%s/%s/%s/pull/%d", s.URL, owner, repo, pr.Number)
+	for i := range files {
+		if files[i].Contents_url == "" {
+			files[i].Contents_url = fmt.Sprintf("%s/repos/%s/%s/contents/%s?ref=%s", s.URL, owner, repo, files[i].Filename, pr.Head.SHA)
+		}
+		if files[i].Status == "" {
+			files[i].Status = "modified"
+		}
+	}
+	r.pulls[pr.Number] = &pullRequest{pr: pr, files: files, resolved: map[int64]bool{}}
+	return pr
+}
+
+// SetFile sets the contents of a file at ref, served by the contents and
+// tarball endpoints.
+func (s *Server) SetFile(owner, repo, ref, path string, contents []byte) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	r := s.repo(owner, repo)
+	if r.contents[ref] == nil {
+		r.contents[ref] = map[string][]byte{}
+	}
+	r.contents[ref][path] = contents
+}
+
+// SetPermission sets a user's permission on a repository ("admin",
+// "write", "read" or "none"). Users default to "read".
+func (s *Server) SetPermission(owner, repo, user, permission string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.repo(owner, repo).permissions[user] = permission
+}
+
+// AddReviewComment adds a review comment by someone else, e.g. a reply to
+// the bot, and returns it with its id.
+func (s *Server) AddReviewComment(owner, repo string, number int, comment models.ReviewComment) (models.ReviewComment, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	pr, err := s.pull(owner, repo, number)
+	if err != nil {
+		return comment, err
+	}
+	comment.ID = s.id()
+	if comment.CreatedAt.IsZero() {
+		comment.CreatedAt = time.Now().UTC()
+	}
+	if comment.InReplyToID != 0 {
+		root := findReviewComment(pr, comment.InReplyToID)
+		if root == nil {
+			return comment, fmt.Errorf("comment %d not found", comment.InReplyToID)
+		}
+		comment.Path, comment.Line, comment.DiffHunk, comment.CommitID = root.Path, root.Line, root.DiffHunk, root.CommitID
+	}
+	pr.reviewComments = append(pr.reviewComments, ReviewComment{ReviewComment: comment})
+	return comment, nil
+}
+
+// AddIssueComment adds a comment on a pull request's conversation and
+// returns it with its id.
+func (s *Server) AddIssueComment(owner, repo string, number int, comment models.IssueComment) (models.IssueComment, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	pr, err := s.pull(owner, repo, number)
+	if err != nil {
+		return comment, err
+	}
+	comment.ID = s.id()
+	pr.issueComments = append(pr.issueComments, comment)
+	return comment, nil
+}
+
+// React adds a reaction by user to a comment.
+func (s *Server) React(owner, repo string, commentID int64, user, content string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	r := s.repo(owner, repo)
+	r.reactions[commentID] = append(r.reactions[commentID], reaction{user: user, content: content})
+}
+
+// ResolveThread marks the review thread started by a comment resolved.
+func (s *Server) ResolveThread(owner, repo string, number int, commentID int64, resolved bool) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	pr, err := s.pull(owner, repo, number)
+	if err != nil {
+		return err
+	}
+	pr.resolved[commentID] = resolved
+	return nil
+}
+
+// ReviewComments returns the review comments of a pull request, oldest first.
+func (s *Server) ReviewComments(owner, repo string, number int) []ReviewComment {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	pr, err := s.pull(owner, repo, number)
+	if err != nil {
+		return nil
+	}
+	return append([]ReviewComment(nil), pr.reviewComments...)
+}
+
+// IssueComments returns the comments on a pull request's conversation.
+func (s *Server) IssueComments(owner, repo string, number int) []models.IssueComment {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	pr, err := s.pull(owner, repo, number)
+	if err != nil {
+		return nil
+	}
+	return append([]models.IssueComment(nil), pr.issueComments...)
+}
+
+// Reviews returns the reviews submitted on a pull request.
+func (s *Server) Reviews(owner, repo string, number int) []Review {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	pr, err := s.pull(owner, repo, number)
+	if err != nil {
+		return nil
+	}
+	return append([]Review(nil), pr.reviews...)
+}
+
+// Statuses returns the commit statuses set on sha, oldest first.
+func (s *Server) Statuses(owner, repo, sha string) []models.CommitStatus {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]models.CommitStatus(nil), s.repo(owner, repo).statuses[sha]...)
+}
+
+// CheckRuns returns the check runs of a repository.
+func (s *Server) CheckRuns(owner, repo string) []CheckRun {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var runs []CheckRun
+	for _, run := range s.repo(owner, repo).checkRuns {
+		runs = append(runs, *run)
+	}
+	return runs
+}
+
+// SARIFUploads returns the code scanning uploads of a repository.
+func (s *Server) SARIFUploads(owner, repo string) []SARIFUpload {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]SARIFUpload(nil), s.repo(owner, repo).sarifUploads...)
+}
+
+// Reactions returns the contents of the reactions on a comment.
+func (s *Server) Reactions(owner, repo string, commentID int64) []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var contents []string
+	for _, r := range s.repo(owner, repo).reactions[commentID] {
+		contents = append(contents, r.content)
+	}
+	return contents
+}
+
+// Requests returns every request received, in order.
+func (s *Server) Requests() []Request {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]Request(nil), s.requests...)
+}
+
+// RequestCount counts the requests with method (any when empty) whose path
+// matches pattern, see Fail.
+func (s *Server) RequestCount(method, pattern string) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	n := 0
+	for _, req := range s.requests {
+		if (method == "" || method == req.Method) && matchPath(pattern, req.Path) {
+			n++
+		}
+	}
+	return n
+}
+
+// FindReviewComment returns the review comment on path at line containing
+// substr, if one was posted on the pull request.
+func (s *Server) FindReviewComment(owner, repo string, number int, path string, line int, substr string) (ReviewComment, bool) {
+	for _, c := range s.ReviewComments(owner, repo, number) {
+		if c.Path == path && c.Line == line && strings.Contains(c.Body, substr) {
+			return c, true
+		}
+	}
+	return ReviewComment{}, false
+}
+
+// DeliverWebhook posts a webhook event to url the way GitHub does, signed
+// with secret.
+func (s *Server) DeliverWebhook(url, secret, event string, payload interface{}) (*http.Response, error) {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal webhook payload: %w", err)
+	}
+	req, err := http.NewRequest("POST", url, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	s.mu.Lock()
+	delivery := s.id()
+	s.mu.Unlock()
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-GitHub-Event", event)
+	req.Header.Set("X-GitHub-Delivery", fmt.Sprintf("delivery-%d", delivery))
+	req.Header.Set("X-Hub-Signature-256", auth.SignPayload(secret, body))
+	return http.DefaultClient.Do(req)
+}
+
+// repo returns the state of owner/repo, creating it. s.mu must be held.
+func (s *Server) repo(owner, name string) *repository {
+	key := owner + "/" + name
+	r := s.repos[key]
+	if r == nil {
+		r = &repository{
+			owner:       owner,
+			name:        name,
+			pulls:       map[int]*pullRequest{},
+			contents:    map[string]map[string][]byte{},
+			permissions: map[string]string{},
+			statuses:    map[string][]models.CommitStatus{},
+			reactions:   map[int64][]reaction{},
+		}
+		s.repos[key] = r
+	}
+	return r
+}
+
+// pull returns a pull request. s.mu must be held.
+func (s *Server) pull(owner, repo string, number int) (*pullRequest, error) {
+	pr := s.repo(owner, repo).pulls[number]
+	if pr == nil {
+		return nil, fmt.Errorf("pull request %s/%s#%d not found", owner, repo, number)
+	}
+	return pr, nil
+}
+
+// id returns a new comment, review or check run id. s.mu must be held.
+func (s *Server) id() int64 {
+	s.nextID++
+	return s.nextID
+}
+
+// user is the account requests are made as.
+func (s *Server) user() models.User {
+	userType := "User"
+	if strings.HasSuffix(s.Login, "[bot]") {
+		userType = "Bot"
+	}
+	return models.User{Login: s.Login, Type: userType}
+}
+
+func findReviewComment(pr *pullRequest, id int64) *ReviewComment {
+	for i := range pr.reviewComments {
+		if pr.reviewComments[i].ID == id {
+			return &pr.reviewComments[i]
+		}
+	}
+	return nil
+}
@@ -0,0 +1,132 @@
+package fakegithub
+
+import (
+	"ai-api/clients"
+	"ai-api/models"
+	"context"
+	"errors"
+	"strings"
+	"testing"
+)
+
+const summaryMarker = "<!-- pr-checker:summary -->"
+
+func TestUpsertSummary(t *testing.T) {
+	tests := []struct {
+		name string
+		// existing are the bodies and authors of the comments already on
+		// the pull request
+		existing []models.IssueComment
+		fail     *Response
+		// want are the bodies of the comments afterwards
+		want    []string
+		wantErr bool
+	}{
+		{
+			name: "first summary",
+			want: []string{summaryMarker + " new"},
+		},
+		{
+			name:     "edits its own summary",
+			existing: []models.IssueComment{{Body: "LGTM", User: models.User{Login: "dev"}}, {Body: summaryMarker + " old", User: models.User{Login: DefaultLogin}}},
+			want:     []string{"LGTM", summaryMarker + " new"},
+		},
+		{
+			name:     "leaves a quote of the marker alone",
+			existing: []models.IssueComment{{Body: "> " + summaryMarker + " old", User: models.User{Login: "dev"}}},
+			want:     []string{"> " + summaryMarker + " old", summaryMarker + " new"},
+		},
+		{
+			name:     "secondary rate limit",
+			existing: []models.IssueComment{{Body: summaryMarker + " old", User: models.User{Login: DefaultLogin}}},
+			fail:     ptr(SecondaryRateLimit()),
+			want:     []string{summaryMarker + " old"},
+			wantErr:  true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			gh := New()
+			defer gh.Close()
+			gh.AddPullRequest("acme", "api", models.PullRequest{})
+			for _, c := range tt.existing {
+				if _, err := gh.AddIssueComment("acme", "api", 1, c); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if tt.fail != nil {
+				gh.Fail("", "/repos/acme/api/issues/comments/*", 1, *tt.fail)
+			}
+
+			req := models.PullRequestRequest{OwnerID: "acme", RepoID: "api", ID: "1"}
+			err := gh.Client().UpsertSummary(context.Background(), req, summaryMarker, summaryMarker+" new")
+			if tt.wantErr {
+				var rateLimited *clients.RateLimitError
+				if !errors.As(err, &rateLimited) || rateLimited.RetryAfter.Seconds() != 60 {
+					t.Errorf("UpsertSummary() error = %v, want a rate limit error asking to wait 60s", err)
+				}
+			} else if err != nil {
+				t.Fatalf("UpsertSummary() error = %v", err)
+			}
+			var got []string
+			for _, c := range gh.IssueComments("acme", "api", 1) {
+				got = append(got, c.Body)
+			}
+			if strings.Join(got, "\n") != strings.Join(tt.want, "\n") {
+				t.Errorf("comments = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPostInlineCommentAnchoring(t *testing.T) {
+	patch := "@@ -1,2 +1,4 @@\n package store\n+\n+func Save() {}\n import \"os\""
+	tests := []struct {
+		name   string
+		params models.GeneratePRCommentParams
+		fail   *Response
+		// wantLine is the line of the posted comment, 0 when rejected
+		wantLine int
+	}{
+		{name: "position", params: models.GeneratePRCommentParams{FileName: "cache.go", Position: 3}, wantLine: 3},
+		{name: "hunk header", params: models.GeneratePRCommentParams{FileName: "cache.go", Position: 0}},
+		{name: "past the patch", params: models.GeneratePRCommentParams{FileName: "cache.go", Position: 5}},
+		{name: "other file", params: models.GeneratePRCommentParams{FileName: "main.go", Position: 1}},
+		{name: "suggestion on lines", params: models.GeneratePRCommentParams{FileName: "cache.go", StartLine: 2, Line: 3, Suggestion: "func Save() error { return nil }"}, wantLine: 3},
+		{name: "suggestion past the hunk", params: models.GeneratePRCommentParams{FileName: "cache.go", StartLine: 3, Line: 5, Suggestion: "x"}},
+		{name: "injected invalid position", params: models.GeneratePRCommentParams{FileName: "cache.go", Position: 3}, fail: ptr(InvalidPosition())},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			gh := New()
+			defer gh.Close()
+			gh.AddPullRequest("acme", "api", models.PullRequest{}, models.ChangeFile{Filename: "cache.go", Patch: patch})
+			if tt.fail != nil {
+				gh.Fail("POST", "/repos/*/*/pulls/*/comments", 1, *tt.fail)
+			}
+			params := tt.params
+			params.RepoOwner, params.RepoName, params.PRNumber, params.CommentBody = "acme", "api", "1", "finding"
+
+			id, err := gh.Client().PostInlineComment(context.Background(), params)
+			if tt.wantLine == 0 {
+				if err == nil || !strings.Contains(err.Error(), "422") {
+					t.Errorf("PostInlineComment() = %s, %v, want a 422", id, err)
+				}
+				if n := len(gh.ReviewComments("acme", "api", 1)); n != 0 {
+					t.Errorf("%d comments posted, want none", n)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("PostInlineComment() error = %v", err)
+			}
+			if c, ok := gh.FindReviewComment("acme", "api", 1, "cache.go", tt.wantLine, "finding"); !ok || id == "" {
+				t.Errorf("no comment on line %d with id %q: %+v", tt.wantLine, id, c)
+			}
+		})
+	}
+}
+
+func ptr[T any](v T) *T {
+	return &v
+}
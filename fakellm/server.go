@@ -0,0 +1,376 @@
+// File: fakellm/server.go
+// A local fake of the OpenAI-compatible endpoints OpenFGAClient calls:
+// /embeddings returns deterministic vectors hashed from the input and
+// /chat/completions answers with responses scripted by prompt pattern, so
+// the reviewer runs in tests and locally without an LLM service.
+package fakellm
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+
+	"gopkg.in/yaml.v3"
+)
+
+// DefaultDimensions is the length of the embedding vectors.
+const DefaultDimensions = 64
+
+// DefaultReply answers prompts no rule matches: the reviewer's "nothing to
+// comment" reply, see prompts.NoIssuesReply.
+const DefaultReply = "NO_ISSUES"
+
+// Rule scripts the answer to chat completions whose prompt matches Pattern.
+// The answer is Reply, or when Status is set an error response with that
+// status and Body (an OpenAI error object with Reply as message when Body
+// is empty). Body without Status is sent with 200, e.g. for malformed JSON.
+type Rule struct {
+	Pattern string `yaml:"pattern"`
+	Reply   string `yaml:"reply,omitempty"`
+	Status  int    `yaml:"status,omitempty"`
+	Body    string `yaml:"body,omitempty"`
+	// Times is how many prompts the rule answers; every one when 0.
+	Times int `yaml:"times,omitempty"`
+
+	re   *regexp.Regexp
+	used int
+}
+
+// Server is a fake LLM service. Create it with New and Close it when done.
+type Server struct {
+	*httptest.Server
+
+	// Dimensions is the length of the embedding vectors.
+	Dimensions int
+	// Default answers prompts no rule matches.
+	Default string
+
+	mu         sync.Mutex
+	rules      []*Rule
+	prompts    []string
+	embeddings int
+	ids        int
+}
+
+// New starts a fake LLM service answering every prompt with DefaultReply.
+func New() *Server {
+	s := &Server{Dimensions: DefaultDimensions, Default: DefaultReply}
+	s.Server = httptest.NewServer(http.HandlerFunc(s.serveHTTP))
+	return s
+}
+
+// Script adds rules, tried in the order added, the first match answering.
+func (s *Server) Script(rules ...Rule) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for i := range rules {
+		rule := rules[i]
+		re, err := regexp.Compile(rule.Pattern)
+		if err != nil {
+			return fmt.Errorf("invalid pattern %q: %w", rule.Pattern, err)
+		}
+		rule.re = re
+		s.rules = append(s.rules, &rule)
+	}
+	return nil
+}
+
+// Reply answers prompts matching pattern with content.
+func (s *Server) Reply(pattern, content string) error {
+	return s.Script(Rule{Pattern: pattern, Reply: content})
+}
+
+// ReplyError answers prompts matching pattern with an error status, e.g.
+// 429 or 500. The client retries those, so times should cover its retries.
+func (s *Server) ReplyError(pattern string, status, times int, message string) error {
+	return s.Script(Rule{Pattern: pattern, Status: status, Reply: message, Times: times})
+}
+
+// ReplyRaw answers prompts matching pattern with body as is, e.g. a
+// malformed or truncated response.
+func (s *Server) ReplyRaw(pattern string, status int, body string) error {
+	return s.Script(Rule{Pattern: pattern, Status: status, Body: body})
+}
+
+// LoadScript reads rules from a YAML list of rules.
+func LoadScript(path string) ([]Rule, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read fake LLM script: %w", err)
+	}
+	var rules []Rule
+	if err := yaml.Unmarshal(data, &rules); err != nil {
+		return nil, fmt.Errorf("failed to parse fake LLM script: %w", err)
+	}
+	return rules, nil
+}
+
+// Prompts returns the prompts received, in order.
+func (s *Server) Prompts() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]string(nil), s.prompts...)
+}
+
+// EmbeddingCount counts the inputs embedded.
+func (s *Server) EmbeddingCount() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.embeddings
+}
+
+func (s *Server) serveHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeError(w, http.StatusNotFound, "not found")
+		return
+	}
+	switch {
+	case strings.HasSuffix(r.URL.Path, "/embeddings"):
+		s.embed(w, r)
+	case strings.HasSuffix(r.URL.Path, "/chat/completions"):
+		s.complete(w, r)
+	default:
+		writeError(w, http.StatusNotFound, "not found")
+	}
+}
+
+func (s *Server) embed(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Model          string          `json:"model"`
+		Input          json.RawMessage `json:"input"`
+		EncodingFormat string          `json:"encoding_format"`
+		Dimensions     *int            `json:"dimensions"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid JSON body")
+		return
+	}
+	var inputs []string
+	var single string
+	if err := json.Unmarshal(req.Input, &single); err == nil {
+		inputs = []string{single}
+	} else if err := json.Unmarshal(req.Input, &inputs); err != nil {
+		writeError(w, http.StatusBadRequest, "input must be a string or an array of strings")
+		return
+	}
+
+	if req.Dimensions != nil && *req.Dimensions <= 0 {
+		writeError(w, http.StatusBadRequest, "dimensions must be at least 1")
+		return
+	}
+
+	s.mu.Lock()
+	dimensions := s.Dimensions
+	if req.Dimensions != nil {
+		dimensions = *req.Dimensions
+	}
+	if dimensions <= 0 {
+		s.mu.Unlock()
+		writeError(w, http.StatusInternalServerError, fmt.Sprintf("fake LLM misconfigured: Dimensions is %d, want at least 1", dimensions))
+		return
+	}
+	s.embeddings += len(inputs)
+	s.mu.Unlock()
+
+	data := make([]map[string]interface{}, len(inputs))
+	tokens := 0
+	for i, input := range inputs {
+		vector := Embed(input, dimensions)
+		var embedding interface{} = vector
+		if req.EncodingFormat == "base64" {
+			buf := make([]byte, 4*len(vector))
+			for j, v := range vector {
+				binary.LittleEndian.PutUint32(buf[4*j:], math.Float32bits(float32(v)))
+			}
+			embedding = base64.StdEncoding.EncodeToString(buf)
+		}
+		data[i] = map[string]interface{}{"object": "embedding", "index": i, "embedding": embedding}
+		tokens += len(strings.Fields(input))
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"object": "list",
+		"data":   data,
+		"model":  req.Model,
+		"usage":  map[string]int{"prompt_tokens": tokens, "total_tokens": tokens},
+	})
+}
+
+func (s *Server) complete(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Model    string `json:"model"`
+		Stream   bool   `json:"stream"`
+		Messages []struct {
+			Role    string          `json:"role"`
+			Content json.RawMessage `json:"content"`
+		} `json:"messages"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid JSON body")
+		return
+	}
+	if req.Stream {
+		writeError(w, http.StatusBadRequest, "streaming is not supported by the fake")
+		return
+	}
+	var parts []string
+	for i, message := range req.Messages {
+		text, err := messageText(message.Content)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, fmt.Sprintf("invalid content of message %d: %v", i, err))
+			return
+		}
+		parts = append(parts, text)
+	}
+	prompt := strings.Join(parts, "\n")
+
+	s.mu.Lock()
+	s.prompts = append(s.prompts, prompt)
+	s.ids++
+	id := s.ids
+	rule := s.match(prompt)
+	reply := s.Default
+	s.mu.Unlock()
+
+	if rule != nil {
+		switch {
+		case rule.Status != 0 && rule.Body == "":
+			writeError(w, rule.Status, rule.Reply)
+			return
+		case rule.Body != "":
+			status := rule.Status
+			if status == 0 {
+				status = http.StatusOK
+			}
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(status)
+			io.WriteString(w, rule.Body)
+			return
+		}
+		reply = rule.Reply
+	}
+
+	promptTokens, replyTokens := len(strings.Fields(prompt)), len(strings.Fields(reply))
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"id":      fmt.Sprintf("chatcmpl-fake-%d", id),
+		"object":  "chat.completion",
+		"created": time.Now().Unix(),
+		"model":   req.Model,
+		"choices": []map[string]interface{}{{
+			"index":         0,
+			"message":       map[string]string{"role": "assistant", "content": reply},
+			"finish_reason": "stop",
+		}},
+		"usage": map[string]int{
+			"prompt_tokens":     promptTokens,
+			"completion_tokens": replyTokens,
+			"total_tokens":      promptTokens + replyTokens,
+		},
+	})
+}
+
+// match returns the first rule matching prompt that has answers left, and
+// counts the answer. s.mu must be held.
+func (s *Server) match(prompt string) *Rule {
+	for _, rule := range s.rules {
+		if rule.Times > 0 && rule.used >= rule.Times {
+			continue
+		}
+		if rule.re.MatchString(prompt) {
+			rule.used++
+			return rule
+		}
+	}
+	return nil
+}
+
+// messageText returns the text of a message's content, which is a string,
+// null or a list of typed parts.
+func messageText(content json.RawMessage) (string, error) {
+	var text string
+	if err := json.Unmarshal(content, &text); err == nil {
+		return text, nil
+	}
+	var parts []struct {
+		Type string `json:"type"`
+		Text string `json:"text"`
+	}
+	if err := json.Unmarshal(content, &parts); err != nil {
+		return "", fmt.Errorf("content is neither a string nor a list of parts: %w", err)
+	}
+	var b strings.Builder
+	for _, part := range parts {
+		b.WriteString(part.Text)
+	}
+	return b.String(), nil
+}
+
+// Embed returns a deterministic unit vector for input by feature hashing:
+// each word adds ±1 to the dimension its hash picks, so texts sharing words
+// are similar. Inputs without words hash as a whole. It returns nil when
+// dimensions is not positive.
+func Embed(input string, dimensions int) []float64 {
+	if dimensions <= 0 {
+		return nil
+	}
+	vector := make([]float64, dimensions)
+	words := strings.FieldsFunc(strings.ToLower(input), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r) && r != '_'
+	})
+	if len(words) == 0 {
+		words = []string{input}
+	}
+	for _, word := range words {
+		sum := sha256.Sum256([]byte(word))
+		index := binary.BigEndian.Uint32(sum[:4]) % uint32(dimensions)
+		if sum[4]&1 == 0 {
+			vector[index]++
+		} else {
+			vector[index]--
+		}
+	}
+	norm := 0.0
+	for _, v := range vector {
+		norm += v * v
+	}
+	if norm == 0 {
+		// the words cancelled out; any fixed unit vector will do
+		vector[0], norm = 1, 1
+	}
+	norm = math.Sqrt(norm)
+	for i := range vector {
+		vector[i] /= norm
+	}
+	return vector
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+// writeError writes an OpenAI error object.
+func writeError(w http.ResponseWriter, status int, message string) {
+	errorType := "invalid_request_error"
+	switch {
+	case status == http.StatusTooManyRequests:
+		errorType = "rate_limit_exceeded"
+	case status >= 500:
+		errorType = "server_error"
+	}
+	writeJSON(w, status, map[string]interface{}{
+		"error": map[string]interface{}{"message": message, "type": errorType, "code": nil},
+	})
+}
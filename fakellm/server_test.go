@@ -0,0 +1,95 @@
+package fakellm
+
+import (
+	"io"
+	"net/http"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestServeHTTP(t *testing.T) {
+	tests := []struct {
+		name       string
+		dimensions int
+		path       string
+		body       string
+		wantStatus int
+		wantBody   string
+	}{
+		{
+			name:       "string content",
+			path:       "/v1/chat/completions",
+			body:       `{"model":"m","messages":[{"role":"user","content":"review this"}]}`,
+			wantStatus: http.StatusOK,
+			wantBody:   DefaultReply,
+		},
+		{
+			name:       "content parts",
+			path:       "/v1/chat/completions",
+			body:       `{"model":"m","messages":[{"role":"user","content":[{"type":"text","text":"review this"}]}]}`,
+			wantStatus: http.StatusOK,
+			wantBody:   DefaultReply,
+		},
+		{
+			name:       "malformed content",
+			path:       "/v1/chat/completions",
+			body:       `{"model":"m","messages":[{"role":"user","content":42}]}`,
+			wantStatus: http.StatusBadRequest,
+			wantBody:   "invalid content of message 0",
+		},
+		{
+			name:       "embedding",
+			path:       "/v1/embeddings",
+			body:       `{"model":"m","input":"func Save"}`,
+			wantStatus: http.StatusOK,
+			wantBody:   `"embedding"`,
+		},
+		{
+			name:       "requested dimensions not positive",
+			path:       "/v1/embeddings",
+			body:       `{"model":"m","input":"func Save","dimensions":0}`,
+			wantStatus: http.StatusBadRequest,
+			wantBody:   "dimensions must be at least 1",
+		},
+		{
+			name:       "configured dimensions not positive",
+			dimensions: -1,
+			path:       "/v1/embeddings",
+			body:       `{"model":"m","input":"func Save"}`,
+			wantStatus: http.StatusInternalServerError,
+			wantBody:   "Dimensions is -1",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := New()
+			defer s.Close()
+			if tt.dimensions != 0 {
+				s.Dimensions = tt.dimensions
+			}
+			resp, err := http.Post(s.URL+tt.path, "application/json", strings.NewReader(tt.body))
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer resp.Body.Close()
+			body, err := io.ReadAll(resp.Body)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if resp.StatusCode != tt.wantStatus || !strings.Contains(string(body), tt.wantBody) {
+				t.Errorf("POST %s = %d %s, want %d containing %q", tt.path, resp.StatusCode, body, tt.wantStatus, tt.wantBody)
+			}
+		})
+	}
+}
+
+func TestEmbed(t *testing.T) {
+	if got := Embed("func Save", 0); got != nil {
+		t.Errorf("Embed(_, 0) = %v, want nil", got)
+	}
+	a, b := Embed("func Save", DefaultDimensions), Embed("func Save", DefaultDimensions)
+	if len(a) != DefaultDimensions || !reflect.DeepEqual(a, b) {
+		t.Errorf("Embed() is not deterministic: %v, %v", a, b)
+	}
+}
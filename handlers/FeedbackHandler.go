@@ -0,0 +1,71 @@
+package handlers
+
+import (
+	"ai-api/services"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// FeedbackHandler reports how developers received the bot's comments
+type FeedbackHandler struct {
+	Service *services.PRService
+}
+
+// NewFeedbackHandler creates a new feedback handler
+func NewFeedbackHandler(service *services.PRService) *FeedbackHandler {
+	return &FeedbackHandler{
+		Service: service,
+	}
+}
+
+// FeedbackReport handles GET requests for the acceptance rate of posted
+// comments over time. Query parameters: group_by, a comma separated list of
+// repo, category, severity, prompt and model; period, one of day, week
+// (default) and month; since, a date (2006-01-02) limiting the reviews
+// counted; refresh=true fetches the latest feedback from GitHub first;
+// tenant, the tenant to report on instead of the instance.
+func (h *FeedbackHandler) FeedbackReport(ctx *gin.Context) {
+	service, ok := tenantService(ctx, h.Service)
+	if !ok {
+		return
+	}
+	refresh, err := parseBoolQuery(ctx, "refresh", false)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"message": "invalid refresh parameter", "error:": err.Error()})
+		return
+	}
+	var since time.Time
+	if raw := ctx.Query("since"); raw != "" {
+		since, err = time.Parse("2006-01-02", raw)
+		if err != nil {
+			ctx.JSON(http.StatusBadRequest, gin.H{"message": "invalid since parameter", "error:": err.Error()})
+			return
+		}
+	}
+	var groupBy []string
+	for _, dimension := range strings.Split(ctx.Query("group_by"), ",") {
+		if dimension = strings.TrimSpace(dimension); dimension != "" {
+			groupBy = append(groupBy, dimension)
+		}
+	}
+
+	if refresh {
+		if err := service.RefreshFeedback(ctx); err != nil {
+			ctx.JSON(http.StatusInternalServerError, gin.H{"message": "error refreshing feedback", "error: ": err.Error()})
+			return
+		}
+	}
+	report, err := service.FeedbackReport(groupBy, ctx.Query("period"), since)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"message": "invalid report parameters", "error:": err.Error()})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{
+		"group_by": groupBy,
+		"report":   report,
+	})
+}
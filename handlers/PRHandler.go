@@ -2,9 +2,13 @@ package handlers
 
 import (
 	"ai-api/models"
+	"ai-api/sarif"
 	"ai-api/services"
+	"bytes"
+	"errors"
 	"fmt"
 	"net/http"
+	"strconv"
 
 	"github.com/gin-gonic/gin"
 )
@@ -17,6 +21,7 @@ type PRHandler struct {
 type PRHandlerInterface interface {
 	GetPR(c *gin.Context)
 	AnalyzePR(c *gin.Context)
+	AnalyzeMR(c *gin.Context)
 }
 
 // NewPRHandler creates a new PR handler
@@ -50,28 +55,90 @@ func (h *PRHandler) AnalyzePR(ctx *gin.Context) {
 		return
 	}
 
-	// fetch changes from github for requested pr
-	pr, err := h.Service.GetPRChangeFilesFromGitHub(ctx, *prRequestBody)
-	if err != nil {
-		ctx.JSON(http.StatusBadRequest, gin.H{"message": "error fetching pr changes", "error:": err.Error()})
+	h.analyze(ctx, *prRequestBody)
+}
+
+// AnalyzeMR handles requests to review a GitLab merge request. The project
+// may be a numeric ID or a URL-encoded full path such as "group%2Fproject".
+func (h *PRHandler) AnalyzeMR(ctx *gin.Context) {
+	prRequestBody := models.PullRequestRequest{
+		Provider: models.ProviderGitLab,
+		OwnerID:  ctx.Param("project"),
+		ID:       ctx.Param("iid"),
+	}
+	if prRequestBody.OwnerID == "" || prRequestBody.ID == "" {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error parsing request body. error:": "missing project or iid"})
 		return
 	}
 
-	// analyze the change files and generate a list of comments
-	codeReviews, err := h.Service.ReviewChanges(ctx, pr, prRequestBody.OwnerID, prRequestBody.RepoID, prRequestBody.ID)
+	h.analyze(ctx, prRequestBody)
+}
+
+// analyze runs the review for a parsed request and writes the response.
+// POST requests may carry a SARIF log of external linters whose findings
+// are merged into the review; format=sarif returns the findings as SARIF.
+func (h *PRHandler) analyze(ctx *gin.Context, prRequestBody models.PullRequestRequest) {
+	// dry_run query parameter overrides the configured default
+	dryRun, err := parseBoolQuery(ctx, "dry_run", h.Service.DefaultDryRun(prRequestBody.OwnerID))
 	if err != nil {
-		ctx.JSON(http.StatusBadRequest, gin.H{"message": "error posting PR comments", "error: ": err.Error()})
+		ctx.JSON(http.StatusBadRequest, gin.H{"message": "invalid dry_run parameter", "error:": err.Error()})
 		return
 	}
+	uploadSARIF, err := parseBoolQuery(ctx, "upload_sarif", false)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"message": "invalid upload_sarif parameter", "error:": err.Error()})
+		return
+	}
+	format := ctx.DefaultQuery("format", "json")
+	if format != "json" && format != "sarif" {
+		ctx.JSON(http.StatusBadRequest, gin.H{"message": "invalid format parameter", "error:": "format must be json or sarif"})
+		return
+	}
+
+	opts := services.AnalyzeOptions{DryRun: dryRun, UploadSARIF: uploadSARIF}
+	if ctx.Request.Method == http.MethodPost {
+		log, err := sarif.Parse(ctx.Request.Body)
+		if err != nil {
+			ctx.JSON(http.StatusBadRequest, gin.H{"message": "invalid SARIF body", "error:": err.Error()})
+			return
+		}
+		opts.External = log.Findings()
+	}
 
-	status, err := h.Service.PostPRComments(ctx, codeReviews)
+	// fetch, review and (unless dry-run) post comments for the requested pr
+	result, err := h.Service.AnalyzePR(ctx, prRequestBody, opts)
+	if errors.Is(err, services.ErrBudgetExceeded) {
+		ctx.JSON(http.StatusTooManyRequests, gin.H{"message": "review budget exceeded", "error:": err.Error()})
+		return
+	}
 	if err != nil {
-		ctx.JSON(http.StatusBadRequest, gin.H{"message": "error posting PR comments", "error: ": err.Error()})
+		ctx.JSON(http.StatusBadRequest, gin.H{"message": "error analyzing PR", "error: ": err.Error()})
+		return
+	}
+
+	if format == "sarif" {
+		var body bytes.Buffer
+		if err := sarif.Encode(&body, sarif.FromFindings(result.Comments)); err != nil {
+			ctx.JSON(http.StatusInternalServerError, gin.H{"message": "error encoding SARIF", "error: ": err.Error()})
+			return
+		}
+		ctx.Data(http.StatusOK, "application/sarif+json", body.Bytes())
 		return
 	}
 
 	// return status
-	ctx.JSON(http.StatusOK, gin.H{"message": "PR Analyzed", "commented_files_count": len(codeReviews), "status": status})
+	ctx.JSON(http.StatusOK, gin.H{
+		"message":               "PR Analyzed",
+		"commented_files_count": len(result.Comments),
+		"status":                result.Status,
+		"dry_run":               result.DryRun,
+		"comments":              result.Comments,
+		"skipped":               result.Skipped,
+		"config_error":          result.ConfigError,
+		"summary":               result.Summary,
+		"sarif_upload_id":       result.SarifUploadID,
+		"sarif_upload_error":    result.SarifUploadError,
+	})
 }
 
 func parseFetchPullRequestBody(c *gin.Context) (*models.PullRequestRequest, error) {
@@ -84,3 +151,27 @@ func parseFetchPullRequestBody(c *gin.Context) (*models.PullRequestRequest, erro
 	}
 	return req, nil
 }
+
+// tenantService returns the service of the tenant named by the tenant
+// query parameter, the instance's when absent. It writes a 404 and returns
+// false for unknown tenants.
+func tenantService(c *gin.Context, service *services.PRService) (*services.PRService, bool) {
+	name := c.Query("tenant")
+	tenant, ok := service.Tenant(name)
+	if !ok {
+		c.JSON(http.StatusNotFound, gin.H{"message": "unknown tenant", "error:": fmt.Sprintf("no tenant named %q", name)})
+	}
+	return tenant, ok
+}
+
+// parseBoolQuery reads a boolean query parameter; a bare "?name" means true.
+func parseBoolQuery(c *gin.Context, name string, defaultValue bool) (bool, error) {
+	raw, ok := c.GetQuery(name)
+	if !ok {
+		return defaultValue, nil
+	}
+	if raw == "" {
+		return true, nil
+	}
+	return strconv.ParseBool(raw)
+}
@@ -0,0 +1,99 @@
+package handlers
+
+import (
+	"ai-api/clients"
+	"ai-api/config"
+	"ai-api/fakegithub"
+	"ai-api/models"
+	"ai-api/services"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+// stubLLM answers every prompt with reply.
+type stubLLM struct{ reply string }
+
+func (l stubLLM) GenerateReviewComment(ctx context.Context, req clients.ReviewRequest) (string, error) {
+	return l.reply, nil
+}
+
+func (l stubLLM) RelevantStyleChunks(ctx context.Context, code string, extra []string) ([]string, error) {
+	return nil, nil
+}
+
+func (l stubLLM) Complete(ctx context.Context, prompt, model string) (string, error) {
+	return l.reply, nil
+}
+
+// markerPatch replaces a last line that had no newline; the model reports
+// the dropped error of new line 4, at diff position 6 past the marker.
+const markerPatch = "@@ -1,3 +1,5 @@\n package store\n \n-func Save() {}\n\\ No newline at end of file\n+func Save(f *os.File) {\n+\tf.Close()\n+}"
+
+func TestAnalyzePRDryRun(t *testing.T) {
+	tests := []struct {
+		name       string
+		defaultDry bool
+		query      string
+		wantStatus int
+		wantDryRun bool
+		wantPosted int
+	}{
+		{name: "preview", query: "?dry_run=true", wantStatus: http.StatusOK, wantDryRun: true},
+		{name: "preview without a value", query: "?dry_run", wantStatus: http.StatusOK, wantDryRun: true},
+		{name: "configured default", defaultDry: true, wantStatus: http.StatusOK, wantDryRun: true},
+		{name: "forced posting", defaultDry: true, query: "?dry_run=false", wantStatus: http.StatusOK, wantPosted: 1},
+		{name: "posting", wantStatus: http.StatusOK, wantPosted: 1},
+		{name: "invalid", query: "?dry_run=maybe", wantStatus: http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			gh := fakegithub.New()
+			defer gh.Close()
+			pr := gh.AddPullRequest("acme", "api", models.PullRequest{}, models.ChangeFile{Filename: "store/cache.go", Patch: markerPatch})
+			cfg := config.Config{
+				GithubToken:     gh.Token,
+				GithubBaseURL:   gh.URL,
+				BotLogin:        gh.Login,
+				DryRun:          tt.defaultDry,
+				SummaryDisabled: true,
+			}
+			svc, err := services.NewServicesWithLLM(cfg, stubLLM{reply: "Line: 4\nSeverity: high\nCategory: bug\nThe error of Close is dropped."})
+			if err != nil {
+				t.Fatal(err)
+			}
+			gin.SetMode(gin.TestMode)
+			r := gin.New()
+			r.GET("/v1/api/pr/:owner/:repo/:id", NewPRHandler(svc.PRService).AnalyzePR)
+
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/v1/api/pr/acme/api/1"+tt.query, nil))
+			if w.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d: %s", w.Code, tt.wantStatus, w.Body)
+			}
+			if tt.wantStatus != http.StatusOK {
+				return
+			}
+			var body struct {
+				DryRun   bool                             `json:"dry_run"`
+				Comments []models.GeneratePRCommentParams `json:"comments"`
+			}
+			if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil {
+				t.Fatal(err)
+			}
+			if body.DryRun != tt.wantDryRun {
+				t.Errorf("dry_run = %v, want %v", body.DryRun, tt.wantDryRun)
+			}
+			if len(body.Comments) != 1 || body.Comments[0].Line != 4 || body.Comments[0].Position != 6 {
+				t.Errorf("comments = %+v, want one at line 4, position 6", body.Comments)
+			}
+			if posted := gh.ReviewComments("acme", "api", pr.Number); len(posted) != tt.wantPosted {
+				t.Errorf("posted %d review comments, want %d", len(posted), tt.wantPosted)
+			}
+		})
+	}
+}
@@ -0,0 +1,49 @@
+package handlers
+
+import (
+	"ai-api/services"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+// PromptHandler serves the prompt registry's statistics
+type PromptHandler struct {
+	Service *services.PRService
+}
+
+// NewPromptHandler creates a new prompt handler
+func NewPromptHandler(service *services.PRService) *PromptHandler {
+	return &PromptHandler{
+		Service: service,
+	}
+}
+
+// PromptStats handles GET requests summarizing the outcomes of every prompt
+// version: reviews, findings by severity, posted comments and the reactions
+// they received. With refresh_reactions=true the reactions are fetched from
+// GitHub first; tenant selects a tenant's prompts instead of the instance's.
+func (h *PromptHandler) PromptStats(ctx *gin.Context) {
+	service, ok := tenantService(ctx, h.Service)
+	if !ok {
+		return
+	}
+	if raw := ctx.Query("refresh_reactions"); raw != "" {
+		refresh, err := strconv.ParseBool(raw)
+		if err != nil {
+			ctx.JSON(http.StatusBadRequest, gin.H{"message": "invalid refresh_reactions parameter", "error:": err.Error()})
+			return
+		}
+		if refresh {
+			if err := service.RefreshFeedback(ctx); err != nil {
+				ctx.JSON(http.StatusInternalServerError, gin.H{"message": "error refreshing reactions", "error: ": err.Error()})
+				return
+			}
+		}
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{
+		"prompts": service.PromptStats(),
+	})
+}
@@ -0,0 +1,79 @@
+package handlers
+
+import (
+	"ai-api/models"
+	"ai-api/services"
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/rs/zerolog"
+)
+
+// webhookTimeout bounds the work started by one webhook delivery.
+const webhookTimeout = 5 * time.Minute
+
+// WebhookHandler handles webhook deliveries from GitHub. Their signature is
+// verified by middleware before they get here.
+type WebhookHandler struct {
+	Service *services.PRService
+}
+
+// NewWebhookHandler creates a new webhook handler
+func NewWebhookHandler(service *services.PRService) *WebhookHandler {
+	return &WebhookHandler{
+		Service: service,
+	}
+}
+
+// GitHub handles POST requests from a GitHub webhook. Comments on pull
+// requests (slash commands, replies to the bot's review comments) are handled
+// in the background, since reviewing or generating an answer can take longer
+// than GitHub waits for a delivery; other events are acknowledged and ignored.
+func (h *WebhookHandler) GitHub(ctx *gin.Context) {
+	logger := ctx.MustGet("zlog").(zerolog.Logger)
+	delivery := ctx.GetHeader("X-GitHub-Delivery")
+
+	switch ctx.GetHeader("X-GitHub-Event") {
+	case models.EventPing:
+		ctx.JSON(http.StatusOK, gin.H{"message": "pong"})
+	case models.EventPullRequestReviewComment:
+		var event models.ReviewCommentEvent
+		if err := ctx.ShouldBindJSON(&event); err != nil {
+			ctx.JSON(http.StatusBadRequest, gin.H{"message": "invalid event payload", "error:": err.Error()})
+			return
+		}
+		h.handleInBackground(logger, delivery, event.Comment.ID, func(ctx context.Context) (string, string, error) {
+			return h.Service.HandleReviewComment(ctx, event)
+		})
+		ctx.JSON(http.StatusAccepted, gin.H{"message": "accepted"})
+	case models.EventIssueComment:
+		var event models.IssueCommentEvent
+		if err := ctx.ShouldBindJSON(&event); err != nil {
+			ctx.JSON(http.StatusBadRequest, gin.H{"message": "invalid event payload", "error:": err.Error()})
+			return
+		}
+		h.handleInBackground(logger, delivery, event.Comment.ID, func(ctx context.Context) (string, string, error) {
+			return h.Service.HandleIssueComment(ctx, event)
+		})
+		ctx.JSON(http.StatusAccepted, gin.H{"message": "accepted"})
+	default:
+		ctx.JSON(http.StatusAccepted, gin.H{"message": "event ignored"})
+	}
+}
+
+// handleInBackground runs handle for a comment without the request's
+// context, which ends with the response, and logs the outcome.
+func (h *WebhookHandler) handleInBackground(logger zerolog.Logger, delivery string, commentID int64, handle func(ctx context.Context) (outcome, reason string, err error)) {
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), webhookTimeout)
+		defer cancel()
+		outcome, reason, err := handle(ctx)
+		if err != nil {
+			logger.Error().Err(err).Str("delivery", delivery).Int64("comment_id", commentID).Msg("failed to handle comment")
+			return
+		}
+		logger.Info().Str("delivery", delivery).Int64("comment_id", commentID).Str("outcome", outcome).Str("reason", reason).Msg("handled comment")
+	}()
+}
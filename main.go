@@ -1,26 +1,127 @@
 package main
 
 import (
+	"ai-api/auth"
+	"ai-api/authz"
 	"ai-api/config"
+	"ai-api/fakellm"
 	router "ai-api/server"
 	"ai-api/services"
+	"context"
+	"flag"
+	"fmt"
+	"os"
 
 	"github.com/gofiber/fiber/v2/log"
 )
 
+const usage = `usage: ai-api <command> [flags]
+
+commands:
+  serve    run the HTTP API (default)
+  review   review a local diff, stdin or git range without the server
+  eval     score reviews of a golden dataset of diffs
+  analyze  run static analyzers on a checkout, as the server does in a sandbox
+
+Run "ai-api <command> -h" for the flags of a command.
+`
+
 func main() {
+	command, args := "serve", os.Args[1:]
+	if len(args) > 0 && args[0] != "" && args[0][0] != '-' {
+		command, args = args[0], args[1:]
+	}
+
+	switch command {
+	case "serve":
+		runServe(args)
+	case "review":
+		os.Exit(runReview(args))
+	case "eval":
+		os.Exit(runEval(args))
+	case "analyze":
+		os.Exit(runAnalyze(args))
+	case "help":
+		fmt.Print(usage)
+	default:
+		fmt.Fprintf(os.Stderr, "unknown command %q\n\n%s", command, usage)
+		os.Exit(exitError)
+	}
+}
+
+// runServe starts the HTTP API.
+func runServe(args []string) {
+	flags := flag.NewFlagSet("serve", flag.ExitOnError)
+	envFile := flags.String("env", ".env", "path to the env file")
+	addr := flags.String("addr", "localhost:8080", "address to listen on")
+	fakeLLM := flags.Bool("fake-llm", false, "answer LLM requests from a local fake instead of the configured service")
+	fakeLLMScript := flags.String("fake-llm-script", "", "YAML file of scripted responses for -fake-llm")
+	flags.Parse(args)
 
 	// Setup the router from the external package
 	// Load configuration from the .env file
-	cfg, err := config.LoadConfig(".env")
+	cfg, err := config.LoadConfig(*envFile)
 	if err != nil {
 		// Handle error
 		log.Fatal(err)
 		return
 	}
-	services := services.NewServices(*cfg)
-	server := router.NewServer(cfg, services)
 
-	server.Router.Run("localhost:8080")
+	// Point the LLM client at a local fake, e.g. to try the server offline
+	if *fakeLLM {
+		fake := fakellm.New()
+		defer fake.Close()
+		if *fakeLLMScript != "" {
+			rules, err := fakellm.LoadScript(*fakeLLMScript)
+			if err == nil {
+				err = fake.Script(rules...)
+			}
+			if err != nil {
+				log.Fatal(err)
+				return
+			}
+		}
+		cfg.LLMServiceURL, cfg.LLMServiceAPIKey = fake.URL, "fake"
+		fmt.Printf("answering LLM requests from the fake at %s\n", fake.URL)
+	}
+
+	// Load the hashed api keys that guard /v1/api
+	var apiKeys *auth.KeyStore
+	if !cfg.AuthDisabled {
+		apiKeys, err = auth.LoadKeyStore(cfg.APIKeysFile)
+		if err != nil {
+			log.Fatal(err)
+			return
+		}
+	}
+
+	// Load the relationships deciding what each api key may do
+	authorizer, err := authz.FromConfig(*cfg)
+	if err != nil {
+		log.Fatal(err)
+		return
+	}
+
+	services, err := services.NewServices(*cfg)
+	if err != nil {
+		log.Fatal(err)
+		return
+	}
+	// keep the reactions and resolution state of posted comments current
+	interval, err := cfg.FeedbackPollInterval()
+	if err != nil {
+		log.Fatal(err)
+		return
+	}
+	if _, err := cfg.FeedbackRefreshAge(); err != nil {
+		log.Fatal(err)
+		return
+	}
+	if interval > 0 {
+		go services.PRService.PollFeedback(context.Background(), interval)
+	}
+	server := router.NewServer(cfg, services, apiKeys, authorizer)
+
+	server.Router.Run(*addr)
 
 }
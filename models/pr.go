@@ -1,19 +1,92 @@
 package models
 
-import "time"
+import (
+	"fmt"
+	"strings"
+	"time"
+)
 
 type GeneratePRCommentParams struct {
-	RepoOwner   string
-	RepoName    string
-	PRNumber    string
-	CommentBody string
-	CommitSha   string
-	FileName    string
-	Position    int
+	RepoOwner   string   `json:"repo_owner"`
+	RepoName    string   `json:"repo_name"`
+	PRNumber    string   `json:"pr_number"`
+	CommentBody string   `json:"body"`
+	CommitSha   string   `json:"commit_sha"`
+	FileName    string   `json:"file"`
+	Position    int      `json:"position"`
+	Line        int      `json:"line"`
+	LineType    string   `json:"line_type,omitempty"` // added or context, see diff.LineKindForPosition
+	Severity    Severity `json:"severity"`
+	Category    string   `json:"category,omitempty"`
+	OldFileName string   `json:"old_file,omitempty"`
+	BaseSha     string   `json:"base_sha,omitempty"`
+	StartSha    string   `json:"start_sha,omitempty"`
+	Rule        string   `json:"rule,omitempty"`       // rule id, e.g. "review/security" or an analyzer's name
+	Tool        string   `json:"tool,omitempty"`       // tool that reported the finding; empty for the model
+	Prompt      string   `json:"prompt,omitempty"`     // prompt version that produced the comment, "name@version"
+	CommentID   string   `json:"comment_id,omitempty"` // provider id of the comment once posted
+	// Suggestion replaces lines StartLine through Line of the new file. It is
+	// posted as a suggestion block where the provider supports one.
+	StartLine  int    `json:"start_line,omitempty"`
+	Suggestion string `json:"suggestion,omitempty"`
+}
+
+// ProseBody is the comment body with the suggested replacement, if any,
+// shown as a plain code block, for providers without suggestion blocks.
+func (p GeneratePRCommentParams) ProseBody() string {
+	if p.Suggestion == "" {
+		return p.CommentBody
+	}
+	lines := fmt.Sprintf("line %d", p.Line)
+	if p.StartLine > 0 && p.StartLine < p.Line {
+		lines = fmt.Sprintf("lines %d-%d", p.StartLine, p.Line)
+	}
+	return fmt.Sprintf("%s\n\nSuggested replacement for %s:\n```\n%s\n```", p.CommentBody, lines, p.Suggestion)
+}
+
+// Headline is the first line of the comment body, to list the comment in
+// summaries and reports.
+func (p GeneratePRCommentParams) Headline() string {
+	line, _, _ := strings.Cut(strings.TrimSpace(p.CommentBody), "\n")
+	return line
+}
+
+// SuggestionBody is the comment body with the replacement as a GitHub
+// suggestion block, which applies to the lines the comment spans.
+func (p GeneratePRCommentParams) SuggestionBody() string {
+	if p.Suggestion == "" {
+		return p.CommentBody
+	}
+	return fmt.Sprintf("%s\n\n```suggestion\n%s\n```", p.CommentBody, p.Suggestion)
+}
+
+// AnalyzeResult is the outcome of reviewing a pull request. In dry-run mode
+// Comments holds the would-be comments and nothing is posted to GitHub.
+type AnalyzeResult struct {
+	DryRun   bool                      `json:"dry_run"`
+	Status   string                    `json:"status,omitempty"`
+	Comments []GeneratePRCommentParams `json:"comments"`
+	// Skipped lists the changed files that were not reviewed and why.
+	Skipped []SkippedFile `json:"skipped"`
+	// ConfigError explains why the repository's .prchecker.yml was ignored.
+	ConfigError string `json:"config_error,omitempty"`
+	// Summary is the markdown of the pr-level summary comment.
+	Summary string `json:"summary,omitempty"`
+	// SarifUploadID is the GitHub code scanning upload of the findings, and
+	// SarifUploadError why uploading them failed.
+	SarifUploadID    string `json:"sarif_upload_id,omitempty"`
+	SarifUploadError string `json:"sarif_upload_error,omitempty"`
 }
 
 type ChangeFiles struct {
 	Files []ChangeFile
+	// Skipped lists the changed files left out of the review and why.
+	Skipped []SkippedFile
+
+	// Diff refs of the change, set by providers that report them (GitLab).
+	BaseSHA  string
+	StartSHA string
+	HeadSHA  string
 }
 
 type ChangeFile struct {
@@ -27,6 +100,14 @@ type ChangeFile struct {
 	Raw_url      string `json:"raw_url"`
 	Sha          string `json:"sha"`
 	Status       string `json:"status"`
+
+	PreviousFilename string `json:"previous_filename,omitempty"`
+}
+
+// SkippedFile is a changed file that was not reviewed.
+type SkippedFile struct {
+	Filename string `json:"filename"`
+	Reason   string `json:"reason"`
 }
 
 type PRComment struct {
@@ -36,12 +117,39 @@ type PRComment struct {
 	Position int    `json:"position"`
 }
 
+// Source code hosts a pull request can come from.
+const (
+	ProviderGitHub    = "github"
+	ProviderGitLab    = "gitlab"
+	ProviderBitbucket = "bitbucket"
+	ProviderGitea     = "gitea"
+)
+
 type PullRequestRequest struct {
 	ID      string `json:"id"`
 	OwnerID string `json:"owner_id"`
 	RepoID  string `json:"repo_id"`
+
+	// Provider selects the source code host, empty means GitHub.
+	// For GitLab OwnerID holds the project ID or full path and RepoID is empty.
+	Provider string `json:"provider,omitempty"`
+}
+
+// CommitStatus is the review status reported on the head commit of a change.
+type CommitStatus struct {
+	State       string `json:"state"` // pending, success, failure or error
+	Description string `json:"description"`
+	Context     string `json:"context"`
 }
 
+// Commit status states.
+const (
+	StatusPending = "pending"
+	StatusSuccess = "success"
+	StatusFailure = "failure"
+	StatusError   = "error"
+)
+
 type Comment struct {
 	// Owner      string      `json:"owner"`
 	// Repo       string      `json:"repo"`
@@ -56,6 +164,19 @@ type CommentBody struct {
 	Position int    `json:"position"`
 }
 
+// PullRequest is the subset of GitHub's pull request object the reviewer uses.
+type PullRequest struct {
+	Number  int     `json:"number"`
+	Title   string  `json:"title"`
+	Body    string  `json:"body"`
+	State   string  `json:"state,omitempty"` // open or closed
+	HTMLURL string  `json:"html_url"`
+	User    User    `json:"user"`
+	Labels  []Label `json:"labels"`
+	Head    Branch  `json:"head"`
+	Base    Branch  `json:"base"`
+}
+
 type User struct {
 	Login             string `json:"login"`
 	ID                int    `json:"id"`
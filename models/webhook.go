@@ -0,0 +1,68 @@
+package models
+
+import "time"
+
+// GitHub webhook event names, from the X-GitHub-Event header.
+const (
+	EventPing                     = "ping"
+	EventPullRequestReviewComment = "pull_request_review_comment"
+	EventIssueComment             = "issue_comment"
+)
+
+// ReviewComment is a pull request review comment, as listed by the API and
+// sent in pull_request_review_comment events.
+type ReviewComment struct {
+	ID int64 `json:"id"`
+	// InReplyToID is the first comment of the thread for replies, 0 otherwise.
+	InReplyToID int64     `json:"in_reply_to_id,omitempty"`
+	Body        string    `json:"body"`
+	User        User      `json:"user"`
+	Path        string    `json:"path"`
+	DiffHunk    string    `json:"diff_hunk"`
+	Line        int       `json:"line"`
+	CommitID    string    `json:"commit_id"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+// ReviewCommentEvent is the payload of a pull_request_review_comment event.
+type ReviewCommentEvent struct {
+	Action      string        `json:"action"`
+	Comment     ReviewComment `json:"comment"`
+	PullRequest PullRequest   `json:"pull_request"`
+	Repository  Repo          `json:"repository"`
+	Sender      User          `json:"sender"`
+	// Installation is set for events delivered to a GitHub App.
+	Installation *Installation `json:"installation,omitempty"`
+}
+
+// Installation is the GitHub App installation a webhook was delivered for.
+type Installation struct {
+	ID int64 `json:"id"`
+}
+
+// IssueComment is a comment on the conversation of an issue or pull request.
+type IssueComment struct {
+	ID   int64  `json:"id"`
+	Body string `json:"body"`
+	User User   `json:"user"`
+}
+
+// Issue is the subset of an issue the reviewer uses. PullRequest is set
+// when the issue is a pull request.
+type Issue struct {
+	Number      int `json:"number"`
+	PullRequest *struct {
+		URL string `json:"url"`
+	} `json:"pull_request,omitempty"`
+}
+
+// IssueCommentEvent is the payload of an issue_comment event.
+type IssueCommentEvent struct {
+	Action     string       `json:"action"`
+	Issue      Issue        `json:"issue"`
+	Comment    IssueComment `json:"comment"`
+	Repository Repo         `json:"repository"`
+	Sender     User         `json:"sender"`
+	// Installation is set for events delivered to a GitHub App.
+	Installation *Installation `json:"installation,omitempty"`
+}
@@ -0,0 +1,259 @@
+// File: prompts/prompts.go
+// Review prompts written as text/template files. Templates are loaded from
+// a directory at startup, validated against sample data and rendered per
+// file with the pull request, file, hunks and retrieved style guide chunks.
+// Each template has one or more versions; see registry.go for how a version
+// is chosen for a review.
+package prompts
+
+import (
+	"ai-api/diff"
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"text/template"
+)
+
+// DefaultName is the name of the built-in template.
+const DefaultName = "default"
+
+// ReviewOutputFormat tells the model how to lay out its findings so they can
+// be split into one comment per finding with a line and a severity.
+const ReviewOutputFormat = `Report each finding in this format, separating findings with a line containing only ---:
+Line: <line number in the new version of the file>
+Severity: <info|low|medium|high|critical>
+Category: <one or two words, e.g. bug, style, performance, security>
+<explanation of the problem and how to fix it>
+
+When the fix is a concrete replacement of specific lines, add a header
+Lines: <first>-<last>
+naming the lines of the new version it replaces, and end the explanation with the complete replacement for exactly those lines, keeping their indentation:
+` + "```suggestion\n<replacement lines>\n```" + `
+
+If there is nothing worth commenting on, reply with exactly: ` + NoIssuesReply
+
+// NoIssuesReply is the model's answer when a change needs no comments.
+const NoIssuesReply = "NO_ISSUES"
+
+// templateExt is the extension of template files in the prompt directory.
+const templateExt = ".tmpl"
+
+// Versions given to templates that do not name one: the built-in default
+// template and files without "@<version>" in their name.
+const (
+	BuiltinVersion = "builtin"
+	InitialVersion = "v1"
+)
+
+// defaultTemplate reproduces the original prompt layout: the configured base
+// prompt, the retrieved style guide chunks and the patch.
+const defaultTemplate = `{{.BasePrompt}}{{if .Instructions}}
+
+{{.Instructions}}{{end}}
+{{- if .PR.Title}}
+
+Pull request: {{.PR.Title}}{{if .PR.Description}}
+{{.PR.Description}}{{end}}{{end}}
+
+Here is the style guide: {{join .StyleChunks "\n\n"}}
+
+{{- if .Context}}
+
+Surrounding code from {{.File.Name}} at the head commit:
+
+{{.Context}}{{end}}
+{{- if .Diagnostics}}
+
+Static analysis already reported these problems, which are posted separately. Do not repeat them; focus on what the tools cannot see:
+{{join .Diagnostics "\n"}}{{end}}
+
+Here is the code to review ({{.File.Name}}):
+
+{{.File.Patch}}
+
+{{.OutputFormat}}`
+
+// PullRequest is the pull request context available to templates.
+type PullRequest struct {
+	Number      string
+	Title       string
+	Description string
+	Author      string
+	Labels      []string
+}
+
+// File is the changed file being reviewed.
+type File struct {
+	Name     string
+	Language string
+	Status   string
+	Patch    string
+}
+
+// Data is what a prompt template is executed with.
+type Data struct {
+	// BasePrompt is the service-wide prompt (AI_CHECKER_LLM_ANALYZE_PR_PROMPT).
+	BasePrompt string
+	// Instructions are the repository's extra instructions from .prchecker.yml.
+	Instructions string
+	PR           PullRequest
+	File         File
+	Hunks        []diff.Hunk
+	// Context is surrounding code of the file (enclosing functions,
+	// referenced types, imports) when it could be gathered, see codecontext.
+	Context string
+	// Diagnostics are the static analyzer findings on the file's changed
+	// lines, already posted on their own, e.g. "line 12: ... (printf)".
+	Diagnostics []string
+	// StyleChunks are the style guide passages retrieved for this file.
+	StyleChunks []string
+	// OutputFormat describes how findings must be laid out; templates should
+	// include it so comments can be anchored to lines.
+	OutputFormat string
+}
+
+// funcs are the helper functions available to templates.
+var funcs = template.FuncMap{
+	"join":  strings.Join,
+	"lower": strings.ToLower,
+	"upper": strings.ToUpper,
+	"trim":  strings.TrimSpace,
+}
+
+// Set is a collection of named, versioned prompt templates.
+type Set struct {
+	// templates are keyed by Ref.String()
+	templates map[string]*template.Template
+	// versions lists the versions of each template name
+	versions map[string][]string
+	// stable is the version of each name used outside experiments
+	stable map[string]string
+	// experiments route part of the reviews to a candidate version
+	experiments map[string]Experiment
+}
+
+// Load parses every *.tmpl file in dir. A file named "<name>@<version>.tmpl"
+// is one version of the template <name>; "<name>.tmpl" is its version v1.
+// The built-in "default" template is always present as default@builtin. An
+// empty dir loads only the built-in template. Every template is executed
+// against sample data so mistakes surface at startup rather than during a
+// review.
+func Load(dir string) (*Set, error) {
+	set := &Set{
+		templates:   map[string]*template.Template{},
+		versions:    map[string][]string{},
+		stable:      map[string]string{},
+		experiments: map[string]Experiment{},
+	}
+	if err := set.add(Ref{Name: DefaultName, Version: BuiltinVersion}, defaultTemplate); err != nil {
+		return nil, err
+	}
+	if dir == "" {
+		return set, nil
+	}
+
+	files, err := filepath.Glob(filepath.Join(dir, "*"+templateExt))
+	if err != nil {
+		return nil, fmt.Errorf("failed to list prompt templates: %w", err)
+	}
+	for _, file := range files {
+		text, err := os.ReadFile(file)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read prompt template: %w", err)
+		}
+		ref, err := ParseRef(strings.TrimSuffix(filepath.Base(file), templateExt))
+		if err != nil {
+			return nil, err
+		}
+		if ref.Version == "" {
+			ref.Version = InitialVersion
+		}
+		if err := set.add(ref, string(text)); err != nil {
+			return nil, err
+		}
+	}
+	return set, nil
+}
+
+// add parses and validates a template version.
+func (s *Set) add(ref Ref, text string) error {
+	if _, ok := s.templates[ref.String()]; ok {
+		return fmt.Errorf("prompt template %s is defined twice", ref)
+	}
+	tmpl, err := template.New(ref.String()).Funcs(funcs).Option("missingkey=error").Parse(text)
+	if err != nil {
+		return fmt.Errorf("invalid prompt template %s: %w", ref, err)
+	}
+	if err := tmpl.Execute(&bytes.Buffer{}, sampleData()); err != nil {
+		return fmt.Errorf("invalid prompt template %s: %w", ref, err)
+	}
+	s.templates[ref.String()] = tmpl
+	s.versions[ref.Name] = append(s.versions[ref.Name], ref.Version)
+	versions := s.versions[ref.Name]
+	sort.Slice(versions, func(i, j int) bool { return VersionLess(versions[i], versions[j]) })
+	return nil
+}
+
+// Has reports whether a template with this name exists.
+func (s *Set) Has(name string) bool {
+	_, ok := s.versions[name]
+	return ok
+}
+
+// Names returns the names of all templates, sorted.
+func (s *Set) Names() []string {
+	names := make([]string, 0, len(s.versions))
+	for name := range s.versions {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Versions returns the versions of a template, oldest first.
+func (s *Set) Versions(name string) []string {
+	return append([]string{}, s.versions[name]...)
+}
+
+// Render executes the template version ref with data.
+func (s *Set) Render(ref Ref, data Data) (string, error) {
+	tmpl, ok := s.templates[ref.String()]
+	if !ok {
+		return "", fmt.Errorf("unknown prompt template %s", ref)
+	}
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, data); err != nil {
+		return "", fmt.Errorf("failed to render prompt template %s: %w", ref, err)
+	}
+	return buf.String(), nil
+}
+
+// sampleData is used to validate templates at load time.
+func sampleData() Data {
+	patch := "@@ -1,3 +1,4 @@\n package main\n+\n+import \"fmt\"\n func main() {}"
+	return Data{
+		BasePrompt:   "Review this change.",
+		Instructions: "Prefer table driven tests.",
+		PR: PullRequest{
+			Number:      "1",
+			Title:       "Add feature",
+			Description: "Adds a feature.",
+			Author:      "octocat",
+			Labels:      []string{"enhancement"},
+		},
+		File: File{
+			Name:     "main.go",
+			Language: "go",
+			Status:   "modified",
+			Patch:    patch,
+		},
+		Hunks:        diff.Hunks(patch),
+		Context:      "// func main (lines 1-1)\nfunc main() {}",
+		Diagnostics:  []string{"line 3: fmt imported and not used (vet)"},
+		StyleChunks:  []string{"Use gofmt.", "Name things well."},
+		OutputFormat: "Line: <n>",
+	}
+}
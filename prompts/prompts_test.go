@@ -0,0 +1,116 @@
+package prompts
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestLoad(t *testing.T) {
+	tests := []struct {
+		name    string
+		files   map[string]string
+		want    map[string][]string // template name -> versions
+		wantErr string
+	}{
+		{name: "no templates", want: map[string][]string{DefaultName: {BuiltinVersion}}},
+		{
+			name: "named and versioned templates",
+			files: map[string]string{
+				"strict-go.tmpl":    "{{.BasePrompt}} {{.File.Patch}}",
+				"strict-go@v2.tmpl": "{{.BasePrompt}} {{range .Hunks}}{{.Header}}{{end}}",
+				"default@v10.tmpl":  "{{.File.Name}}",
+				"default@v9.tmpl":   "{{.File.Name}}",
+				"notes.txt":         "not a template",
+			},
+			want: map[string][]string{
+				DefaultName: {BuiltinVersion, "v9", "v10"},
+				"strict-go": {"v1", "v2"},
+			},
+		},
+		{name: "syntax error", files: map[string]string{"bad.tmpl": "{{.BasePrompt"}, wantErr: "invalid prompt template bad@v1"},
+		{name: "unknown field", files: map[string]string{"bad.tmpl": "{{.Repository}}"}, wantErr: "can't evaluate field Repository"},
+		{name: "unknown function", files: map[string]string{"bad.tmpl": "{{title .BasePrompt}}"}, wantErr: `function "title" not defined`},
+		{name: "missing name", files: map[string]string{"@v2.tmpl": "x"}, wantErr: "missing name"},
+		{name: "duplicate version", files: map[string]string{"go.tmpl": "x", "go@v1.tmpl": "y"}, wantErr: "go@v1 is defined twice"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := ""
+			if tt.files != nil {
+				dir = t.TempDir()
+				for name, text := range tt.files {
+					if err := os.WriteFile(filepath.Join(dir, name), []byte(text), 0o644); err != nil {
+						t.Fatal(err)
+					}
+				}
+			}
+			set, err := Load(dir)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("Load() error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Load() error = %v", err)
+			}
+			got := map[string][]string{}
+			for _, name := range set.Names() {
+				got[name] = set.Versions(name)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("templates = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRenderDefault(t *testing.T) {
+	set, err := Load("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name        string
+		data        Data
+		contains    []string
+		notContains []string
+	}{
+		{
+			name:     "sample data",
+			data:     sampleData(),
+			contains: []string{"Review this change.\n\nPrefer table driven tests.", "Pull request: Add feature\nAdds a feature.", "Use gofmt.\n\nName things well.", "Surrounding code from main.go", "line 3: fmt imported and not used (vet)", "Here is the code to review (main.go):\n\n@@ -1,3 +1,4 @@", "Line: <n>"},
+		},
+		{
+			name:        "optional sections left out",
+			data:        Data{BasePrompt: "Review.", File: File{Name: "a.go", Patch: "@@ -1 +1 @@\n-a\n+b"}, OutputFormat: ReviewOutputFormat},
+			contains:    []string{"Review.\n\nHere is the style guide: ", "Here is the code to review (a.go)", NoIssuesReply},
+			notContains: []string{"Pull request:", "Surrounding code", "Static analysis"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			prompt, err := set.Render(Ref{Name: DefaultName, Version: BuiltinVersion}, tt.data)
+			if err != nil {
+				t.Fatalf("Render() error = %v", err)
+			}
+			for _, want := range tt.contains {
+				if !strings.Contains(prompt, want) {
+					t.Errorf("prompt does not contain %q:\n%s", want, prompt)
+				}
+			}
+			for _, unwanted := range tt.notContains {
+				if strings.Contains(prompt, unwanted) {
+					t.Errorf("prompt contains %q:\n%s", unwanted, prompt)
+				}
+			}
+		})
+	}
+
+	if _, err := set.Render(Ref{Name: "missing", Version: "v1"}, sampleData()); err == nil {
+		t.Error("Render() of an unknown template succeeded")
+	}
+}
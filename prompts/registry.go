@@ -0,0 +1,123 @@
+package prompts
+
+import (
+	"fmt"
+	"hash/fnv"
+	"strconv"
+	"strings"
+)
+
+// Ref identifies one version of a template, written "<name>@<version>".
+type Ref struct {
+	Name    string `json:"name"`
+	Version string `json:"version"`
+}
+
+func (r Ref) String() string {
+	return r.Name + "@" + r.Version
+}
+
+// ParseRef parses "<name>" or "<name>@<version>".
+func ParseRef(s string) (Ref, error) {
+	name, version, _ := strings.Cut(s, "@")
+	if name == "" {
+		return Ref{}, fmt.Errorf("invalid prompt reference %q: missing name", s)
+	}
+	return Ref{Name: name, Version: version}, nil
+}
+
+// Experiment sends Percent percent of the reviews using template Name to
+// its Candidate version instead of the stable one.
+type Experiment struct {
+	Name      string `json:"name"`
+	Candidate string `json:"candidate"`
+	Percent   int    `json:"percent"`
+}
+
+// ParseExperiment parses an experiment written "<version>:<percent>", e.g. "v3:20".
+func ParseExperiment(name, spec string) (Experiment, error) {
+	candidate, rawPercent, ok := strings.Cut(spec, ":")
+	if !ok || candidate == "" {
+		return Experiment{}, fmt.Errorf("invalid prompt experiment %s=%q: want <version>:<percent>", name, spec)
+	}
+	percent, err := strconv.Atoi(rawPercent)
+	if err != nil || percent < 0 || percent > 100 {
+		return Experiment{}, fmt.Errorf("invalid prompt experiment %s=%q: percent must be between 0 and 100", name, spec)
+	}
+	return Experiment{Name: name, Candidate: candidate, Percent: percent}, nil
+}
+
+// Configure sets the stable version of templates and the running
+// experiments. Templates without a stable version use their latest one.
+func (s *Set) Configure(stable map[string]string, experiments []Experiment) error {
+	for name, version := range stable {
+		if !s.hasVersion(name, version) {
+			return fmt.Errorf("stable prompt version %s@%s does not exist", name, version)
+		}
+		s.stable[name] = version
+	}
+	for _, experiment := range experiments {
+		if !s.hasVersion(experiment.Name, experiment.Candidate) {
+			return fmt.Errorf("candidate prompt version %s@%s does not exist", experiment.Name, experiment.Candidate)
+		}
+		s.experiments[experiment.Name] = experiment
+	}
+	return nil
+}
+
+// Stable returns the version of name used outside experiments.
+func (s *Set) Stable(name string) string {
+	if version, ok := s.stable[name]; ok {
+		return version
+	}
+	versions := s.versions[name]
+	if len(versions) == 0 {
+		return ""
+	}
+	return versions[len(versions)-1]
+}
+
+// Experiment returns the experiment running on name, if any.
+func (s *Set) Experiment(name string) (Experiment, bool) {
+	experiment, ok := s.experiments[name]
+	return experiment, ok
+}
+
+// Select picks the version of name to render. key identifies the unit of
+// the experiment (the pull request), so re-reviews of the same pull request
+// keep using the same version.
+func (s *Set) Select(name, key string) Ref {
+	ref := Ref{Name: name, Version: s.Stable(name)}
+	experiment, ok := s.experiments[name]
+	if ok && experiment.Candidate != ref.Version && bucket(name, key) < experiment.Percent {
+		ref.Version = experiment.Candidate
+	}
+	return ref
+}
+
+func (s *Set) hasVersion(name, version string) bool {
+	_, ok := s.templates[Ref{Name: name, Version: version}.String()]
+	return ok
+}
+
+// bucket hashes key into one of 100 buckets. The template name is mixed in
+// so experiments on different templates are independent.
+func bucket(name, key string) int {
+	h := fnv.New32a()
+	h.Write([]byte(name + "\x00" + key))
+	return int(h.Sum32() % 100)
+}
+
+// VersionLess orders versions: the built-in version first, then by number
+// for "v<n>" versions and alphabetically otherwise.
+func VersionLess(a, b string) bool {
+	if a == BuiltinVersion || b == BuiltinVersion {
+		return a == BuiltinVersion && b != BuiltinVersion
+	}
+	na, errA := strconv.Atoi(strings.TrimPrefix(a, "v"))
+	nb, errB := strconv.Atoi(strings.TrimPrefix(b, "v"))
+	if errA == nil && errB == nil {
+		return na < nb
+	}
+	return a < b
+}
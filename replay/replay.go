@@ -0,0 +1,285 @@
+// File: replay/replay.go
+// An http.RoundTripper recording the outbound traffic of the API clients
+// (GitHub, the LLM service, ...) to fixture files and replaying it, so the
+// review pipeline can be exercised end to end without credentials or network.
+package replay
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"unicode/utf8"
+)
+
+// Modes of a Transport.
+const (
+	// ModeRecord sends requests and saves each exchange to a fixture.
+	ModeRecord = "record"
+	// ModeReplay answers requests from the fixtures without sending them.
+	ModeReplay = "replay"
+)
+
+// Redacted replaces scrubbed secrets in fixtures.
+const Redacted = "REDACTED"
+
+// sensitiveHeaders never reach a fixture.
+var sensitiveHeaders = []string{
+	"Authorization",
+	"Proxy-Authorization",
+	"Cookie",
+	"Set-Cookie",
+	"Api-Key",
+	"X-Api-Key",
+	"Private-Token", // GitLab
+	"X-Hub-Signature-256",
+}
+
+// sensitiveParams are query parameters carrying credentials.
+var sensitiveParams = []string{"access_token", "token", "key", "api_key", "client_secret"}
+
+// Fixture is one recorded exchange.
+type Fixture struct {
+	Request  Message `json:"request"`
+	Response Message `json:"response"`
+}
+
+// Message is a scrubbed request or response. Bodies that are not UTF-8 are
+// stored base64 encoded.
+type Message struct {
+	Method     string      `json:"method,omitempty"`
+	URL        string      `json:"url,omitempty"`
+	StatusCode int         `json:"status_code,omitempty"`
+	Header     http.Header `json:"header,omitempty"`
+	Body       string      `json:"body,omitempty"`
+	Base64     bool        `json:"base64,omitempty"`
+}
+
+// Transport records or replays HTTP exchanges in a directory of fixtures,
+// one JSON file per exchange. Fixtures are named after the method, URL and
+// body of the scrubbed request, and the number of times the same request
+// was made before, so repeated requests (pagination, polling) replay in
+// order. Credentials are scrubbed from headers, query parameters and bodies
+// before anything is written.
+type Transport struct {
+	mode    string
+	dir     string
+	next    http.RoundTripper
+	secrets []string
+
+	mu    sync.Mutex
+	calls map[string]int
+}
+
+// New returns a Transport in the given mode over the fixtures of dir. next
+// sends the requests being recorded, http.DefaultTransport when nil. secrets
+// are values (tokens, API keys) scrubbed wherever they appear.
+func New(mode, dir string, next http.RoundTripper, secrets ...string) (*Transport, error) {
+	if mode != ModeRecord && mode != ModeReplay {
+		return nil, fmt.Errorf("unknown replay mode %q, want %s or %s", mode, ModeRecord, ModeReplay)
+	}
+	if dir == "" {
+		return nil, fmt.Errorf("no fixture directory for %s mode", mode)
+	}
+	if mode == ModeRecord {
+		if err := os.MkdirAll(dir, 0o755); err != nil {
+			return nil, fmt.Errorf("failed to create fixture directory: %w", err)
+		}
+	}
+	if next == nil {
+		next = http.DefaultTransport
+	}
+	t := &Transport{mode: mode, dir: dir, next: next, calls: map[string]int{}}
+	for _, secret := range secrets {
+		if secret != "" {
+			t.secrets = append(t.secrets, secret)
+		}
+	}
+	return t, nil
+}
+
+// Wrap makes client use t. The client's own transport, if any, sends the
+// requests being recorded.
+func (t *Transport) Wrap(client *http.Client) {
+	if client.Transport != nil {
+		t.next = client.Transport
+	}
+	client.Transport = t
+}
+
+// RoundTrip implements http.RoundTripper.
+func (t *Transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	var body []byte
+	if req.Body != nil {
+		var err error
+		body, err = io.ReadAll(req.Body)
+		req.Body.Close()
+		if err != nil {
+			return nil, fmt.Errorf("failed to read request body: %w", err)
+		}
+	}
+	request := t.message(req.Header, body)
+	request.Method = req.Method
+	request.URL = t.scrubURL(req.URL)
+	path := filepath.Join(t.dir, t.fixtureName(request))
+
+	if t.mode == ModeReplay {
+		return t.replay(req, path)
+	}
+
+	out := req.Clone(req.Context())
+	out.Body = io.NopCloser(bytes.NewReader(body))
+	out.ContentLength = int64(len(body))
+	resp, err := t.next.RoundTrip(out)
+	if err != nil {
+		return nil, err
+	}
+	respBody, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read response body: %w", err)
+	}
+	response := t.message(resp.Header, respBody)
+	response.StatusCode = resp.StatusCode
+	if err := writeFixture(path, Fixture{Request: request, Response: response}); err != nil {
+		return nil, err
+	}
+	resp.Body = io.NopCloser(bytes.NewReader(respBody))
+	return resp, nil
+}
+
+// replay answers req from the fixture at path.
+func (t *Transport) replay(req *http.Request, path string) (*http.Response, error) {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, fmt.Errorf("no recorded response for %s %s (%s), record it again", req.Method, t.scrubURL(req.URL), filepath.Base(path))
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read fixture: %w", err)
+	}
+	var fixture Fixture
+	if err := json.Unmarshal(data, &fixture); err != nil {
+		return nil, fmt.Errorf("failed to parse fixture %s: %w", filepath.Base(path), err)
+	}
+	body := []byte(fixture.Response.Body)
+	if fixture.Response.Base64 {
+		if body, err = base64.StdEncoding.DecodeString(fixture.Response.Body); err != nil {
+			return nil, fmt.Errorf("failed to decode fixture %s: %w", filepath.Base(path), err)
+		}
+	}
+	header := fixture.Response.Header
+	if header == nil {
+		header = http.Header{}
+	}
+	return &http.Response{
+		Status:        fmt.Sprintf("%d %s", fixture.Response.StatusCode, http.StatusText(fixture.Response.StatusCode)),
+		StatusCode:    fixture.Response.StatusCode,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        header,
+		Body:          io.NopCloser(bytes.NewReader(body)),
+		ContentLength: int64(len(body)),
+		Request:       req,
+	}, nil
+}
+
+// fixtureName names the fixture of a scrubbed request: a readable prefix,
+// a hash of the method, URL and body, and the occurrence of the request.
+func (t *Transport) fixtureName(request Message) string {
+	sum := sha256.Sum256([]byte(request.Method + " " + request.URL + "\n" + request.Body))
+	key := hex.EncodeToString(sum[:8])
+
+	t.mu.Lock()
+	n := t.calls[key]
+	t.calls[key] = n + 1
+	t.mu.Unlock()
+
+	prefix := request.Method
+	if u, err := url.Parse(request.URL); err == nil {
+		prefix += "-" + u.Host + u.Path
+	}
+	prefix = strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9', r == '-', r == '.':
+			return r
+		}
+		return '_'
+	}, prefix)
+	if len(prefix) > 80 {
+		prefix = prefix[:80]
+	}
+	return fmt.Sprintf("%s-%s-%d.json", prefix, key, n)
+}
+
+// message scrubs a header and body into a Message.
+func (t *Transport) message(header http.Header, body []byte) Message {
+	m := Message{Header: header.Clone()}
+	for _, name := range sensitiveHeaders {
+		if m.Header.Get(name) != "" {
+			m.Header.Set(name, Redacted)
+		}
+	}
+	for name, values := range m.Header {
+		for i, value := range values {
+			values[i] = t.scrub(value)
+		}
+		m.Header[name] = values
+	}
+	if len(m.Header) == 0 {
+		m.Header = nil
+	}
+	if utf8.Valid(body) {
+		m.Body = t.scrub(string(body))
+	} else {
+		m.Body = base64.StdEncoding.EncodeToString(body)
+		m.Base64 = true
+	}
+	return m
+}
+
+// scrubURL returns u with credentials removed from its user info and query.
+func (t *Transport) scrubURL(u *url.URL) string {
+	scrubbed := *u
+	if scrubbed.User != nil {
+		scrubbed.User = url.User(Redacted)
+	}
+	query := scrubbed.Query()
+	for _, name := range sensitiveParams {
+		if query.Has(name) {
+			query.Set(name, Redacted)
+		}
+	}
+	scrubbed.RawQuery = query.Encode()
+	return t.scrub(scrubbed.String())
+}
+
+// scrub replaces the secrets of t in s.
+func (t *Transport) scrub(s string) string {
+	for _, secret := range t.secrets {
+		s = strings.ReplaceAll(s, secret, Redacted)
+	}
+	return s
+}
+
+func writeFixture(path string, fixture Fixture) error {
+	data, err := json.MarshalIndent(fixture, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode fixture: %w", err)
+	}
+	if err := os.WriteFile(path, append(data, '\n'), 0o644); err != nil {
+		return fmt.Errorf("failed to write fixture: %w", err)
+	}
+	return nil
+}
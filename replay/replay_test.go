@@ -0,0 +1,82 @@
+package replay
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestRecordAndReplay(t *testing.T) {
+	const secret = "s3cr3t-token"
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Set-Cookie", "session=abc")
+		io.WriteString(w, `{"echo": "`+r.Header.Get("Authorization")+`", "page": "`+r.URL.Query().Get("page")+`"}`)
+	}))
+	defer srv.Close()
+	dir := t.TempDir()
+
+	get := func(client *http.Client, page string) string {
+		t.Helper()
+		req, _ := http.NewRequest(http.MethodGet, srv.URL+"/items?page="+page+"&access_token="+secret, nil)
+		req.Header.Set("Authorization", "Bearer "+secret)
+		resp, err := client.Do(req)
+		if err != nil {
+			t.Fatalf("GET page %s: %v", page, err)
+		}
+		defer resp.Body.Close()
+		body, _ := io.ReadAll(resp.Body)
+		return string(body)
+	}
+
+	recorder, err := New(ModeRecord, dir, nil, secret)
+	if err != nil {
+		t.Fatal(err)
+	}
+	recording := &http.Client{}
+	recorder.Wrap(recording)
+	if got := get(recording, "1"); !strings.Contains(got, secret) {
+		t.Fatalf("recorded response = %s, want the server's answer", got)
+	}
+	get(recording, "1")
+
+	fixtures, _ := filepath.Glob(filepath.Join(dir, "*.json"))
+	if len(fixtures) != 2 {
+		t.Fatalf("recorded %d fixtures, want one per request", len(fixtures))
+	}
+	for _, fixture := range fixtures {
+		data, _ := os.ReadFile(fixture)
+		if strings.Contains(string(data), secret) || strings.Contains(string(data), "session=abc") {
+			t.Errorf("%s holds a credential:\n%s", filepath.Base(fixture), data)
+		}
+	}
+
+	srv.Close()
+	replayer, err := New(ModeReplay, dir, nil, secret)
+	if err != nil {
+		t.Fatal(err)
+	}
+	replaying := &http.Client{}
+	replayer.Wrap(replaying)
+	for i := 0; i < 2; i++ {
+		if got := get(replaying, "1"); !strings.Contains(got, `"echo": "Bearer REDACTED"`) {
+			t.Errorf("replayed response %d = %s, want the scrubbed recording", i, got)
+		}
+	}
+	req, _ := http.NewRequest(http.MethodGet, srv.URL+"/items?page=2", nil)
+	if _, err := replaying.Do(req); err == nil || !strings.Contains(err.Error(), "no recorded response") {
+		t.Errorf("unrecorded request error = %v", err)
+	}
+}
+
+func TestNewValidation(t *testing.T) {
+	if _, err := New("live", t.TempDir(), nil); err == nil {
+		t.Error("New() accepted an unknown mode")
+	}
+	if _, err := New(ModeReplay, "", nil); err == nil {
+		t.Error("New() accepted an empty fixture directory")
+	}
+}
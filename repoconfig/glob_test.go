@@ -0,0 +1,53 @@
+package repoconfig
+
+import "testing"
+
+func TestMatchGlob(t *testing.T) {
+	tests := []struct {
+		pattern, name string
+		want          bool
+	}{
+		{"*.pb.go", "api/v1/service.pb.go", true},
+		{"*.pb.go", "service.go", false},
+		{"vendor/", "vendor/github.com/x/y.go", true},
+		{"/vendor/", "vendor/x.go", true},
+		{"vendor/", "internal/vendor/x.go", false},
+		{"**/testdata/**", "pkg/a/testdata/in.go", true},
+		{"**/testdata/**", "testdata/in.go", true},
+		{"cmd/*/main.go", "cmd/server/main.go", true},
+		{"cmd/*/main.go", "cmd/server/sub/main.go", false},
+		{"cmd/**/main.go", "cmd/server/sub/main.go", true},
+		{"internal/**", "internal", true},
+	}
+	for _, tt := range tests {
+		if got := MatchGlob(tt.pattern, tt.name); got != tt.want {
+			t.Errorf("MatchGlob(%q, %q) = %v, want %v", tt.pattern, tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestSkipReason(t *testing.T) {
+	cfg := Config{
+		Include:   []string{"cmd/**", "internal/**"},
+		Exclude:   []string{"**/*_mock.go"},
+		Languages: []string{"Go", "python"},
+	}
+	tests := []struct {
+		name, want string
+	}{
+		{"cmd/server/main.go", ""},
+		{"internal/tools/gen.py", ""},
+		{"docs/readme.go", "not matched by include paths"},
+		{"internal/store/store_mock.go", "matched by exclude paths"},
+		{"internal/store/schema.sql", "language sql is not enabled"},
+		{"internal/LICENSE", "not a source file"},
+	}
+	for _, tt := range tests {
+		if got := cfg.SkipReason(tt.name); got != tt.want {
+			t.Errorf("SkipReason(%q) = %q, want %q", tt.name, got, tt.want)
+		}
+		if cfg.Reviews(tt.name) != (tt.want == "") {
+			t.Errorf("Reviews(%q) disagrees with SkipReason", tt.name)
+		}
+	}
+}
@@ -0,0 +1,51 @@
+package repoconfig
+
+import (
+	"path"
+	"strings"
+)
+
+// languagesByExtension maps file extensions to the language names used in
+// the "languages" setting.
+var languagesByExtension = map[string]string{
+	".go":    "go",
+	".py":    "python",
+	".js":    "javascript",
+	".jsx":   "javascript",
+	".ts":    "typescript",
+	".tsx":   "typescript",
+	".java":  "java",
+	".kt":    "kotlin",
+	".rb":    "ruby",
+	".rs":    "rust",
+	".c":     "c",
+	".h":     "c",
+	".cc":    "cpp",
+	".cpp":   "cpp",
+	".hpp":   "cpp",
+	".cs":    "csharp",
+	".php":   "php",
+	".swift": "swift",
+	".scala": "scala",
+	".sh":    "shell",
+	".sql":   "sql",
+	".proto": "protobuf",
+	".tf":    "terraform",
+	".yaml":  "yaml",
+	".yml":   "yaml",
+}
+
+// LanguageForFile returns the language of a file from its extension, or ""
+// when it is not a known source file.
+func LanguageForFile(filename string) string {
+	return languagesByExtension[strings.ToLower(path.Ext(filename))]
+}
+
+func knownLanguage(language string) bool {
+	for _, known := range languagesByExtension {
+		if strings.EqualFold(known, language) {
+			return true
+		}
+	}
+	return false
+}
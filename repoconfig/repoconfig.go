@@ -0,0 +1,209 @@
+// File: repoconfig/repoconfig.go
+// Per-repository reviewer settings read from a .prchecker.yml file on the
+// repository's default branch and merged over the service-wide defaults.
+package repoconfig
+
+import (
+	"ai-api/config"
+	"ai-api/models"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// FileName is the path of the repository configuration file.
+const FileName = ".prchecker.yml"
+
+// Config holds the settings a repository can override.
+type Config struct {
+	// Include and Exclude are path globs ("**" matches any number of
+	// directories). A file is reviewed when it matches an Include glob (or
+	// Include is empty) and matches no Exclude glob.
+	Include []string `yaml:"include"`
+	Exclude []string `yaml:"exclude"`
+	// Languages limits reviews to files of these languages, see LanguageForFile.
+	Languages []string `yaml:"languages"`
+	// Instructions are appended to the review prompt.
+	Instructions string `yaml:"instructions"`
+	// SeverityThreshold drops findings below this severity.
+	SeverityThreshold string `yaml:"severity_threshold"`
+	// MaxComments caps the number of comments per review, 0 means no limit.
+	MaxComments int `yaml:"max_comments"`
+	// Model overrides the LLM model used for reviews.
+	Model string `yaml:"model"`
+	// StyleGuides are extra style guide files in the repository (HTML,
+	// Markdown or plain text) used alongside the built-in Go style guide.
+	StyleGuides []string `yaml:"style_guides"`
+	// Prompt names the prompt template used for this repository and
+	// LanguagePrompts overrides it per language, e.g. {go: strict-go}.
+	Prompt          string            `yaml:"prompt"`
+	LanguagePrompts map[string]string `yaml:"language_prompts"`
+
+	// StyleGuideChunks are the parsed contents of StyleGuides, filled in by
+	// the service after fetching the files.
+	StyleGuideChunks []string `yaml:"-"`
+}
+
+// Defaults returns the service-wide settings repositories start from.
+func Defaults(cfg config.Config) Config {
+	return Config{
+		Include:           splitList(cfg.IncludePaths),
+		Exclude:           splitList(cfg.ExcludePaths),
+		Languages:         []string{"go"},
+		SeverityThreshold: string(models.SeverityInfo),
+		Model:             cfg.LLMModel,
+		Prompt:            cfg.DefaultPrompt,
+		LanguagePrompts:   cfg.PromptsByLanguage(),
+	}
+}
+
+// Parse decodes a .prchecker.yml file. Unknown keys are rejected so typos
+// do not go unnoticed.
+func Parse(data []byte) (*Config, error) {
+	var cfg Config
+	dec := yaml.NewDecoder(bytes.NewReader(data))
+	dec.KnownFields(true)
+	if err := dec.Decode(&cfg); err != nil && !errors.Is(err, io.EOF) {
+		return nil, fmt.Errorf("failed to parse %s: %w", FileName, err)
+	}
+	return &cfg, nil
+}
+
+// Validate reports every invalid setting at once.
+func (c Config) Validate() error {
+	var problems []string
+	for _, pattern := range append(append([]string{}, c.Include...), c.Exclude...) {
+		if err := ValidateGlob(pattern); err != nil {
+			problems = append(problems, err.Error())
+		}
+	}
+	for _, language := range c.Languages {
+		if !knownLanguage(language) {
+			problems = append(problems, fmt.Sprintf("unknown language %q", language))
+		}
+	}
+	if c.SeverityThreshold != "" {
+		if _, err := models.ParseSeverity(c.SeverityThreshold); err != nil {
+			problems = append(problems, fmt.Sprintf("severity_threshold: %v", err))
+		}
+	}
+	if c.MaxComments < 0 {
+		problems = append(problems, "max_comments must not be negative")
+	}
+	for language := range c.LanguagePrompts {
+		if !knownLanguage(language) {
+			problems = append(problems, fmt.Sprintf("language_prompts: unknown language %q", language))
+		}
+	}
+	for _, guide := range c.StyleGuides {
+		if strings.TrimSpace(guide) == "" {
+			problems = append(problems, "style_guides contains an empty path")
+		}
+	}
+	if len(problems) > 0 {
+		return fmt.Errorf("invalid %s: %s", FileName, strings.Join(problems, "; "))
+	}
+	return nil
+}
+
+// Merge returns defaults with every setting that is set in override replaced.
+func Merge(defaults, override Config) Config {
+	merged := defaults
+	if len(override.Include) > 0 {
+		merged.Include = override.Include
+	}
+	if len(override.Exclude) > 0 {
+		merged.Exclude = append(append([]string{}, defaults.Exclude...), override.Exclude...)
+	}
+	if len(override.Languages) > 0 {
+		merged.Languages = override.Languages
+	}
+	if override.Instructions != "" {
+		merged.Instructions = override.Instructions
+	}
+	if override.SeverityThreshold != "" {
+		merged.SeverityThreshold = override.SeverityThreshold
+	}
+	if override.MaxComments > 0 {
+		merged.MaxComments = override.MaxComments
+	}
+	if override.Model != "" {
+		merged.Model = override.Model
+	}
+	if len(override.StyleGuides) > 0 {
+		merged.StyleGuides = override.StyleGuides
+	}
+	if override.Prompt != "" {
+		merged.Prompt = override.Prompt
+	}
+	if len(override.LanguagePrompts) > 0 {
+		merged.LanguagePrompts = map[string]string{}
+		for language, name := range defaults.LanguagePrompts {
+			merged.LanguagePrompts[language] = name
+		}
+		for language, name := range override.LanguagePrompts {
+			merged.LanguagePrompts[language] = name
+		}
+	}
+	return merged
+}
+
+// Reviews reports whether a file should be reviewed under this configuration.
+func (c Config) Reviews(filename string) bool {
+	return c.SkipReason(filename) == ""
+}
+
+// SkipReason explains why a file is not reviewed under this configuration,
+// or returns "" when it is.
+func (c Config) SkipReason(filename string) string {
+	if len(c.Include) > 0 && !MatchAny(c.Include, filename) {
+		return "not matched by include paths"
+	}
+	if MatchAny(c.Exclude, filename) {
+		return "matched by exclude paths"
+	}
+	language := LanguageForFile(filename)
+	for _, enabled := range c.Languages {
+		if strings.EqualFold(enabled, language) {
+			return ""
+		}
+	}
+	if language == "" {
+		return "not a source file"
+	}
+	return fmt.Sprintf("language %s is not enabled", language)
+}
+
+// PromptFor returns the prompt template name for a language: the language's
+// own template if one is set, otherwise the repository-wide one. An empty
+// result means the built-in default template.
+func (c Config) PromptFor(language string) string {
+	if name, ok := c.LanguagePrompts[language]; ok && name != "" {
+		return name
+	}
+	return c.Prompt
+}
+
+// Threshold returns the minimum severity of reported findings.
+func (c Config) Threshold() models.Severity {
+	severity, err := models.ParseSeverity(c.SeverityThreshold)
+	if err != nil {
+		return models.SeverityInfo
+	}
+	return severity
+}
+
+// splitList splits a comma separated list, dropping empty entries.
+func splitList(list string) []string {
+	var values []string
+	for _, value := range strings.Split(list, ",") {
+		if value = strings.TrimSpace(value); value != "" {
+			values = append(values, value)
+		}
+	}
+	return values
+}
@@ -0,0 +1,156 @@
+package repoconfig
+
+import (
+	"ai-api/config"
+	"ai-api/models"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		name    string
+		data    string
+		want    Config
+		wantErr string
+	}{
+		{name: "empty file", data: "", want: Config{}},
+		{
+			name: "settings",
+			data: "include: [\"cmd/**\"]\nexclude:\n  - \"*.pb.go\"\nlanguages: [go, python]\nseverity_threshold: medium\nmax_comments: 5\nlanguage_prompts: {go: strict-go}\n",
+			want: Config{
+				Include:           []string{"cmd/**"},
+				Exclude:           []string{"*.pb.go"},
+				Languages:         []string{"go", "python"},
+				SeverityThreshold: "medium",
+				MaxComments:       5,
+				LanguagePrompts:   map[string]string{"go": "strict-go"},
+			},
+		},
+		{name: "unknown key", data: "exclued: [vendor/]\n", wantErr: "field exclued not found"},
+		{name: "wrong type", data: "max_comments: many\n", wantErr: "failed to parse .prchecker.yml"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Parse([]byte(tt.data))
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("Parse() error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Parse() error = %v", err)
+			}
+			if !reflect.DeepEqual(*got, tt.want) {
+				t.Errorf("Parse() = %+v, want %+v", *got, tt.want)
+			}
+		})
+	}
+}
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		name string
+		cfg  Config
+		want []string // substrings of the error, none means valid
+	}{
+		{name: "valid", cfg: Config{Include: []string{"**/*.go"}, Languages: []string{"Go"}, SeverityThreshold: "high", LanguagePrompts: map[string]string{"python": "py"}}},
+		{name: "bad glob", cfg: Config{Exclude: []string{"gen/[a-"}}, want: []string{`invalid path glob "gen/[a-"`}},
+		{name: "empty glob", cfg: Config{Include: []string{" "}}, want: []string{"empty path glob"}},
+		{name: "unknown language", cfg: Config{Languages: []string{"cobol"}}, want: []string{`unknown language "cobol"`}},
+		{name: "bad severity", cfg: Config{SeverityThreshold: "fatal"}, want: []string{"severity_threshold"}},
+		{
+			name: "every problem at once",
+			cfg:  Config{MaxComments: -1, StyleGuides: []string{""}, LanguagePrompts: map[string]string{"cobol": "x"}},
+			want: []string{"max_comments must not be negative", "style_guides contains an empty path", `language_prompts: unknown language "cobol"`},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.cfg.Validate()
+			if len(tt.want) == 0 {
+				if err != nil {
+					t.Fatalf("Validate() error = %v", err)
+				}
+				return
+			}
+			if err == nil {
+				t.Fatal("Validate() error = nil")
+			}
+			for _, want := range tt.want {
+				if !strings.Contains(err.Error(), want) {
+					t.Errorf("Validate() error = %v, want it to contain %q", err, want)
+				}
+			}
+		})
+	}
+}
+
+func TestMerge(t *testing.T) {
+	defaults := Defaults(config.Config{
+		ExcludePaths:     "vendor/, *.pb.go",
+		LLMModel:         "gpt-4o",
+		DefaultPrompt:    "default",
+		PromptByLanguage: "go=go-review",
+	})
+	tests := []struct {
+		name     string
+		override Config
+		check    func(t *testing.T, merged Config)
+	}{
+		{
+			name:     "empty override keeps defaults",
+			override: Config{},
+			check: func(t *testing.T, merged Config) {
+				if !reflect.DeepEqual(merged, defaults) {
+					t.Errorf("Merge() = %+v, want defaults %+v", merged, defaults)
+				}
+			},
+		},
+		{
+			name:     "excludes add to the defaults",
+			override: Config{Exclude: []string{"testdata/"}},
+			check: func(t *testing.T, merged Config) {
+				want := []string{"vendor/", "*.pb.go", "testdata/"}
+				if !reflect.DeepEqual(merged.Exclude, want) {
+					t.Errorf("Exclude = %v, want %v", merged.Exclude, want)
+				}
+			},
+		},
+		{
+			name:     "scalars and lists are replaced",
+			override: Config{Languages: []string{"python"}, Model: "gpt-4o-mini", MaxComments: 3, SeverityThreshold: "medium", Prompt: "strict"},
+			check: func(t *testing.T, merged Config) {
+				if !reflect.DeepEqual(merged.Languages, []string{"python"}) || merged.Model != "gpt-4o-mini" || merged.MaxComments != 3 || merged.Prompt != "strict" {
+					t.Errorf("Merge() = %+v", merged)
+				}
+				if merged.Threshold() != models.SeverityMedium {
+					t.Errorf("Threshold() = %v, want medium", merged.Threshold())
+				}
+			},
+		},
+		{
+			name:     "language prompts merge per language",
+			override: Config{LanguagePrompts: map[string]string{"python": "py-review"}},
+			check: func(t *testing.T, merged Config) {
+				want := map[string]string{"go": "go-review", "python": "py-review"}
+				if !reflect.DeepEqual(merged.LanguagePrompts, want) {
+					t.Errorf("LanguagePrompts = %v, want %v", merged.LanguagePrompts, want)
+				}
+				if len(defaults.LanguagePrompts) != 1 {
+					t.Errorf("Merge() modified the defaults: %v", defaults.LanguagePrompts)
+				}
+				if merged.PromptFor("python") != "py-review" || merged.PromptFor("rust") != "default" {
+					t.Errorf("PromptFor() = %q, %q", merged.PromptFor("python"), merged.PromptFor("rust"))
+				}
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.check(t, Merge(defaults, tt.override))
+		})
+	}
+}
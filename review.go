@@ -0,0 +1,189 @@
+package main
+
+import (
+	"ai-api/config"
+	"ai-api/diff"
+	"ai-api/models"
+	"ai-api/sarif"
+	"ai-api/services"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"os/exec"
+	"strings"
+)
+
+// exit codes of the review command
+const (
+	exitOK       = 0
+	exitFindings = 1
+	exitError    = 2
+)
+
+// runReview reviews a local diff with the same retrieval and
+// GenerateReviewComment pipeline the server uses and prints the findings.
+// It returns a nonzero exit code when a finding reaches the -fail-on severity.
+func runReview(args []string) int {
+	flags := flag.NewFlagSet("review", flag.ContinueOnError)
+	envFile := flags.String("env", ".env", "path to the env file")
+	diffFile := flags.String("diff", "", `unified diff file to review, "-" for stdin`)
+	repoDir := flags.String("repo", ".", "local git repository used with -range")
+	gitRange := flags.String("range", "", `git range to review, e.g. "main..HEAD"`)
+	format := flags.String("format", "text", "output format: text, json, markdown or sarif")
+	sarifFiles := flags.String("sarif", "", "comma separated SARIF files of other linters to merge into the review")
+	failOn := flags.String("fail-on", string(models.SeverityHigh), "exit nonzero on findings at or above this severity (info, low, medium, high, critical)")
+	if err := flags.Parse(args); err != nil {
+		return exitError
+	}
+
+	threshold, err := models.ParseSeverity(*failOn)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return exitError
+	}
+
+	patch, err := readDiff(*diffFile, *repoDir, *gitRange)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return exitError
+	}
+	changeFiles, err := diff.Parse(bytes.NewReader(patch))
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return exitError
+	}
+
+	// the default env file is optional: the environment may hold the config
+	cfg, err := config.LoadConfig(*envFile)
+	if errors.Is(err, fs.ErrNotExist) && !flagSet(flags, "env") {
+		cfg, err = config.LoadConfigFromEnv()
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return exitError
+	}
+	services, err := services.NewServices(*cfg)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return exitError
+	}
+
+	external, err := readSARIF(*sarifFiles)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return exitError
+	}
+
+	findings, err := services.PRService.ReviewDiff(context.Background(), changeFiles, external, nil)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return exitError
+	}
+
+	if err := printFindings(os.Stdout, *format, findings); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return exitError
+	}
+
+	for _, finding := range findings {
+		if finding.Severity.AtLeast(threshold) {
+			return exitFindings
+		}
+	}
+	return exitOK
+}
+
+// readDiff returns the diff to review from a file, stdin or a git range.
+func readDiff(diffFile, repoDir, gitRange string) ([]byte, error) {
+	switch {
+	case diffFile != "" && gitRange != "":
+		return nil, fmt.Errorf("use either -diff or -range, not both")
+	case diffFile == "-":
+		return io.ReadAll(os.Stdin)
+	case diffFile != "":
+		return os.ReadFile(diffFile)
+	case gitRange != "":
+		cmd := exec.Command("git", "-C", repoDir, "diff", "--no-color", "--no-ext-diff", gitRange)
+		var stderr bytes.Buffer
+		cmd.Stderr = &stderr
+		out, err := cmd.Output()
+		if err != nil {
+			return nil, fmt.Errorf("git diff %s failed: %w: %s", gitRange, err, strings.TrimSpace(stderr.String()))
+		}
+		return out, nil
+	}
+	return nil, fmt.Errorf("nothing to review: pass -diff <file>, -diff - or -range <base..head>")
+}
+
+// readSARIF reads the findings of a comma separated list of SARIF files.
+func readSARIF(files string) ([]sarif.Finding, error) {
+	var findings []sarif.Finding
+	for _, file := range strings.Split(files, ",") {
+		if file = strings.TrimSpace(file); file == "" {
+			continue
+		}
+		f, err := os.Open(file)
+		if err != nil {
+			return nil, err
+		}
+		log, err := sarif.Parse(f)
+		f.Close()
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", file, err)
+		}
+		findings = append(findings, log.Findings()...)
+	}
+	return findings, nil
+}
+
+// printFindings writes the findings to w in the requested format.
+func printFindings(w io.Writer, format string, findings []models.GeneratePRCommentParams) error {
+	switch format {
+	case "json":
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(findings)
+	case "sarif":
+		return sarif.Encode(w, sarif.FromFindings(findings))
+	case "markdown", "md":
+		fmt.Fprintf(w, "## Review findings (%d)\n\n", len(findings))
+		for _, f := range findings {
+			fmt.Fprintf(w, "### `%s:%d` — %s", f.FileName, f.Line, f.Severity)
+			if f.Category != "" {
+				fmt.Fprintf(w, " (%s)", f.Category)
+			}
+			fmt.Fprintf(w, "\n\n%s\n\n", f.ProseBody())
+		}
+		return nil
+	case "text":
+		for _, f := range findings {
+			fmt.Fprintf(w, "%s:%d: [%s]", f.FileName, f.Line, f.Severity)
+			if f.Category != "" {
+				fmt.Fprintf(w, " %s:", f.Category)
+			}
+			fmt.Fprintf(w, " %s\n\n", f.ProseBody())
+		}
+		if len(findings) == 0 {
+			fmt.Fprintln(w, "no findings")
+		}
+		return nil
+	}
+	return fmt.Errorf("unknown output format %q", format)
+}
+
+// flagSet reports whether the named flag was given on the command line.
+func flagSet(flags *flag.FlagSet, name string) bool {
+	set := false
+	flags.Visit(func(f *flag.Flag) {
+		if f.Name == name {
+			set = true
+		}
+	})
+	return set
+}
@@ -0,0 +1,279 @@
+// File: sarif/sarif.go
+// SARIF 2.1.0 (Static Analysis Results Interchange Format) export of review
+// findings and import of results produced by external linters.
+package sarif
+
+import (
+	"ai-api/models"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/url"
+	"sort"
+	"strings"
+)
+
+// Version and Schema identify the SARIF format written.
+const (
+	Version = "2.1.0"
+	Schema  = "https://json.schemastore.org/sarif-2.1.0.json"
+)
+
+// ToolName is the driver name of the reviewer's own runs.
+const ToolName = "pr-checker"
+
+// Log is a SARIF log file.
+type Log struct {
+	Version string `json:"version"`
+	Schema  string `json:"$schema,omitempty"`
+	Runs    []Run  `json:"runs"`
+}
+
+// Run is the output of one tool.
+type Run struct {
+	Tool    Tool     `json:"tool"`
+	Results []Result `json:"results"`
+}
+
+type Tool struct {
+	Driver Driver `json:"driver"`
+}
+
+type Driver struct {
+	Name           string `json:"name"`
+	Version        string `json:"version,omitempty"`
+	InformationURI string `json:"informationUri,omitempty"`
+	Rules          []Rule `json:"rules,omitempty"`
+}
+
+type Rule struct {
+	ID               string   `json:"id"`
+	ShortDescription *Message `json:"shortDescription,omitempty"`
+	// DefaultConfiguration holds the rule's level when results omit it.
+	DefaultConfiguration *RuleConfiguration `json:"defaultConfiguration,omitempty"`
+}
+
+type RuleConfiguration struct {
+	Level string `json:"level,omitempty"`
+}
+
+// Result is one finding.
+type Result struct {
+	RuleID    string     `json:"ruleId,omitempty"`
+	RuleIndex *int       `json:"ruleIndex,omitempty"`
+	Level     string     `json:"level,omitempty"`
+	Message   Message    `json:"message"`
+	Locations []Location `json:"locations,omitempty"`
+	// Properties carries the reviewer's severity so it survives a round trip.
+	Properties map[string]interface{} `json:"properties,omitempty"`
+}
+
+type Message struct {
+	Text     string `json:"text,omitempty"`
+	Markdown string `json:"markdown,omitempty"`
+}
+
+type Location struct {
+	PhysicalLocation PhysicalLocation `json:"physicalLocation"`
+}
+
+type PhysicalLocation struct {
+	ArtifactLocation ArtifactLocation `json:"artifactLocation"`
+	Region           *Region          `json:"region,omitempty"`
+}
+
+type ArtifactLocation struct {
+	URI       string `json:"uri"`
+	URIBaseID string `json:"uriBaseId,omitempty"`
+}
+
+type Region struct {
+	StartLine   int `json:"startLine,omitempty"`
+	StartColumn int `json:"startColumn,omitempty"`
+	EndLine     int `json:"endLine,omitempty"`
+}
+
+// SARIF result levels.
+const (
+	LevelError   = "error"
+	LevelWarning = "warning"
+	LevelNote    = "note"
+	LevelNone    = "none"
+)
+
+// severityProperty is the result property holding the reviewer's severity.
+const severityProperty = "severity"
+
+// LevelForSeverity maps a severity to a SARIF level.
+func LevelForSeverity(severity models.Severity) string {
+	switch {
+	case severity.AtLeast(models.SeverityHigh):
+		return LevelError
+	case severity.AtLeast(models.SeverityMedium):
+		return LevelWarning
+	}
+	return LevelNote
+}
+
+// SeverityForLevel maps a SARIF level to a severity.
+func SeverityForLevel(level string) models.Severity {
+	switch level {
+	case LevelError:
+		return models.SeverityHigh
+	case LevelNote:
+		return models.SeverityLow
+	case LevelNone:
+		return models.SeverityInfo
+	}
+	// "warning" is also the level of results that do not set one
+	return models.SeverityMedium
+}
+
+// FromFindings builds a log with one run holding every finding. Findings are
+// grouped into rules by their Rule; rule IDs of tools are kept as they are
+// and the tool is recorded in the result's properties.
+func FromFindings(findings []models.GeneratePRCommentParams) *Log {
+	run := Run{
+		Tool:    Tool{Driver: Driver{Name: ToolName}},
+		Results: []Result{},
+	}
+	ruleIndex := map[string]int{}
+	for _, finding := range findings {
+		ruleID := finding.Rule
+		if ruleID == "" {
+			ruleID = "review"
+		}
+		index, ok := ruleIndex[ruleID]
+		if !ok {
+			index = len(run.Tool.Driver.Rules)
+			ruleIndex[ruleID] = index
+			rule := Rule{ID: ruleID}
+			if finding.Category != "" {
+				rule.ShortDescription = &Message{Text: finding.Category}
+			}
+			run.Tool.Driver.Rules = append(run.Tool.Driver.Rules, rule)
+		}
+
+		result := Result{
+			RuleID:    ruleID,
+			RuleIndex: &index,
+			Level:     LevelForSeverity(finding.Severity),
+			Message:   Message{Text: finding.CommentBody, Markdown: finding.ProseBody()},
+			Properties: map[string]interface{}{
+				severityProperty: string(finding.Severity),
+			},
+		}
+		location := Location{PhysicalLocation: PhysicalLocation{ArtifactLocation: ArtifactLocation{URI: finding.FileName}}}
+		if finding.Line > 0 {
+			location.PhysicalLocation.Region = &Region{StartLine: finding.Line}
+		}
+		result.Locations = []Location{location}
+		if finding.Tool != "" {
+			result.Properties["tool"] = finding.Tool
+		}
+		run.Results = append(run.Results, result)
+	}
+	return &Log{Version: Version, Schema: Schema, Runs: []Run{run}}
+}
+
+// Encode writes log as indented JSON.
+func Encode(w io.Writer, log *Log) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(log)
+}
+
+// Parse decodes a SARIF log. Only version 2.1.0 is accepted.
+func Parse(r io.Reader) (*Log, error) {
+	var log Log
+	if err := json.NewDecoder(r).Decode(&log); err != nil {
+		return nil, fmt.Errorf("failed to decode SARIF: %w", err)
+	}
+	if log.Version != Version {
+		return nil, fmt.Errorf("unsupported SARIF version %q, want %s", log.Version, Version)
+	}
+	return &log, nil
+}
+
+// Finding is a result of an external tool located in a file.
+type Finding struct {
+	Tool     string
+	RuleID   string
+	File     string
+	Line     int
+	Severity models.Severity
+	Message  string
+}
+
+// Findings returns the results of every run that point at a line of a file.
+// Results without a location or line are left out since they cannot be
+// placed on a diff.
+func (l *Log) Findings() []Finding {
+	var findings []Finding
+	for _, run := range l.Runs {
+		levels := map[string]string{}
+		for _, rule := range run.Tool.Driver.Rules {
+			if rule.DefaultConfiguration != nil {
+				levels[rule.ID] = rule.DefaultConfiguration.Level
+			}
+		}
+		for _, result := range run.Results {
+			if len(result.Locations) == 0 {
+				continue
+			}
+			physical := result.Locations[0].PhysicalLocation
+			if physical.Region == nil || physical.Region.StartLine == 0 || physical.ArtifactLocation.URI == "" {
+				continue
+			}
+
+			ruleID := result.RuleID
+			if ruleID == "" && result.RuleIndex != nil && *result.RuleIndex < len(run.Tool.Driver.Rules) {
+				ruleID = run.Tool.Driver.Rules[*result.RuleIndex].ID
+			}
+			level := result.Level
+			if level == "" {
+				level = levels[ruleID]
+			}
+			severity := SeverityForLevel(level)
+			if raw, ok := result.Properties[severityProperty].(string); ok {
+				if parsed, err := models.ParseSeverity(raw); err == nil {
+					severity = parsed
+				}
+			}
+			message := result.Message.Markdown
+			if message == "" {
+				message = result.Message.Text
+			}
+
+			findings = append(findings, Finding{
+				Tool:     run.Tool.Driver.Name,
+				RuleID:   ruleID,
+				File:     physical.ArtifactLocation.URI,
+				Line:     physical.Region.StartLine,
+				Severity: severity,
+				Message:  message,
+			})
+		}
+	}
+	return findings
+}
+
+// MatchFile returns the file of files a result URI refers to. External tools
+// report paths relative to where they ran or as absolute file:// URIs, so
+// the longest file the URI ends with (at a path boundary) wins.
+func MatchFile(uri string, files []string) (string, bool) {
+	path := strings.TrimPrefix(uri, "file://")
+	if unescaped, err := url.PathUnescape(path); err == nil {
+		path = unescaped
+	}
+	path = strings.TrimPrefix(path, "./")
+
+	candidates := append([]string{}, files...)
+	sort.Slice(candidates, func(i, j int) bool { return len(candidates[i]) > len(candidates[j]) })
+	for _, file := range candidates {
+		if path == file || strings.HasSuffix(path, "/"+file) {
+			return file, true
+		}
+	}
+	return "", false
+}
@@ -0,0 +1,148 @@
+package sarif
+
+import (
+	"ai-api/models"
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestLevels(t *testing.T) {
+	tests := []struct {
+		severity models.Severity
+		level    string
+	}{
+		{models.SeverityCritical, LevelError},
+		{models.SeverityHigh, LevelError},
+		{models.SeverityMedium, LevelWarning},
+		{models.SeverityLow, LevelNote},
+		{models.SeverityInfo, LevelNote},
+	}
+	for _, tt := range tests {
+		if got := LevelForSeverity(tt.severity); got != tt.level {
+			t.Errorf("LevelForSeverity(%s) = %s, want %s", tt.severity, got, tt.level)
+		}
+	}
+
+	levels := map[string]models.Severity{
+		LevelError:   models.SeverityHigh,
+		LevelWarning: models.SeverityMedium,
+		"":           models.SeverityMedium,
+		LevelNote:    models.SeverityLow,
+		LevelNone:    models.SeverityInfo,
+	}
+	for level, want := range levels {
+		if got := SeverityForLevel(level); got != want {
+			t.Errorf("SeverityForLevel(%q) = %s, want %s", level, got, want)
+		}
+	}
+}
+
+func TestRoundTrip(t *testing.T) {
+	findings := []models.GeneratePRCommentParams{
+		{FileName: "store/cache.go", Line: 12, Severity: models.SeverityHigh, Category: "bug", Rule: "review/bug", CommentBody: "The map is nil."},
+		{FileName: "store/cache.go", Line: 20, Severity: models.SeverityCritical, Category: "bug", Rule: "review/bug", CommentBody: "Data race on c.data."},
+		{FileName: "main.go", Line: 3, Severity: models.SeverityMedium, Category: "static analysis", Rule: "printf", Tool: "go/analysis", CommentBody: "**printf**: wrong verb"},
+	}
+	log := FromFindings(findings)
+	if rules := log.Runs[0].Tool.Driver.Rules; len(rules) != 2 || rules[0].ID != "review/bug" || rules[1].ID != "printf" {
+		t.Fatalf("rules = %+v, want review/bug and printf", rules)
+	}
+	if index := *log.Runs[0].Results[1].RuleIndex; index != 0 {
+		t.Errorf("second result rule index = %d, want 0", index)
+	}
+	if tool := log.Runs[0].Results[2].Properties["tool"]; tool != "go/analysis" {
+		t.Errorf("tool property = %v", tool)
+	}
+
+	var buf bytes.Buffer
+	if err := Encode(&buf, log); err != nil {
+		t.Fatal(err)
+	}
+	parsed, err := Parse(&buf)
+	if err != nil {
+		t.Fatalf("Parse() error = %v", err)
+	}
+	got := parsed.Findings()
+	want := []Finding{
+		{Tool: ToolName, RuleID: "review/bug", File: "store/cache.go", Line: 12, Severity: models.SeverityHigh, Message: "The map is nil."},
+		{Tool: ToolName, RuleID: "review/bug", File: "store/cache.go", Line: 20, Severity: models.SeverityCritical, Message: "Data race on c.data."},
+		{Tool: ToolName, RuleID: "printf", File: "main.go", Line: 3, Severity: models.SeverityMedium, Message: "**printf**: wrong verb"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Findings() =\n%+v\nwant\n%+v", got, want)
+	}
+}
+
+func TestParseExternal(t *testing.T) {
+	tests := []struct {
+		name    string
+		log     string
+		want    []Finding
+		wantErr string
+	}{
+		{
+			name: "levels from results, rules and defaults",
+			log: `{"version": "2.1.0", "runs": [{
+				"tool": {"driver": {"name": "staticcheck", "rules": [
+					{"id": "SA1000", "defaultConfiguration": {"level": "error"}},
+					{"id": "ST1003"}
+				]}},
+				"results": [
+					{"ruleId": "SA1000", "message": {"text": "invalid regexp"}, "locations": [{"physicalLocation": {"artifactLocation": {"uri": "pkg/a.go"}, "region": {"startLine": 4}}}]},
+					{"ruleIndex": 1, "level": "note", "message": {"text": "bad name"}, "locations": [{"physicalLocation": {"artifactLocation": {"uri": "pkg/b.go"}, "region": {"startLine": 9}}}]},
+					{"ruleId": "ST1003", "message": {"text": "no level"}, "locations": [{"physicalLocation": {"artifactLocation": {"uri": "pkg/b.go"}, "region": {"startLine": 10}}}]},
+					{"ruleId": "SA1000", "message": {"text": "no region"}, "locations": [{"physicalLocation": {"artifactLocation": {"uri": "pkg/a.go"}}}]},
+					{"ruleId": "SA1000", "message": {"text": "no location"}}
+				]
+			}]}`,
+			want: []Finding{
+				{Tool: "staticcheck", RuleID: "SA1000", File: "pkg/a.go", Line: 4, Severity: models.SeverityHigh, Message: "invalid regexp"},
+				{Tool: "staticcheck", RuleID: "ST1003", File: "pkg/b.go", Line: 9, Severity: models.SeverityLow, Message: "bad name"},
+				{Tool: "staticcheck", RuleID: "ST1003", File: "pkg/b.go", Line: 10, Severity: models.SeverityMedium, Message: "no level"},
+			},
+		},
+		{name: "wrong version", log: `{"version": "2.0.0", "runs": []}`, wantErr: "unsupported SARIF version"},
+		{name: "not json", log: `<sarif/>`, wantErr: "failed to decode SARIF"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			log, err := Parse(strings.NewReader(tt.log))
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("Parse() error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Parse() error = %v", err)
+			}
+			if got := log.Findings(); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Findings() =\n%+v\nwant\n%+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMatchFile(t *testing.T) {
+	files := []string{"cache.go", "store/cache.go", "cmd/main.go"}
+	tests := []struct {
+		uri, want string
+		ok        bool
+	}{
+		{"store/cache.go", "store/cache.go", true},
+		{"./cmd/main.go", "cmd/main.go", true},
+		{"file:///home/ci/repo/store/cache.go", "store/cache.go", true},
+		{"file:///home/ci/repo/cache.go", "cache.go", true},
+		{"file:///home/ci/my%20repo/cmd/main.go", "cmd/main.go", true},
+		{"other/main.go", "", false},
+		{"xcache.go", "", false},
+	}
+	for _, tt := range tests {
+		got, ok := MatchFile(tt.uri, files)
+		if got != tt.want || ok != tt.ok {
+			t.Errorf("MatchFile(%q) = %q, %v, want %q, %v", tt.uri, got, ok, tt.want, tt.ok)
+		}
+	}
+}
@@ -0,0 +1,163 @@
+package router
+
+import (
+	"ai-api/auth"
+	"ai-api/authz"
+	"bytes"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/rs/zerolog"
+)
+
+// APIKeyMiddleware authenticates requests with a static API key sent as
+// "Authorization: Bearer <key>" or "X-API-Key: <key>", and checks that the
+// key's scopes cover the :owner/:repo of the route, or include the admin
+// scope for routes without one. The key ID (never the key) is stored in the
+// context and logged for auditing.
+func APIKeyMiddleware(keys *auth.KeyStore) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		logger := c.MustGet("zlog").(zerolog.Logger)
+
+		key, ok := keys.Authenticate(apiKeyFromRequest(c))
+		if !ok {
+			logger.Warn().Str("path", c.FullPath()).Str("remote_ip", c.ClientIP()).Msg("rejected request with missing or invalid api key")
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "missing or invalid api key"})
+			return
+		}
+
+		owner, repo := routeRepository(c)
+		if owner != "" && !key.Allows(owner, repo) {
+			logger.Warn().Str("api_key_id", key.ID).Str("owner", owner).Str("repo", repo).Msg("api key not scoped for repository")
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "api key is not allowed for this repository"})
+			return
+		}
+		// routes about no single repository (reports over all of them)
+		// need the admin scope
+		if owner == "" && !key.IsAdmin() {
+			logger.Warn().Str("api_key_id", key.ID).Str("path", c.FullPath()).Msg("api key without admin scope")
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "api key is not allowed for this route"})
+			return
+		}
+
+		c.Set("api_key_id", key.ID)
+		c.Set("zlog", logger.With().Str("api_key_id", key.ID).Logger())
+		logger.Info().Str("api_key_id", key.ID).Str("method", c.Request.Method).Str("path", c.Request.URL.Path).Msg("authenticated api request")
+		c.Next()
+	}
+}
+
+func apiKeyFromRequest(c *gin.Context) string {
+	if key := c.GetHeader("X-API-Key"); key != "" {
+		return key
+	}
+	header := c.GetHeader("Authorization")
+	if strings.HasPrefix(header, "Bearer ") {
+		return strings.TrimSpace(strings.TrimPrefix(header, "Bearer "))
+	}
+	return ""
+}
+
+// routeRepository returns the owner and repository a route targets. GitLab
+// project paths ("group/sub/project") are split on their last slash.
+func routeRepository(c *gin.Context) (owner, repo string) {
+	if project := c.Param("project"); project != "" {
+		if i := strings.LastIndex(project, "/"); i >= 0 {
+			return project[:i], project[i+1:]
+		}
+		return project, ""
+	}
+	return c.Param("owner"), c.Param("repo")
+}
+
+// AuthorizationMiddleware checks that the authenticated api key has the
+// permission a route needs: can_trigger_review on the repository of review
+// routes, plus can_configure when the request forces posting with
+// dry_run=false or uploads to code scanning with upload_sarif, and
+// can_view_history on the tenant of report routes. Read-only previews with
+// dry_run=true need no more than a review. It must run after
+// APIKeyMiddleware.
+func AuthorizationMiddleware(checker authz.Checker) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		logger := c.MustGet("zlog").(zerolog.Logger)
+		user := authz.User(c.GetString("api_key_id"))
+
+		var object string
+		var contextual []authz.Tuple
+		relations := []string{authz.CanViewHistory}
+		if owner, repo := routeRepository(c); owner != "" {
+			var ownerTuple authz.Tuple
+			object, ownerTuple = authz.Repo(owner, repo)
+			contextual = append(contextual, ownerTuple)
+			relations = []string{authz.CanTriggerReview}
+			if queryMayBe(c, "dry_run", false) || queryMayBe(c, "upload_sarif", true) {
+				relations = append(relations, authz.CanConfigure)
+			}
+		} else {
+			object = authz.Tenant(c.Query("tenant"))
+		}
+
+		for _, relation := range relations {
+			allowed, err := checker.Check(c.Request.Context(), user, relation, object, contextual...)
+			if err != nil {
+				logger.Error().Err(err).Str("relation", relation).Str("object", object).Msg("authorization check failed")
+				c.AbortWithStatusJSON(http.StatusServiceUnavailable, gin.H{"error": "authorization check failed"})
+				return
+			}
+			if !allowed {
+				logger.Warn().Str("relation", relation).Str("object", object).Msg("api key not authorized")
+				c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "api key lacks " + relation + " on " + object})
+				return
+			}
+		}
+		c.Next()
+	}
+}
+
+// queryMayBe reports whether the boolean query parameter name is given and
+// set to value, or to something the handler may not read as its opposite.
+// Like the handler, it reads "?name" without a value as true.
+func queryMayBe(c *gin.Context, name string, value bool) bool {
+	raw, ok := c.GetQuery(name)
+	if !ok {
+		return false
+	}
+	if raw == "" {
+		return value
+	}
+	parsed, err := strconv.ParseBool(raw)
+	return err != nil || parsed == value
+}
+
+// maxWebhookPayload is the largest webhook payload read, GitHub's own limit.
+const maxWebhookPayload = 25 << 20
+
+// WebhookSignatureMiddleware rejects webhook deliveries whose
+// X-Hub-Signature-256 header is not the HMAC of the body under secret. The
+// body is put back for the handler. Without a secret every delivery is
+// rejected.
+func WebhookSignatureMiddleware(secret string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		logger := c.MustGet("zlog").(zerolog.Logger)
+
+		if secret == "" {
+			c.AbortWithStatusJSON(http.StatusServiceUnavailable, gin.H{"error": "webhooks are not configured"})
+			return
+		}
+		payload, err := io.ReadAll(io.LimitReader(c.Request.Body, maxWebhookPayload))
+		if err != nil {
+			c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "failed to read payload"})
+			return
+		}
+		if !auth.VerifySignature(secret, payload, c.GetHeader("X-Hub-Signature-256")) {
+			logger.Warn().Str("path", c.FullPath()).Str("remote_ip", c.ClientIP()).Str("delivery", c.GetHeader("X-GitHub-Delivery")).Msg("rejected webhook with missing or invalid signature")
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "missing or invalid signature"})
+			return
+		}
+		c.Request.Body = io.NopCloser(bytes.NewReader(payload))
+		c.Next()
+	}
+}
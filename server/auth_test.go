@@ -0,0 +1,180 @@
+package router
+
+import (
+	"ai-api/auth"
+	"ai-api/authz"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/rs/zerolog"
+)
+
+func newAPIKeyTestRouter(t *testing.T) *gin.Engine {
+	t.Helper()
+	keys, err := auth.NewKeyStore([]auth.APIKey{
+		{ID: "acme-ci", Hash: auth.HashKey("acme-key"), Scopes: []string{"acme/*"}},
+		{ID: "all-repos", Hash: auth.HashKey("all-key"), Scopes: []string{"*/*"}},
+		{ID: "ops", Hash: auth.HashKey("admin-key"), Scopes: []string{"admin"}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.UseRawPath = true
+	r.UnescapePathValues = true
+	r.Use(ZlogMiddleware(zerolog.Nop()))
+	api := r.Group("/v1/api", APIKeyMiddleware(keys))
+	ok := func(c *gin.Context) { c.Status(http.StatusOK) }
+	api.GET("/pr/:owner/:repo/:id", ok)
+	api.GET("/mr/gitlab/:project/:iid", ok)
+	api.GET("/prompts/stats", ok)
+	api.GET("/feedback/report", ok)
+	return r
+}
+
+func TestAPIKeyMiddleware(t *testing.T) {
+	r := newAPIKeyTestRouter(t)
+	tests := []struct {
+		name, key, path string
+		want            int
+	}{
+		{"no key", "", "/v1/api/pr/acme/api/1", http.StatusUnauthorized},
+		{"wrong key", "nope", "/v1/api/pr/acme/api/1", http.StatusUnauthorized},
+		{"scoped repo", "acme-key", "/v1/api/pr/acme/api/1", http.StatusOK},
+		{"other owner", "acme-key", "/v1/api/pr/globex/api/1", http.StatusForbidden},
+		{"gitlab project", "acme-key", "/v1/api/mr/gitlab/acme%2Fapi/1", http.StatusOK},
+		{"gitlab other project", "acme-key", "/v1/api/mr/gitlab/globex%2Fapi/1", http.StatusForbidden},
+		{"repo key on stats", "acme-key", "/v1/api/prompts/stats", http.StatusForbidden},
+		{"all repos key on report", "all-key", "/v1/api/feedback/report?refresh=true", http.StatusForbidden},
+		{"admin on report", "admin-key", "/v1/api/feedback/report?tenant=acme", http.StatusOK},
+		{"admin on stats", "admin-key", "/v1/api/prompts/stats", http.StatusOK},
+		{"admin on repo", "admin-key", "/v1/api/pr/acme/api/1", http.StatusForbidden},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, tt.path, nil)
+			if tt.key != "" {
+				req.Header.Set("Authorization", "Bearer "+tt.key)
+			}
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, req)
+			if w.Code != tt.want {
+				t.Errorf("GET %s = %d, want %d: %s", tt.path, w.Code, tt.want, w.Body)
+			}
+		})
+	}
+}
+
+func TestAPIKeyFromRequest(t *testing.T) {
+	tests := []struct {
+		header, value, want string
+	}{
+		{"X-API-Key", "abc", "abc"},
+		{"Authorization", "Bearer abc", "abc"},
+		{"Authorization", "Basic abc", ""},
+	}
+	for _, tt := range tests {
+		c, _ := gin.CreateTestContext(httptest.NewRecorder())
+		c.Request = httptest.NewRequest(http.MethodGet, "/", nil)
+		c.Request.Header.Set(tt.header, tt.value)
+		if got := apiKeyFromRequest(c); got != tt.want {
+			t.Errorf("apiKeyFromRequest(%s: %s) = %q, want %q", tt.header, tt.value, got, tt.want)
+		}
+	}
+}
+
+func TestWebhookSignatureMiddleware(t *testing.T) {
+	payload := `{"action":"created"}`
+	tests := []struct {
+		name, secret, signature string
+		want                    int
+	}{
+		{"valid", "secret", auth.SignPayload("secret", []byte(payload)), http.StatusOK},
+		{"missing signature", "secret", "", http.StatusUnauthorized},
+		{"wrong secret", "secret", auth.SignPayload("other", []byte(payload)), http.StatusUnauthorized},
+		{"other payload", "secret", auth.SignPayload("secret", []byte(`{}`)), http.StatusUnauthorized},
+		{"not configured", "", auth.SignPayload("", []byte(payload)), http.StatusServiceUnavailable},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			gin.SetMode(gin.TestMode)
+			r := gin.New()
+			r.Use(ZlogMiddleware(zerolog.Nop()))
+			var received string
+			r.POST("/v1/webhooks/github", WebhookSignatureMiddleware(tt.secret), func(c *gin.Context) {
+				body, _ := io.ReadAll(c.Request.Body)
+				received = string(body)
+				c.Status(http.StatusOK)
+			})
+
+			req := httptest.NewRequest(http.MethodPost, "/v1/webhooks/github", strings.NewReader(payload))
+			if tt.signature != "" {
+				req.Header.Set("X-Hub-Signature-256", tt.signature)
+			}
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, req)
+			if w.Code != tt.want {
+				t.Fatalf("POST = %d, want %d: %s", w.Code, tt.want, w.Body)
+			}
+			// the handler still reads the verified payload
+			if tt.want == http.StatusOK && received != payload {
+				t.Errorf("handler read %q, want %q", received, payload)
+			}
+		})
+	}
+}
+
+func TestAuthorizationMiddleware(t *testing.T) {
+	checker, err := authz.NewMemory(authz.DefaultModel, []authz.Tuple{
+		{User: "user:ci", Relation: "reviewer", Object: "org:acme"},
+		{User: "user:lead", Relation: "maintainer", Object: "repo:acme/api"},
+		{User: "user:*", Relation: "viewer", Object: "tenant:_instance"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.Use(ZlogMiddleware(zerolog.Nop()))
+	// stands in for APIKeyMiddleware
+	authenticate := func(c *gin.Context) { c.Set("api_key_id", c.GetHeader("X-Key-ID")) }
+	api := r.Group("/v1/api", authenticate, AuthorizationMiddleware(checker))
+	ok := func(c *gin.Context) { c.Status(http.StatusOK) }
+	api.GET("/pr/:owner/:repo/:id", ok)
+	api.GET("/prompts/stats", ok)
+
+	tests := []struct {
+		name, keyID, path string
+		want              int
+	}{
+		{"review", "ci", "/v1/api/pr/acme/api/1", http.StatusOK},
+		{"review of another org", "ci", "/v1/api/pr/globex/api/1", http.StatusForbidden},
+		{"review with an owner in another case", "ci", "/v1/api/pr/Acme/api/1", http.StatusOK},
+		{"preview", "ci", "/v1/api/pr/acme/api/1?dry_run=true", http.StatusOK},
+		{"preview without a value", "ci", "/v1/api/pr/acme/api/1?dry_run", http.StatusOK},
+		{"forced posting", "ci", "/v1/api/pr/acme/api/1?dry_run=false", http.StatusForbidden},
+		{"forced posting by a maintainer", "lead", "/v1/api/pr/acme/api/1?dry_run=false", http.StatusOK},
+		{"invalid dry_run", "ci", "/v1/api/pr/acme/api/1?dry_run=maybe", http.StatusForbidden},
+		{"sarif upload", "ci", "/v1/api/pr/acme/api/1?upload_sarif=true", http.StatusForbidden},
+		{"sarif upload by a maintainer", "lead", "/v1/api/pr/acme/api/1?upload_sarif", http.StatusOK},
+		{"no sarif upload", "ci", "/v1/api/pr/acme/api/1?upload_sarif=false", http.StatusOK},
+		{"stats", "anyone", "/v1/api/prompts/stats", http.StatusOK},
+		{"tenant stats", "anyone", "/v1/api/prompts/stats?tenant=acme", http.StatusForbidden},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, tt.path, nil)
+			req.Header.Set("X-Key-ID", tt.keyID)
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, req)
+			if w.Code != tt.want {
+				t.Errorf("GET %s as %s = %d, want %d: %s", tt.path, tt.keyID, w.Code, tt.want, w.Body)
+			}
+		})
+	}
+}
@@ -1,6 +1,8 @@
 package router
 
 import (
+	"ai-api/auth"
+	"ai-api/authz"
 	config "ai-api/config"
 	"ai-api/handlers"
 	handler "ai-api/handlers" // Import the handler package
@@ -12,29 +14,47 @@ import (
 )
 
 type Server struct {
-	Config    *config.Config
-	PRHandler *handler.PRHandler
-	Router    *gin.Engine
+	Config          *config.Config
+	PRHandler       *handler.PRHandler
+	PromptHandler   *handler.PromptHandler
+	WebhookHandler  *handler.WebhookHandler
+	FeedbackHandler *handler.FeedbackHandler
+	Router          *gin.Engine
+	APIKeys         *auth.KeyStore
+	Authorizer      authz.Checker
 }
 
 // SetupRouter sets up all routes for the application
-func NewServer(cfg *config.Config, services *services.Services) Server {
+// apiKeys may be nil only when authentication is disabled in the config,
+// authorizer when api keys are not authorized beyond their scopes.
+func NewServer(cfg *config.Config, services *services.Services, apiKeys *auth.KeyStore, authorizer authz.Checker) Server {
 
 	logger := setupLogger()
 
 	r := gin.Default()
+	// match on the raw path so URL-encoded GitLab project paths stay one segment
+	r.UseRawPath = true
+	r.UnescapePathValues = true
 
 	// create handlers
 	prHandler := handlers.NewPRHandler(services.PRService)
+	promptHandler := handlers.NewPromptHandler(services.PRService)
+	webhookHandler := handlers.NewWebhookHandler(services.PRService)
+	feedbackHandler := handlers.NewFeedbackHandler(services.PRService)
 
 	r.Use(ZlogMiddleware(logger))
 	r.SetTrustedProxies([]string{})
 
 	// Register routes
 	server := &Server{
-		Config:    cfg,
-		Router:    r,
-		PRHandler: prHandler,
+		Config:          cfg,
+		Router:          r,
+		PRHandler:       prHandler,
+		PromptHandler:   promptHandler,
+		WebhookHandler:  webhookHandler,
+		FeedbackHandler: feedbackHandler,
+		APIKeys:         apiKeys,
+		Authorizer:      authorizer,
 	}
 
 	server.routes()
@@ -56,14 +76,50 @@ func ZlogMiddleware(logger zerolog.Logger) gin.HandlerFunc {
 
 func (s *Server) routes() {
 
+	// Webhook routes must be registered outside this group: they are
+	// authenticated with signature verification rather than api keys.
 	api := s.Router.Group("/v1/api")
+	if !s.Config.AuthDisabled {
+		api.Use(APIKeyMiddleware(s.APIKeys))
+		if s.Authorizer != nil {
+			api.Use(AuthorizationMiddleware(s.Authorizer))
+		}
+	}
 	{
 		// PULL REQUEST ROUTES
 		pr := api.Group("/pr")
 		{
 			pr.GET("/:owner/:repo/:id", s.PRHandler.AnalyzePR)
+			// same review with a SARIF log of external linters as the body
+			pr.POST("/:owner/:repo/:id", s.PRHandler.AnalyzePR)
+		}
+
+		// MERGE REQUEST ROUTES
+		mr := api.Group("/mr")
+		{
+			mr.GET("/gitlab/:project/:iid", s.PRHandler.AnalyzeMR)
+			mr.POST("/gitlab/:project/:iid", s.PRHandler.AnalyzeMR)
+		}
+
+		// PROMPT ROUTES
+		prompts := api.Group("/prompts")
+		{
+			prompts.GET("/stats", s.PromptHandler.PromptStats)
+		}
+
+		// FEEDBACK ROUTES
+		feedback := api.Group("/feedback")
+		{
+			feedback.GET("/report", s.FeedbackHandler.FeedbackReport)
 		}
 	}
 
+	// WEBHOOK ROUTES
+	webhooks := s.Router.Group("/v1/webhooks")
+	webhooks.Use(WebhookSignatureMiddleware(s.Config.WebhookSecret))
+	{
+		webhooks.POST("/github", s.WebhookHandler.GitHub)
+	}
+
 	// return r
 }
@@ -0,0 +1,157 @@
+package services
+
+import (
+	"ai-api/analyzers"
+	"ai-api/diff"
+	"ai-api/models"
+	"ai-api/sarif"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"strings"
+	"time"
+)
+
+// analysisTimeout bounds downloading, loading and analyzing a pull request.
+const analysisTimeout = 5 * time.Minute
+
+// analyzerCategory is the category of findings reported by analyzers and
+// external linters, and analyzerTool the tool of in-process analyzers.
+const (
+	analyzerCategory = "static analysis"
+	analyzerTool     = "go/analysis"
+)
+
+// ArchiveFetcher downloads a repository at a commit as a gzipped tarball.
+type ArchiveFetcher interface {
+	DownloadTarball(ctx context.Context, owner, repo, ref string) (io.ReadCloser, error)
+}
+
+// analyzeChanges checks out the head commit into a temporary directory and
+// runs the configured analyzers on the packages of the changed Go files, in
+// the sandbox, when the repository is trusted. It returns the diagnostics by
+// file. Failures are logged and leave the review without analyzer findings.
+func (s *PRService) analyzeChanges(ctx context.Context, scope ReviewScope, changeFiles *models.ChangeFiles) map[string][]analyzers.Diagnostic {
+	if len(s.analyzers) == 0 || scope.Archive == nil || !s.analysisTrusted(scope.RepoOwner, scope.RepoName) {
+		return nil
+	}
+	var goFiles []string
+	for _, file := range changeFiles.Files {
+		if strings.HasSuffix(file.Filename, ".go") {
+			goFiles = append(goFiles, file.Filename)
+		}
+	}
+	sha := headCommitSHA(changeFiles)
+	if len(goFiles) == 0 || sha == "" {
+		return nil
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, analysisTimeout)
+	defer cancel()
+
+	dir, err := os.MkdirTemp("", "pr-checker-")
+	if err != nil {
+		fmt.Printf("failed to create checkout directory: %v\n", err)
+		return nil
+	}
+	defer os.RemoveAll(dir)
+
+	archive, err := scope.Archive.DownloadTarball(ctx, scope.RepoOwner, scope.RepoName, sha)
+	if err != nil {
+		fmt.Printf("failed to check out %s for analysis: %v\n", sha, err)
+		return nil
+	}
+	err = analyzers.Extract(archive, dir)
+	archive.Close()
+	if err != nil {
+		fmt.Printf("failed to check out %s for analysis: %v\n", sha, err)
+		return nil
+	}
+
+	diagnostics, err := s.sandbox.Run(ctx, dir, s.analyzers, goFiles)
+	if err != nil {
+		fmt.Printf("failed to run analyzers: %v\n", err)
+		return nil
+	}
+	byFile := map[string][]analyzers.Diagnostic{}
+	for _, diagnostic := range diagnostics {
+		byFile[diagnostic.File] = append(byFile[diagnostic.File], diagnostic)
+	}
+	return byFile
+}
+
+// analysisTrusted reports whether owner/repo matches one of the
+// AnalyzerRepos globs. Analyzing loads the pull request's code with the go
+// command, so only repositories whose contributors are trusted opt in.
+func (s *PRService) analysisTrusted(owner, repo string) bool {
+	name := strings.ToLower(owner + "/" + repo)
+	for _, pattern := range strings.Split(s.cfg.AnalyzerRepos, ",") {
+		pattern = strings.ToLower(strings.TrimSpace(pattern))
+		if ok, _ := path.Match(pattern, name); ok && pattern != "" {
+			return true
+		}
+	}
+	return false
+}
+
+// analyzerFindings turns the diagnostics on lines a patch adds into findings;
+// problems in untouched code are not the pull request's doing.
+func analyzerFindings(diagnostics []analyzers.Diagnostic, patch string) []reviewFinding {
+	var findings []reviewFinding
+	for _, diagnostic := range diagnostics {
+		if !addedLine(patch, diagnostic.Line) {
+			continue
+		}
+		findings = append(findings, reviewFinding{
+			Line:     diagnostic.Line,
+			Severity: models.SeverityMedium,
+			Category: analyzerCategory,
+			Message:  fmt.Sprintf("**%s**: %s", diagnostic.Analyzer, diagnostic.Message),
+			Rule:     diagnostic.Analyzer,
+			Tool:     analyzerTool,
+		})
+	}
+	return findings
+}
+
+// externalFindings turns the findings of external linters (from SARIF) in
+// file on lines the patch adds into findings. files are all changed files,
+// used to resolve the linters' paths.
+func externalFindings(external []sarif.Finding, file string, files []string, patch string) []reviewFinding {
+	var findings []reviewFinding
+	for _, finding := range external {
+		if matched, ok := sarif.MatchFile(finding.File, files); !ok || matched != file || !addedLine(patch, finding.Line) {
+			continue
+		}
+		rule := finding.RuleID
+		if rule == "" {
+			rule = finding.Tool
+		}
+		findings = append(findings, reviewFinding{
+			Line:     finding.Line,
+			Severity: finding.Severity,
+			Category: analyzerCategory,
+			Message:  fmt.Sprintf("**%s %s**: %s", finding.Tool, finding.RuleID, finding.Message),
+			Rule:     rule,
+			Tool:     finding.Tool,
+		})
+	}
+	return findings
+}
+
+// addedLine reports whether a line of the new file is added by patch.
+func addedLine(patch string, line int) bool {
+	position := diff.PositionForLine(patch, line)
+	return position != 0 && diff.LineKindForPosition(patch, position) == diff.LineAdded
+}
+
+// promptDiagnostics describes the findings of tools for the prompt.
+func promptDiagnostics(findings []reviewFinding) []string {
+	var lines []string
+	for _, finding := range findings {
+		lines = append(lines, fmt.Sprintf("line %d: %s", finding.Line, finding.Message))
+	}
+	return lines
+}
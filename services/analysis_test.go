@@ -0,0 +1,29 @@
+package services
+
+import (
+	"ai-api/config"
+	"testing"
+)
+
+func TestAnalysisTrusted(t *testing.T) {
+	tests := []struct {
+		repos       string
+		owner, repo string
+		want        bool
+	}{
+		{"", "acme", "api", false},
+		{"acme/*", "acme", "api", true},
+		{"acme/*", "Acme", "API", true},
+		{"acme/*", "acme-forks", "api", false},
+		{"tools/cli, acme/api", "acme", "api", true},
+		{"tools/cli", "tools", "cli-fork", false},
+		{"*/*", "anyone", "anything", true},
+		{",", "acme", "api", false},
+	}
+	for _, tt := range tests {
+		s := &PRService{cfg: config.Config{AnalyzerRepos: tt.repos}}
+		if got := s.analysisTrusted(tt.owner, tt.repo); got != tt.want {
+			t.Errorf("analysisTrusted(%q, %s/%s) = %v, want %v", tt.repos, tt.owner, tt.repo, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,242 @@
+package services
+
+import (
+	"ai-api/diff"
+	"ai-api/models"
+	"ai-api/store"
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Slash commands developers post on a pull request to drive the reviewer.
+const (
+	commandReview  = "/review"  // review the pull request, or the files given
+	commandIgnore  = "/ignore"  // in a thread of the bot: suppress its finding
+	commandSummary = "/summary" // regenerate the summary comment
+	commandExplain = "/explain" // on a line: explain the code, or answer a question about it
+)
+
+// CommandRun is the outcome of a command that was run.
+const CommandRun = "ran"
+
+// Reactions acknowledging a command.
+const (
+	reactionAccepted = "eyes"
+	reactionDone     = "rocket"
+	reactionDenied   = "-1"
+	reactionFailed   = "confused"
+)
+
+// command is a slash command found in a comment.
+type command struct {
+	Name string
+	Args []string
+
+	Owner, Repo, PRNumber string
+	User                  models.User
+	CommentID             int64
+	// ReviewComment is the review comment holding the command, nil for
+	// commands on the pull request's conversation.
+	ReviewComment *models.ReviewComment
+	PullRequest   *models.PullRequest
+}
+
+// parseCommand returns the slash command on the first non-empty line of a
+// comment body. Unknown commands are not ours and are left alone.
+func parseCommand(body string) (name string, args []string, ok bool) {
+	for _, line := range strings.Split(body, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) == 0 {
+			continue
+		}
+		switch name := strings.ToLower(fields[0]); name {
+		case commandReview, commandIgnore, commandSummary, commandExplain:
+			return name, fields[1:], true
+		}
+		return "", nil, false
+	}
+	return "", nil, false
+}
+
+// HandleIssueComment runs the slash command of a comment on a pull
+// request's conversation. It returns CommandRun or ReplyIgnored and why.
+func (s *PRService) HandleIssueComment(ctx context.Context, event models.IssueCommentEvent) (outcome, reason string, err error) {
+	s = s.forEvent(event.Installation, event.Repository.Owner.Login)
+	switch {
+	case event.Action != "created":
+		return ReplyIgnored, "action " + event.Action, nil
+	case event.Issue.PullRequest == nil:
+		return ReplyIgnored, "not a pull request", nil
+	case s.isBot(event.Comment.User):
+		return ReplyIgnored, "comment by a bot", nil
+	}
+	name, args, ok := parseCommand(event.Comment.Body)
+	if !ok {
+		return ReplyIgnored, "no command", nil
+	}
+	return s.runCommand(ctx, command{
+		Name:      name,
+		Args:      args,
+		Owner:     event.Repository.Owner.Login,
+		Repo:      event.Repository.Name,
+		PRNumber:  strconv.Itoa(event.Issue.Number),
+		User:      event.Comment.User,
+		CommentID: event.Comment.ID,
+	})
+}
+
+// HandleReviewComment runs the slash command of a review comment, or
+// answers it as a reply in one of the bot's threads, see ReplyToReviewComment.
+func (s *PRService) HandleReviewComment(ctx context.Context, event models.ReviewCommentEvent) (outcome, reason string, err error) {
+	s = s.forEvent(event.Installation, event.Repository.Owner.Login)
+	name, args, ok := parseCommand(event.Comment.Body)
+	if !ok {
+		return s.ReplyToReviewComment(ctx, event)
+	}
+	switch {
+	case event.Action != "created":
+		return ReplyIgnored, "action " + event.Action, nil
+	case s.isBot(event.Comment.User):
+		return ReplyIgnored, "comment by a bot", nil
+	}
+	return s.runCommand(ctx, command{
+		Name:          name,
+		Args:          args,
+		Owner:         event.Repository.Owner.Login,
+		Repo:          event.Repository.Name,
+		PRNumber:      strconv.Itoa(event.PullRequest.Number),
+		User:          event.Comment.User,
+		CommentID:     event.Comment.ID,
+		ReviewComment: &event.Comment,
+		PullRequest:   &event.PullRequest,
+	})
+}
+
+// runCommand checks that the commenter may write to the repository, then
+// runs the command. The command's comment gets a reaction when the command
+// is accepted, denied, done or failed.
+func (s *PRService) runCommand(ctx context.Context, cmd command) (outcome, reason string, err error) {
+	github := s.githubFor(cmd.Owner)
+	react := func(content string) {
+		if err := github.AddReaction(ctx, cmd.Owner, cmd.Repo, cmd.CommentID, cmd.ReviewComment != nil, content); err != nil {
+			fmt.Printf("failed to react to command %s: %v\n", cmd.Name, err)
+		}
+	}
+
+	permission, err := github.FetchPermission(ctx, cmd.Owner, cmd.Repo, cmd.User.Login)
+	if err != nil {
+		return "", "", err
+	}
+	if permission != "admin" && permission != "write" {
+		react(reactionDenied)
+		return ReplyIgnored, fmt.Sprintf("%s has no write access", cmd.User.Login), nil
+	}
+	if problem := cmd.problem(); problem != "" {
+		react(reactionFailed)
+		return ReplyIgnored, problem, nil
+	}
+	react(reactionAccepted)
+
+	prRequestBody := models.PullRequestRequest{Provider: models.ProviderGitHub, OwnerID: cmd.Owner, RepoID: cmd.Repo, ID: cmd.PRNumber}
+	switch cmd.Name {
+	case commandReview:
+		_, err = s.AnalyzePR(ctx, prRequestBody, AnalyzeOptions{DryRun: s.cfg.DryRun, Files: cmd.Args})
+	case commandSummary:
+		err = s.RegenerateSummary(ctx, prRequestBody)
+	case commandIgnore:
+		err = s.ignoreFinding(ctx, cmd)
+	case commandExplain:
+		err = s.explainLine(ctx, cmd)
+	}
+	if err != nil {
+		react(reactionFailed)
+		return "", "", fmt.Errorf("command %s failed: %w", cmd.Name, err)
+	}
+	react(reactionDone)
+	return CommandRun, cmd.Name, nil
+}
+
+// problem explains why a command cannot run where it was posted, or
+// returns "" when it can.
+func (cmd command) problem() string {
+	switch cmd.Name {
+	case commandIgnore:
+		if cmd.ReviewComment == nil || cmd.ReviewComment.InReplyToID == 0 {
+			return "/ignore must reply to a review thread"
+		}
+	case commandExplain:
+		if cmd.ReviewComment == nil {
+			return "/explain must be a review comment on a line"
+		}
+	case commandSummary:
+		if len(cmd.Args) > 0 {
+			return "/summary takes no arguments"
+		}
+	}
+	return ""
+}
+
+// ignoreFinding suppresses the finding a thread of the bot is about, so
+// later reviews of the pull request do not report it again.
+func (s *PRService) ignoreFinding(ctx context.Context, cmd command) error {
+	github := s.githubFor(cmd.Owner)
+	comments, err := github.FetchReviewComments(ctx, cmd.Owner, cmd.Repo, cmd.PRNumber)
+	if err != nil {
+		return err
+	}
+	thread := reviewThread(comments, *cmd.ReviewComment)
+	root := thread[0]
+	// another bot's thread is not ours to suppress
+	if root.ID != cmd.ReviewComment.InReplyToID || s.cfg.BotLogin == "" || !strings.EqualFold(root.User.Login, s.cfg.BotLogin) {
+		return fmt.Errorf("thread %d was not started by the bot", cmd.ReviewComment.InReplyToID)
+	}
+
+	suppression := store.Suppression{
+		Provider: models.ProviderGitHub,
+		Owner:    cmd.Owner,
+		Repo:     cmd.Repo,
+		PRNumber: cmd.PRNumber,
+		File:     root.Path,
+		Code:     diff.LastHunkLine(root.DiffHunk),
+		By:       cmd.User.Login,
+	}
+	// the rule is known when the review that posted the comment was recorded
+	if _, comment, ok := s.store.FindComment(models.ProviderGitHub, cmd.Owner, cmd.Repo, strconv.FormatInt(root.ID, 10)); ok {
+		suppression.Rule = comment.Rule
+	}
+	return s.store.AddSuppression(suppression)
+}
+
+// explainLine answers /explain on a line: the model explains the code the
+// comment is on, or answers the question following the command, in the
+// comment's thread.
+func (s *PRService) explainLine(ctx context.Context, cmd command) error {
+	comment := cmd.ReviewComment
+	github := s.githubFor(cmd.Owner)
+	prRequestBody := models.PullRequestRequest{OwnerID: cmd.Owner, RepoID: cmd.Repo, ID: cmd.PRNumber}
+	repoCfg, _ := s.loadRepoConfig(ctx, prRequestBody, github, cmd.PullRequest)
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "A developer reviewing a pull request asks about line %d of %s, the last line of this diff hunk:\n", comment.Line, comment.Path)
+	fmt.Fprintf(&b, "```diff\n%s\n```\n\n", comment.DiffHunk)
+	if question := strings.Join(cmd.Args, " "); question != "" {
+		fmt.Fprintf(&b, "Their question: %s\n\n", question)
+		b.WriteString("Answer it")
+	} else {
+		b.WriteString("Explain what the line does and why it might have been changed")
+	}
+	b.WriteString(" in a few sentences of markdown without headings.")
+
+	reply, err := s.llmClient.Complete(ctx, b.String(), repoCfg.Model)
+	if err != nil {
+		return fmt.Errorf("failed to generate explanation: %w", err)
+	}
+	threadID := comment.ID
+	if comment.InReplyToID != 0 {
+		threadID = comment.InReplyToID
+	}
+	_, err = github.ReplyToReviewComment(ctx, cmd.Owner, cmd.Repo, cmd.PRNumber, threadID, strings.TrimSpace(reply))
+	return err
+}
@@ -0,0 +1,172 @@
+package services
+
+import (
+	"ai-api/fakegithub"
+	"ai-api/models"
+	"ai-api/store"
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseCommand(t *testing.T) {
+	tests := []struct {
+		body     string
+		wantName string
+		wantArgs []string
+		wantOK   bool
+	}{
+		{body: "/review", wantName: commandReview, wantArgs: []string{}, wantOK: true},
+		{body: "/review main.go store/cache.go", wantName: commandReview, wantArgs: []string{"main.go", "store/cache.go"}, wantOK: true},
+		{body: "\n  /IGNORE  \nthanks", wantName: commandIgnore, wantArgs: []string{}, wantOK: true},
+		{body: "/explain why a mutex?", wantName: commandExplain, wantArgs: []string{"why", "a", "mutex?"}, wantOK: true},
+		{body: "/summary", wantName: commandSummary, wantArgs: []string{}, wantOK: true},
+		{body: "/deploy now"},
+		{body: "please /review"},
+		{body: "looks good\n/review"},
+		{body: "  \n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.body, func(t *testing.T) {
+			name, args, ok := parseCommand(tt.body)
+			if name != tt.wantName || ok != tt.wantOK || (ok && !reflect.DeepEqual(args, tt.wantArgs)) {
+				t.Errorf("parseCommand(%q) = %q, %q, %v, want %q, %q, %v", tt.body, name, args, ok, tt.wantName, tt.wantArgs, tt.wantOK)
+			}
+		})
+	}
+}
+
+func TestHandleReviewCommentCommands(t *testing.T) {
+	developer := models.User{Login: "dev", Type: "User"}
+	tests := []struct {
+		name       string
+		body       string
+		permission string
+		// inThread replies to the bot's thread, or to otherBot's with
+		// otherBot set
+		inThread  bool
+		otherBot  bool
+		author    models.User
+		wantOK    bool
+		wantErr   string
+		wantWhy   string
+		wantReact []string
+		// wantIgnored is the number of suppressions added
+		wantIgnored int
+		wantReplies int
+	}{
+		{name: "ignore by a writer", body: "/ignore", permission: "write", inThread: true, author: developer, wantOK: true, wantReact: []string{"eyes", "rocket"}, wantIgnored: 1},
+		{name: "ignore by an admin", body: "/ignore false positive", permission: "admin", inThread: true, author: developer, wantOK: true, wantReact: []string{"eyes", "rocket"}, wantIgnored: 1},
+		{name: "ignore by a reader", body: "/ignore", permission: "read", inThread: true, author: developer, wantWhy: "dev has no write access", wantReact: []string{"-1"}},
+		{name: "ignore without access", body: "/ignore", inThread: true, author: developer, wantWhy: "dev has no write access", wantReact: []string{"-1"}},
+		{name: "ignore outside a thread", body: "/ignore", permission: "write", author: developer, wantWhy: "/ignore must reply to a review thread", wantReact: []string{"confused"}},
+		{name: "ignore in another bot's thread", body: "/ignore", permission: "write", inThread: true, otherBot: true, author: developer, wantErr: "was not started by the bot", wantReact: []string{"eyes", "confused"}},
+		{name: "explain", body: "/explain why?", permission: "write", inThread: true, author: developer, wantOK: true, wantReact: []string{"eyes", "rocket"}, wantReplies: 1},
+		{name: "command by a bot", body: "/ignore", permission: "write", inThread: true, author: models.User{Login: "dependabot[bot]", Type: "Bot"}, wantWhy: "comment by a bot"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, gh, pr, root := newConversationTest(t, &stubLLM{reply: "It closes the file."}, 0)
+			reviewStore, err := store.Open("")
+			if err != nil {
+				t.Fatal(err)
+			}
+			s.store = reviewStore
+			if tt.permission != "" {
+				gh.SetPermission("acme", "api", tt.author.Login, tt.permission)
+			}
+			if tt.otherBot {
+				other, err := gh.AddReviewComment("acme", "api", pr.Number, models.ReviewComment{Body: "bump", User: models.User{Login: "dependabot[bot]", Type: "Bot"}, Path: "go.mod"})
+				if err != nil {
+					t.Fatal(err)
+				}
+				root = other.ID
+			}
+			comment := models.ReviewComment{Body: tt.body, User: tt.author, Path: "main.go", Line: 3, DiffHunk: "@@ -1,2 +1,3 @@\n+\tf.Close()"}
+			if tt.inThread {
+				comment.InReplyToID = root
+			}
+			comment, err = gh.AddReviewComment("acme", "api", pr.Number, comment)
+			if err != nil {
+				t.Fatal(err)
+			}
+			before := len(gh.ReviewComments("acme", "api", pr.Number))
+			event := models.ReviewCommentEvent{Action: "created", Comment: comment, PullRequest: pr}
+			event.Repository.Name = "api"
+			event.Repository.Owner.Login = "acme"
+
+			outcome, why, err := s.HandleReviewComment(context.Background(), event)
+			switch {
+			case tt.wantErr != "":
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Errorf("HandleReviewComment() error = %v, want %q", err, tt.wantErr)
+				}
+			case err != nil:
+				t.Fatalf("HandleReviewComment() error = %v", err)
+			case tt.wantOK && outcome != CommandRun:
+				t.Errorf("HandleReviewComment() = %s (%s), want %s", outcome, why, CommandRun)
+			case !tt.wantOK && (outcome != ReplyIgnored || why != tt.wantWhy):
+				t.Errorf("HandleReviewComment() = %s (%s), want ignored (%s)", outcome, why, tt.wantWhy)
+			}
+			if got := gh.Reactions("acme", "api", comment.ID); !reflect.DeepEqual(got, tt.wantReact) {
+				t.Errorf("reactions = %q, want %q", got, tt.wantReact)
+			}
+			suppressions := s.store.Suppressions(models.ProviderGitHub, "acme", "api", "1")
+			if len(suppressions) != tt.wantIgnored {
+				t.Fatalf("suppressions = %+v, want %d", suppressions, tt.wantIgnored)
+			}
+			if tt.wantIgnored > 0 && (suppressions[0].File != "main.go" || suppressions[0].By != "dev") {
+				t.Errorf("suppression = %+v, want main.go by dev", suppressions[0])
+			}
+			if replies := len(gh.ReviewComments("acme", "api", pr.Number)) - before; replies != tt.wantReplies {
+				t.Errorf("%d replies posted, want %d", replies, tt.wantReplies)
+			}
+		})
+	}
+}
+
+func TestHandleIssueComment(t *testing.T) {
+	tests := []struct {
+		name       string
+		body       string
+		author     models.User
+		permission string
+		wantWhy    string
+		wantReact  []string
+	}{
+		{name: "no command", body: "looks good", author: models.User{Login: "dev"}, permission: "write", wantWhy: "no command"},
+		{name: "comment by the bot", body: "/summary", author: models.User{Login: fakegithub.DefaultLogin, Type: "Bot"}, permission: "write", wantWhy: "comment by a bot"},
+		{name: "refused", body: "/review", author: models.User{Login: "dev"}, permission: "read", wantWhy: "dev has no write access", wantReact: []string{"-1"}},
+		{name: "ignore on the conversation", body: "/ignore", author: models.User{Login: "dev"}, permission: "write", wantWhy: "/ignore must reply to a review thread", wantReact: []string{"confused"}},
+		{name: "summary with arguments", body: "/summary please", author: models.User{Login: "dev"}, permission: "admin", wantWhy: "/summary takes no arguments", wantReact: []string{"confused"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, gh, pr, _ := newConversationTest(t, &stubLLM{}, 0)
+			gh.SetPermission("acme", "api", tt.author.Login, tt.permission)
+			comment, err := gh.AddIssueComment("acme", "api", pr.Number, models.IssueComment{Body: tt.body, User: tt.author})
+			if err != nil {
+				t.Fatal(err)
+			}
+			event := models.IssueCommentEvent{Action: "created", Comment: comment}
+			event.Issue.Number = pr.Number
+			event.Issue.PullRequest = &struct {
+				URL string `json:"url"`
+			}{}
+			event.Repository.Name = "api"
+			event.Repository.Owner.Login = "acme"
+
+			outcome, why, err := s.HandleIssueComment(context.Background(), event)
+			if err != nil {
+				t.Fatalf("HandleIssueComment() error = %v", err)
+			}
+			if outcome != ReplyIgnored || why != tt.wantWhy {
+				t.Errorf("HandleIssueComment() = %s (%s), want ignored (%s)", outcome, why, tt.wantWhy)
+			}
+			if got := gh.Reactions("acme", "api", comment.ID); !reflect.DeepEqual(got, tt.wantReact) {
+				t.Errorf("reactions = %q, want %q", got, tt.wantReact)
+			}
+		})
+	}
+}
@@ -0,0 +1,95 @@
+package services
+
+import (
+	"ai-api/codecontext"
+	"ai-api/diff"
+	"ai-api/models"
+	"context"
+	"fmt"
+	"path"
+	"strings"
+)
+
+// defaultContextTokens is the token budget of surrounding code per file when
+// none is configured.
+const defaultContextTokens = 2000
+
+// ContentsFetcher reads a file of a repository at a commit.
+type ContentsFetcher interface {
+	FetchFileContents(ctx context.Context, owner, repo, path, ref string) ([]byte, error)
+}
+
+// maxPackageFiles caps the other files of a package read to look up the
+// types a changed Go file uses.
+const maxPackageFiles = 10
+
+// DirectoryLister lists the files of a repository directory at a commit. A
+// ContentsFetcher that implements it lets the context of a Go file include
+// the types declared in the other files of its package.
+type DirectoryLister interface {
+	ListDirectory(ctx context.Context, owner, repo, dir, ref string) ([]string, error)
+}
+
+// fileContext returns the surrounding code of a changed Go file — the
+// functions enclosing the changes, the types of its package they use and
+// the imports — read at the head commit. Any failure leaves the review
+// without context.
+func (s *PRService) fileContext(ctx context.Context, scope ReviewScope, file models.ChangeFile, language, ref string) string {
+	budget := s.cfg.ContextTokens
+	if budget == 0 {
+		budget = defaultContextTokens
+	}
+	if language != "go" || budget < 0 || scope.Contents == nil || ref == "" || file.Status == "removed" {
+		return ""
+	}
+
+	src, err := scope.Contents.FetchFileContents(ctx, scope.RepoOwner, scope.RepoName, file.Filename, ref)
+	if err != nil {
+		fmt.Printf("failed to fetch %s for context: %v\n", file.Filename, err)
+		return ""
+	}
+	pkgFiles := s.packageFiles(ctx, scope, file.Filename, ref)
+	text, err := codecontext.Go(file.Filename, src, diff.Hunks(file.Patch), budget, pkgFiles)
+	if err != nil {
+		fmt.Printf("failed to build context for %s: %v\n", file.Filename, err)
+		return ""
+	}
+	return text
+}
+
+// packageFiles reads the other Go files in the directory of filename, test
+// files only when filename is one, up to maxPackageFiles. It returns nil
+// when the contents fetcher cannot list directories; files that fail to
+// read are left out.
+func (s *PRService) packageFiles(ctx context.Context, scope ReviewScope, filename, ref string) map[string][]byte {
+	lister, ok := scope.Contents.(DirectoryLister)
+	if !ok {
+		return nil
+	}
+	dir := path.Dir(filename)
+	if dir == "." {
+		dir = ""
+	}
+	paths, err := lister.ListDirectory(ctx, scope.RepoOwner, scope.RepoName, dir, ref)
+	if err != nil {
+		fmt.Printf("failed to list %s for context: %v\n", dir, err)
+		return nil
+	}
+	isTest := strings.HasSuffix(filename, "_test.go")
+	files := map[string][]byte{}
+	for _, p := range paths {
+		if len(files) == maxPackageFiles {
+			break
+		}
+		if p == filename || !strings.HasSuffix(p, ".go") || (strings.HasSuffix(p, "_test.go") && !isTest) {
+			continue
+		}
+		src, err := scope.Contents.FetchFileContents(ctx, scope.RepoOwner, scope.RepoName, p, ref)
+		if err != nil {
+			fmt.Printf("failed to fetch %s for context: %v\n", p, err)
+			continue
+		}
+		files[p] = src
+	}
+	return files
+}
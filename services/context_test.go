@@ -0,0 +1,68 @@
+package services
+
+import (
+	"ai-api/config"
+	"ai-api/fakegithub"
+	"ai-api/models"
+	"context"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+// onlyContents hides the directory listing of a contents fetcher.
+type onlyContents struct {
+	ContentsFetcher
+}
+
+func TestFileContext(t *testing.T) {
+	const ref = "1111111111111111111111111111111111111111"
+	gh := fakegithub.New()
+	defer gh.Close()
+	gh.SetFile("acme", "api", ref, "store/cache.go", []byte("package store\n\nfunc Get(c *Cache, key string) Entry {\n\treturn c.entries[key]\n}\n"))
+	gh.SetFile("acme", "api", ref, "store/types.go", []byte("package store\n\n// Cache keeps entries.\ntype Cache struct {\n\tentries map[string]Entry\n}\n"))
+	gh.SetFile("acme", "api", ref, "store/entry.go", []byte("package store\n\n// Entry is a cached value.\ntype Entry string\n"))
+	gh.SetFile("acme", "api", ref, "store/cache_test.go", []byte("package store\n\ntype Entry int\n"))
+	gh.SetFile("acme", "api", ref, "store/disk/disk.go", []byte("package disk\n\ntype Cache int\n"))
+	file := models.ChangeFile{Filename: "store/cache.go", Status: "modified", Patch: "@@ -3,2 +3,3 @@\n func Get(c *Cache, key string) Entry {\n+\treturn c.entries[key]\n }"}
+
+	s, err := newPRService(config.Config{}, http.DefaultClient, &stubLLM{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name     string
+		contents ContentsFetcher
+		want     []string
+		notWant  []string
+	}{
+		{
+			name:     "types of the package",
+			contents: gh.Client(),
+			want:     []string{"// func Get (lines 3-5)", "// type Cache (types.go)\n", "// type Entry (entry.go)\ntype Entry string"},
+			notWant:  []string{"type Entry int", "type Cache int"},
+		},
+		{
+			name:     "no directory listing",
+			contents: onlyContents{gh.Client()},
+			want:     []string{"// func Get (lines 3-5)"},
+			notWant:  []string{"// type"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			scope := ReviewScope{RepoOwner: "acme", RepoName: "api", Contents: tt.contents}
+			got := s.fileContext(context.Background(), scope, file, "go", ref)
+			for _, want := range tt.want {
+				if !strings.Contains(got, want) {
+					t.Errorf("context lacks %q:\n%s", want, got)
+				}
+			}
+			for _, notWant := range tt.notWant {
+				if strings.Contains(got, notWant) {
+					t.Errorf("context has %q:\n%s", notWant, got)
+				}
+			}
+		})
+	}
+}
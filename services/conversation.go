@@ -0,0 +1,144 @@
+package services
+
+import (
+	"ai-api/models"
+	"context"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// defaultMaxThreadReplies is how many replies the bot posts in one comment
+// thread when the config does not say otherwise.
+const defaultMaxThreadReplies = 3
+
+// Outcomes of handling a review comment event.
+const (
+	ReplyPosted  = "replied"
+	ReplyIgnored = "ignored"
+)
+
+// ReplyToReviewComment answers a developer's reply in a review thread the
+// bot started. The conversation so far and the diff hunk the thread is on
+// are sent to the model and its answer is posted in the thread.
+//
+// To avoid loops, comments by the bot (or any bot account) are never
+// answered, nor are threads where the bot already had the last word or
+// posted MaxThreadReplies replies. It returns ReplyPosted or ReplyIgnored
+// and why.
+func (s *PRService) ReplyToReviewComment(ctx context.Context, event models.ReviewCommentEvent) (outcome, reason string, err error) {
+	s = s.forEvent(event.Installation, event.Repository.Owner.Login)
+	if s.cfg.BotLogin == "" {
+		return "", "", fmt.Errorf("bot login is not configured")
+	}
+	comment := event.Comment
+	switch {
+	case event.Action != "created":
+		return ReplyIgnored, "action " + event.Action, nil
+	case s.isBot(comment.User):
+		return ReplyIgnored, "comment by a bot", nil
+	case comment.InReplyToID == 0:
+		return ReplyIgnored, "not a reply", nil
+	}
+
+	owner, repo := event.Repository.Owner.Login, event.Repository.Name
+	prNumber := strconv.Itoa(event.PullRequest.Number)
+	// the checks below read the thread as it is before the reply is
+	// posted; held until then so concurrent events see each other's reply
+	unlock := s.threads.Lock(fmt.Sprintf("%s/%s#%d", strings.ToLower(owner), strings.ToLower(repo), comment.InReplyToID))
+	defer unlock()
+	github := s.githubFor(owner)
+	comments, err := github.FetchReviewComments(ctx, owner, repo, prNumber)
+	if err != nil {
+		return "", "", err
+	}
+	thread := reviewThread(comments, comment)
+	if len(thread) == 0 || thread[0].ID != comment.InReplyToID {
+		return "", "", fmt.Errorf("first comment %d of the thread not found", comment.InReplyToID)
+	}
+	if !strings.EqualFold(thread[0].User.Login, s.cfg.BotLogin) {
+		return ReplyIgnored, "thread not started by the bot", nil
+	}
+	if s.isBot(thread[len(thread)-1].User) {
+		return ReplyIgnored, "already answered", nil
+	}
+	limit := s.cfg.MaxThreadReplies
+	if limit <= 0 {
+		limit = defaultMaxThreadReplies
+	}
+	replies := 0
+	for _, c := range thread[1:] {
+		if strings.EqualFold(c.User.Login, s.cfg.BotLogin) {
+			replies++
+		}
+	}
+	if replies >= limit {
+		return ReplyIgnored, "reply limit reached", nil
+	}
+
+	prRequestBody := models.PullRequestRequest{OwnerID: owner, RepoID: repo, ID: prNumber}
+	repoCfg, _ := s.loadRepoConfig(ctx, prRequestBody, github, &event.PullRequest)
+	reply, err := s.llmClient.Complete(ctx, s.conversationPrompt(thread, repoCfg.Instructions), repoCfg.Model)
+	if err != nil {
+		return "", "", fmt.Errorf("failed to generate reply: %w", err)
+	}
+	reply = strings.TrimSpace(reply)
+	if reply == "" {
+		return ReplyIgnored, "empty reply", nil
+	}
+	if _, err := github.ReplyToReviewComment(ctx, owner, repo, prNumber, thread[0].ID, reply); err != nil {
+		return "", "", err
+	}
+	return ReplyPosted, fmt.Sprintf("reply %d of %d", replies+1, limit), nil
+}
+
+// isBot reports whether a comment was written by the reviewer or another
+// bot account.
+func (s *PRService) isBot(user models.User) bool {
+	return strings.EqualFold(user.Login, s.cfg.BotLogin) || user.Type == "Bot"
+}
+
+// reviewThread returns the comments of the thread latest belongs to, first
+// comment first. latest is added when the listing does not include it yet.
+func reviewThread(comments []models.ReviewComment, latest models.ReviewComment) []models.ReviewComment {
+	var thread []models.ReviewComment
+	seen := false
+	for _, c := range comments {
+		if c.ID == latest.InReplyToID || c.InReplyToID == latest.InReplyToID {
+			thread = append(thread, c)
+			seen = seen || c.ID == latest.ID
+		}
+	}
+	if !seen {
+		thread = append(thread, latest)
+	}
+	sort.SliceStable(thread, func(i, j int) bool {
+		if thread[i].ID == latest.InReplyToID || thread[j].ID == latest.InReplyToID {
+			return thread[i].ID == latest.InReplyToID
+		}
+		return thread[i].CreatedAt.Before(thread[j].CreatedAt)
+	})
+	return thread
+}
+
+// conversationPrompt asks the model to answer the last message of a thread.
+func (s *PRService) conversationPrompt(thread []models.ReviewComment, instructions string) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "You are an automated code reviewer. You left the first comment of the review thread below on %s, and a developer answered.\n", thread[0].Path)
+	b.WriteString("Reply to the latest message. If asked why, explain your reasoning with reference to the code. ")
+	b.WriteString("If the developer says the code is intentional or gives a good reason, acknowledge it instead of insisting. ")
+	b.WriteString("If you were wrong, say so. Keep the reply to a few sentences of markdown without headings, and do not repeat earlier messages.\n")
+	if instructions != "" {
+		fmt.Fprintf(&b, "\nRepository instructions:\n%s\n", instructions)
+	}
+	fmt.Fprintf(&b, "\nThe code the thread is on:\n```diff\n%s\n```\n\nConversation:\n", thread[0].DiffHunk)
+	for _, c := range thread {
+		author := "@" + c.User.Login
+		if strings.EqualFold(c.User.Login, s.cfg.BotLogin) {
+			author = "you"
+		}
+		fmt.Fprintf(&b, "\n[%s]\n%s\n", author, strings.TrimSpace(c.Body))
+	}
+	return b.String()
+}
@@ -0,0 +1,165 @@
+package services
+
+import (
+	"ai-api/clients"
+	"ai-api/config"
+	"ai-api/fakegithub"
+	"ai-api/models"
+	"ai-api/prompts"
+	"context"
+	"sync"
+	"testing"
+	"time"
+)
+
+// stubLLM answers every prompt with reply after delay and counts the calls.
+type stubLLM struct {
+	reply string
+	delay time.Duration
+
+	mu    sync.Mutex
+	calls int
+}
+
+func (l *stubLLM) GenerateReviewComment(ctx context.Context, req clients.ReviewRequest) (string, error) {
+	return l.Complete(ctx, req.CodeDiff, req.Model)
+}
+
+func (l *stubLLM) RelevantStyleChunks(ctx context.Context, code string, extra []string) ([]string, error) {
+	return nil, nil
+}
+
+func (l *stubLLM) Complete(ctx context.Context, prompt, model string) (string, error) {
+	time.Sleep(l.delay)
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.calls++
+	return l.reply, nil
+}
+
+// newConversationTest returns a service talking to a fake GitHub with a
+// pull request whose first review thread was started by the bot, and the
+// id of that first comment.
+func newConversationTest(t *testing.T, llm *stubLLM, maxReplies int) (*PRService, *fakegithub.Server, models.PullRequest, int64) {
+	t.Helper()
+	gh := fakegithub.New()
+	t.Cleanup(gh.Close)
+	promptSet, err := prompts.Load("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := &PRService{
+		githubClient: *gh.Client(),
+		llmClient:    llm,
+		cfg:          config.Config{BotLogin: gh.Login, MaxThreadReplies: maxReplies},
+		prompts:      promptSet,
+	}
+	pr := gh.AddPullRequest("acme", "api", models.PullRequest{})
+	root, err := gh.AddReviewComment("acme", "api", pr.Number, models.ReviewComment{
+		Body:      "this error is ignored",
+		User:      models.User{Login: gh.Login, Type: "Bot"},
+		Path:      "main.go",
+		CreatedAt: time.Now().Add(-time.Hour),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return s, gh, pr, root.ID
+}
+
+// reply adds a comment by user in the thread of root and returns its event.
+func reply(t *testing.T, gh *fakegithub.Server, pr models.PullRequest, root int64, user models.User, minutesAgo int) models.ReviewCommentEvent {
+	t.Helper()
+	comment, err := gh.AddReviewComment("acme", "api", pr.Number, models.ReviewComment{
+		InReplyToID: root,
+		Body:        "why?",
+		User:        user,
+		CreatedAt:   time.Now().Add(-time.Duration(minutesAgo) * time.Minute),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	event := models.ReviewCommentEvent{Action: "created", Comment: comment, PullRequest: pr}
+	event.Repository.Name = "api"
+	event.Repository.Owner.Login = "acme"
+	return event
+}
+
+func TestReplyToReviewComment(t *testing.T) {
+	developer := models.User{Login: "dev", Type: "User"}
+	bot := models.User{Login: fakegithub.DefaultLogin, Type: "Bot"}
+	otherBot := models.User{Login: "dependabot[bot]", Type: "Bot"}
+	tests := []struct {
+		name string
+		// thread holds the authors of the replies before the event's
+		thread     []models.User
+		author     models.User
+		maxReplies int
+		wantReason string
+		wantPosted bool
+	}{
+		{name: "developer reply", author: developer, wantPosted: true},
+		{name: "own comment", author: bot, wantReason: "comment by a bot"},
+		{name: "other bot", author: otherBot, wantReason: "comment by a bot"},
+		{name: "under the cap", thread: []models.User{developer, bot}, author: developer, maxReplies: 2, wantPosted: true},
+		{name: "cap reached", thread: []models.User{developer, bot, developer, bot}, author: developer, maxReplies: 2, wantReason: "reply limit reached"},
+		{name: "default cap", thread: []models.User{developer, bot, developer, bot, developer, bot}, author: developer, wantReason: "reply limit reached"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			llm := &stubLLM{reply: "It is returned by Close."}
+			s, gh, pr, root := newConversationTest(t, llm, tt.maxReplies)
+			for i, user := range tt.thread {
+				reply(t, gh, pr, root, user, 30-i)
+			}
+			before := len(gh.ReviewComments("acme", "api", pr.Number))
+
+			outcome, reason, err := s.ReplyToReviewComment(context.Background(), reply(t, gh, pr, root, tt.author, 0))
+			if err != nil {
+				t.Fatalf("ReplyToReviewComment() error = %v", err)
+			}
+			posted := len(gh.ReviewComments("acme", "api", pr.Number)) - before - 1
+			if tt.wantPosted {
+				if outcome != ReplyPosted || posted != 1 {
+					t.Errorf("ReplyToReviewComment() = %s (%s) with %d replies, want one reply", outcome, reason, posted)
+				}
+				return
+			}
+			if outcome != ReplyIgnored || reason != tt.wantReason || posted != 0 || llm.calls != 0 {
+				t.Errorf("ReplyToReviewComment() = %s (%s) with %d replies and %d model calls, want ignored (%s)", outcome, reason, posted, llm.calls, tt.wantReason)
+			}
+		})
+	}
+}
+
+func TestReplyToReviewCommentConcurrentEvents(t *testing.T) {
+	llm := &stubLLM{reply: "It is returned by Close.", delay: 50 * time.Millisecond}
+	s, gh, pr, root := newConversationTest(t, llm, 0)
+	developer := models.User{Login: "dev", Type: "User"}
+	events := []models.ReviewCommentEvent{
+		reply(t, gh, pr, root, developer, 2),
+		reply(t, gh, pr, root, developer, 1),
+	}
+
+	var wg sync.WaitGroup
+	for _, event := range events {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, _, err := s.ReplyToReviewComment(context.Background(), event); err != nil {
+				t.Errorf("ReplyToReviewComment() error = %v", err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	replies := 0
+	for _, c := range gh.ReviewComments("acme", "api", pr.Number) {
+		if c.ID != root && c.User.Login == gh.Login {
+			replies++
+		}
+	}
+	if replies != 1 {
+		t.Errorf("bot replied %d times to two messages delivered together, want once", replies)
+	}
+}
@@ -0,0 +1,343 @@
+package services
+
+import (
+	clients "ai-api/clients"
+	"ai-api/models"
+	"ai-api/store"
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Dimensions a feedback report can be grouped by.
+const (
+	FeedbackByRepo     = "repo"
+	FeedbackByCategory = "category"
+	FeedbackBySeverity = "severity"
+	FeedbackByPrompt   = "prompt"
+	FeedbackByModel    = "model"
+)
+
+// Periods a feedback report can be bucketed by.
+const (
+	PeriodDay   = "day"
+	PeriodWeek  = "week"
+	PeriodMonth = "month"
+)
+
+// FeedbackRow is the feedback on the comments posted in one period, for one
+// combination of the grouped dimensions.
+type FeedbackRow struct {
+	// Period is the first day of the period, e.g. "2026-10-12" for a week.
+	Period string            `json:"period"`
+	Group  map[string]string `json:"group,omitempty"`
+	// Posted counts the posted comments. Accepted counts those with more 👍
+	// than 👎 or a resolved thread, Rejected those with more 👎 than 👍.
+	Posted     int `json:"posted"`
+	Accepted   int `json:"accepted"`
+	Rejected   int `json:"rejected"`
+	Resolved   int `json:"resolved"`
+	ThumbsUp   int `json:"thumbs_up"`
+	ThumbsDown int `json:"thumbs_down"`
+	// AcceptanceRate is Accepted over the comments with a verdict (the
+	// precision of the bot as judged by developers), and FeedbackRate the
+	// share of posted comments with a verdict.
+	AcceptanceRate float64 `json:"acceptance_rate"`
+	FeedbackRate   float64 `json:"feedback_rate"`
+}
+
+// feedbackRateLimitPause is how long feedback is not fetched after a rate
+// limit answer that does not say when to retry.
+const feedbackRateLimitPause = time.Minute
+
+// RefreshFeedback fetches the current reactions and thread resolution state
+// of the recorded comments posted to GitHub, the only provider reporting
+// them, the tenants' included. Only pull requests reviewed within
+// FeedbackMaxAge or still open are fetched. Comments whose feedback cannot
+// be fetched (deleted comments, for instance) keep their last known
+// feedback. When GitHub rate limits the requests, the refresh stops and
+// resumes once the limit is lifted.
+func (s *PRService) RefreshFeedback(ctx context.Context) error {
+	for _, service := range append([]*PRService{s}, s.tenants...) {
+		if err := service.refreshFeedback(ctx); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// feedbackPullRequest is a pull request whose comments' feedback is
+// fetched: its reviews and when it was last reviewed.
+type feedbackPullRequest struct {
+	owner, repo, number string
+	reviews             []store.Review
+	lastReviewed        time.Time
+	closed              bool
+}
+
+func (s *PRService) refreshFeedback(ctx context.Context) error {
+	maxAge, err := s.cfg.FeedbackRefreshAge()
+	if err != nil {
+		return err
+	}
+	// one refresh at a time, so polls and report requests do not fetch
+	// the same comments twice
+	s.feedbackMu.Lock()
+	defer s.feedbackMu.Unlock()
+	now := time.Now()
+	if now.Before(s.feedbackPausedUntil) {
+		return nil
+	}
+
+	for _, pr := range feedbackPullRequests(s.store.Reviews()) {
+		err := s.refreshPullRequestFeedback(ctx, pr, now.Add(-maxAge))
+		var rateLimited *clients.RateLimitError
+		if errors.As(err, &rateLimited) {
+			pause := rateLimited.RetryAfter
+			if pause <= 0 {
+				pause = feedbackRateLimitPause
+			}
+			s.feedbackPausedUntil = now.Add(pause)
+			fmt.Printf("stopped refreshing feedback until %s: %v\n", s.feedbackPausedUntil.Format(time.RFC3339), err)
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// feedbackPullRequests groups the reviews posted to GitHub by pull request,
+// in the order they were first reviewed.
+func feedbackPullRequests(reviews []store.Review) []*feedbackPullRequest {
+	var prs []*feedbackPullRequest
+	byKey := map[string]*feedbackPullRequest{}
+	for _, review := range reviews {
+		if review.Provider != models.ProviderGitHub || review.DryRun {
+			continue
+		}
+		key := review.Owner + "/" + review.Repo + "#" + review.PRNumber
+		pr := byKey[key]
+		if pr == nil {
+			pr = &feedbackPullRequest{owner: review.Owner, repo: review.Repo, number: review.PRNumber}
+			byKey[key] = pr
+			prs = append(prs, pr)
+		}
+		pr.reviews = append(pr.reviews, review)
+		if review.CreatedAt.After(pr.lastReviewed) {
+			pr.lastReviewed = review.CreatedAt
+		}
+		pr.closed = pr.closed || review.Closed
+	}
+	return prs
+}
+
+// refreshPullRequestFeedback fetches the feedback on the comments of a pull
+// request last reviewed after cutoff, or still open, and records it with
+// one store write per review. Rate limit errors are returned after
+// recording what was fetched; other failures are logged and skipped.
+func (s *PRService) refreshPullRequestFeedback(ctx context.Context, pr *feedbackPullRequest, cutoff time.Time) error {
+	github := s.githubFor(pr.owner)
+	if pr.lastReviewed.Before(cutoff) {
+		if pr.closed {
+			return nil
+		}
+		current, err := github.FetchPullRequest(ctx, models.PullRequestRequest{OwnerID: pr.owner, RepoID: pr.repo, ID: pr.number})
+		if err != nil {
+			if isRateLimited(err) {
+				return err
+			}
+			fmt.Printf("failed to fetch %s/%s#%s: %v\n", pr.owner, pr.repo, pr.number, err)
+			return nil
+		}
+		if current.State == "closed" {
+			return s.store.MarkClosed(models.ProviderGitHub, pr.owner, pr.repo, pr.number)
+		}
+	}
+
+	var resolutions map[string]bool
+	if number, err := strconv.Atoi(pr.number); err == nil {
+		resolutions, err = github.FetchThreadResolutions(ctx, pr.owner, pr.repo, number)
+		if isRateLimited(err) {
+			return err
+		}
+		if err != nil {
+			fmt.Printf("failed to fetch review threads of %s/%s#%s: %v\n", pr.owner, pr.repo, pr.number, err)
+		}
+	}
+	for _, review := range pr.reviews {
+		feedback := map[string]store.Feedback{}
+		var fetchErr error
+		for _, comment := range review.Comments {
+			if comment.CommentID == "" {
+				continue
+			}
+			reactions, err := github.FetchCommentReactions(ctx, pr.owner, pr.repo, comment.CommentID)
+			if isRateLimited(err) {
+				fetchErr = err
+				break
+			}
+			if err != nil {
+				fmt.Printf("failed to fetch reactions of comment %s: %v\n", comment.CommentID, err)
+				continue
+			}
+			resolved, ok := resolutions[comment.CommentID]
+			if !ok {
+				resolved = comment.Resolved
+			}
+			feedback[comment.CommentID] = store.Feedback{Reactions: reactions, Resolved: resolved}
+		}
+		if len(feedback) > 0 {
+			if err := s.store.SetFeedback(review.ID, feedback); err != nil {
+				return err
+			}
+		}
+		if fetchErr != nil {
+			return fetchErr
+		}
+	}
+	return nil
+}
+
+// isRateLimited reports whether err is GitHub refusing a request for the
+// rate limit.
+func isRateLimited(err error) bool {
+	var rateLimited *clients.RateLimitError
+	return errors.As(err, &rateLimited)
+}
+
+// PollFeedback refreshes the feedback every interval until ctx is done.
+func (s *PRService) PollFeedback(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := s.RefreshFeedback(ctx); err != nil {
+				fmt.Printf("failed to refresh feedback: %v\n", err)
+			}
+		}
+	}
+}
+
+// FeedbackReport summarizes the feedback on the comments posted since the
+// given time (all of them when zero), per period and per combination of the
+// groupBy dimensions. Rows are ordered by period, then group.
+func (s *PRService) FeedbackReport(groupBy []string, period string, since time.Time) ([]FeedbackRow, error) {
+	for _, dimension := range groupBy {
+		switch dimension {
+		case FeedbackByRepo, FeedbackByCategory, FeedbackBySeverity, FeedbackByPrompt, FeedbackByModel:
+		default:
+			return nil, fmt.Errorf("unknown group_by dimension %q", dimension)
+		}
+	}
+	if period == "" {
+		period = PeriodWeek
+	}
+	if period != PeriodDay && period != PeriodWeek && period != PeriodMonth {
+		return nil, fmt.Errorf("unknown period %q", period)
+	}
+
+	rows := map[string]*FeedbackRow{}
+	for _, review := range s.store.Reviews() {
+		if review.DryRun || review.CreatedAt.Before(since) {
+			continue
+		}
+		start := periodStart(review.CreatedAt, period)
+		for _, comment := range review.Comments {
+			if comment.CommentID == "" {
+				continue
+			}
+			group := feedbackGroup(review, comment, groupBy)
+			key := start + "|" + groupKey(group, groupBy)
+			row := rows[key]
+			if row == nil {
+				row = &FeedbackRow{Period: start, Group: group}
+				rows[key] = row
+			}
+
+			up, down := comment.Reactions["+1"], comment.Reactions["-1"]
+			row.Posted++
+			row.ThumbsUp += up
+			row.ThumbsDown += down
+			if comment.Resolved {
+				row.Resolved++
+			}
+			switch {
+			case down > up:
+				row.Rejected++
+			case up > down || comment.Resolved:
+				row.Accepted++
+			}
+		}
+	}
+
+	report := make([]FeedbackRow, 0, len(rows))
+	for _, row := range rows {
+		if verdicts := row.Accepted + row.Rejected; verdicts > 0 {
+			row.AcceptanceRate = float64(row.Accepted) / float64(verdicts)
+			row.FeedbackRate = float64(verdicts) / float64(row.Posted)
+		}
+		report = append(report, *row)
+	}
+	sort.Slice(report, func(i, j int) bool {
+		if report[i].Period != report[j].Period {
+			return report[i].Period < report[j].Period
+		}
+		return groupKey(report[i].Group, groupBy) < groupKey(report[j].Group, groupBy)
+	})
+	return report, nil
+}
+
+// feedbackGroup returns the values of the groupBy dimensions for a comment.
+func feedbackGroup(review store.Review, comment store.Comment, groupBy []string) map[string]string {
+	if len(groupBy) == 0 {
+		return nil
+	}
+	group := map[string]string{}
+	for _, dimension := range groupBy {
+		switch dimension {
+		case FeedbackByRepo:
+			group[dimension] = review.Owner + "/" + review.Repo
+		case FeedbackByCategory:
+			group[dimension] = comment.Category
+		case FeedbackBySeverity:
+			group[dimension] = string(comment.Severity)
+		case FeedbackByPrompt:
+			group[dimension] = comment.Prompt
+		case FeedbackByModel:
+			group[dimension] = review.Model
+		}
+	}
+	return group
+}
+
+// groupKey joins the values of a group in groupBy order.
+func groupKey(group map[string]string, groupBy []string) string {
+	values := make([]string, len(groupBy))
+	for i, dimension := range groupBy {
+		values[i] = group[dimension]
+	}
+	return strings.Join(values, "|")
+}
+
+// periodStart returns the first day (UTC) of the period t falls in. Weeks
+// start on Monday.
+func periodStart(t time.Time, period string) string {
+	t = t.UTC()
+	day := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+	switch period {
+	case PeriodWeek:
+		day = day.AddDate(0, 0, -((int(day.Weekday()) + 6) % 7))
+	case PeriodMonth:
+		day = day.AddDate(0, 0, 1-day.Day())
+	}
+	return day.Format("2006-01-02")
+}
@@ -0,0 +1,139 @@
+package services
+
+import (
+	"ai-api/config"
+	"ai-api/fakegithub"
+	"ai-api/models"
+	"ai-api/store"
+	"context"
+	"strconv"
+	"testing"
+	"time"
+)
+
+// newFeedbackTest returns a service talking to a fake GitHub, with a
+// review of a pull request in state created age ago whose two posted
+// comments got a 👍 and a resolved thread.
+func newFeedbackTest(t *testing.T, state string, age time.Duration) (*PRService, *fakegithub.Server, store.Review) {
+	t.Helper()
+	gh := fakegithub.New()
+	t.Cleanup(gh.Close)
+	reviewStore, err := store.Open("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := &PRService{githubClient: *gh.Client(), store: reviewStore}
+
+	pr := gh.AddPullRequest("acme", "api", models.PullRequest{State: state})
+	review := store.Review{
+		Provider:  models.ProviderGitHub,
+		Owner:     "acme",
+		Repo:      "api",
+		PRNumber:  strconv.Itoa(pr.Number),
+		CreatedAt: time.Now().Add(-age),
+	}
+	for i := 0; i < 2; i++ {
+		comment, err := gh.AddReviewComment("acme", "api", pr.Number, models.ReviewComment{Body: "finding", User: models.User{Login: gh.Login, Type: "Bot"}})
+		if err != nil {
+			t.Fatal(err)
+		}
+		review.Comments = append(review.Comments, store.Comment{CommentID: strconv.FormatInt(comment.ID, 10)})
+	}
+	first, _ := strconv.ParseInt(review.Comments[0].CommentID, 10, 64)
+	gh.React("acme", "api", first, "dev", "+1")
+	if err := gh.ResolveThread("acme", "api", pr.Number, first, true); err != nil {
+		t.Fatal(err)
+	}
+	if review, err = reviewStore.AddReview(review); err != nil {
+		t.Fatal(err)
+	}
+	return s, gh, review
+}
+
+func TestRefreshFeedback(t *testing.T) {
+	tests := []struct {
+		name        string
+		state       string
+		age         time.Duration
+		wantFetched bool
+		wantClosed  bool
+	}{
+		{"recent open", "open", time.Hour, true, false},
+		{"recent closed", "closed", time.Hour, true, false},
+		{"old open", "open", 30 * 24 * time.Hour, true, false},
+		{"old closed", "closed", 30 * 24 * time.Hour, false, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, gh, _ := newFeedbackTest(t, tt.state, tt.age)
+			if err := s.RefreshFeedback(context.Background()); err != nil {
+				t.Fatalf("RefreshFeedback() error = %v", err)
+			}
+			got := s.store.Reviews()[0]
+			if got.Closed != tt.wantClosed {
+				t.Errorf("Closed = %v, want %v", got.Closed, tt.wantClosed)
+			}
+			fetched := got.Comments[0].Reactions["+1"] == 1 && got.Comments[0].Resolved && !got.Comments[1].Resolved
+			if fetched != tt.wantFetched {
+				t.Errorf("feedback fetched = %v, want %v: %+v", fetched, tt.wantFetched, got.Comments)
+			}
+
+			// closed pull requests are not looked up again
+			before := len(gh.Requests())
+			if err := s.RefreshFeedback(context.Background()); err != nil {
+				t.Fatalf("RefreshFeedback() error = %v", err)
+			}
+			if tt.wantClosed && len(gh.Requests()) != before {
+				t.Errorf("second refresh made %d requests for a closed pull request", len(gh.Requests())-before)
+			}
+		})
+	}
+}
+
+func TestRefreshFeedbackRateLimited(t *testing.T) {
+	tests := []struct {
+		name     string
+		response fakegithub.Response
+	}{
+		{"primary", fakegithub.RateLimitExceeded()},
+		{"secondary", fakegithub.SecondaryRateLimit()},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, gh, review := newFeedbackTest(t, "open", time.Hour)
+			gh.Fail("GET", "/repos/*/*/pulls/comments/*/reactions", 0, tt.response)
+
+			if err := s.RefreshFeedback(context.Background()); err != nil {
+				t.Fatalf("RefreshFeedback() error = %v", err)
+			}
+			if n := gh.RequestCount("GET", "/repos/*/*/pulls/comments/*/reactions"); n != 1 {
+				t.Errorf("fetched reactions %d times, want the refresh to stop at the first rate limit", n)
+			}
+
+			// paused until the limit is lifted
+			gh.ClearFailures()
+			before := len(gh.Requests())
+			if err := s.RefreshFeedback(context.Background()); err != nil {
+				t.Fatalf("RefreshFeedback() error = %v", err)
+			}
+			if len(gh.Requests()) != before {
+				t.Errorf("refresh during the pause made %d requests", len(gh.Requests())-before)
+			}
+
+			s.feedbackPausedUntil = time.Time{}
+			if err := s.RefreshFeedback(context.Background()); err != nil {
+				t.Fatalf("RefreshFeedback() error = %v", err)
+			}
+			if got := s.store.Reviews()[0].Comments[0]; got.Reactions["+1"] != 1 {
+				t.Errorf("feedback of review %s after the pause = %+v", review.ID, got)
+			}
+		})
+	}
+}
+
+func TestFeedbackRefreshAge(t *testing.T) {
+	s := &PRService{cfg: config.Config{FeedbackMaxAge: "soon"}}
+	if err := s.refreshFeedback(context.Background()); err == nil {
+		t.Error("refreshFeedback() with an invalid feedback_max_age succeeded")
+	}
+}
@@ -0,0 +1,92 @@
+package services
+
+import (
+	"ai-api/diff"
+	"ai-api/models"
+	"ai-api/repoconfig"
+	"ai-api/store"
+	"strings"
+)
+
+// filterReviewableFiles keeps only the files worth reviewing: removed files,
+// pure renames and generated files are skipped, as are files not selected by
+// the include/exclude globs and enabled languages of repoCfg (by default,
+// .go files). Every skipped file is recorded in Skipped with the reason.
+func filterReviewableFiles(changeFiles *models.ChangeFiles, repoCfg repoconfig.Config) *models.ChangeFiles {
+	var result = models.ChangeFiles{
+		Files:   []models.ChangeFile{},
+		Skipped: []models.SkippedFile{},
+	}
+	for _, file := range changeFiles.Files {
+		if reason := skipReason(file, repoCfg); reason != "" {
+			result.Skipped = append(result.Skipped, models.SkippedFile{Filename: file.Filename, Reason: reason})
+			continue
+		}
+		result.Files = append(result.Files, file)
+	}
+	return &result
+}
+
+// skipReason returns why a file is left out of the review, or "" to review it.
+func skipReason(file models.ChangeFile, repoCfg repoconfig.Config) string {
+	switch {
+	case file.Status == "removed":
+		return "file removed"
+	case file.Status == "renamed" && file.Changes == 0:
+		return "renamed without changes"
+	case file.Patch == "":
+		return "no patch (binary or too large)"
+	}
+	if reason := repoCfg.SkipReason(file.Filename); reason != "" {
+		return reason
+	}
+	if diff.IsGenerated(file.Patch) {
+		return "generated file"
+	}
+	return ""
+}
+
+// keepRequestedFiles narrows a review to the files named in only, recording
+// the other files as skipped.
+func keepRequestedFiles(changeFiles *models.ChangeFiles, only []string) {
+	requested := map[string]bool{}
+	for _, file := range only {
+		requested[strings.TrimPrefix(file, "./")] = true
+	}
+	kept := []models.ChangeFile{}
+	for _, file := range changeFiles.Files {
+		if requested[file.Filename] {
+			kept = append(kept, file)
+			continue
+		}
+		changeFiles.Skipped = append(changeFiles.Skipped, models.SkippedFile{Filename: file.Filename, Reason: "not requested"})
+	}
+	changeFiles.Files = kept
+}
+
+// dropSuppressed removes the findings developers suppressed on the pull
+// request, see store.Suppression.
+func dropSuppressed(reviews []models.GeneratePRCommentParams, changeFiles *models.ChangeFiles, suppressions []store.Suppression) []models.GeneratePRCommentParams {
+	if len(suppressions) == 0 {
+		return reviews
+	}
+	patches := map[string]string{}
+	for _, file := range changeFiles.Files {
+		patches[file.Filename] = file.Patch
+	}
+	kept := []models.GeneratePRCommentParams{}
+	for _, review := range reviews {
+		code := diff.CodeAtPosition(patches[review.FileName], review.Position)
+		suppressed := false
+		for _, suppression := range suppressions {
+			if suppression.Matches(review.FileName, review.Rule, code) {
+				suppressed = true
+				break
+			}
+		}
+		if !suppressed {
+			kept = append(kept, review)
+		}
+	}
+	return kept
+}
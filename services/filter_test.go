@@ -0,0 +1,47 @@
+package services
+
+import (
+	"ai-api/models"
+	"ai-api/repoconfig"
+	"testing"
+)
+
+func TestFilterReviewableFiles(t *testing.T) {
+	repoCfg := repoconfig.Config{Languages: []string{"go"}, Exclude: []string{"vendor/"}}
+	patch := "@@ -1 +1,2 @@\n package store\n+var x = 1"
+	tests := []struct {
+		name string
+		file models.ChangeFile
+		want string
+	}{
+		{"reviewed", models.ChangeFile{Filename: "store/cache.go", Status: "modified", Changes: 1, Patch: patch}, ""},
+		{"removed", models.ChangeFile{Filename: "store/old.go", Status: "removed", Changes: 3, Patch: patch}, "file removed"},
+		{"pure rename", models.ChangeFile{Filename: "store/new.go", Status: "renamed"}, "renamed without changes"},
+		{"renamed and edited", models.ChangeFile{Filename: "store/new.go", Status: "renamed", Changes: 1, Patch: patch}, ""},
+		{"binary", models.ChangeFile{Filename: "store/cache.go", Status: "modified", Changes: 1}, "no patch (binary or too large)"},
+		{"excluded", models.ChangeFile{Filename: "vendor/x/x.go", Status: "modified", Changes: 1, Patch: patch}, "matched by exclude paths"},
+		{"other language", models.ChangeFile{Filename: "web/app.ts", Status: "added", Changes: 1, Patch: patch}, "language typescript is not enabled"},
+		{
+			"generated",
+			models.ChangeFile{Filename: "api/api.pb.go", Status: "added", Changes: 2, Patch: "@@ -0,0 +1,2 @@\n+// Code generated by protoc-gen-go. DO NOT EDIT.\n+package api"},
+			"generated file",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := filterReviewableFiles(&models.ChangeFiles{Files: []models.ChangeFile{tt.file}}, repoCfg)
+			if tt.want == "" {
+				if len(got.Files) != 1 || len(got.Skipped) != 0 {
+					t.Fatalf("file was skipped: %+v", got.Skipped)
+				}
+				return
+			}
+			if len(got.Files) != 0 || len(got.Skipped) != 1 {
+				t.Fatalf("file was reviewed, want it skipped for %q", tt.want)
+			}
+			if skipped := got.Skipped[0]; skipped.Filename != tt.file.Filename || skipped.Reason != tt.want {
+				t.Errorf("Skipped = %+v, want reason %q", skipped, tt.want)
+			}
+		})
+	}
+}
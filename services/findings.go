@@ -0,0 +1,322 @@
+package services
+
+import (
+	"ai-api/diff"
+	"ai-api/models"
+	"ai-api/prompts"
+	"ai-api/repoconfig"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// reviewFinding is a single issue reported by the model for one file.
+type reviewFinding struct {
+	Line     int
+	Severity models.Severity
+	Category string
+	Message  string
+	// Rule identifies the check that produced the finding, see reviewRule.
+	Rule string
+	// Tool names the static analyzer or external linter that reported the
+	// finding; empty for findings of the model.
+	Tool string
+	// Suggestion is the model's replacement for lines StartLine through
+	// EndLine, taken from a ```suggestion block of its answer.
+	StartLine, EndLine int
+	Suggestion         string
+}
+
+// parseFindings splits a model response laid out as prompts.ReviewOutputFormat
+// into findings. Responses that ignore the format become a single info
+// finding on the first changed line of the patch.
+func parseFindings(body, patch string) []reviewFinding {
+	body = strings.TrimSpace(body)
+	if body == "" || body == prompts.NoIssuesReply {
+		return nil
+	}
+
+	var (
+		findings   []reviewFinding
+		structured bool
+	)
+	for _, block := range splitFindingBlocks(body) {
+		finding := reviewFinding{Severity: models.SeverityInfo}
+		var message []string
+		inHeader := true
+		for _, line := range strings.Split(block, "\n") {
+			if inHeader && strings.TrimSpace(line) == "" {
+				continue
+			}
+			if inHeader {
+				if key, value, ok := findingHeader(line); ok {
+					structured = true
+					switch key {
+					case "line":
+						finding.Line, _ = strconv.Atoi(strings.Fields(value + " 0")[0])
+					case "lines":
+						finding.StartLine, finding.EndLine = parseLineRange(value)
+					case "severity":
+						if severity, err := models.ParseSeverity(value); err == nil {
+							finding.Severity = severity
+						}
+					case "category":
+						finding.Category = strings.ToLower(value)
+					}
+					continue
+				}
+				inHeader = false
+			}
+			message = append(message, line)
+		}
+		finding.Message, finding.Suggestion = splitSuggestion(message)
+		if finding.Message == "" {
+			continue
+		}
+		if finding.Line == 0 {
+			finding.Line = finding.StartLine
+		}
+		if finding.Line == 0 {
+			finding.Line = diff.FirstChangedLine(patch)
+		}
+		if finding.Suggestion != "" && finding.StartLine == 0 {
+			// a suggestion without a range replaces the commented line
+			finding.StartLine, finding.EndLine = finding.Line, finding.Line
+		}
+		finding.Rule = reviewRule(finding.Category)
+		findings = append(findings, finding)
+	}
+
+	if !structured {
+		return []reviewFinding{{
+			Line:     diff.FirstChangedLine(patch),
+			Severity: models.SeverityInfo,
+			Message:  body,
+			Rule:     reviewRule(""),
+		}}
+	}
+	return findings
+}
+
+// attachSuggestion adds the finding's suggested replacement to its comment.
+// A suggestion is only applicable when the lines it replaces lie on the new
+// side of a single hunk; the comment is then anchored to the last of them.
+// Otherwise the replacement is folded into the comment as prose.
+func attachSuggestion(comment *models.GeneratePRCommentParams, finding reviewFinding, patch string) {
+	if finding.Suggestion == "" {
+		return
+	}
+	fallback := *comment
+	fallback.StartLine, fallback.Line, fallback.Suggestion = finding.StartLine, finding.EndLine, finding.Suggestion
+	if !diff.InSingleHunk(patch, finding.StartLine, finding.EndLine) {
+		comment.CommentBody = fallback.ProseBody()
+		return
+	}
+	position := diff.PositionForLine(patch, finding.EndLine)
+	comment.Position = position
+	comment.Line = finding.EndLine
+	comment.LineType = diff.LineKindForPosition(patch, position)
+	comment.StartLine = finding.StartLine
+	comment.Suggestion = finding.Suggestion
+}
+
+// reviewRule is the rule id of a model finding: "review/" followed by its
+// category, e.g. "review/security".
+func reviewRule(category string) string {
+	slug := strings.Join(strings.FieldsFunc(strings.ToLower(category), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	}), "-")
+	if slug == "" {
+		slug = "general"
+	}
+	return "review/" + slug
+}
+
+// mergeFindings combines the findings of tools with those of the model.
+// Tools reporting the same problem on a line keep one finding, and a model
+// finding is dropped when a tool reported the same problem on its line: the
+// tool's is precise and the model was told about it. Other model findings
+// on those lines are kept.
+func mergeFindings(tool, model []reviewFinding) []reviewFinding {
+	var merged []reviewFinding
+	type key struct {
+		line          int
+		rule, message string
+	}
+	seen := map[key]bool{}
+	toolByLine := map[int][]reviewFinding{}
+	for _, finding := range tool {
+		k := key{finding.Line, finding.Rule, finding.Message}
+		if seen[k] {
+			continue
+		}
+		seen[k] = true
+		toolByLine[finding.Line] = append(toolByLine[finding.Line], finding)
+		merged = append(merged, finding)
+	}
+	for _, finding := range model {
+		duplicate := false
+		for _, reported := range toolByLine[finding.Line] {
+			if sameProblem(reported, finding) {
+				duplicate = true
+				break
+			}
+		}
+		if !duplicate {
+			merged = append(merged, finding)
+		}
+	}
+	return merged
+}
+
+// sameProblem reports whether a model finding restates a tool finding on
+// the same line: the model named the tool's rule or category, or described
+// the problem in mostly the same words.
+func sameProblem(tool, model reviewFinding) bool {
+	category := strings.ToLower(strings.TrimSpace(model.Category))
+	if category != "" && (category == strings.ToLower(tool.Category) || category == strings.ToLower(tool.Rule)) {
+		return true
+	}
+	if model.Rule == tool.Rule {
+		return true
+	}
+	return similarMessages(tool.Message, model.Message)
+}
+
+// similarMessages reports whether most of the words of the shorter message
+// appear in the other one. Words of fewer than three letters and the bold
+// "**tool rule**:" label of tool findings are ignored.
+func similarMessages(a, b string) bool {
+	wordsA, wordsB := messageWords(a), messageWords(b)
+	if len(wordsA) > len(wordsB) {
+		wordsA, wordsB = wordsB, wordsA
+	}
+	if len(wordsA) < 2 {
+		return false
+	}
+	shared := 0
+	for word := range wordsA {
+		if wordsB[word] {
+			shared++
+		}
+	}
+	return shared*10 >= len(wordsA)*6
+}
+
+// messageWords returns the lowercased words of a finding's message.
+func messageWords(message string) map[string]bool {
+	if rest, ok := strings.CutPrefix(message, "**"); ok {
+		if _, text, found := strings.Cut(rest, "**:"); found {
+			message = text
+		}
+	}
+	words := map[string]bool{}
+	for _, word := range strings.FieldsFunc(strings.ToLower(message), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	}) {
+		if len(word) >= 3 {
+			words[word] = true
+		}
+	}
+	return words
+}
+
+// parseLineRange parses "12-14", "12" or "12 to 14" into a line range.
+func parseLineRange(value string) (first, last int) {
+	fields := strings.FieldsFunc(value, func(r rune) bool { return !unicode.IsDigit(r) })
+	if len(fields) == 0 {
+		return 0, 0
+	}
+	first, _ = strconv.Atoi(fields[0])
+	last = first
+	if len(fields) > 1 {
+		last, _ = strconv.Atoi(fields[1])
+	}
+	if last < first {
+		return 0, 0
+	}
+	return first, last
+}
+
+// splitSuggestion separates the prose of a finding from the contents of its
+// ```suggestion block, if it has one. The suggestion keeps its indentation.
+func splitSuggestion(lines []string) (message, suggestion string) {
+	var prose, code []string
+	inBlock, found := false, false
+	for _, line := range lines {
+		trimmed := strings.TrimSpace(line)
+		switch {
+		case !inBlock && !found && trimmed == "```suggestion":
+			inBlock = true
+		case inBlock && trimmed == "```":
+			inBlock, found = false, true
+		case inBlock:
+			code = append(code, line)
+		default:
+			prose = append(prose, line)
+		}
+	}
+	message = strings.TrimSpace(strings.Join(prose, "\n"))
+	if !found {
+		// an unterminated block is kept as prose
+		if inBlock {
+			message = strings.TrimSpace(strings.Join(lines, "\n"))
+		}
+		return message, ""
+	}
+	return message, strings.TrimRight(strings.Join(code, "\n"), "\n")
+}
+
+func splitFindingBlocks(body string) []string {
+	var (
+		blocks  []string
+		current []string
+	)
+	for _, line := range strings.Split(body, "\n") {
+		if strings.TrimSpace(line) == "---" {
+			blocks = append(blocks, strings.Join(current, "\n"))
+			current = nil
+			continue
+		}
+		current = append(current, line)
+	}
+	return append(blocks, strings.Join(current, "\n"))
+}
+
+// findingHeader recognises "Line: 12", "**Severity:** high" and similar.
+func findingHeader(line string) (key, value string, ok bool) {
+	line = strings.TrimSpace(strings.ReplaceAll(line, "*", ""))
+	if line == "" {
+		return "", "", false
+	}
+	key, value, ok = strings.Cut(line, ":")
+	if !ok {
+		return "", "", false
+	}
+	key = strings.ToLower(strings.TrimSpace(key))
+	switch key {
+	case "line", "lines", "severity", "category":
+		return key, strings.TrimSpace(value), true
+	}
+	return "", "", false
+}
+
+// applyLimits drops findings below the configured severity threshold and,
+// when MaxComments is set, keeps only the most severe ones.
+func applyLimits(reviews []models.GeneratePRCommentParams, repoCfg repoconfig.Config) []models.GeneratePRCommentParams {
+	threshold := repoCfg.Threshold()
+	kept := []models.GeneratePRCommentParams{}
+	for _, review := range reviews {
+		if review.Severity.AtLeast(threshold) {
+			kept = append(kept, review)
+		}
+	}
+	if repoCfg.MaxComments > 0 && len(kept) > repoCfg.MaxComments {
+		sort.SliceStable(kept, func(i, j int) bool {
+			return kept[i].Severity.Rank() > kept[j].Severity.Rank()
+		})
+		kept = kept[:repoCfg.MaxComments]
+	}
+	return kept
+}
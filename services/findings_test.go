@@ -0,0 +1,123 @@
+package services
+
+import (
+	"ai-api/models"
+	"ai-api/prompts"
+	"reflect"
+	"testing"
+)
+
+// findingsPatch adds lines 2 to 4 of the new file.
+const findingsPatch = "@@ -1,2 +1,5 @@\n package store\n+\n+func Get(m map[string]int, k string) int {\n+\treturn m[k]\n }"
+
+func TestParseFindings(t *testing.T) {
+	tests := []struct {
+		name string
+		body string
+		want []reviewFinding
+	}{
+		{name: "no issues", body: "  " + prompts.NoIssuesReply + "\n", want: nil},
+		{name: "empty", body: "", want: nil},
+		{
+			name: "structured findings",
+			body: "Line: 3\nSeverity: high\nCategory: Bug\nThe map may be nil.\n---\n**Line:** 4\n**Severity:** bogus\nCheck the key exists.",
+			want: []reviewFinding{
+				{Line: 3, Severity: models.SeverityHigh, Category: "bug", Message: "The map may be nil.", Rule: "review/bug"},
+				{Line: 4, Severity: models.SeverityInfo, Message: "Check the key exists.", Rule: "review/general"},
+			},
+		},
+		{
+			name: "suggestion with a range",
+			body: "Lines: 3-4\nSeverity: low\nCategory: error handling\nReport missing keys.\n```suggestion\nfunc Get(m map[string]int, k string) (int, bool) {\n\tv, ok := m[k]\n```",
+			want: []reviewFinding{{
+				Line: 3, StartLine: 3, EndLine: 4, Severity: models.SeverityLow, Category: "error handling",
+				Message: "Report missing keys.", Rule: "review/error-handling",
+				Suggestion: "func Get(m map[string]int, k string) (int, bool) {\n\tv, ok := m[k]",
+			}},
+		},
+		{
+			name: "suggestion without a range replaces its line",
+			body: "Line: 4\nUse the comma-ok form.\n```suggestion\n\tv, _ := m[k]\n```",
+			want: []reviewFinding{{
+				Line: 4, StartLine: 4, EndLine: 4, Severity: models.SeverityInfo,
+				Message: "Use the comma-ok form.", Rule: "review/general", Suggestion: "\tv, _ := m[k]",
+			}},
+		},
+		{
+			name: "finding without a line goes to the first change",
+			body: "Severity: medium\nName the parameters better.",
+			want: []reviewFinding{{Line: 2, Severity: models.SeverityMedium, Message: "Name the parameters better.", Rule: "review/general"}},
+		},
+		{
+			name: "free text",
+			body: "Looks fine, but consider documenting Get.",
+			want: []reviewFinding{{Line: 2, Severity: models.SeverityInfo, Message: "Looks fine, but consider documenting Get.", Rule: "review/general"}},
+		},
+		{
+			name: "empty blocks are dropped",
+			body: "---\nLine: 3\nSeverity: low\n\n---\nLine: 4\nFine otherwise.",
+			want: []reviewFinding{{Line: 4, Severity: models.SeverityInfo, Message: "Fine otherwise.", Rule: "review/general"}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := parseFindings(tt.body, findingsPatch)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("parseFindings() =\n%+v\nwant\n%+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMergeFindings(t *testing.T) {
+	printfFinding := reviewFinding{Line: 3, Category: analyzerCategory, Rule: "printf", Tool: analyzerTool,
+		Message: "**printf**: fmt.Printf format %d has arg name of wrong type string"}
+	tests := []struct {
+		name  string
+		tool  []reviewFinding
+		model []reviewFinding
+		want  []string // messages of the merged findings
+	}{
+		{
+			name:  "duplicate tool findings",
+			tool:  []reviewFinding{printfFinding, printfFinding},
+			model: nil,
+			want:  []string{printfFinding.Message},
+		},
+		{
+			name:  "model restates the tool",
+			tool:  []reviewFinding{printfFinding},
+			model: []reviewFinding{{Line: 3, Category: "bug", Rule: "review/bug", Message: "The format %d has an arg of the wrong type: name is a string."}},
+			want:  []string{printfFinding.Message},
+		},
+		{
+			name:  "model names the tool's rule",
+			tool:  []reviewFinding{printfFinding},
+			model: []reviewFinding{{Line: 3, Category: "printf", Rule: "review/printf", Message: "Wrong verb."}},
+			want:  []string{printfFinding.Message},
+		},
+		{
+			name:  "another problem on the same line",
+			tool:  []reviewFinding{printfFinding},
+			model: []reviewFinding{{Line: 3, Category: "security", Rule: "review/security", Message: "This logs the user's password."}},
+			want:  []string{printfFinding.Message, "This logs the user's password."},
+		},
+		{
+			name:  "same words on another line",
+			tool:  []reviewFinding{printfFinding},
+			model: []reviewFinding{{Line: 4, Category: "bug", Rule: "review/bug", Message: "The format %d has an arg of the wrong type: name is a string."}},
+			want:  []string{printfFinding.Message, "The format %d has an arg of the wrong type: name is a string."},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got []string
+			for _, finding := range mergeFindings(tt.tool, tt.model) {
+				got = append(got, finding.Message)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("mergeFindings() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -1,13 +1,23 @@
 package services
 
 import (
+	"ai-api/analyzers"
 	clients "ai-api/clients"
 	"ai-api/config"
+	"ai-api/diff"
 	"ai-api/models"
+	"ai-api/prompts"
+	"ai-api/repoconfig"
+	"ai-api/sarif"
+	"ai-api/store"
+	"bytes"
 	"context"
 	"fmt"
 	"net/url"
-	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/tools/go/analysis"
 )
 
 // DiffEntry represents a single entry in the diff response from GitHub
@@ -28,15 +38,84 @@ type DiffEntry struct {
 // PRService is a concrete implementation of the PRService interface
 type PRService struct {
 	githubClient clients.GithubClient
-	llmClient    clients.OpenFGAClient
+	llmClient    clients.OpenFGAClientInterface
 	cfg          config.Config
+
+	// providers maps models.Provider* names to their SCM implementation
+	providers map[string]clients.SCMProvider
+	// githubHosts holds the clients of owners living on another GitHub host
+	githubHosts map[string]*clients.GithubClient
+	// prompts are the prompt templates, loaded and validated at startup
+	prompts *prompts.Set
+	// store records the prompt versions used by each review and their outcome
+	store *store.Store
+	// analyzers are run on Go changes of trusted repos before the model
+	// reviews them, in the sandbox
+	analyzers []*analysis.Analyzer
+	sandbox   analyzers.Sandbox
+	// threads serializes replies in the same review thread, so webhooks
+	// delivered together cannot both pass the reply checks
+	threads keyedMutex
+	// feedbackMu serializes feedback refreshes, which are paused until
+	// feedbackPausedUntil after GitHub rate limits them
+	feedbackMu          sync.Mutex
+	feedbackPausedUntil time.Time
+	// budgetMu guards budgetReserved, the reviews of MaxReviewsPerDay
+	// running and not yet recorded, and budgetFailed, when the reviews that
+	// failed after calling the model started
+	budgetMu       sync.Mutex
+	budgetReserved int
+	budgetFailed   []time.Time
+
+	// tenant is the name of the tenant this service serves, empty for the
+	// instance, which hands requests of its tenants' owners and
+	// installations to their services
+	tenant        string
+	tenants       []*PRService
+	tenantOwners  map[string]*PRService
+	installations map[int64]*PRService
 }
 
+// ReviewScope describes what a review is for: the repository and pull request
+// the changes belong to and the settings to review them with.
+type ReviewScope struct {
+	RepoOwner string
+	RepoName  string
+	PRNumber  string
+	// PullRequest holds the PR metadata when the provider reports it.
+	PullRequest *models.PullRequest
+	Config      repoconfig.Config
+	// Contents reads files of the repository at a commit to give the model
+	// surrounding code; nil when the provider cannot serve files.
+	Contents ContentsFetcher
+	// Archive downloads the repository for static analysis; nil when the
+	// provider cannot serve archives.
+	Archive ArchiveFetcher
+	// External are findings of external linters, read from SARIF, merged
+	// with the review's own findings.
+	External []sarif.Finding
+}
+
+// statusContext is the name the review status is reported under.
+const statusContext = "pr-checker"
+
 // Responses include a maximum of 3000 files. The paginated response returns 30 files per page by default.
 // GetPRsFromGitHub is the implementation of the PRService interface method
 func (s *PRService) GetPRChangeFilesFromGitHub(ctx context.Context, prRequestBody models.PullRequestRequest) (*models.ChangeFiles, error) {
-	// Build GitHub API URL for fetching PRs
-	changeFiles, err := s.githubClient.FetchPullRequestChanges(prRequestBody)
+	s = s.forOwner(prRequestBody.OwnerID)
+	prRequestBody.Provider = models.ProviderGitHub
+	return s.GetPRChangeFiles(ctx, prRequestBody, s.defaultRepoConfig())
+}
+
+// GetPRChangeFiles fetches the changed files of a pull or merge request from
+// the provider named in the request and keeps the ones repoCfg selects for review.
+func (s *PRService) GetPRChangeFiles(ctx context.Context, prRequestBody models.PullRequestRequest, repoCfg repoconfig.Config) (*models.ChangeFiles, error) {
+	s = s.forOwner(prRequestBody.OwnerID)
+	provider, err := s.provider(prRequestBody)
+	if err != nil {
+		return nil, err
+	}
+	changeFiles, err := provider.FetchChanges(ctx, prRequestBody)
 	if err != nil {
 		return nil, fmt.Errorf("failed to fetch PR changes: %w", err)
 	}
@@ -44,17 +123,59 @@ func (s *PRService) GetPRChangeFilesFromGitHub(ctx context.Context, prRequestBod
 		return nil, fmt.Errorf("no files found in the PR")
 	}
 
-	// Filter out only .go files
-	var result = models.ChangeFiles{
-		Files: []models.ChangeFile{},
+	result := filterReviewableFiles(changeFiles, repoCfg)
+	result.BaseSHA, result.StartSHA, result.HeadSHA = changeFiles.BaseSHA, changeFiles.StartSHA, changeFiles.HeadSHA
+	return result, nil
+}
+
+// provider returns the SCM provider a request is addressed to, falling back to
+// the provider configured for the owner, the default provider and then GitHub.
+func (s *PRService) provider(prRequestBody models.PullRequestRequest) (clients.SCMProvider, error) {
+	name := s.providerName(prRequestBody)
+	if name == models.ProviderGitHub {
+		return s.githubFor(prRequestBody.OwnerID), nil
 	}
-	for _, file := range changeFiles.Files {
-		if strings.HasSuffix(file.Filename, ".go") {
-			result.Files = append(result.Files, file)
-		}
+	provider, ok := s.providers[name]
+	if !ok {
+		return nil, fmt.Errorf("unsupported provider %q", name)
+	}
+	return provider, nil
+}
+
+// providerName returns the name of the provider serving a request.
+func (s *PRService) providerName(prRequestBody models.PullRequestRequest) string {
+	name := prRequestBody.Provider
+	if name == "" {
+		name = s.cfg.ProviderForOwner(prRequestBody.OwnerID)
+	}
+	if name == "" {
+		name = models.ProviderGitHub
+	}
+	return name
+}
+
+// githubFor returns the GitHub client for owner: the owner's own host when one
+// is configured, otherwise the default host.
+func (s *PRService) githubFor(owner string) *clients.GithubClient {
+	if client, ok := s.githubHosts[owner]; ok {
+		return client
 	}
+	return &s.githubClient
+}
 
-	return &result, nil
+// ReviewDiff reviews changes that did not come from GitHub, such as a local
+// unified diff, applying the same file filtering as pull requests. external
+// are findings of other linters merged into the review, and contents, when
+// not nil, serves the files at changeFiles.HeadSHA for surrounding context.
+func (s *PRService) ReviewDiff(ctx context.Context, changeFiles *models.ChangeFiles, external []sarif.Finding, contents ContentsFetcher) ([]models.GeneratePRCommentParams, error) {
+	repoCfg := s.defaultRepoConfig()
+	files := filterReviewableFiles(changeFiles, repoCfg)
+	files.HeadSHA = changeFiles.HeadSHA
+	reviews, err := s.ReviewChanges(ctx, files, ReviewScope{Config: repoCfg, External: external, Contents: contents})
+	if err != nil {
+		return nil, err
+	}
+	return applyLimits(reviews, repoCfg), nil
 }
 
 // ReviewChanges reviews the changes in a pull request by analyzing the provided change files
@@ -63,50 +184,319 @@ func (s *PRService) GetPRChangeFilesFromGitHub(ctx context.Context, prRequestBod
 // Parameters:
 //   - ctx: The context for managing request deadlines and cancellations.
 //   - changeFiles: A pointer to a models.ChangeFiles object containing the list of changed files.
-//   - repoOwner: The owner of the repository where the pull request resides.
-//   - repoName: The name of the repository where the pull request resides.
-//   - prNumber: The pull request number.
+//   - scope: The repository and pull request under review and the review settings
+//     (prompt template, extra instructions, model, style guides).
 //
 // Returns:
 //   - reviews: A slice of models.GeneratePRCommentParams containing the generated review comments.
 //   - err: An error if any issue occurs during the review process.
 //
-// The function iterates over the list of changed files, extracts the head commit SHA from the file's
-// contents URL, and uses an LLM client to generate a review comment body based on the file's patch.
-// It then constructs a GeneratePRCommentParams object for each file and appends it to the reviews slice.
-func (s *PRService) ReviewChanges(ctx context.Context, changeFiles *models.ChangeFiles, repoOwner, repoName, prNumber string) (reviews []models.GeneratePRCommentParams, err error) {
+// Go changes are first checked by the configured static analyzers. The function then iterates over
+// the list of changed files, extracts the head commit SHA from the file's contents URL, retrieves the
+// relevant style guide chunks, the surrounding code and the analyzer findings of Go files, and
+// renders the file's prompt template
+// (in the version chosen for this pull request), then asks the LLM client for a review of the file's patch.
+// The response is split into findings and a GeneratePRCommentParams object is appended to the
+// reviews slice for each finding and each analyzer or external linter finding on an added line,
+// positioned on the line the finding refers to. Model findings on a line a tool reported are dropped.
+func (s *PRService) ReviewChanges(ctx context.Context, changeFiles *models.ChangeFiles, scope ReviewScope) (reviews []models.GeneratePRCommentParams, err error) {
+	repoCfg := scope.Config
+
+	// static analysis of the Go changes, posted as findings of their own
+	diagnostics := s.analyzeChanges(ctx, scope, changeFiles)
+	var filenames []string
+	for _, file := range changeFiles.Files {
+		filenames = append(filenames, file.Filename)
+	}
+
 	for _, file := range changeFiles.Files {
 		// get the sha from the contents url (find a better way to do this?)
 		headCommitSHA, err := parseRefForHeadCommitSHA(file.Contents_url)
 		if err != nil {
 			return nil, fmt.Errorf("failed to extra head commit sha: %w", err)
 		}
+		if headCommitSHA == "" {
+			headCommitSHA = changeFiles.HeadSHA
+		}
+
+		// Retrieve the style guide passages relevant to this patch
+		styleChunks, err := s.llmClient.RelevantStyleChunks(ctx, file.Patch, repoCfg.StyleGuideChunks)
+		if err != nil {
+			return nil, fmt.Errorf("failed to retrieve style guide: %w", err)
+		}
+
+		// Render the prompt version selected for the file's language
+		language := repoconfig.LanguageForFile(file.Filename)
+		promptRef := s.promptFor(scope, language)
+		data := s.promptData(scope, file, language, styleChunks)
+		data.Context = s.fileContext(ctx, scope, file, language, headCommitSHA)
+		toolFindings := append(analyzerFindings(diagnostics[file.Filename], file.Patch),
+			externalFindings(scope.External, file.Filename, filenames, file.Patch)...)
+		data.Diagnostics = promptDiagnostics(toolFindings)
+		prompt, err := s.prompts.Render(promptRef, data)
+		if err != nil {
+			return nil, fmt.Errorf("failed to build prompt: %w", err)
+		}
 
 		// Generate the comment body using the LLM client
-		commentBody, err := s.llmClient.GenerateReviewComment(ctx, file.Patch, s.cfg.LLMAnalyzePrompt)
+		commentBody, err := s.llmClient.Complete(ctx, prompt, repoCfg.Model)
 		if err != nil {
 			return nil, fmt.Errorf("failed to generate comment body: %w", err)
 		}
-		generateCommentsRequest := models.GeneratePRCommentParams{
-			RepoOwner:   repoOwner,
-			RepoName:    repoName,
-			PRNumber:    prNumber,
-			CommentBody: commentBody,
-			CommitSha:   headCommitSHA,
-			FileName:    file.Filename,
-			Position:    1,
-		}
 
-		reviews = append(reviews, generateCommentsRequest)
+		// one comment per finding, anchored to the line the model pointed at
+		for _, finding := range mergeFindings(toolFindings, parseFindings(commentBody, file.Patch)) {
+			position := diff.PositionForLine(file.Patch, finding.Line)
+			if position == 0 {
+				position = 1
+			}
+			generateCommentsRequest := models.GeneratePRCommentParams{
+				RepoOwner:   scope.RepoOwner,
+				RepoName:    scope.RepoName,
+				PRNumber:    scope.PRNumber,
+				CommentBody: finding.Message,
+				CommitSha:   headCommitSHA,
+				FileName:    file.Filename,
+				Position:    position,
+				Line:        diff.LineForPosition(file.Patch, position),
+				LineType:    diff.LineKindForPosition(file.Patch, position),
+				Severity:    finding.Severity,
+				Category:    finding.Category,
+				OldFileName: file.PreviousFilename,
+				BaseSha:     changeFiles.BaseSHA,
+				StartSha:    changeFiles.StartSHA,
+				Rule:        finding.Rule,
+				Tool:        finding.Tool,
+			}
+			if finding.Tool == "" {
+				generateCommentsRequest.Prompt = promptRef.String()
+			}
+			attachSuggestion(&generateCommentsRequest, finding, file.Patch)
+
+			reviews = append(reviews, generateCommentsRequest)
+		}
 	}
 	return reviews, nil
 }
 
-func (s *PRService) PostPRComments(ctx context.Context, codeReviews []models.GeneratePRCommentParams) (status string, err error) {
+// AnalyzeOptions control how a pull request is reviewed.
+type AnalyzeOptions struct {
+	// DryRun returns the comments without posting them, which makes it safe
+	// to iterate on prompts against real PRs.
+	DryRun bool
+	// External are findings of external linters merged into the review.
+	External []sarif.Finding
+	// UploadSARIF uploads the findings to GitHub code scanning.
+	UploadSARIF bool
+	// Files limits the review to these changed files; all are reviewed when
+	// empty. The summary is left as it is for such partial reviews.
+	Files []string
+}
+
+// AnalyzePR fetches the changes of a pull request, reviews them and posts the
+// resulting comments, see AnalyzeOptions.
+func (s *PRService) AnalyzePR(ctx context.Context, prRequestBody models.PullRequestRequest, opts AnalyzeOptions) (*models.AnalyzeResult, error) {
+	s = s.forOwner(prRequestBody.OwnerID)
+	budget, err := s.reserveBudget(time.Now())
+	if err != nil {
+		return nil, err
+	}
+	recorded := false
+	defer func() { budget.release(recorded) }()
+	dryRun := opts.DryRun
+	provider, err := s.provider(prRequestBody)
+	if err != nil {
+		return nil, err
+	}
+
+	// pr metadata (title, author, labels, branches) where the provider has it
+	pr := s.fetchPullRequest(ctx, prRequestBody, provider)
+
+	// repository settings from .prchecker.yml; invalid files are reported
+	// on the PR and the review continues with the defaults
+	repoCfg, configErr := s.loadRepoConfig(ctx, prRequestBody, provider, pr)
+
+	// fetch changes from the provider for requested pr
+	changeFiles, err := s.GetPRChangeFiles(ctx, prRequestBody, repoCfg)
+	if err != nil {
+		return nil, fmt.Errorf("error fetching pr changes: %w", err)
+	}
+	if len(opts.Files) > 0 {
+		keepRequestedFiles(changeFiles, opts.Files)
+		if len(changeFiles.Files) == 0 {
+			return nil, fmt.Errorf("none of the requested files are reviewed in the PR")
+		}
+	}
+
+	headSHA := headCommitSHA(changeFiles)
+	if !dryRun {
+		s.setStatus(ctx, provider, prRequestBody, headSHA, models.StatusPending, "review in progress")
+	}
+
+	// analyze the change files and generate a list of comments
+	scope := ReviewScope{
+		RepoOwner:   prRequestBody.OwnerID,
+		RepoName:    prRequestBody.RepoID,
+		PRNumber:    prRequestBody.ID,
+		PullRequest: pr,
+		Config:      repoCfg,
+		External:    opts.External,
+	}
+	if contents, ok := provider.(ContentsFetcher); ok {
+		scope.Contents = contents
+	}
+	if archive, ok := provider.(ArchiveFetcher); ok {
+		scope.Archive = archive
+	}
+	budget.spend()
+	codeReviews, err := s.ReviewChanges(ctx, changeFiles, scope)
+	if err != nil {
+		if !dryRun {
+			s.setStatus(ctx, provider, prRequestBody, headSHA, models.StatusError, "review failed")
+		}
+		return nil, fmt.Errorf("error reviewing pr changes: %w", err)
+	}
+	codeReviews = dropSuppressed(codeReviews, changeFiles, s.store.Suppressions(s.providerName(prRequestBody), prRequestBody.OwnerID, prRequestBody.RepoID, prRequestBody.ID))
+	codeReviews = applyLimits(codeReviews, repoCfg)
+
+	result := &models.AnalyzeResult{
+		DryRun:   dryRun,
+		Comments: codeReviews,
+		Skipped:  changeFiles.Skipped,
+	}
+	record := func() {
+		s.recordReview(prRequestBody, changeFiles, scope, result)
+		recorded = true
+	}
+	// pr-level overview posted next to the line comments
+	if !s.cfg.SummaryDisabled && len(opts.Files) == 0 {
+		result.Summary, err = s.summarize(ctx, scope, changeFiles, codeReviews)
+		if err != nil {
+			fmt.Printf("failed to summarize PR: %v\n", err)
+		}
+	}
+	if configErr != nil {
+		result.ConfigError = configErr.Error()
+	}
+	// completed reviews record which prompt versions were used and what they
+	// found, once the ids of posted comments are known; reviews failing
+	// before anything is posted are not recorded, but count against the
+	// budget
+	if dryRun {
+		record()
+		return result, nil
+	}
+	if configErr != nil {
+		s.reportConfigError(ctx, provider, prRequestBody, configErr)
+	}
+	if opts.UploadSARIF {
+		s.uploadSARIF(ctx, provider, prRequestBody, headSHA, result)
+	}
+	if len(codeReviews) == 0 {
+		result.Status = "no findings"
+		s.postSummary(ctx, provider, prRequestBody, result.Summary)
+		s.setStatus(ctx, provider, prRequestBody, headSHA, models.StatusSuccess, result.Status)
+		record()
+		return result, nil
+	}
+
+	posted, status, err := s.PostPRComments(ctx, provider, codeReviews)
+	if err != nil {
+		s.setStatus(ctx, provider, prRequestBody, headSHA, models.StatusError, "failed to post review comments")
+		// the comments that did post are recorded so feedback and /ignore
+		// find them
+		if len(posted) > 0 {
+			result.Comments = posted
+			record()
+		}
+		return nil, fmt.Errorf("error posting PR comments: %w", err)
+	}
+	result.Status = status
+	s.postSummary(ctx, provider, prRequestBody, result.Summary)
+	s.setStatus(ctx, provider, prRequestBody, headSHA, models.StatusSuccess, fmt.Sprintf("%d review comments", len(codeReviews)))
+	record()
+	return result, nil
+}
+
+// postSummary posts the summary comment, or updates the one posted by an
+// earlier review. Failing to post it does not fail the review.
+func (s *PRService) postSummary(ctx context.Context, provider clients.SCMProvider, prRequestBody models.PullRequestRequest, summary string) {
+	if summary == "" {
+		return
+	}
+	if err := provider.UpsertSummary(ctx, prRequestBody, summaryMarker, summary); err != nil {
+		fmt.Printf("failed to post PR summary: %v\n", err)
+	}
+}
+
+// uploadSARIF uploads the review's findings to GitHub code scanning for the
+// pull request's head ref. An empty review is uploaded too so alerts fixed
+// by the pull request are closed. Failures are reported in the result.
+func (s *PRService) uploadSARIF(ctx context.Context, provider clients.SCMProvider, prRequestBody models.PullRequestRequest, sha string, result *models.AnalyzeResult) {
+	github, ok := provider.(*clients.GithubClient)
+	if !ok {
+		result.SarifUploadError = "code scanning uploads are only supported on GitHub"
+		return
+	}
+	var log bytes.Buffer
+	if err := sarif.Encode(&log, sarif.FromFindings(result.Comments)); err != nil {
+		result.SarifUploadError = err.Error()
+		return
+	}
+	ref := fmt.Sprintf("refs/pull/%s/head", prRequestBody.ID)
+	id, err := github.UploadSARIF(ctx, prRequestBody.OwnerID, prRequestBody.RepoID, sha, ref, log.Bytes())
+	if err != nil {
+		result.SarifUploadError = err.Error()
+		return
+	}
+	result.SarifUploadID = id
+}
+
+// setStatus reports the review status on the head commit. Failing to set a
+// status does not fail the review.
+func (s *PRService) setStatus(ctx context.Context, provider clients.SCMProvider, prRequestBody models.PullRequestRequest, sha, state, description string) {
+	if sha == "" {
+		return
+	}
+	err := provider.SetStatus(ctx, prRequestBody, sha, models.CommitStatus{
+		State:       state,
+		Description: description,
+		Context:     statusContext,
+	})
+	if err != nil {
+		fmt.Printf("failed to set %s status on %s: %v\n", state, sha, err)
+	}
+}
+
+// headCommitSHA returns the head commit of the change, from the diff refs
+// when the provider reports them or from the first file's contents URL.
+func headCommitSHA(changeFiles *models.ChangeFiles) string {
+	if changeFiles.HeadSHA != "" {
+		return changeFiles.HeadSHA
+	}
+	for _, file := range changeFiles.Files {
+		if sha, err := parseRefForHeadCommitSHA(file.Contents_url); err == nil && sha != "" {
+			return sha
+		}
+	}
+	return ""
+}
+
+// DefaultDryRun reports whether reviews of owner's repositories run in
+// dry-run mode when the request does not say otherwise.
+func (s *PRService) DefaultDryRun(owner string) bool {
+	return s.forOwner(owner).cfg.DryRun
+}
+
+// PostPRComments posts every review comment through provider, continuing past
+// failures and reporting them together at the end. The id of each posted
+// comment is stored in its CommentID, and the posted comments are returned
+// even when others failed.
+func (s *PRService) PostPRComments(ctx context.Context, provider clients.SCMProvider, codeReviews []models.GeneratePRCommentParams) (posted []models.GeneratePRCommentParams, status string, err error) {
 	var failedComments []models.GeneratePRCommentParams
 
-	for _, codeReview := range codeReviews {
-		resp, err := s.githubClient.PostPullRequestCommentOnLine(codeReview)
+	for i, codeReview := range codeReviews {
+		commentID, err := provider.PostInlineComment(ctx, codeReview)
 		if err != nil {
 			// Log the failed comment and continue with the next one
 			fmt.Printf("failed to post comment for file %s: %v\n", codeReview.FileName, err)
@@ -114,17 +504,18 @@ func (s *PRService) PostPRComments(ctx context.Context, codeReviews []models.Gen
 			continue
 		}
 		fmt.Println("Comment posted for: ", codeReview.FileName)
-		status = resp[0].Body
+		codeReviews[i].CommentID = commentID
+		posted = append(posted, codeReviews[i])
 	}
 
 	if len(failedComments) > 0 {
-		return "", fmt.Errorf("some comments failed to post: %v", failedComments)
+		return posted, "", fmt.Errorf("some comments failed to post: %v", failedComments)
 	}
 	// If all comments were posted successfully, return the status
-	if status == "" {
-		return "", fmt.Errorf("no comments posted")
+	if len(posted) == 0 {
+		return nil, "", fmt.Errorf("no comments posted")
 	}
-	return status, nil
+	return posted, fmt.Sprintf("posted %d comments", len(posted)), nil
 }
 
 // parseRefForHeadCommitSHA parses the rawURL string to get the head commit SHA for a PR
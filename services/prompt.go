@@ -0,0 +1,67 @@
+package services
+
+import (
+	"ai-api/diff"
+	"ai-api/models"
+	"ai-api/prompts"
+	"ai-api/repoconfig"
+	"fmt"
+)
+
+// promptName selects the prompt template for a file: the repository's
+// per-language or repository-wide choice, falling back to the built-in one.
+func promptName(repoCfg repoconfig.Config, language string) string {
+	if name := repoCfg.PromptFor(language); name != "" {
+		return name
+	}
+	return prompts.DefaultName
+}
+
+// promptFor selects the template version for a file of language. The pull
+// request is the unit of prompt experiments so every file of a review and
+// every re-review of the pull request use the same version.
+func (s *PRService) promptFor(scope ReviewScope, language string) prompts.Ref {
+	key := fmt.Sprintf("%s/%s#%s", scope.RepoOwner, scope.RepoName, scope.PRNumber)
+	return s.prompts.Select(promptName(scope.Config, language), key)
+}
+
+// validatePromptNames checks that every template a configuration refers to exists.
+func (s *PRService) validatePromptNames(repoCfg repoconfig.Config) error {
+	names := []string{repoCfg.Prompt}
+	for _, name := range repoCfg.LanguagePrompts {
+		names = append(names, name)
+	}
+	for _, name := range names {
+		if name != "" && !s.prompts.Has(name) {
+			return fmt.Errorf("unknown prompt template %q (available: %v)", name, s.prompts.Names())
+		}
+	}
+	return nil
+}
+
+// promptData gathers everything a prompt template can refer to for one file.
+func (s *PRService) promptData(scope ReviewScope, file models.ChangeFile, language string, styleChunks []string) prompts.Data {
+	data := prompts.Data{
+		BasePrompt:   s.cfg.LLMAnalyzePrompt,
+		Instructions: scope.Config.Instructions,
+		PR:           prompts.PullRequest{Number: scope.PRNumber},
+		File: prompts.File{
+			Name:     file.Filename,
+			Language: language,
+			Status:   file.Status,
+			Patch:    file.Patch,
+		},
+		Hunks:        diff.Hunks(file.Patch),
+		StyleChunks:  styleChunks,
+		OutputFormat: prompts.ReviewOutputFormat,
+	}
+	if pr := scope.PullRequest; pr != nil {
+		data.PR.Title = pr.Title
+		data.PR.Description = pr.Body
+		data.PR.Author = pr.User.Login
+		for _, label := range pr.Labels {
+			data.PR.Labels = append(data.PR.Labels, label.Name)
+		}
+	}
+	return data
+}
@@ -0,0 +1,136 @@
+package services
+
+import (
+	"ai-api/models"
+	"ai-api/prompts"
+	"ai-api/repoconfig"
+	"ai-api/store"
+	"fmt"
+	"sort"
+)
+
+// PromptStats summarizes the outcomes of every version of a prompt template.
+type PromptStats struct {
+	Name       string               `json:"name"`
+	Stable     string               `json:"stable"`
+	Experiment *prompts.Experiment  `json:"experiment,omitempty"`
+	Versions   []PromptVersionStats `json:"versions"`
+}
+
+// PromptVersionStats summarizes the reviews one prompt version took part in.
+type PromptVersionStats struct {
+	Version string `json:"version"`
+	// Reviews and Files count the reviews and files the version was used for.
+	Reviews int `json:"reviews"`
+	Files   int `json:"files"`
+	// Findings counts what the version reported, in total and per severity.
+	Findings        int                     `json:"findings"`
+	FindingsPerFile float64                 `json:"findings_per_file"`
+	Severities      map[models.Severity]int `json:"severities"`
+	// Posted counts the findings posted as comments and Reactions the
+	// reactions they received, by content ("+1", "-1", ...).
+	Posted    int            `json:"posted"`
+	Reactions map[string]int `json:"reactions"`
+}
+
+// recordReview stores which prompt versions reviewed a pull request and what
+// they found. Failing to record does not fail the review.
+func (s *PRService) recordReview(prRequestBody models.PullRequestRequest, changeFiles *models.ChangeFiles, scope ReviewScope, result *models.AnalyzeResult) {
+	review := store.Review{
+		Provider: s.providerName(prRequestBody),
+		Owner:    prRequestBody.OwnerID,
+		Repo:     prRequestBody.RepoID,
+		PRNumber: prRequestBody.ID,
+		DryRun:   result.DryRun,
+		Model:    scope.Config.Model,
+		Files:    map[string]int{},
+	}
+	for _, file := range changeFiles.Files {
+		review.Files[s.promptFor(scope, repoconfig.LanguageForFile(file.Filename)).String()]++
+	}
+	for _, comment := range result.Comments {
+		review.Comments = append(review.Comments, store.Comment{
+			Prompt:    comment.Prompt,
+			File:      comment.FileName,
+			Line:      comment.Line,
+			Severity:  comment.Severity,
+			Category:  comment.Category,
+			Rule:      comment.Rule,
+			CommentID: comment.CommentID,
+		})
+	}
+	if _, err := s.store.AddReview(review); err != nil {
+		fmt.Printf("failed to record review: %v\n", err)
+	}
+}
+
+// PromptStats summarizes the recorded reviews per prompt template and version.
+// Versions that are loaded but were never used are listed with zero counts.
+func (s *PRService) PromptStats() []PromptStats {
+	versions := map[prompts.Ref]*PromptVersionStats{}
+	stat := func(ref prompts.Ref) *PromptVersionStats {
+		if versions[ref] == nil {
+			versions[ref] = &PromptVersionStats{
+				Version:    ref.Version,
+				Severities: map[models.Severity]int{},
+				Reactions:  map[string]int{},
+			}
+		}
+		return versions[ref]
+	}
+
+	for _, name := range s.prompts.Names() {
+		for _, version := range s.prompts.Versions(name) {
+			stat(prompts.Ref{Name: name, Version: version})
+		}
+	}
+	for _, review := range s.store.Reviews() {
+		for rawRef, files := range review.Files {
+			ref, err := prompts.ParseRef(rawRef)
+			if err != nil {
+				continue
+			}
+			stat(ref).Reviews++
+			stat(ref).Files += files
+		}
+		for _, comment := range review.Comments {
+			ref, err := prompts.ParseRef(comment.Prompt)
+			if err != nil {
+				continue
+			}
+			version := stat(ref)
+			version.Findings++
+			version.Severities[comment.Severity]++
+			if comment.CommentID != "" {
+				version.Posted++
+			}
+			for content, count := range comment.Reactions {
+				version.Reactions[content] += count
+			}
+		}
+	}
+
+	byName := map[string]*PromptStats{}
+	for ref, version := range versions {
+		if version.Files > 0 {
+			version.FindingsPerFile = float64(version.Findings) / float64(version.Files)
+		}
+		if byName[ref.Name] == nil {
+			byName[ref.Name] = &PromptStats{Name: ref.Name, Stable: s.prompts.Stable(ref.Name)}
+			if experiment, ok := s.prompts.Experiment(ref.Name); ok {
+				byName[ref.Name].Experiment = &experiment
+			}
+		}
+		byName[ref.Name].Versions = append(byName[ref.Name].Versions, *version)
+	}
+
+	stats := make([]PromptStats, 0, len(byName))
+	for _, prompt := range byName {
+		sort.Slice(prompt.Versions, func(i, j int) bool {
+			return prompts.VersionLess(prompt.Versions[i].Version, prompt.Versions[j].Version)
+		})
+		stats = append(stats, *prompt)
+	}
+	sort.Slice(stats, func(i, j int) bool { return stats[i].Name < stats[j].Name })
+	return stats
+}
@@ -0,0 +1,100 @@
+package services
+
+import (
+	clients "ai-api/clients"
+	"ai-api/models"
+	"ai-api/repoconfig"
+	"context"
+	"errors"
+	"fmt"
+)
+
+// defaultRepoConfig returns the review settings used when a repository has no
+// configuration of its own.
+func (s *PRService) defaultRepoConfig() repoconfig.Config {
+	return repoconfig.Defaults(s.cfg)
+}
+
+// loadRepoConfig returns the review settings for a pull request: the global
+// defaults merged with the repository's .prchecker.yml from its default
+// branch. Only GitHub repositories can carry a configuration file.
+//
+// A missing file is not an error. An invalid file yields the defaults and a
+// non-nil configErr describing the problem so it can be reported on the PR.
+func (s *PRService) loadRepoConfig(ctx context.Context, prRequestBody models.PullRequestRequest, provider clients.SCMProvider, pr *models.PullRequest) (repoCfg repoconfig.Config, configErr error) {
+	defaults := s.defaultRepoConfig()
+
+	github, ok := provider.(*clients.GithubClient)
+	if !ok || pr == nil {
+		return defaults, nil
+	}
+
+	ref := pr.Base.Repo.DefaultBranch
+	if ref == "" {
+		ref = pr.Base.Ref
+	}
+
+	data, err := github.FetchFileContents(ctx, prRequestBody.OwnerID, prRequestBody.RepoID, repoconfig.FileName, ref)
+	if errors.Is(err, clients.ErrNotFound) {
+		return defaults, nil
+	}
+	if err != nil {
+		fmt.Printf("failed to fetch %s, using defaults: %v\n", repoconfig.FileName, err)
+		return defaults, nil
+	}
+
+	override, err := repoconfig.Parse(data)
+	if err != nil {
+		return defaults, err
+	}
+	if err := override.Validate(); err != nil {
+		return defaults, err
+	}
+	repoCfg = repoconfig.Merge(defaults, *override)
+	if err := s.validatePromptNames(repoCfg); err != nil {
+		return defaults, err
+	}
+
+	// fetch and split the repository's own style guides
+	for _, guide := range repoCfg.StyleGuides {
+		data, err := github.FetchFileContents(ctx, prRequestBody.OwnerID, prRequestBody.RepoID, guide, ref)
+		if err != nil {
+			return defaults, fmt.Errorf("style guide %s: %w", guide, err)
+		}
+		chunks, err := clients.ParseStyleGuideFile(guide, data)
+		if err != nil {
+			return defaults, fmt.Errorf("style guide %s: %w", guide, err)
+		}
+		repoCfg.StyleGuideChunks = append(repoCfg.StyleGuideChunks, chunks...)
+	}
+
+	return repoCfg, nil
+}
+
+// fetchPullRequest returns the pull request's metadata, or nil when the
+// provider does not report it or it cannot be fetched.
+func (s *PRService) fetchPullRequest(ctx context.Context, prRequestBody models.PullRequestRequest, provider clients.SCMProvider) *models.PullRequest {
+	github, ok := provider.(*clients.GithubClient)
+	if !ok {
+		return nil
+	}
+	pr, err := github.FetchPullRequest(ctx, prRequestBody)
+	if err != nil {
+		fmt.Printf("failed to fetch PR metadata: %v\n", err)
+		return nil
+	}
+	return pr
+}
+
+// configErrorMarker is hidden in the configuration error comment so each
+// review updates the same comment instead of posting another.
+const configErrorMarker = "<!-- pr-checker:config-error -->"
+
+// reportConfigError posts, or updates, a comment on the PR explaining why
+// the repository configuration was ignored.
+func (s *PRService) reportConfigError(ctx context.Context, provider clients.SCMProvider, prRequestBody models.PullRequestRequest, configErr error) {
+	body := fmt.Sprintf("%s\n**pr-checker configuration error**\n\n`%s` could not be used: %v\n\nThis review ran with the default settings.", configErrorMarker, repoconfig.FileName, configErr)
+	if err := provider.UpsertSummary(ctx, prRequestBody, configErrorMarker, body); err != nil {
+		fmt.Printf("failed to report configuration error: %v\n", err)
+	}
+}
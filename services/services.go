@@ -1,9 +1,18 @@
 package services
 
 import (
+	"ai-api/analyzers"
 	clients "ai-api/clients"
 	"ai-api/config"
+	"ai-api/models"
+	"ai-api/prompts"
+	"ai-api/replay"
+	"ai-api/repoconfig"
+	"ai-api/store"
+	"fmt"
 	"net/http"
+	"os"
+	"strings"
 	"time"
 )
 
@@ -12,21 +21,190 @@ type Services struct {
 }
 
 // NewServices creates a new Services instance
-func NewServices(cfg config.Config) *Services {
+func NewServices(cfg config.Config) (*Services, error) {
+	return buildServices(cfg, func(cfg config.Config, httpClient *http.Client) (clients.OpenFGAClientInterface, error) {
+		llmClient, err := clients.NewOpenFGAClient(httpClient, cfg.LLMServiceAPIKey, cfg.LLMServiceURL, cfg.LLMModel)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create the LLM client: %w", err)
+		}
+		return llmClient, nil
+	})
+}
 
-	httpClient := &http.Client{
-		Timeout: 60 * time.Second,
+// NewServicesWithLLM creates a Services instance reviewing with llmClient
+// instead of the configured LLM service, e.g. a stub replaying recorded
+// responses. Every tenant shares llmClient.
+func NewServicesWithLLM(cfg config.Config, llmClient clients.OpenFGAClientInterface) (*Services, error) {
+	if llmClient == nil {
+		return nil, fmt.Errorf("an LLM client is required")
 	}
-	githubClient := clients.NewGithubClient(httpClient, cfg.GithubToken, cfg.GithubBaseURL)
-	openFGAClient := clients.NewOpenFGAClient(httpClient, cfg.LLMServiceAPIKey, cfg.LLMServiceURL)
+	return buildServices(cfg, func(config.Config, *http.Client) (clients.OpenFGAClientInterface, error) {
+		return llmClient, nil
+	})
+}
+
+// buildServices creates the instance's PRService and, when a tenants file
+// is configured, one PRService per tenant built from the tenant's config,
+// so tenants share no clients, caches, prompts or stored reviews.
+func buildServices(cfg config.Config, llmFor func(config.Config, *http.Client) (clients.OpenFGAClientInterface, error)) (*Services, error) {
+	var tenants []config.Tenant
+	if cfg.TenantsFile != "" {
+		var err error
+		if tenants, err = config.LoadTenants(cfg.TenantsFile); err != nil {
+			return nil, err
+		}
+	}
+	tenantCfgs, err := config.ApplyTenants(cfg, tenants)
+	if err != nil {
+		return nil, err
+	}
+
+	httpClient, err := newHTTPClient(cfg, tenantCfgs...)
+	if err != nil {
+		return nil, err
+	}
+	llmClient, err := llmFor(cfg, httpClient)
+	if err != nil {
+		return nil, err
+	}
+	prService, err := newPRService(cfg, httpClient, llmClient)
+	if err != nil {
+		return nil, err
+	}
+	for i, tenant := range tenants {
+		tenantLLM, err := llmFor(tenantCfgs[i], httpClient)
+		if err != nil {
+			return nil, fmt.Errorf("tenant %s: %w", tenant.Name, err)
+		}
+		tenantService, err := newPRService(tenantCfgs[i], httpClient, tenantLLM)
+		if err != nil {
+			return nil, fmt.Errorf("tenant %s: %w", tenant.Name, err)
+		}
+		prService.addTenant(tenant, tenantService)
+	}
+
+	return &Services{
+		PRService: prService,
+	}, nil
+}
+
+// newHTTPClient creates the http.Client shared by all API clients. When a
+// replay mode is configured, its traffic is recorded to or replayed from
+// the fixture directory, with every configured credential, the tenants'
+// included, scrubbed.
+func newHTTPClient(cfg config.Config, tenantCfgs ...config.Config) (*http.Client, error) {
+	httpClient, err := clients.NewHTTPClient(60*time.Second, cfg.CABundle)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create http client: %w", err)
+	}
+	if cfg.HTTPReplayMode == "" {
+		return httpClient, nil
+	}
+	var secrets []string
+	for _, c := range append([]config.Config{cfg}, tenantCfgs...) {
+		secrets = append(secrets, c.GithubToken, c.GitlabToken, c.BitbucketToken, c.GiteaToken, c.LLMServiceAPIKey, c.WebhookSecret)
+		for _, host := range c.GithubHostsByOwner() {
+			secrets = append(secrets, host.Token)
+		}
+	}
+	transport, err := replay.New(cfg.HTTPReplayMode, cfg.HTTPFixtureDir, nil, secrets...)
+	if err != nil {
+		return nil, err
+	}
+	transport.Wrap(httpClient)
+	return httpClient, nil
+}
 
+// newPRService wires the SCM clients, prompts, store and analyzers of cfg
+// around an LLM client.
+func newPRService(cfg config.Config, httpClient *http.Client, llmClient clients.OpenFGAClientInterface) (*PRService, error) {
+	githubClient := clients.NewGithubClient(httpClient, cfg.GithubToken, cfg.GithubBaseURL)
+	githubClient.BotLogin = cfg.BotLogin
+	gitlabClient := clients.NewGitlabClient(httpClient, cfg.GitlabToken, cfg.GitlabBaseURL)
+	bitbucketClient := clients.NewBitbucketClient(httpClient, cfg.BitbucketToken, cfg.BitbucketBaseURL)
+	githubHosts := map[string]*clients.GithubClient{}
+	for owner, host := range cfg.GithubHostsByOwner() {
+		githubHosts[owner] = clients.NewGithubClient(httpClient, host.Token, host.BaseURL)
+		githubHosts[owner].BotLogin = cfg.BotLogin
+	}
+	promptSet, err := prompts.Load(cfg.PromptDir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load prompt templates: %w", err)
+	}
+	var experiments []prompts.Experiment
+	for name, spec := range cfg.PromptExperiments() {
+		experiment, err := prompts.ParseExperiment(name, spec)
+		if err != nil {
+			return nil, err
+		}
+		experiments = append(experiments, experiment)
+	}
+	if err := promptSet.Configure(cfg.StablePromptVersions(), experiments); err != nil {
+		return nil, err
+	}
+	reviewStore, err := store.Open(cfg.StoreFile)
+	if err != nil {
+		return nil, err
+	}
+	reviewAnalyzers, err := analyzers.Lookup(strings.Split(cfg.Analyzers, ","))
+	if err != nil {
+		return nil, err
+	}
+	var sandbox analyzers.Sandbox
+	if len(reviewAnalyzers) > 0 {
+		if sandbox, err = analysisSandbox(cfg); err != nil {
+			return nil, err
+		}
+	}
 	prService := &PRService{
 		githubClient: *githubClient,
-		llmClient:    *openFGAClient,
+		llmClient:    llmClient,
 		cfg:          cfg,
+		providers: map[string]clients.SCMProvider{
+			models.ProviderGitHub:    githubClient,
+			models.ProviderGitLab:    gitlabClient,
+			models.ProviderBitbucket: bitbucketClient,
+		},
+		githubHosts: githubHosts,
+		prompts:     promptSet,
+		store:       reviewStore,
+		analyzers:   reviewAnalyzers,
+		sandbox:     sandbox,
+	}
+	// Gitea is served only when configured; a token without a base url is
+	// an error rather than a token sent to the wrong host
+	if cfg.GiteaBaseURL != "" || cfg.GiteaToken != "" {
+		giteaClient, err := clients.NewGiteaClient(httpClient, cfg.GiteaToken, cfg.GiteaBaseURL)
+		if err != nil {
+			return nil, fmt.Errorf("invalid gitea configuration: %w", err)
+		}
+		prService.providers[models.ProviderGitea] = giteaClient
 	}
+	// the service-wide prompt choices must name loaded templates
+	if err := prService.validatePromptNames(repoconfig.Defaults(cfg)); err != nil {
+		return nil, err
+	}
+	return prService, nil
+}
 
-	return &Services{
-		PRService: prService,
+// analysisSandbox runs analyses with the analyze command of this executable,
+// isolated by cfg.AnalyzerSandbox: DefaultIsolation when empty, nothing when
+// "none" (for instances already running without network access).
+func analysisSandbox(cfg config.Config) (analyzers.Sandbox, error) {
+	executable, err := os.Executable()
+	if err != nil {
+		return analyzers.Sandbox{}, fmt.Errorf("failed to locate the analyze command: %w", err)
+	}
+	sandbox := analyzers.Sandbox{
+		Command: []string{executable, "analyze"},
+		Isolate: analyzers.DefaultIsolation,
+	}
+	switch cfg.AnalyzerSandbox {
+	case "":
+	case "none":
+		sandbox.Isolate = nil
+	default:
+		sandbox.Isolate = strings.Fields(cfg.AnalyzerSandbox)
 	}
+	return sandbox, nil
 }
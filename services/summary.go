@@ -0,0 +1,244 @@
+package services
+
+import (
+	"ai-api/codecontext"
+	"ai-api/models"
+	"context"
+	"fmt"
+	"strings"
+)
+
+// summaryMarker is hidden in the summary comment so later reviews of the
+// pull request edit it instead of posting another one.
+const summaryMarker = "<!-- pr-checker:summary -->"
+
+// summaryTokenBudget bounds the patches sent to the model for the summary.
+const summaryTokenBudget = 12000
+
+// summaryFormat is the layout the model is asked to answer in.
+const summaryFormat = `Reply in exactly this layout and nothing else:
+Description: <two or three sentences on what the pull request does and why>
+Risk: <low, medium or high> - <one sentence on what could break>
+Files:
+<path>: <one line summary of the change to the file>`
+
+// prSummary is the model's overview of a pull request.
+type prSummary struct {
+	Description string
+	Risk        string
+	RiskReason  string
+	// Files maps changed files to their one line summary.
+	Files map[string]string
+}
+
+// summarize asks the model for an overview of the pull request and renders
+// the summary comment: description, a table of changed files, the findings
+// by severity and a risk assessment.
+func (s *PRService) summarize(ctx context.Context, scope ReviewScope, changeFiles *models.ChangeFiles, reviews []models.GeneratePRCommentParams) (string, error) {
+	reply, err := s.llmClient.Complete(ctx, summaryPrompt(scope, changeFiles, reviews), scope.Config.Model)
+	if err != nil {
+		return "", fmt.Errorf("failed to generate summary: %w", err)
+	}
+	var filenames []string
+	for _, file := range changeFiles.Files {
+		filenames = append(filenames, file.Filename)
+	}
+	return renderSummary(parseSummary(reply, filenames), changeFiles, reviews), nil
+}
+
+// RegenerateSummary rewrites the summary comment of a pull request from its
+// current changes and the findings of its latest recorded review, without
+// reviewing it again.
+func (s *PRService) RegenerateSummary(ctx context.Context, prRequestBody models.PullRequestRequest) error {
+	s = s.forOwner(prRequestBody.OwnerID)
+	provider, err := s.provider(prRequestBody)
+	if err != nil {
+		return err
+	}
+	pr := s.fetchPullRequest(ctx, prRequestBody, provider)
+	repoCfg, _ := s.loadRepoConfig(ctx, prRequestBody, provider, pr)
+	changeFiles, err := s.GetPRChangeFiles(ctx, prRequestBody, repoCfg)
+	if err != nil {
+		return fmt.Errorf("error fetching pr changes: %w", err)
+	}
+	scope := ReviewScope{
+		RepoOwner:   prRequestBody.OwnerID,
+		RepoName:    prRequestBody.RepoID,
+		PRNumber:    prRequestBody.ID,
+		PullRequest: pr,
+		Config:      repoCfg,
+	}
+	summary, err := s.summarize(ctx, scope, changeFiles, s.recordedFindings(prRequestBody))
+	if err != nil {
+		return err
+	}
+	return provider.UpsertSummary(ctx, prRequestBody, summaryMarker, summary)
+}
+
+// recordedFindings returns the findings of the latest review posted on a
+// pull request. Only their location, severity and category are recorded.
+func (s *PRService) recordedFindings(prRequestBody models.PullRequestRequest) []models.GeneratePRCommentParams {
+	provider := s.providerName(prRequestBody)
+	reviews := s.store.Reviews()
+	for i := len(reviews) - 1; i >= 0; i-- {
+		review := reviews[i]
+		if review.DryRun || review.Provider != provider || review.Owner != prRequestBody.OwnerID ||
+			review.Repo != prRequestBody.RepoID || review.PRNumber != prRequestBody.ID {
+			continue
+		}
+		findings := []models.GeneratePRCommentParams{}
+		for _, comment := range review.Comments {
+			findings = append(findings, models.GeneratePRCommentParams{
+				FileName:    comment.File,
+				Line:        comment.Line,
+				Severity:    comment.Severity,
+				Category:    comment.Category,
+				Rule:        comment.Rule,
+				CommentBody: comment.Category,
+			})
+		}
+		return findings
+	}
+	return nil
+}
+
+// summaryPrompt describes the pull request, its patches (as many as fit in
+// summaryTokenBudget) and the review's findings.
+func summaryPrompt(scope ReviewScope, changeFiles *models.ChangeFiles, reviews []models.GeneratePRCommentParams) string {
+	var b strings.Builder
+	b.WriteString("Summarize this pull request for its reviewers.\n\n")
+	if pr := scope.PullRequest; pr != nil {
+		fmt.Fprintf(&b, "Title: %s\n", pr.Title)
+		if pr.Body != "" {
+			fmt.Fprintf(&b, "Description:\n%s\n", pr.Body)
+		}
+		b.WriteString("\n")
+	}
+
+	left := summaryTokenBudget
+	b.WriteString("Changed files:\n")
+	for _, file := range changeFiles.Files {
+		entry := fmt.Sprintf("\n### %s (%s, +%d -%d)\n%s\n", file.Filename, file.Status, file.Additions, file.Deletions, file.Patch)
+		if cost := codecontext.EstimateTokens(entry); cost <= left {
+			left -= cost
+			b.WriteString(entry)
+			continue
+		}
+		fmt.Fprintf(&b, "\n### %s (%s, +%d -%d, patch omitted)\n", file.Filename, file.Status, file.Additions, file.Deletions)
+	}
+
+	if len(reviews) > 0 {
+		b.WriteString("\nFindings of the review:\n")
+		for _, review := range reviews {
+			fmt.Fprintf(&b, "- %s:%d [%s] %s\n", review.FileName, review.Line, review.Severity, review.Headline())
+		}
+	}
+	b.WriteString("\n" + summaryFormat)
+	return b.String()
+}
+
+// parseSummary reads a reply laid out as summaryFormat. A reply ignoring the
+// layout becomes the description.
+func parseSummary(reply string, filenames []string) prSummary {
+	summary := prSummary{Files: map[string]string{}}
+	known := map[string]bool{}
+	for _, name := range filenames {
+		known[name] = true
+	}
+
+	section := ""
+	var description []string
+	for _, line := range strings.Split(strings.TrimSpace(reply), "\n") {
+		trimmed := strings.TrimSpace(strings.ReplaceAll(line, "*", ""))
+		key, value, _ := strings.Cut(trimmed, ":")
+		switch strings.ToLower(key) {
+		case "description":
+			section = "description"
+			description = append(description, strings.TrimSpace(value))
+			continue
+		case "risk":
+			section = "risk"
+			level, reason, _ := strings.Cut(strings.TrimSpace(value), " - ")
+			summary.Risk = strings.ToLower(strings.TrimSpace(level))
+			summary.RiskReason = strings.TrimSpace(reason)
+			continue
+		case "files":
+			section = "files"
+			continue
+		}
+
+		switch section {
+		case "description":
+			description = append(description, trimmed)
+		case "files":
+			path, text, ok := strings.Cut(strings.TrimLeft(trimmed, "-` "), ":")
+			path = strings.Trim(path, "` ")
+			if ok && known[path] {
+				summary.Files[path] = strings.TrimSpace(text)
+			}
+		}
+	}
+	summary.Description = strings.TrimSpace(strings.Join(description, "\n"))
+	if summary.Description == "" && summary.Risk == "" && len(summary.Files) == 0 {
+		summary.Description = strings.TrimSpace(reply)
+	}
+	return summary
+}
+
+// renderSummary builds the markdown of the summary comment.
+func renderSummary(summary prSummary, changeFiles *models.ChangeFiles, reviews []models.GeneratePRCommentParams) string {
+	var b strings.Builder
+	b.WriteString(summaryMarker + "\n## Review summary\n\n")
+	if summary.Description != "" {
+		b.WriteString(summary.Description + "\n\n")
+	}
+
+	b.WriteString("| File | Change |\n| --- | --- |\n")
+	for _, file := range changeFiles.Files {
+		fmt.Fprintf(&b, "| `%s` | %s |\n", file.Filename, tableCell(summary.Files[file.Filename]))
+	}
+	for _, skipped := range changeFiles.Skipped {
+		fmt.Fprintf(&b, "| `%s` | not reviewed: %s |\n", skipped.Filename, tableCell(skipped.Reason))
+	}
+
+	counts := map[models.Severity]int{}
+	for _, review := range reviews {
+		counts[review.Severity]++
+	}
+	var parts []string
+	for _, severity := range models.Severities {
+		if counts[severity] > 0 {
+			parts = append(parts, fmt.Sprintf("%d %s", counts[severity], severity))
+		}
+	}
+	if len(parts) == 0 {
+		b.WriteString("\n**Findings:** none\n")
+	} else {
+		fmt.Fprintf(&b, "\n**Findings:** %s\n", strings.Join(parts, ", "))
+	}
+
+	if summary.Risk != "" {
+		fmt.Fprintf(&b, "\n**Risk:** %s", summary.Risk)
+		if summary.RiskReason != "" {
+			b.WriteString(" — " + summary.RiskReason)
+		}
+		b.WriteString("\n")
+	}
+	if sha := headCommitSHA(changeFiles); sha != "" {
+		fmt.Fprintf(&b, "\n<sub>Reviewed at %s.</sub>\n", shortSHA(sha))
+	}
+	return b.String()
+}
+
+// tableCell makes text safe for a markdown table cell.
+func tableCell(text string) string {
+	text = strings.ReplaceAll(text, "|", "\\|")
+	return strings.Join(strings.Fields(text), " ")
+}
+
+func shortSHA(sha string) string {
+	if len(sha) > 7 {
+		return sha[:7]
+	}
+	return sha
+}
@@ -0,0 +1,146 @@
+package services
+
+import (
+	"ai-api/config"
+	"ai-api/models"
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ErrBudgetExceeded is returned by AnalyzePR when the tenant has used up
+// its reviews for the day.
+var ErrBudgetExceeded = errors.New("daily review budget exceeded")
+
+// addTenant registers the service of a tenant, resolved by owner and
+// installation from then on.
+func (s *PRService) addTenant(tenant config.Tenant, service *PRService) {
+	service.tenant = tenant.Name
+	if s.tenantOwners == nil {
+		s.tenantOwners = map[string]*PRService{}
+		s.installations = map[int64]*PRService{}
+	}
+	s.tenants = append(s.tenants, service)
+	for _, owner := range tenant.Owners {
+		s.tenantOwners[strings.ToLower(owner)] = service
+	}
+	for _, id := range tenant.Installations {
+		s.installations[id] = service
+	}
+}
+
+// forOwner returns the service of the tenant owner belongs to, or s when
+// it belongs to none. GitLab project paths, URL-encoded or not, resolve by
+// their top group.
+func (s *PRService) forOwner(owner string) *PRService {
+	if unescaped, err := url.PathUnescape(owner); err == nil {
+		owner = unescaped
+	}
+	owner, _, _ = strings.Cut(owner, "/")
+	if tenant, ok := s.tenantOwners[strings.ToLower(owner)]; ok {
+		return tenant
+	}
+	return s
+}
+
+// forEvent returns the service of the tenant a webhook belongs to, by its
+// GitHub App installation and else by the repository owner.
+func (s *PRService) forEvent(installation *models.Installation, owner string) *PRService {
+	if installation != nil {
+		if tenant, ok := s.installations[installation.ID]; ok {
+			return tenant
+		}
+	}
+	return s.forOwner(owner)
+}
+
+// Tenant returns the service of the named tenant, or s for an empty name.
+func (s *PRService) Tenant(name string) (*PRService, bool) {
+	if name == "" {
+		return s, true
+	}
+	for _, tenant := range s.tenants {
+		if tenant.tenant == name {
+			return tenant, true
+		}
+	}
+	return nil, false
+}
+
+// reserveBudget reserves one of the MaxReviewsPerDay reviews of the UTC
+// day, or returns ErrBudgetExceeded when the recorded reviews, those still
+// running and those that failed after calling the model use them all.
+// Completed reviews count, dry-runs included as they run the model all the
+// same. The reservation must be released once the review is recorded or
+// failed; a review failing before it called the model does not count, so a
+// provider outage does not use up the budget, but one failing after does,
+// or a tenant whose comments keep failing to post could call the model
+// without limit.
+func (s *PRService) reserveBudget(now time.Time) (*budgetReservation, error) {
+	if s.cfg.MaxReviewsPerDay <= 0 {
+		return nil, nil
+	}
+	s.budgetMu.Lock()
+	defer s.budgetMu.Unlock()
+	midnight := now.UTC().Truncate(24 * time.Hour)
+	count := s.budgetReserved
+	for _, review := range s.store.Reviews() {
+		if !review.CreatedAt.Before(midnight) {
+			count++
+		}
+	}
+	failed := s.budgetFailed[:0]
+	for _, at := range s.budgetFailed {
+		if !at.Before(midnight) {
+			failed = append(failed, at)
+		}
+	}
+	s.budgetFailed = failed
+	count += len(failed)
+	if count >= s.cfg.MaxReviewsPerDay {
+		name := s.tenant
+		if name == "" {
+			name = "instance"
+		}
+		return nil, fmt.Errorf("%w: %s ran %d of %d reviews today", ErrBudgetExceeded, name, count, s.cfg.MaxReviewsPerDay)
+	}
+	s.budgetReserved++
+	return &budgetReservation{s: s, at: now}, nil
+}
+
+// budgetReservation is a review reserved in the MaxReviewsPerDay budget; nil
+// when the budget is unlimited.
+type budgetReservation struct {
+	s  *PRService
+	at time.Time
+	// spent is set once the review calls the model
+	spent bool
+	once  sync.Once
+}
+
+// spend marks the review as calling the model: from then on it counts
+// against the budget even if it fails.
+func (r *budgetReservation) spend() {
+	if r != nil {
+		r.spent = true
+	}
+}
+
+// release ends the reservation; recorded says whether the review is in the
+// store, where the budget counts it from then on. Later calls do nothing.
+func (r *budgetReservation) release(recorded bool) {
+	if r == nil {
+		return
+	}
+	r.once.Do(func() {
+		r.s.budgetMu.Lock()
+		defer r.s.budgetMu.Unlock()
+		r.s.budgetReserved--
+		if r.spent && !recorded {
+			r.s.budgetFailed = append(r.s.budgetFailed, r.at)
+		}
+	})
+}
@@ -0,0 +1,175 @@
+package services
+
+import (
+	"ai-api/config"
+	"ai-api/fakegithub"
+	"ai-api/models"
+	"ai-api/store"
+	"context"
+	"errors"
+	"net/http"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestForOwner(t *testing.T) {
+	instance := &PRService{}
+	acme := &PRService{}
+	instance.addTenant(config.Tenant{Name: "acme", Owners: []string{"Acme"}}, acme)
+	tests := []struct {
+		owner string
+		want  *PRService
+	}{
+		{owner: "acme", want: acme},
+		{owner: "ACME", want: acme},
+		{owner: "acme/platform/api", want: acme},
+		{owner: "acme%2Fplatform", want: acme},
+		{owner: "globex", want: instance},
+		{owner: "", want: instance},
+	}
+	for _, tt := range tests {
+		if got := instance.forOwner(tt.owner); got != tt.want {
+			t.Errorf("forOwner(%q) = tenant %q, want %q", tt.owner, got.tenant, tt.want.tenant)
+		}
+	}
+}
+
+func TestReserveBudget(t *testing.T) {
+	now := time.Date(2026, 10, 18, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name     string
+		max      int
+		recorded []time.Time
+		running  int
+		// failed reviews, after calling the model or before
+		failed      int
+		failedEarly int
+		wantErr     bool
+	}{
+		{name: "unlimited", max: 0, recorded: []time.Time{now, now}},
+		{name: "below budget", max: 2, recorded: []time.Time{now}},
+		{name: "recorded today", max: 2, recorded: []time.Time{now, now.Add(-time.Hour)}, wantErr: true},
+		{name: "recorded yesterday", max: 2, recorded: []time.Time{now.Add(-13 * time.Hour), now.Add(-24 * time.Hour)}},
+		{name: "running", max: 2, recorded: []time.Time{now}, running: 1, wantErr: true},
+		{name: "failed after calling the model", max: 2, recorded: []time.Time{now}, failed: 1, wantErr: true},
+		{name: "failed before calling the model", max: 2, recorded: []time.Time{now}, failedEarly: 3},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := budgetTestService(t, tt.max)
+			for _, createdAt := range tt.recorded {
+				if _, err := s.store.AddReview(store.Review{CreatedAt: createdAt}); err != nil {
+					t.Fatal(err)
+				}
+			}
+			for i := 0; i < tt.running+tt.failed+tt.failedEarly; i++ {
+				budget, err := s.reserveBudget(now)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if i >= tt.running {
+					if i < tt.running+tt.failed {
+						budget.spend()
+					}
+					budget.release(false)
+				}
+			}
+			budget, err := s.reserveBudget(now)
+			if tt.wantErr {
+				if !errors.Is(err, ErrBudgetExceeded) {
+					t.Fatalf("reserveBudget() error = %v, want ErrBudgetExceeded", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("reserveBudget() error = %v", err)
+			}
+			budget.release(true)
+		})
+	}
+}
+
+// TestReserveBudgetConcurrent checks that reviews started together cannot
+// all pass the budget, and that reviews failing before calling the model
+// give their reservation back.
+func TestReserveBudgetConcurrent(t *testing.T) {
+	s := budgetTestService(t, 3)
+	now := time.Now()
+	var (
+		wg      sync.WaitGroup
+		mu      sync.Mutex
+		budgets []*budgetReservation
+	)
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if budget, err := s.reserveBudget(now); err == nil {
+				mu.Lock()
+				budgets = append(budgets, budget)
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+	if len(budgets) != 3 {
+		t.Fatalf("%d reviews reserved the budget, want 3", len(budgets))
+	}
+
+	// one review completes and is recorded, the others fail
+	if _, err := s.store.AddReview(store.Review{DryRun: true}); err != nil {
+		t.Fatal(err)
+	}
+	for i, budget := range budgets {
+		budget.release(i == 0)
+		budget.release(i == 0)
+	}
+	for i := 0; i < 2; i++ {
+		if _, err := s.reserveBudget(now); err != nil {
+			t.Fatalf("reservation %d after failed reviews: %v", i, err)
+		}
+	}
+	if _, err := s.reserveBudget(now); !errors.Is(err, ErrBudgetExceeded) {
+		t.Errorf("reserveBudget() error = %v, want ErrBudgetExceeded", err)
+	}
+}
+
+// TestAnalyzePRBudgetFailedPosting checks that a review whose comments fail
+// to post still counts against the budget, having called the model.
+func TestAnalyzePRBudgetFailedPosting(t *testing.T) {
+	gh := fakegithub.New()
+	defer gh.Close()
+	gh.AddPullRequest("acme", "api", models.PullRequest{}, models.ChangeFile{
+		Filename: "store/cache.go",
+		Patch:    "@@ -1,1 +1,4 @@\n package store\n+\n+func Save(f *os.File) {\n+\tf.Close()\n+}",
+	})
+	gh.Fail("POST", "/repos/acme/api/pulls/1/comments", 2, fakegithub.InvalidPosition())
+	cfg := config.Config{GithubToken: gh.Token, GithubBaseURL: gh.URL, BotLogin: gh.Login, SummaryDisabled: true, MaxReviewsPerDay: 1}
+	llm := &stubLLM{reply: "Line: 4\nSeverity: high\nCategory: bug\nThe error of Close is dropped."}
+	s, err := newPRService(cfg, http.DefaultClient, llm)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req := models.PullRequestRequest{OwnerID: "acme", RepoID: "api", ID: "1"}
+
+	if _, err := s.AnalyzePR(context.Background(), req, AnalyzeOptions{}); err == nil || errors.Is(err, ErrBudgetExceeded) {
+		t.Fatalf("AnalyzePR() error = %v, want a posting error", err)
+	}
+	calls := llm.calls
+	if _, err := s.AnalyzePR(context.Background(), req, AnalyzeOptions{}); !errors.Is(err, ErrBudgetExceeded) {
+		t.Fatalf("second AnalyzePR() error = %v, want ErrBudgetExceeded", err)
+	}
+	if llm.calls != calls {
+		t.Errorf("model called %d times after the budget was used, want 0", llm.calls-calls)
+	}
+}
+
+func budgetTestService(t *testing.T, maxReviews int) *PRService {
+	t.Helper()
+	reviewStore, err := store.Open("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &PRService{cfg: config.Config{MaxReviewsPerDay: maxReviews}, store: reviewStore}
+}
@@ -0,0 +1,268 @@
+// File: store/store.go
+// Records the reviews the service ran — which prompt versions were used,
+// what was found and which comments were posted — so prompt versions can be
+// compared, and the findings developers asked to suppress. Records are kept
+// in memory and, when a file is configured, written to it as JSON after
+// every change.
+package store
+
+import (
+	"ai-api/models"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Review is one review of a pull request.
+type Review struct {
+	ID        string    `json:"id"`
+	Provider  string    `json:"provider"`
+	Owner     string    `json:"owner"`
+	Repo      string    `json:"repo"`
+	PRNumber  string    `json:"pr_number"`
+	CreatedAt time.Time `json:"created_at"`
+	DryRun    bool      `json:"dry_run"`
+	// Model is the LLM model the review ran with.
+	Model string `json:"model,omitempty"`
+	// Files counts the files reviewed with each prompt version ("name@version").
+	Files    map[string]int `json:"files"`
+	Comments []Comment      `json:"comments"`
+	// Closed tells the pull request was closed when its feedback was last
+	// fetched.
+	Closed bool `json:"closed,omitempty"`
+}
+
+// Comment is a finding of a review and, once posted, the comment holding it.
+type Comment struct {
+	// Prompt is the prompt version ("name@version") that produced the finding.
+	Prompt   string          `json:"prompt"`
+	File     string          `json:"file"`
+	Line     int             `json:"line"`
+	Severity models.Severity `json:"severity"`
+	Category string          `json:"category,omitempty"`
+	Rule     string          `json:"rule,omitempty"`
+	// CommentID is the provider's id of the posted comment, empty when the
+	// finding was not posted.
+	CommentID string `json:"comment_id,omitempty"`
+	// Reactions counts the reactions on the posted comment by content
+	// ("+1", "-1", "heart", ...) and Resolved tells whether its thread was
+	// resolved, as last fetched.
+	Reactions map[string]int `json:"reactions,omitempty"`
+	Resolved  bool           `json:"resolved,omitempty"`
+}
+
+// Suppression is a finding a developer asked not to be reported again on a
+// pull request. Findings are matched by file, rule and the code of the line
+// they are on, since line numbers move as the pull request changes.
+type Suppression struct {
+	Provider string `json:"provider"`
+	Owner    string `json:"owner"`
+	Repo     string `json:"repo"`
+	PRNumber string `json:"pr_number"`
+	File     string `json:"file"`
+	// Rule is empty when the rule of the suppressed finding is not known;
+	// findings of any rule on the line are suppressed then.
+	Rule      string    `json:"rule,omitempty"`
+	Code      string    `json:"code"`
+	By        string    `json:"by"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// Matches reports whether a finding of rule on a line holding code in file
+// is suppressed.
+func (s Suppression) Matches(file, rule, code string) bool {
+	return s.File == file && (s.Rule == "" || s.Rule == rule) && s.Code == strings.TrimSpace(code)
+}
+
+// Store holds the recorded reviews and suppressions.
+type Store struct {
+	mu           sync.Mutex
+	path         string
+	reviews      []Review
+	suppressions []Suppression
+}
+
+// contents is the layout of the store file.
+type contents struct {
+	Reviews      []Review      `json:"reviews"`
+	Suppressions []Suppression `json:"suppressions,omitempty"`
+}
+
+// Open loads the reviews recorded in path. An empty path keeps reviews in
+// memory only; a missing file starts an empty store.
+func Open(path string) (*Store, error) {
+	s := &Store{path: path}
+	if path == "" {
+		return s, nil
+	}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return s, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read store: %w", err)
+	}
+	// files written before suppressions were added hold only the reviews
+	if trimmed := bytes.TrimSpace(data); len(trimmed) > 0 && trimmed[0] == '[' {
+		if err := json.Unmarshal(data, &s.reviews); err != nil {
+			return nil, fmt.Errorf("failed to decode store %s: %w", path, err)
+		}
+		return s, nil
+	}
+	var stored contents
+	if err := json.Unmarshal(data, &stored); err != nil {
+		return nil, fmt.Errorf("failed to decode store %s: %w", path, err)
+	}
+	s.reviews, s.suppressions = stored.Reviews, stored.Suppressions
+	return s, nil
+}
+
+// AddReview records a review, assigning its id and creation time.
+func (s *Store) AddReview(review Review) (Review, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	review.ID = fmt.Sprintf("r%d", len(s.reviews)+1)
+	if review.CreatedAt.IsZero() {
+		review.CreatedAt = time.Now().UTC()
+	}
+	s.reviews = append(s.reviews, review)
+	return review, s.save()
+}
+
+// Reviews returns a copy of every recorded review, oldest first.
+func (s *Store) Reviews() []Review {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	reviews := make([]Review, len(s.reviews))
+	for i, review := range s.reviews {
+		review.Comments = append([]Comment{}, review.Comments...)
+		reviews[i] = review
+	}
+	return reviews
+}
+
+// Feedback is the feedback on a posted comment, as last fetched.
+type Feedback struct {
+	Reactions map[string]int
+	Resolved  bool
+}
+
+// SetFeedback replaces the reactions and resolution state of posted
+// comments of a review, keyed by comment id, writing the store once.
+func (s *Store) SetFeedback(reviewID string, feedback map[string]Feedback) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for i := range s.reviews {
+		if s.reviews[i].ID != reviewID {
+			continue
+		}
+		for j := range s.reviews[i].Comments {
+			comment := &s.reviews[i].Comments[j]
+			if f, ok := feedback[comment.CommentID]; ok && comment.CommentID != "" {
+				comment.Reactions = f.Reactions
+				comment.Resolved = f.Resolved
+			}
+		}
+		return s.save()
+	}
+	return fmt.Errorf("review %s not found", reviewID)
+}
+
+// MarkClosed records that a pull request was closed, so the feedback on its
+// reviews is no longer fetched once they are old.
+func (s *Store) MarkClosed(provider, owner, repo, prNumber string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for i := range s.reviews {
+		review := &s.reviews[i]
+		if review.Provider == provider && review.Owner == owner && review.Repo == repo && review.PRNumber == prNumber {
+			review.Closed = true
+		}
+	}
+	return s.save()
+}
+
+// FindComment returns the recorded comment posted with commentID on a
+// pull request of owner/repo, and the review it belongs to.
+func (s *Store) FindComment(provider, owner, repo, commentID string) (Review, Comment, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for i := len(s.reviews) - 1; i >= 0; i-- {
+		review := s.reviews[i]
+		if review.Provider != provider || review.Owner != owner || review.Repo != repo {
+			continue
+		}
+		for _, comment := range review.Comments {
+			if comment.CommentID == commentID {
+				return review, comment, true
+			}
+		}
+	}
+	return Review{}, Comment{}, false
+}
+
+// AddSuppression records a suppressed finding, setting its creation time.
+func (s *Store) AddSuppression(suppression Suppression) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	suppression.Code = strings.TrimSpace(suppression.Code)
+	if suppression.CreatedAt.IsZero() {
+		suppression.CreatedAt = time.Now().UTC()
+	}
+	s.suppressions = append(s.suppressions, suppression)
+	return s.save()
+}
+
+// Suppressions returns the suppressions of a pull request.
+func (s *Store) Suppressions(provider, owner, repo, prNumber string) []Suppression {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var suppressions []Suppression
+	for _, suppression := range s.suppressions {
+		if suppression.Provider == provider && suppression.Owner == owner && suppression.Repo == repo && suppression.PRNumber == prNumber {
+			suppressions = append(suppressions, suppression)
+		}
+	}
+	return suppressions
+}
+
+// save writes the reviews to the store file. The file is replaced
+// atomically so a crash never leaves it half written.
+func (s *Store) save() error {
+	if s.path == "" {
+		return nil
+	}
+	data, err := json.MarshalIndent(contents{Reviews: s.reviews, Suppressions: s.suppressions}, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode store: %w", err)
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(s.path), filepath.Base(s.path)+".*")
+	if err != nil {
+		return fmt.Errorf("failed to write store: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to write store: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to write store: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), s.path); err != nil {
+		return fmt.Errorf("failed to write store: %w", err)
+	}
+	return nil
+}
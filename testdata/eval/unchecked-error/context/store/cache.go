@@ -0,0 +1,21 @@
+package store
+
+import (
+	"encoding/json"
+	"os"
+	"sync"
+)
+
+type Cache struct {
+	mu    sync.Mutex
+	items map[string]string
+}
+
+func (c *Cache) Save(path string) {
+	data, _ := json.Marshal(c.items)
+	os.WriteFile(path, data, 0o644)
+}
+
+func (c *Cache) Put(key, value string) {
+	c.items[key] = value
+}